@@ -26,6 +26,19 @@ var (
 	BuildTime = "unknown"
 )
 
+// operationValidatorAdapter adapts media.Module to jobs.OperationValidator
+type operationValidatorAdapter struct {
+	module *media.Module
+}
+
+func (a *operationValidatorAdapter) ValidateOperations(ctx context.Context, operations []jobs.Operation, inputType, outputFormat string) []string {
+	ops := make([]media.Operation, len(operations))
+	for i, op := range operations {
+		ops[i] = media.Operation{Type: op.Type, Params: op.Params}
+	}
+	return a.module.ValidateOperations(ctx, ops, inputType, outputFormat).Errors
+}
+
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
@@ -86,7 +99,16 @@ func main() {
 	// Initialize modules
 	subscriptionSvc := subscription.NewService(db)
 	mediaModule := media.NewModule(db, storageService, jobQueue, logger)
+	mediaModule.SetCapabilityStore(redisClient) // Validates against the FFmpeg build the workers run
+	mediaModule.SetFFprobePath(cfg.FFprobePath)
 	jobsModule := jobs.NewModule(db, redisClient, storageService, jobQueue, wsHub, subscriptionSvc, logger)
+	jobsModule.SetMediaProber(mediaModule) // Probes legacy uploads that have no stored metadata
+	jobsModule.SetOperationValidator(&operationValidatorAdapter{module: mediaModule})
+
+	// Forward job events published by workers (and other replicas) to local WebSocket clients
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go jobsModule.RelayEvents(relayCtx)
 
 	// Create API server
 	server := api.NewServer(api.ServerConfig{
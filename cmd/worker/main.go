@@ -6,12 +6,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"strings"
 
 	"github.com/nextconvert/backend/internal/modules/jobs"
 	"github.com/nextconvert/backend/internal/modules/media"
 	"github.com/nextconvert/backend/internal/modules/subscription"
+	"github.com/nextconvert/backend/internal/modules/uploads"
 	"github.com/nextconvert/backend/internal/shared/config"
 	"github.com/nextconvert/backend/internal/shared/database"
 	"github.com/nextconvert/backend/internal/shared/logging"
@@ -30,7 +32,7 @@ type mediaProcessorAdapter struct {
 	processor *media.Processor
 }
 
-func (a *mediaProcessorAdapter) Process(ctx context.Context, opts jobs.MediaProcessOptions) error {
+func (a *mediaProcessorAdapter) Process(ctx context.Context, opts jobs.MediaProcessOptions) (*jobs.MediaProcessResult, error) {
 	// Convert jobs.Operation to media.Operation
 	operations := make([]media.Operation, len(opts.Operations))
 	for i, op := range opts.Operations {
@@ -40,24 +42,98 @@ func (a *mediaProcessorAdapter) Process(ctx context.Context, opts jobs.MediaProc
 		}
 	}
 
-	return a.processor.Process(ctx, media.ProcessOptions{
+	result, err := a.processor.Process(ctx, media.ProcessOptions{
+		JobID:            opts.JobID,
 		InputPath:        opts.InputPath,
 		OutputPath:       opts.OutputPath,
 		Operations:       operations,
-		OnProgress:       opts.OnProgress,
+		OnProgress:       adaptProgress(opts.OnProgress),
+		OnCommand:        adaptCommand(opts.OnCommand),
 		UseHardwareAccel: opts.UseHardwareAccel,
 	})
+	if err != nil || result == nil {
+		return nil, err
+	}
+	return adaptResult(result), nil
 }
 
 func (a *mediaProcessorAdapter) ProcessMerge(ctx context.Context, opts jobs.MergeProcessOptions) error {
 	return a.processor.ProcessMerge(ctx, media.MergeOptions{
+		JobID:            opts.JobID,
 		InputPaths:       opts.InputPaths,
 		OutputPath:       opts.OutputPath,
-		OnProgress:       opts.OnProgress,
+		Params:           opts.Params,
+		OnProgress:       adaptProgress(opts.OnProgress),
+		OnCommand:        adaptCommand(opts.OnCommand),
 		UseHardwareAccel: opts.UseHardwareAccel,
 	})
 }
 
+func (a *mediaProcessorAdapter) GenerateThumbnails(ctx context.Context, inputPath, dir string) (*jobs.MediaProcessResult, error) {
+	result, err := a.processor.GenerateThumbnails(ctx, inputPath, dir)
+	if err != nil {
+		return nil, err
+	}
+	return adaptResult(result), nil
+}
+
+func (a *mediaProcessorAdapter) GenerateWaveform(ctx context.Context, inputPath, dir string, opts jobs.WaveformOptions) (*jobs.MediaProcessResult, error) {
+	result, err := a.processor.GenerateWaveform(ctx, inputPath, dir, media.WaveformOptions{
+		SamplesPerPixel: opts.SamplesPerPixel,
+		Channels:        opts.Channels,
+		Bits:            opts.Bits,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return adaptResult(result), nil
+}
+
+// adaptResult converts a media.ProcessResult to a jobs.MediaProcessResult
+func adaptResult(result *media.ProcessResult) *jobs.MediaProcessResult {
+	artifacts := make([]jobs.OutputArtifact, len(result.Artifacts))
+	for i, a := range result.Artifacts {
+		artifacts[i] = jobs.OutputArtifact{Name: a.Name, Path: a.Path}
+	}
+	outputs := make([]jobs.OutputArtifact, len(result.Outputs))
+	for i, o := range result.Outputs {
+		outputs[i] = jobs.OutputArtifact{Name: o.Name, Path: o.Path}
+	}
+	return &jobs.MediaProcessResult{Dir: result.Dir, Entry: result.Entry, Artifacts: artifacts, Outputs: outputs}
+}
+
+// adaptCommand converts media.CommandLog callbacks to jobs.CommandRun callbacks
+func adaptCommand(onCommand func(jobs.CommandRun)) func(media.CommandLog) {
+	if onCommand == nil {
+		return nil
+	}
+	return func(l media.CommandLog) {
+		onCommand(jobs.CommandRun{
+			Operation: l.Operation,
+			Args:      l.Args,
+			Stderr:    l.Stderr,
+			Dropped:   l.Dropped,
+			Err:       l.Err,
+			Duration:  l.Duration,
+		})
+	}
+}
+
+// adaptProgress converts media.ProgressUpdate callbacks to jobs.Progress callbacks
+func adaptProgress(onProgress func(jobs.Progress)) func(media.ProgressUpdate) {
+	if onProgress == nil {
+		return nil
+	}
+	return func(u media.ProgressUpdate) {
+		onProgress(jobs.Progress{
+			Percent:          u.Percent,
+			CurrentOperation: u.Operation,
+			Speed:            u.Speed,
+			ETA:              u.ETA,
+		})
+	}
+}
+
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
@@ -114,23 +190,42 @@ func main() {
 	// Initialize subscription service (for recording conversion minutes on job complete)
 	subscriptionSvc := subscription.NewService(db)
 
-	// Initialize jobs module (without WebSocket hub - worker doesn't need it)
+	// Initialize jobs module (no WebSocket hub - job events are published over Redis
+	// and relayed to clients by the API servers)
 	jobsModule := jobs.NewModule(db, redisClient, storageService, queueClient, nil, subscriptionSvc, logger)
 
 	// Initialize media processor with CPU-friendly settings
 	mediaProcessor := media.NewProcessorWithConfig(storageService, media.ProcessorConfig{
 		FFmpegPath:        cfg.FFmpegPath,
+		FFprobePath:       cfg.FFprobePath,
 		MaxThreads:        cfg.FFmpegMaxThreads,    // Limit CPU threads (default: 2)
 		UseHardwareAccel:  cfg.FFmpegHardwareAccel, // Use VideoToolbox on macOS
 		PreferFastPresets: cfg.FFmpegFastPresets,   // Use veryfast preset
+		AV1Encoder:        cfg.FFmpegAV1Encoder,    // libsvtav1 or libaom-av1
 	}, logger)
 
 	logger.Info("Media processor initialized",
 		zap.Int("max_threads", cfg.FFmpegMaxThreads),
 		zap.Bool("hardware_accel", cfg.FFmpegHardwareAccel),
 		zap.Bool("fast_presets", cfg.FFmpegFastPresets),
+		zap.String("av1_encoder", cfg.FFmpegAV1Encoder),
 	)
 
+	// Ask FFmpeg what it was built with, and share it with the API servers so
+	// operations this build can't run are rejected at validation
+	discoverCtx, cancelDiscover := context.WithTimeout(context.Background(), 30*time.Second)
+	if caps := mediaProcessor.Capabilities(discoverCtx); caps != nil {
+		logger.Info("FFmpeg capabilities discovered",
+			zap.String("version", caps.Version),
+			zap.Int("encoders", len(caps.Encoders)),
+			zap.Int("filters", len(caps.Filters)),
+		)
+		if err := media.PublishCapabilities(discoverCtx, redisClient, caps); err != nil {
+			logger.Warn("Failed to publish FFmpeg capabilities", zap.Error(err))
+		}
+	}
+	cancelDiscover()
+
 	mediaAdapter := &mediaProcessorAdapter{processor: mediaProcessor}
 
 	// Create job handler
@@ -140,6 +235,7 @@ func main() {
 		Storage:        storageService,
 		MediaProcessor: mediaAdapter,
 		JobsModule:     jobsModule,
+		Uploads:        uploads.NewService(db, storageService, logger),
 		Logger:         logger,
 	})
 
@@ -176,9 +272,12 @@ func main() {
 	// Register task handlers
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(jobs.TypeMediaProcess, jobHandler.HandleMediaProcess)
+	mux.HandleFunc(jobs.TypeGenerateThumbnails, jobHandler.HandleGenerateThumbnails)
+	mux.HandleFunc(jobs.TypeGenerateWaveform, jobHandler.HandleGenerateWaveform)
 	mux.HandleFunc(jobs.TypeCleanupFiles, jobHandler.HandleCleanupFiles)
 	mux.HandleFunc(jobs.TypeCleanupStaleJobs, jobHandler.HandleCleanupStaleJobs)
 	mux.HandleFunc(jobs.TypeCleanupAnonProfiles, jobHandler.HandleCleanupAnonProfiles)
+	mux.HandleFunc(jobs.TypeCleanupUploads, jobHandler.HandleCleanupUploads)
 
 	// Start cleanup scheduler (hourly - deletes files past 24h expiry)
 	scheduler, err := queueClient.ScheduleCleanup(cfg.RedisURL)
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/nextconvert/backend/internal/api/middleware"
+	"github.com/nextconvert/backend/internal/shared/authz"
+	"go.uber.org/zap"
+)
+
+// authorize checks that the current user owns the resource. Otherwise it writes
+// a 404 (the same response as for a missing resource) and returns false.
+func authorize(w http.ResponseWriter, r *http.Request, authorizer *authz.Authorizer, resource authz.Resource, id string, logger *zap.Logger) bool {
+	userID := ""
+	if user := middleware.GetUser(r.Context()); user != nil {
+		userID = user.ID
+	}
+
+	err := authorizer.Authorize(r.Context(), resource, id, userID)
+	if errors.Is(err, authz.ErrNotFound) {
+		http.Error(w, string(resource)+" not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		logger.Error("Failed to check resource ownership",
+			zap.String("resource", string(resource)),
+			zap.String("id", id),
+			zap.Error(err),
+		)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/nextconvert/backend/internal/api/middleware"
+	"github.com/nextconvert/backend/internal/modules/jobs"
+	"github.com/nextconvert/backend/internal/shared/authz"
+	"github.com/nextconvert/backend/internal/shared/database"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	aliceID       = "user_alice"
+	aliceResource = "6f1c2a8e-0d1b-4c3e-9a7f-1b2c3d4e5f60"
+	missingID     = "9e8d7c6b-5a49-4382-b1a0-f9e8d7c6b5a4"
+)
+
+func newMockDB(t *testing.T) (*database.Postgres, pgxmock.PgxPoolIface) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+	return &database.Postgres{Pool: mock}, mock
+}
+
+// expectOwner answers the ownership lookup of a resource; a nil owner means the row doesn't exist
+func expectOwner(mock pgxmock.PgxPoolIface, table, id string, owner *string) {
+	query := mock.ExpectQuery(regexp.QuoteMeta("SELECT user_id FROM " + table + " WHERE id = $1")).WithArgs(id)
+	if owner == nil {
+		query.WillReturnError(pgx.ErrNoRows)
+		return
+	}
+	query.WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(owner))
+}
+
+// serveAs routes a request to handler as userID (no user when empty)
+func serveAs(handler http.HandlerFunc, method, pattern, path, body, userID string) *httptest.ResponseRecorder {
+	router := chi.NewRouter()
+	router.Method(method, pattern, handler)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if userID != "" {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, &middleware.User{ID: userID}))
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func owned(userID string) *string { return &userID }
+
+func TestHandlersHideOtherUsersResources(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   string
+		method  string
+		pattern string
+		path    func(id string) string
+		body    func(id string) string
+		handler func(db *database.Postgres) http.HandlerFunc
+		wantMsg string
+	}{
+		{
+			name:    "GetJob",
+			table:   "jobs",
+			method:  http.MethodGet,
+			pattern: "/jobs/{id}",
+			path:    func(id string) string { return "/jobs/" + id },
+			handler: func(db *database.Postgres) http.HandlerFunc {
+				return NewJobHandler(nil, authz.NewAuthorizer(db), zap.NewNop()).GetJob
+			},
+			wantMsg: "job not found",
+		},
+		{
+			name:    "RetryJob",
+			table:   "jobs",
+			method:  http.MethodPost,
+			pattern: "/jobs/{id}/retry",
+			path:    func(id string) string { return "/jobs/" + id + "/retry" },
+			handler: func(db *database.Postgres) http.HandlerFunc {
+				return NewJobHandler(nil, authz.NewAuthorizer(db), zap.NewNop()).RetryJob
+			},
+			wantMsg: "job not found",
+		},
+		{
+			name:    "DownloadFile",
+			table:   "files",
+			method:  http.MethodGet,
+			pattern: "/files/{id}/download",
+			path:    func(id string) string { return "/files/" + id + "/download" },
+			handler: func(db *database.Postgres) http.HandlerFunc {
+				return NewFileHandler(nil, db, nil, nil, nil, authz.NewAuthorizer(db), zap.NewNop()).DownloadFile
+			},
+			wantMsg: "file not found",
+		},
+		{
+			name:    "DeletePreset",
+			table:   "presets",
+			method:  http.MethodDelete,
+			pattern: "/presets/{id}",
+			path:    func(id string) string { return "/presets/" + id },
+			handler: func(db *database.Postgres) http.HandlerFunc {
+				return NewPresetsHandler(db, authz.NewAuthorizer(db), zap.NewNop()).DeletePreset
+			},
+			wantMsg: "preset not found",
+		},
+		{
+			name:    "CreateJob input file",
+			table:   "files",
+			method:  http.MethodPost,
+			pattern: "/jobs",
+			path:    func(string) string { return "/jobs" },
+			body: func(id string) string {
+				return `{"inputFileId":"` + id + `","outputFormat":"mp4","operations":[]}`
+			},
+			handler: func(db *database.Postgres) http.HandlerFunc {
+				module := jobs.NewModule(db, nil, nil, nil, nil, nil, zap.NewNop())
+				return NewJobHandler(module, authz.NewAuthorizer(db), zap.NewNop()).CreateJob
+			},
+			wantMsg: "input file not found",
+		},
+	}
+
+	for _, tt := range tests {
+		request := func(db *database.Postgres, id, userID string) *httptest.ResponseRecorder {
+			body := ""
+			if tt.body != nil {
+				body = tt.body(id)
+			}
+			return serveAs(tt.handler(db), tt.method, tt.pattern, tt.path(id), body, userID)
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+
+			// Another user's resource looks exactly like a missing one
+			expectOwner(mock, tt.table, aliceResource, owned(aliceID))
+			crossUser := request(db, aliceResource, "user_bob")
+			expectOwner(mock, tt.table, missingID, nil)
+			missing := request(db, missingID, "user_bob")
+
+			assert.Equal(t, http.StatusNotFound, crossUser.Code)
+			assert.Equal(t, tt.wantMsg+"\n", crossUser.Body.String())
+			assert.Equal(t, http.StatusNotFound, missing.Code)
+			assert.Equal(t, crossUser.Body.String(), missing.Body.String())
+
+			// Malformed IDs can't exist and aren't looked up
+			malformed := request(db, "not-a-uuid", "user_bob")
+			assert.Equal(t, http.StatusNotFound, malformed.Code)
+			assert.Equal(t, crossUser.Body.String(), malformed.Body.String())
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run(tt.name+" anonymous", func(t *testing.T) {
+			db, mock := newMockDB(t)
+			if tt.table == "presets" {
+				// Presets can only be changed when signed in
+				assert.Equal(t, http.StatusUnauthorized, request(db, aliceResource, "anon:device-1").Code)
+				return
+			}
+
+			// Anonymous users can't reach a signed-in user's resources either
+			expectOwner(mock, tt.table, aliceResource, owned(aliceID))
+			rec := request(db, aliceResource, "anon:device-1")
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Equal(t, tt.wantMsg+"\n", rec.Body.String())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestHandlersWithoutUser(t *testing.T) {
+	db, mock := newMockDB(t)
+	handler := NewJobHandler(nil, authz.NewAuthorizer(db), zap.NewNop())
+
+	expectOwner(mock, "jobs", aliceResource, owned(aliceID))
+	rec := serveAs(handler.GetJob, http.MethodGet, "/jobs/{id}", "/jobs/"+aliceResource, "", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "job not found\n", rec.Body.String())
+}
+
+func TestDeletePresetAsOwner(t *testing.T) {
+	db, mock := newMockDB(t)
+	handler := NewPresetsHandler(db, authz.NewAuthorizer(db), zap.NewNop())
+
+	expectOwner(mock, "presets", aliceResource, owned(aliceID))
+	mock.ExpectExec("DELETE FROM presets").WithArgs(aliceResource, aliceID).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	rec := serveAs(handler.DeletePreset, http.MethodDelete, "/presets/{id}", "/presets/"+aliceResource, "", aliceID)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,8 +13,12 @@ import (
 	"time"
 
 	"github.com/nextconvert/backend/internal/api/middleware"
+	"github.com/nextconvert/backend/internal/modules/jobs"
+	"github.com/nextconvert/backend/internal/modules/media"
 	"github.com/nextconvert/backend/internal/modules/subscription"
+	"github.com/nextconvert/backend/internal/modules/uploads"
 	"github.com/nextconvert/backend/internal/shared/database"
+	"github.com/nextconvert/backend/internal/shared/authz"
 	"github.com/nextconvert/backend/internal/shared/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -25,15 +30,21 @@ type FileHandler struct {
 	storage   *storage.Service
 	db        *database.Postgres
 	subSvc    *subscription.Service
+	uploads   *uploads.Service
+	media     *media.Module
+	authz     *authz.Authorizer
 	logger    *zap.Logger
 }
 
 // NewFileHandler creates a new file handler
-func NewFileHandler(storage *storage.Service, db *database.Postgres, subSvc *subscription.Service, logger *zap.Logger) *FileHandler {
+func NewFileHandler(storage *storage.Service, db *database.Postgres, subSvc *subscription.Service, uploadSvc *uploads.Service, mediaModule *media.Module, authorizer *authz.Authorizer, logger *zap.Logger) *FileHandler {
 	return &FileHandler{
 		storage: storage,
 		db:      db,
 		subSvc:  subSvc,
+		uploads: uploadSvc,
+		media:   mediaModule,
+		authz:   authorizer,
 		logger:  logger,
 	}
 }
@@ -47,23 +58,26 @@ type FileRecord struct {
 	MimeType     string    `json:"mimeType"`
 	SizeBytes    int64     `json:"sizeBytes"`
 	Zone         string    `json:"zone"`
-	MediaType    *string   `json:"mediaType,omitempty"`
-	ExpiresAt    time.Time `json:"expiresAt"`
-	CreatedAt    time.Time `json:"createdAt"`
+	MediaType    *string         `json:"mediaType,omitempty"`
+	Metadata     json.RawMessage `json:"metadata,omitempty"` // Probed media.MediaInfo
+	ExpiresAt    time.Time       `json:"expiresAt"`
+	CreatedAt    time.Time       `json:"createdAt"`
 }
 
 // UploadInitResponse represents the response for initiating an upload
 type UploadInitResponse struct {
-	UploadID    string `json:"uploadId"`
-	ChunkSize   int64  `json:"chunkSize"`
-	TotalChunks int    `json:"totalChunks"`
+	UploadID    string    `json:"uploadId"`
+	ChunkSize   int64     `json:"chunkSize"`
+	TotalChunks int       `json:"totalChunks"`
+	ExpiresAt   time.Time `json:"expiresAt"`
 }
 
-// InitiateUpload starts a new file upload
+// InitiateUpload starts a new resumable chunked upload session
 func (h *FileHandler) InitiateUpload(w http.ResponseWriter, r *http.Request) {
 	fileName := r.FormValue("filename")
 	fileSizeStr := r.FormValue("size")
 	mimeType := r.FormValue("mimeType")
+	checksum := r.FormValue("checksum") // Optional SHA-256 of the whole file
 
 	if fileName == "" || fileSizeStr == "" {
 		http.Error(w, "filename and size are required", http.StatusBadRequest)
@@ -71,7 +85,7 @@ func (h *FileHandler) InitiateUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	fileSize, err := strconv.ParseInt(fileSizeStr, 10, 64)
-	if err != nil {
+	if err != nil || fileSize <= 0 {
 		http.Error(w, "invalid file size", http.StatusBadRequest)
 		return
 	}
@@ -87,30 +101,39 @@ func (h *FileHandler) InitiateUpload(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// For files under 10MB, use simple upload
-	const chunkSize int64 = 5 * 1024 * 1024 // 5MB chunks
-	totalChunks := int((fileSize + chunkSize - 1) / chunkSize)
-
-	uploadID := uuid.New().String()
+	session, err := h.uploads.CreateSession(r.Context(), uploads.CreateParams{
+		UserID:   userID,
+		FileName: fileName,
+		MimeType: mimeType,
+		Size:     fileSize,
+		Checksum: checksum,
+	})
+	if err != nil {
+		h.writeUploadError(w, err)
+		return
+	}
 
 	h.logger.Info("Upload initiated",
-		zap.String("upload_id", uploadID),
+		zap.String("upload_id", session.ID),
 		zap.String("filename", fileName),
 		zap.Int64("size", fileSize),
 		zap.String("mime_type", mimeType),
+		zap.Int("total_chunks", session.TotalChunks),
 	)
 
 	response := UploadInitResponse{
-		UploadID:    uploadID,
-		ChunkSize:   chunkSize,
-		TotalChunks: totalChunks,
+		UploadID:    session.ID,
+		ChunkSize:   session.ChunkSize,
+		TotalChunks: session.TotalChunks,
+		ExpiresAt:   session.ExpiresAt,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// UploadChunk handles a chunk upload
+// UploadChunk stores one chunk of a resumable upload. Chunks may arrive in any
+// order and may be re-sent; an optional "checksum" field (SHA-256) is verified.
 func (h *FileHandler) UploadChunk(w http.ResponseWriter, r *http.Request) {
 	uploadID := r.FormValue("uploadId")
 	chunkIndexStr := r.FormValue("chunkIndex")
@@ -133,61 +156,149 @@ func (h *FileHandler) UploadChunk(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// Store chunk temporarily
-	chunkName := uploadID + "_chunk_" + strconv.Itoa(chunkIndex)
-	_, err = h.storage.Store(r.Context(), storage.ZoneWorking, chunkName, file)
+	user := middleware.GetUser(r.Context())
+	session, err := h.uploads.PutChunk(r.Context(), uploadID, user.ID, chunkIndex, r.FormValue("checksum"), file)
 	if err != nil {
-		h.logger.Error("Failed to store chunk", zap.Error(err))
-		http.Error(w, "failed to store chunk", http.StatusInternalServerError)
+		h.logger.Warn("Failed to store chunk",
+			zap.String("upload_id", uploadID),
+			zap.Int("chunk_index", chunkIndex),
+			zap.Error(err),
+		)
+		h.writeUploadError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"received":       true,
-		"uploadedChunks": chunkIndex + 1,
+		"chunkIndex":     chunkIndex,
+		"checksum":       session.ReceivedChunks[strconv.Itoa(chunkIndex)].Checksum,
+		"uploadedChunks": len(session.ReceivedIndexes()),
+		"totalChunks":    session.TotalChunks,
+	})
+}
+
+// UploadStatusResponse describes a resumable upload so clients can resume it
+type UploadStatusResponse struct {
+	*uploads.Session
+	ReceivedChunks []int `json:"receivedChunks"`
+	MissingChunks  []int `json:"missingChunks"`
+}
+
+// GetUploadStatus returns which chunks of an upload have been received
+func (h *FileHandler) GetUploadStatus(w http.ResponseWriter, r *http.Request) {
+	uploadID := chi.URLParam(r, "uploadId")
+
+	user := middleware.GetUser(r.Context())
+	session, err := h.uploads.GetSession(r.Context(), uploadID, user.ID)
+	if err != nil {
+		h.writeUploadError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UploadStatusResponse{
+		Session:        session,
+		ReceivedChunks: session.ReceivedIndexes(),
+		MissingChunks:  session.MissingChunks(),
 	})
 }
 
 // CompleteUploadRequest represents the request to complete an upload
 type CompleteUploadRequest struct {
-	UploadID    string `json:"uploadId"`
-	FileName    string `json:"fileName"`
-	TotalChunks int    `json:"totalChunks"`
-	MimeType    string `json:"mimeType"`
+	UploadID string `json:"uploadId"`
+	Checksum string `json:"checksum,omitempty"` // Optional SHA-256 of the whole file
 }
 
 // CompleteUploadResponse represents the response after completing an upload
 type CompleteUploadResponse struct {
-	FileID   string `json:"fileId"`
-	Verified bool   `json:"verified"`
+	FileID    string    `json:"fileId"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	MimeType  string    `json:"mimeType"`
+	Checksum  string           `json:"checksum"`
+	Verified  bool             `json:"verified"`
+	MediaInfo *media.MediaInfo `json:"mediaInfo,omitempty"`
+	CreatedAt time.Time        `json:"createdAt"`
 }
 
-// CompleteUpload finalizes a chunked upload
+// CompleteUpload assembles a chunked upload into a file and registers it
 func (h *FileHandler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
 	var req CompleteUploadRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
+	if req.UploadID == "" {
+		http.Error(w, "uploadId is required", http.StatusBadRequest)
+		return
+	}
 
-	fileID := uuid.New().String()
+	user := middleware.GetUser(r.Context())
+	result, err := h.uploads.Complete(r.Context(), req.UploadID, user.ID, req.Checksum)
+	if err != nil {
+		h.logger.Warn("Failed to complete upload", zap.String("upload_id", req.UploadID), zap.Error(err))
+		h.writeUploadError(w, err)
+		return
+	}
 
 	h.logger.Info("Upload completed",
-		zap.String("file_id", fileID),
+		zap.String("file_id", result.FileID),
 		zap.String("upload_id", req.UploadID),
-		zap.String("filename", req.FileName),
+		zap.String("filename", result.FileName),
+		zap.Int64("size", result.Size),
 	)
 
 	response := CompleteUploadResponse{
-		FileID:   fileID,
-		Verified: true,
+		FileID:    result.FileID,
+		Name:      result.FileName,
+		Size:      result.Size,
+		MimeType:  result.MimeType,
+		Checksum:  result.Checksum,
+		Verified:  result.Verified,
+		MediaInfo: h.probeUpload(r.Context(), result.FileID),
+		CreatedAt: result.CreatedAt,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// probeUpload probes a newly registered file so its metadata is stored server-side,
+// and queues thumbnail generation for videos.
+// Files that can't be probed are still accepted; jobs on them are rejected later.
+func (h *FileHandler) probeUpload(ctx context.Context, fileID string) *media.MediaInfo {
+	if h.media == nil {
+		return nil
+	}
+	info, err := h.media.Probe(ctx, fileID)
+	if err != nil {
+		h.logger.Warn("Failed to probe uploaded file", zap.String("file_id", fileID), zap.Error(err))
+		return nil
+	}
+	if err := h.media.QueueThumbnails(ctx, fileID, info); err != nil {
+		h.logger.Warn("Failed to queue thumbnail generation", zap.String("file_id", fileID), zap.Error(err))
+	}
+	return info
+}
+
+// writeUploadError maps upload service errors to HTTP responses
+func (h *FileHandler) writeUploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, uploads.ErrNotFound):
+		http.Error(w, "upload not found", http.StatusNotFound)
+	case errors.Is(err, uploads.ErrInvalidChunk), errors.Is(err, uploads.ErrInvalidChecksum):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, uploads.ErrConflict), errors.Is(err, uploads.ErrIncomplete):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, uploads.ErrChecksumMismatch), errors.Is(err, uploads.ErrSizeMismatch):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		h.logger.Error("Upload failed", zap.Error(err))
+		http.Error(w, "upload failed", http.StatusInternalServerError)
+	}
+}
+
 // GetFile returns file metadata
 func (h *FileHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 	fileID := chi.URLParam(r, "id")
@@ -195,6 +306,9 @@ func (h *FileHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "file id required", http.StatusBadRequest)
 		return
 	}
+	if !authorize(w, r, h.authz, authz.ResourceFile, fileID, h.logger) {
+		return
+	}
 
 	// Query file from database
 	file, err := h.getFileFromDB(r.Context(), fileID)
@@ -208,13 +322,18 @@ func (h *FileHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(file)
 }
 
-// DownloadFile streams a file download with Range request support for video streaming
+// DownloadFile streams a file download with Range request support for video streaming.
+// Files of a packaged output (e.g. an HLS package) are served inline from
+// /{id}/download/{name}, so players can resolve manifest URIs relative to the entry.
 func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	fileID := chi.URLParam(r, "id")
 	if fileID == "" {
 		http.Error(w, "file id required", http.StatusBadRequest)
 		return
 	}
+	if !authorize(w, r, h.authz, authz.ResourceFile, fileID, h.logger) {
+		return
+	}
 
 	// Get file metadata from database
 	file, err := h.getFileFromDB(r.Context(), fileID)
@@ -224,19 +343,33 @@ func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	storagePath, mimeType, fileSize := file.StoragePath, file.MimeType, file.SizeBytes
+	attachment := true
+	if name := chi.URLParam(r, "*"); name != "" {
+		artifact, err := h.getArtifactFromDB(r.Context(), fileID, name)
+		if err != nil {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		storagePath, mimeType, fileSize = artifact.StoragePath, artifact.MimeType, artifact.SizeBytes
+		attachment = false
+	} else if entry, err := h.getPackageEntry(r.Context(), file); err == nil {
+		// A package is downloaded through its entry so relative URIs keep resolving
+		http.Redirect(w, r, strings.TrimSuffix(r.URL.Path, "/")+"/"+entry, http.StatusFound)
+		return
+	}
+
 	// Retrieve file from storage (works for both local and remote/S3)
-	reader, err := h.storage.Retrieve(r.Context(), file.StoragePath)
+	reader, err := h.storage.Retrieve(r.Context(), storagePath)
 	if err != nil {
-		h.logger.Error("Failed to retrieve file from storage", zap.Error(err), zap.String("path", file.StoragePath))
+		h.logger.Error("Failed to retrieve file from storage", zap.Error(err), zap.String("path", storagePath))
 		http.Error(w, "file not found in storage", http.StatusNotFound)
 		return
 	}
 	defer reader.Close()
 
-	fileSize := file.SizeBytes
-
 	// Set common headers
-	w.Header().Set("Content-Type", file.MimeType)
+	w.Header().Set("Content-Type", mimeType)
 	w.Header().Set("Accept-Ranges", "bytes")
 
 	// For local storage, support Range requests for video streaming
@@ -266,7 +399,9 @@ func (h *FileHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Stream entire file
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+file.OriginalName+"\"")
+	if attachment {
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+file.OriginalName+"\"")
+	}
 	if fileSize > 0 {
 		w.Header().Set("Content-Length", strconv.FormatInt(fileSize, 10))
 	}
@@ -336,16 +471,185 @@ func parseRangeHeader(rangeHeader string, fileSize int64) (int64, int64, error)
 	return start, end, nil
 }
 
-// GetThumbnail returns a thumbnail for media files
+// GetThumbnail serves the previews generated for an uploaded video: a poster
+// frame from /{id}/thumbnail?size=small|medium|large, and the preview sprite and
+// its WebVTT storyboard from /{id}/thumbnail/sprite.jpg and /{id}/thumbnail/storyboard.vtt
 func (h *FileHandler) GetThumbnail(w http.ResponseWriter, r *http.Request) {
 	fileID := chi.URLParam(r, "id")
 	if fileID == "" {
 		http.Error(w, "file id required", http.StatusBadRequest)
 		return
 	}
+	if !authorize(w, r, h.authz, authz.ResourceFile, fileID, h.logger) {
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if name == "" {
+		size := r.URL.Query().Get("size")
+		if size == "" {
+			size = media.DefaultThumbnailSize
+		}
+		if !isThumbnailSize(size) {
+			http.Error(w, "size must be small, medium or large", http.StatusBadRequest)
+			return
+		}
+		name = media.PosterName(size)
+	}
+
+	thumb, modified, err := h.getPreviewFromDB(r.Context(), fileID, jobs.ThumbnailArtifactPrefix+name)
+	if err != nil {
+		// Previews are generated in the background after upload, and only for videos
+		http.Error(w, "thumbnail not available", http.StatusNotFound)
+		return
+	}
+	h.servePreview(w, r, thumb, modified)
+}
+
+// GetWaveform serves min/max peaks of a file's audio for drawing its waveform:
+// /{id}/waveform?samplesPerPixel=256&channels=mono|split&bits=8|16&format=json|dat.
+// The peaks are computed once per resolution by a worker; until they are ready
+// the request is answered with 202 Accepted and should be repeated. If the
+// worker gave up, the next request gets 422 and the one after starts over.
+func (h *FileHandler) GetWaveform(w http.ResponseWriter, r *http.Request) {
+	fileID := chi.URLParam(r, "id")
+	if fileID == "" {
+		http.Error(w, "file id required", http.StatusBadRequest)
+		return
+	}
+	if !authorize(w, r, h.authz, authz.ResourceFile, fileID, h.logger) {
+		return
+	}
+
+	opts, format, err := parseWaveformQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := media.WaveformJSONName
+	if format == "dat" {
+		name = media.WaveformDatName
+	}
+
+	peaks, modified, err := h.getPreviewFromDB(r.Context(), fileID, jobs.WaveformArtifactPrefix+opts.Variant()+"/"+name)
+	if err == nil {
+		h.servePreview(w, r, peaks, modified)
+		return
+	}
+
+	if h.media == nil {
+		http.Error(w, "waveforms not available", http.StatusServiceUnavailable)
+		return
+	}
+	file, err := h.getFileFromDB(r.Context(), fileID)
+	if err != nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	// Uploads are probed on arrival; older files are probed now
+	var info *media.MediaInfo
+	if len(file.Metadata) > 0 {
+		json.Unmarshal(file.Metadata, &info)
+	}
+	if info == nil {
+		info, _ = h.media.Probe(r.Context(), fileID)
+	}
+	if err := h.media.QueueWaveform(fileID, info, opts); err != nil {
+		if errors.Is(err, media.ErrInvalidOperation) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, jobs.ErrWaveformFailed) {
+			// Answering 202 again would have the client poll forever
+			http.Error(w, "waveform generation failed: the file's audio could not be read", http.StatusUnprocessableEntity)
+			return
+		}
+		h.logger.Error("Failed to queue waveform generation", zap.String("file_id", fileID), zap.Error(err))
+		http.Error(w, "failed to queue waveform", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "2")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "processing"})
+}
 
-	// For now, return not found - thumbnail generation to be implemented
-	http.Error(w, "thumbnail not available", http.StatusNotFound)
+// parseWaveformQuery reads the waveform options and output format, applying defaults
+func parseWaveformQuery(r *http.Request) (media.WaveformOptions, string, error) {
+	q := r.URL.Query()
+	opts := media.WaveformOptions{SamplesPerPixel: media.DefaultSamplesPerPixel, Channels: "mono", Bits: 8}
+	if v := q.Get("samplesPerPixel"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, "", errors.New("samplesPerPixel must be a number")
+		}
+		opts.SamplesPerPixel = n
+	}
+	if v := q.Get("channels"); v != "" {
+		opts.Channels = v
+	}
+	if v := q.Get("bits"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, "", errors.New("bits must be 8 or 16")
+		}
+		opts.Bits = n
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, "", err
+	}
+
+	format := q.Get("format")
+	switch format {
+	case "":
+		format = "json"
+	case "json", "dat":
+	default:
+		return opts, "", errors.New("format must be json or dat")
+	}
+	return opts, format, nil
+}
+
+// previewCacheControl lets browsers keep previews: they never change once
+// generated, and the upload they belong to expires within a day
+const previewCacheControl = "private, max-age=86400"
+
+// servePreview streams a generated preview with caching headers, answering
+// conditional requests for an unchanged preview with 304 Not Modified
+func (h *FileHandler) servePreview(w http.ResponseWriter, r *http.Request, a *ArtifactRecord, modified time.Time) {
+	etag := fmt.Sprintf(`"%x-%x"`, modified.UnixNano(), a.SizeBytes)
+	w.Header().Set("Cache-Control", previewCacheControl)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	reader, err := h.storage.Retrieve(r.Context(), a.StoragePath)
+	if err != nil {
+		h.logger.Error("Failed to retrieve preview from storage", zap.Error(err), zap.String("path", a.StoragePath))
+		http.Error(w, "preview not available", http.StatusNotFound)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", a.MimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.SizeBytes, 10))
+	if _, err := io.Copy(w, reader); err != nil && !strings.Contains(err.Error(), "broken pipe") && !strings.Contains(err.Error(), "connection reset") {
+		h.logger.Error("Failed to stream preview", zap.Error(err))
+	}
+}
+
+// isThumbnailSize reports whether size names one of the generated poster variants
+func isThumbnailSize(size string) bool {
+	for _, s := range media.ThumbnailSizes {
+		if s.Name == size {
+			return true
+		}
+	}
+	return false
 }
 
 // ListFiles returns the user's uploaded files
@@ -412,6 +716,9 @@ func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "file id required", http.StatusBadRequest)
 		return
 	}
+	if !authorize(w, r, h.authz, authz.ResourceFile, fileID, h.logger) {
+		return
+	}
 
 	// Get file to find storage path
 	file, err := h.getFileFromDB(r.Context(), fileID)
@@ -420,20 +727,19 @@ func (h *FileHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check ownership
-	user := middleware.GetUser(r.Context())
-	userID := user.ID
-	if file.UserID != nil && *file.UserID != userID {
-		http.Error(w, "forbidden", http.StatusForbidden)
-		return
+	// Delete from storage, including every file of a packaged output
+	artifacts, err := h.listArtifacts(r.Context(), fileID)
+	if err != nil {
+		h.logger.Error("Failed to list packaged output files", zap.Error(err))
 	}
-	// Legacy NULL user_id files: only anonymous users can delete them
-	if file.UserID == nil && !user.IsAnonymous() {
-		http.Error(w, "forbidden", http.StatusForbidden)
-		return
+	for _, a := range artifacts {
+		if a.StoragePath == file.StoragePath {
+			continue
+		}
+		if err := h.storage.Delete(r.Context(), a.StoragePath); err != nil {
+			h.logger.Error("Failed to delete file from storage", zap.Error(err))
+		}
 	}
-
-	// Delete from storage
 	if err := h.storage.Delete(r.Context(), file.StoragePath); err != nil {
 		h.logger.Error("Failed to delete file from storage", zap.Error(err))
 	}
@@ -496,19 +802,13 @@ func (h *FileHandler) SimpleUpload(w http.ResponseWriter, r *http.Request) {
 	file.Seek(0, io.SeekStart)
 	file.Read(buffer)
 	mimeType := http.DetectContentType(buffer)
+	if sub := uploads.SubtitleMimeType(header.Filename); sub != "" {
+		// Subtitle files sniff as plain text
+		mimeType = sub
+	}
 
 	// Determine media type from MIME
-	var mediaType *string
-	if strings.HasPrefix(mimeType, "video/") {
-		mt := "video"
-		mediaType = &mt
-	} else if strings.HasPrefix(mimeType, "audio/") {
-		mt := "audio"
-		mediaType = &mt
-	} else if strings.HasPrefix(mimeType, "image/") {
-		mt := "image"
-		mediaType = &mt
-	}
+	mediaType := uploads.MediaTypeFromMime(mimeType)
 
 	// All users (including anon) get their ID stored for ownership tracking
 	userIDPtr := &user.ID
@@ -523,6 +823,8 @@ func (h *FileHandler) SimpleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	mediaInfo := h.probeUpload(r.Context(), fileRecord.ID)
+
 	h.logger.Info("File uploaded successfully",
 		zap.String("file_id", fileRecord.ID),
 		zap.String("filename", header.Filename),
@@ -537,6 +839,7 @@ func (h *FileHandler) SimpleUpload(w http.ResponseWriter, r *http.Request) {
 		"mimeType":    mimeType,
 		"storagePath": fileInfo.Path,
 		"zone":        string(fileInfo.Zone),
+		"mediaInfo":   mediaInfo,
 		"createdAt":   fileRecord.CreatedAt,
 	}
 
@@ -639,17 +942,10 @@ func (h *FileHandler) ConfirmPresignedUpload(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Determine media type from MIME
-	var mediaType *string
-	if strings.HasPrefix(req.MimeType, "video/") {
-		mt := "video"
-		mediaType = &mt
-	} else if strings.HasPrefix(req.MimeType, "audio/") {
-		mt := "audio"
-		mediaType = &mt
-	} else if strings.HasPrefix(req.MimeType, "image/") {
-		mt := "image"
-		mediaType = &mt
+	if sub := uploads.SubtitleMimeType(req.Filename); sub != "" {
+		req.MimeType = sub
 	}
+	mediaType := uploads.MediaTypeFromMime(req.MimeType)
 
 	// All users (including anon) get their ID stored for ownership tracking
 	user := middleware.GetUser(r.Context())
@@ -672,6 +968,8 @@ func (h *FileHandler) ConfirmPresignedUpload(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	mediaInfo := h.probeUpload(r.Context(), fileRecord.ID)
+
 	h.logger.Info("Presigned upload confirmed",
 		zap.String("file_id", fileRecord.ID),
 		zap.String("filename", req.Filename),
@@ -685,6 +983,7 @@ func (h *FileHandler) ConfirmPresignedUpload(w http.ResponseWriter, r *http.Requ
 		"mimeType":    req.MimeType,
 		"storagePath": req.Key,
 		"zone":        string(storage.ZoneUpload),
+		"mediaInfo":   mediaInfo,
 		"createdAt":   fileRecord.CreatedAt,
 	}
 
@@ -697,7 +996,7 @@ func (h *FileHandler) ConfirmPresignedUpload(w http.ResponseWriter, r *http.Requ
 func (h *FileHandler) getFileFromDB(ctx context.Context, fileID string) (*FileRecord, error) {
 	var file FileRecord
 	err := h.db.Pool.QueryRow(ctx, `
-		SELECT id, user_id, original_name, storage_path, mime_type, size_bytes, zone, media_type, expires_at, created_at
+		SELECT id, user_id, original_name, storage_path, mime_type, size_bytes, zone, media_type, metadata, expires_at, created_at
 		FROM files
 		WHERE id = $1
 	`, fileID).Scan(
@@ -709,6 +1008,7 @@ func (h *FileHandler) getFileFromDB(ctx context.Context, fileID string) (*FileRe
 		&file.SizeBytes,
 		&file.Zone,
 		&file.MediaType,
+		&file.Metadata,
 		&file.ExpiresAt,
 		&file.CreatedAt,
 	)
@@ -718,6 +1018,79 @@ func (h *FileHandler) getFileFromDB(ctx context.Context, fileID string) (*FileRe
 	return &file, nil
 }
 
+// ArtifactRecord is one stored file of a packaged output
+type ArtifactRecord struct {
+	Name        string `json:"name"`
+	StoragePath string `json:"-"`
+	MimeType    string `json:"mimeType"`
+	SizeBytes   int64  `json:"sizeBytes"`
+}
+
+// getArtifactFromDB retrieves a file of a packaged output by its name inside the package
+func (h *FileHandler) getArtifactFromDB(ctx context.Context, fileID, name string) (*ArtifactRecord, error) {
+	a := ArtifactRecord{Name: name}
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT storage_path, COALESCE(mime_type, 'application/octet-stream'), size_bytes
+		FROM file_artifacts
+		WHERE file_id = $1 AND name = $2
+	`, fileID, name).Scan(&a.StoragePath, &a.MimeType, &a.SizeBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// getPreviewFromDB retrieves a generated preview of an upload (a thumbnail or
+// waveform artifact) and when it was generated
+func (h *FileHandler) getPreviewFromDB(ctx context.Context, fileID, name string) (*ArtifactRecord, time.Time, error) {
+	a := ArtifactRecord{Name: name}
+	var createdAt time.Time
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT storage_path, COALESCE(mime_type, 'application/octet-stream'), size_bytes, created_at
+		FROM file_artifacts
+		WHERE file_id = $1 AND name = $2
+	`, fileID, name).Scan(&a.StoragePath, &a.MimeType, &a.SizeBytes, &createdAt)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return &a, createdAt, nil
+}
+
+// getPackageEntry returns the name of a packaged output's entry (its manifest);
+// it errors for files that aren't packages
+func (h *FileHandler) getPackageEntry(ctx context.Context, file *FileRecord) (string, error) {
+	var name string
+	err := h.db.Pool.QueryRow(ctx,
+		"SELECT name FROM file_artifacts WHERE file_id = $1 AND storage_path = $2",
+		file.ID, file.StoragePath,
+	).Scan(&name)
+	return name, err
+}
+
+// listArtifacts returns every stored file of a packaged output, or none for a single file
+func (h *FileHandler) listArtifacts(ctx context.Context, fileID string) ([]ArtifactRecord, error) {
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT name, storage_path, COALESCE(mime_type, 'application/octet-stream'), size_bytes
+		FROM file_artifacts
+		WHERE file_id = $1
+		ORDER BY name
+	`, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artifacts []ArtifactRecord
+	for rows.Next() {
+		var a ArtifactRecord
+		if err := rows.Scan(&a.Name, &a.StoragePath, &a.MimeType, &a.SizeBytes); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
+
 // insertFileToDB inserts a new file record into the database
 func (h *FileHandler) insertFileToDB(ctx context.Context, fileInfo *storage.FileInfo, originalName, mimeType string, mediaType *string, userID *string) (*FileRecord, error) {
 	var record FileRecord
@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/nextconvert/backend/internal/api/middleware"
 	"github.com/nextconvert/backend/internal/modules/jobs"
-	"github.com/nextconvert/backend/internal/modules/subscription"
+	"github.com/nextconvert/backend/internal/shared/authz"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
@@ -15,13 +18,15 @@ import (
 // JobHandler handles job-related endpoints
 type JobHandler struct {
 	module *jobs.Module
+	authz  *authz.Authorizer
 	logger *zap.Logger
 }
 
 // NewJobHandler creates a new job handler
-func NewJobHandler(module *jobs.Module, logger *zap.Logger) *JobHandler {
+func NewJobHandler(module *jobs.Module, authorizer *authz.Authorizer, logger *zap.Logger) *JobHandler {
 	return &JobHandler{
 		module: module,
+		authz:  authorizer,
 		logger: logger,
 	}
 }
@@ -33,7 +38,6 @@ type CreateJobRequest struct {
 	Operations            []jobs.Operation `json:"operations"`
 	OutputFormat          string           `json:"outputFormat"`
 	OutputFileName        string           `json:"outputFileName"`
-	InputDurationSeconds  float64          `json:"inputDurationSeconds"`  // From probe, for conversion minutes
 }
 
 // CreateJob creates a new media processing job
@@ -53,19 +57,30 @@ func (h *JobHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
 		inputFileID = req.InputFileIDs[0]
 	}
 
-	convMin := subscription.ConversionMinutesFromDuration(req.InputDurationSeconds)
-
+	// Conversion minutes are computed from the server-side probe of the inputs
 	job, err := h.module.CreateJob(r.Context(), jobs.CreateJobParams{
-		UserID:               userID,
-		InputFileID:          inputFileID,
-		InputFileIDs:         req.InputFileIDs,
-		Operations:           req.Operations,
-		OutputFormat:         req.OutputFormat,
-		OutputFileName:       req.OutputFileName,
-		InputDurationSeconds: req.InputDurationSeconds,
-		ConversionMinutes:    convMin,
+		UserID:         userID,
+		InputFileID:    inputFileID,
+		InputFileIDs:   req.InputFileIDs,
+		Operations:     req.Operations,
+		OutputFormat:   req.OutputFormat,
+		OutputFileName: req.OutputFileName,
 	})
 	if err != nil {
+		if errors.Is(err, authz.ErrNotFound) {
+			http.Error(w, "input file not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, jobs.ErrNoDecodableStreams) {
+			h.logger.Warn("Job rejected: undecodable input", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, jobs.ErrInvalidOperations) {
+			h.logger.Warn("Job rejected: invalid operations", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 		errStr := err.Error()
 		if strings.Contains(errStr, "conversion minutes limit") || strings.Contains(errStr, "file size") || strings.Contains(errStr, "exceeds limit") {
 			h.logger.Warn("Job creation limit exceeded", zap.Error(err))
@@ -116,6 +131,9 @@ func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
 // GetJob returns a specific job
 func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "id")
+	if !authorize(w, r, h.authz, authz.ResourceJob, jobID, h.logger) {
+		return
+	}
 
 	job, err := h.module.GetJob(r.Context(), jobID)
 	if err != nil {
@@ -130,6 +148,9 @@ func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
 // CancelJob cancels a job (keeps it in database)
 func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "id")
+	if !authorize(w, r, h.authz, authz.ResourceJob, jobID, h.logger) {
+		return
+	}
 
 	if err := h.module.CancelJob(r.Context(), jobID); err != nil {
 		h.logger.Error("Failed to cancel job", zap.Error(err), zap.String("job_id", jobID))
@@ -144,6 +165,9 @@ func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
 // DeleteJob deletes a job from the database
 func (h *JobHandler) DeleteJob(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "id")
+	if !authorize(w, r, h.authz, authz.ResourceJob, jobID, h.logger) {
+		return
+	}
 
 	if err := h.module.DeleteJob(r.Context(), jobID); err != nil {
 		h.logger.Error("Failed to delete job", zap.Error(err), zap.String("job_id", jobID))
@@ -158,8 +182,11 @@ func (h *JobHandler) DeleteJob(w http.ResponseWriter, r *http.Request) {
 // RetryJob retries a failed job
 func (h *JobHandler) RetryJob(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "id")
+	if !authorize(w, r, h.authz, authz.ResourceJob, jobID, h.logger) {
+		return
+	}
 
-	newJob, err := h.module.RetryJob(r.Context(), jobID)
+	newJob, err := h.module.RetryJob(r.Context(), jobID, middleware.GetUser(r.Context()).ID)
 	if err != nil {
 		h.logger.Error("Failed to retry job", zap.Error(err), zap.String("job_id", jobID))
 		http.Error(w, "failed to retry job", http.StatusInternalServerError)
@@ -172,11 +199,35 @@ func (h *JobHandler) RetryJob(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(newJob)
 }
 
-// GetJobLogs returns job execution logs
+// GetJobLogs returns a page of a job's log: pipeline stages, FFmpeg command
+// lines and the end of FFmpeg's output. ?after= takes the previous page's
+// "next" value and ?limit= the page size.
 func (h *JobHandler) GetJobLogs(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "id")
+	if !authorize(w, r, h.authz, authz.ResourceJob, jobID, h.logger) {
+		return
+	}
+
+	var after int64
+	if v := r.URL.Query().Get("after"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid after", http.StatusBadRequest)
+			return
+		}
+		after = n
+	}
+	limit := jobs.DefaultLogPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > jobs.MaxLogPageSize {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", jobs.MaxLogPageSize), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
 
-	logs, err := h.module.GetJobLogs(r.Context(), jobID)
+	page, err := h.module.GetJobLogs(r.Context(), jobID, after, limit)
 	if err != nil {
 		http.Error(w, "logs not found", http.StatusNotFound)
 		return
@@ -184,7 +235,9 @@ func (h *JobHandler) GetJobLogs(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"jobId": jobID,
-		"logs":  logs,
+		"jobId":   jobID,
+		"logs":    page.Logs,
+		"next":    page.Next,
+		"hasMore": page.HasMore,
 	})
 }
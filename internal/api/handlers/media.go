@@ -71,8 +71,9 @@ func (h *MediaHandler) GetPreset(w http.ResponseWriter, r *http.Request) {
 
 // ValidateOperationsRequest represents a validation request
 type ValidateOperationsRequest struct {
-	Operations []media.Operation `json:"operations"`
-	InputType  string            `json:"inputType"`
+	Operations   []media.Operation `json:"operations"`
+	InputType    string            `json:"inputType"`
+	OutputFormat string            `json:"outputFormat"` // Optional; checks codecs against the output container
 }
 
 // ValidateOperations validates a chain of operations
@@ -83,15 +84,23 @@ func (h *MediaHandler) ValidateOperations(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	result := h.module.ValidateOperations(req.Operations, req.InputType)
+	result := h.module.ValidateOperations(r.Context(), req.Operations, req.InputType, req.OutputFormat)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
 
+// GetOperations returns the operations the processor supports, with their parameters
+func (h *MediaHandler) GetOperations(w http.ResponseWriter, r *http.Request) {
+	operations := h.module.GetOperations(r.URL.Query().Get("mediaType"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(operations)
+}
+
 // GetFormats returns supported media formats
 func (h *MediaHandler) GetFormats(w http.ResponseWriter, r *http.Request) {
-	formats := h.module.GetSupportedFormats()
+	formats := h.module.GetSupportedFormats(r.Context())
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(formats)
@@ -99,7 +108,7 @@ func (h *MediaHandler) GetFormats(w http.ResponseWriter, r *http.Request) {
 
 // GetCodecs returns available codecs
 func (h *MediaHandler) GetCodecs(w http.ResponseWriter, r *http.Request) {
-	codecs := h.module.GetAvailableCodecs()
+	codecs := h.module.GetAvailableCodecs(r.Context())
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(codecs)
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/nextconvert/backend/internal/api/middleware"
+	"github.com/nextconvert/backend/internal/shared/authz"
 	"github.com/nextconvert/backend/internal/shared/database"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -15,12 +16,13 @@ import (
 // PresetsHandler handles user preset operations
 type PresetsHandler struct {
 	db     *database.Postgres
+	authz  *authz.Authorizer
 	logger *zap.Logger
 }
 
 // NewPresetsHandler creates a new presets handler
-func NewPresetsHandler(db *database.Postgres, logger *zap.Logger) *PresetsHandler {
-	return &PresetsHandler{db: db, logger: logger}
+func NewPresetsHandler(db *database.Postgres, authorizer *authz.Authorizer, logger *zap.Logger) *PresetsHandler {
+	return &PresetsHandler{db: db, authz: authorizer, logger: logger}
 }
 
 // UserPreset represents a user-created preset
@@ -176,6 +178,9 @@ func (h *PresetsHandler) DeletePreset(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "preset id required", http.StatusBadRequest)
 		return
 	}
+	if !authorize(w, r, h.authz, authz.ResourcePreset, presetID, h.logger) {
+		return
+	}
 
 	result, err := h.db.Pool.Exec(r.Context(), `
 		DELETE FROM presets WHERE id = $1 AND user_id = $2 AND (is_system = FALSE OR is_system IS NULL)
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/nextconvert/backend/internal/api/middleware"
+	"github.com/nextconvert/backend/internal/modules/jobs"
+	"github.com/nextconvert/backend/internal/modules/subtitles"
+	"github.com/nextconvert/backend/internal/shared/authz"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// SubtitleHandler handles subtitle conversion, retiming and extraction
+type SubtitleHandler struct {
+	service *subtitles.Service
+	authz   *authz.Authorizer
+	logger  *zap.Logger
+}
+
+// NewSubtitleHandler creates a new subtitle handler
+func NewSubtitleHandler(service *subtitles.Service, authorizer *authz.Authorizer, logger *zap.Logger) *SubtitleHandler {
+	return &SubtitleHandler{
+		service: service,
+		authz:   authorizer,
+		logger:  logger,
+	}
+}
+
+// CueResponse is one cue of a subtitle file, with times in seconds
+type CueResponse struct {
+	Index int     `json:"index"` // 1-based, as used by merge and split edits
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// CuesResponse lists the cues of a subtitle file
+type CuesResponse struct {
+	Format subtitles.Format `json:"format"`
+	Cues   []CueResponse    `json:"cues"`
+}
+
+// ConvertSubtitleRequest converts and/or retimes a subtitle file
+type ConvertSubtitleRequest struct {
+	Format string           `json:"format,omitempty"` // Defaults to the source format
+	Edits  []subtitles.Edit `json:"edits,omitempty"`
+}
+
+// ExtractSubtitleRequest extracts a subtitle stream from a video
+type ExtractSubtitleRequest struct {
+	Stream int    `json:"stream"` // Index among the video's subtitle streams
+	Format string `json:"format,omitempty"`
+}
+
+// GetCues returns the parsed cues of a subtitle file
+func (h *SubtitleHandler) GetCues(w http.ResponseWriter, r *http.Request) {
+	fileID := chi.URLParam(r, "id")
+	if !authorize(w, r, h.authz, authz.ResourceFile, fileID, h.logger) {
+		return
+	}
+
+	doc, err := h.service.Load(r.Context(), fileID)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	resp := CuesResponse{Format: doc.Format, Cues: make([]CueResponse, len(doc.Cues))}
+	for i, c := range doc.Cues {
+		resp.Cues[i] = CueResponse{
+			Index: i + 1,
+			Start: c.Start.Seconds(),
+			End:   c.End.Seconds(),
+			Text:  c.Text,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Convert writes a converted and/or retimed copy of a subtitle file
+func (h *SubtitleHandler) Convert(w http.ResponseWriter, r *http.Request) {
+	fileID := chi.URLParam(r, "id")
+	if !authorize(w, r, h.authz, authz.ResourceFile, fileID, h.logger) {
+		return
+	}
+
+	var req ConvertSubtitleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	format, ok := h.parseFormat(w, req.Format)
+	if !ok {
+		return
+	}
+
+	file, err := h.service.Convert(r.Context(), subtitles.ConvertParams{
+		UserID: middleware.GetUser(r.Context()).ID,
+		FileID: fileID,
+		Format: format,
+		Edits:  req.Edits,
+	})
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(file)
+}
+
+// Extract queues a job that writes one subtitle stream of a video to a
+// standalone subtitle file; the job's output file is the subtitle file
+func (h *SubtitleHandler) Extract(w http.ResponseWriter, r *http.Request) {
+	fileID := chi.URLParam(r, "id")
+	if !authorize(w, r, h.authz, authz.ResourceFile, fileID, h.logger) {
+		return
+	}
+
+	var req ExtractSubtitleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Stream < 0 {
+		http.Error(w, "stream must not be negative", http.StatusBadRequest)
+		return
+	}
+	format, ok := h.parseFormat(w, req.Format)
+	if !ok {
+		return
+	}
+
+	job, err := h.service.Extract(r.Context(), subtitles.ExtractParams{
+		UserID: middleware.GetUser(r.Context()).ID,
+		FileID: fileID,
+		Stream: req.Stream,
+		Format: format,
+	})
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// parseFormat reads an optional format name, writing a 400 if it's unknown
+func (h *SubtitleHandler) parseFormat(w http.ResponseWriter, name string) (subtitles.Format, bool) {
+	if name == "" {
+		return "", true
+	}
+	format, err := subtitles.ParseFormat(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return format, true
+}
+
+// writeError maps subtitle service errors to HTTP responses
+func (h *SubtitleHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, subtitles.ErrNotFound):
+		http.Error(w, "file not found", http.StatusNotFound)
+	case errors.Is(err, subtitles.ErrUnsupportedFormat), errors.Is(err, subtitles.ErrInvalidEdit):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, subtitles.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, subtitles.ErrInvalidSubtitle), errors.Is(err, subtitles.ErrNoSubtitleStream), errors.Is(err, jobs.ErrNoDecodableStreams),
+		errors.Is(err, jobs.ErrInvalidOperations):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		h.logger.Error("Subtitle operation failed", zap.Error(err))
+		http.Error(w, "subtitle operation failed", http.StatusInternalServerError)
+	}
+}
@@ -3,25 +3,34 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/nextconvert/backend/internal/api/middleware"
 	"github.com/nextconvert/backend/internal/api/websocket"
+	"github.com/nextconvert/backend/internal/shared/authz"
 	"go.uber.org/zap"
 )
 
 // WebSocketHandler handles WebSocket connections
 type WebSocketHandler struct {
-	hub    *websocket.Hub
-	logger *zap.Logger
+	hub        *websocket.Hub
+	authorizer *authz.Authorizer
+	logger     *zap.Logger
 }
 
 // NewWebSocketHandler creates a new WebSocket handler
-func NewWebSocketHandler(hub *websocket.Hub, logger *zap.Logger) *WebSocketHandler {
+func NewWebSocketHandler(hub *websocket.Hub, authorizer *authz.Authorizer, logger *zap.Logger) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub:    hub,
-		logger: logger,
+		hub:        hub,
+		authorizer: authorizer,
+		logger:     logger,
 	}
 }
 
-// HandleConnection upgrades HTTP to WebSocket
+// HandleConnection upgrades HTTP to WebSocket. The connection can only
+// subscribe to the current user's jobs.
 func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Request) {
-	h.hub.HandleConnection(w, r)
+	userID := ""
+	if user := middleware.GetUser(r.Context()); user != nil {
+		userID = user.ID
+	}
+	h.hub.HandleConnection(w, r, userID, h.authorizer)
 }
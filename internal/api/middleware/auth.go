@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/nextconvert/backend/internal/shared/authz"
 	"github.com/clerk/clerk-sdk-go/v2"
 	"github.com/clerk/clerk-sdk-go/v2/jwt"
 	"github.com/clerk/clerk-sdk-go/v2/user"
@@ -21,7 +22,7 @@ const (
 	AnonCookieName = "__nc_anon"
 
 	// AnonIDPrefix is prepended to anonymous user IDs
-	AnonIDPrefix = "anon:"
+	AnonIDPrefix = authz.AnonIDPrefix
 
 	// AnonCookieMaxAge is the max-age of the anonymous cookie (30 days)
 	AnonCookieMaxAge = 30 * 24 * 60 * 60
@@ -12,6 +12,9 @@ import (
 	"github.com/nextconvert/backend/internal/modules/jobs"
 	"github.com/nextconvert/backend/internal/modules/media"
 	"github.com/nextconvert/backend/internal/modules/subscription"
+	"github.com/nextconvert/backend/internal/modules/subtitles"
+	"github.com/nextconvert/backend/internal/modules/uploads"
+	"github.com/nextconvert/backend/internal/shared/authz"
 	"github.com/nextconvert/backend/internal/shared/config"
 	"github.com/nextconvert/backend/internal/shared/database"
 	"github.com/nextconvert/backend/internal/shared/storage"
@@ -96,11 +99,15 @@ func (s *Server) Router() *chi.Mux {
 
 	// Create handlers
 	healthHandler := handlers.NewHealthHandler(s.db, s.redis)
-	fileHandler := handlers.NewFileHandler(s.storage, s.db, s.subscriptionSvc, s.logger)
+	uploadSvc := uploads.NewService(s.db, s.storage, s.logger)
+	authorizer := authz.NewAuthorizer(s.db)
+	fileHandler := handlers.NewFileHandler(s.storage, s.db, s.subscriptionSvc, uploadSvc, s.mediaModule, authorizer, s.logger)
 	mediaHandler := handlers.NewMediaHandler(s.mediaModule, s.logger)
-	jobHandler := handlers.NewJobHandler(s.jobsModule, s.logger)
-	presetsHandler := handlers.NewPresetsHandler(s.db, s.logger)
-	wsHandler := handlers.NewWebSocketHandler(s.wsHub, s.logger)
+	jobHandler := handlers.NewJobHandler(s.jobsModule, authorizer, s.logger)
+	presetsHandler := handlers.NewPresetsHandler(s.db, authorizer, s.logger)
+	subtitleSvc := subtitles.NewService(s.db, s.storage, s.jobsModule, s.logger)
+	subtitleHandler := handlers.NewSubtitleHandler(subtitleSvc, authorizer, s.logger)
+	wsHandler := handlers.NewWebSocketHandler(s.wsHub, authorizer, s.logger)
 
 	priceIDs := map[string]string{
 		"basic":    s.config.StripeBasicPriceID,
@@ -147,10 +154,14 @@ func (s *Server) Router() *chi.Mux {
 				r.Post("/upload/confirm", fileHandler.ConfirmPresignedUpload)
 				r.Post("/upload/chunk", fileHandler.UploadChunk)
 				r.Post("/upload/complete", fileHandler.CompleteUpload)
+				r.Get("/upload/{uploadId}", fileHandler.GetUploadStatus)
 				r.Get("/", fileHandler.ListFiles)
 				r.Get("/{id}", fileHandler.GetFile)
 				r.Get("/{id}/download", fileHandler.DownloadFile)
+				r.Get("/{id}/download/*", fileHandler.DownloadFile)
 				r.Get("/{id}/thumbnail", fileHandler.GetThumbnail)
+				r.Get("/{id}/thumbnail/{name}", fileHandler.GetThumbnail)
+				r.Get("/{id}/waveform", fileHandler.GetWaveform)
 				r.Delete("/{id}", fileHandler.DeleteFile)
 			})
 
@@ -160,10 +171,18 @@ func (s *Server) Router() *chi.Mux {
 				r.Get("/presets", mediaHandler.GetPresets)
 				r.Get("/presets/{id}", mediaHandler.GetPreset)
 				r.Post("/validate", mediaHandler.ValidateOperations)
+				r.Get("/operations", mediaHandler.GetOperations)
 				r.Get("/formats", mediaHandler.GetFormats)
 				r.Get("/codecs", mediaHandler.GetCodecs)
 			})
 
+			// Subtitle files
+			r.Route("/subtitles", func(r chi.Router) {
+				r.Get("/{id}/cues", subtitleHandler.GetCues)
+				r.Post("/{id}/convert", subtitleHandler.Convert)
+				r.Post("/{id}/extract", subtitleHandler.Extract)
+			})
+
 			// User presets
 			r.Route("/presets", func(r chi.Router) {
 				r.Get("/", presetsHandler.ListPresets)
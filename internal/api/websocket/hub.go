@@ -1,14 +1,21 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/nextconvert/backend/internal/shared/authz"
 	"go.uber.org/zap"
 )
 
+// authorizeTimeout bounds the ownership lookup of a subscribe request
+const authorizeTimeout = 5 * time.Second
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -26,16 +33,19 @@ type Message struct {
 
 // JobProgressPayload represents a job progress update
 type JobProgressPayload struct {
-	JobID            string `json:"jobId"`
-	Percent          int    `json:"percent"`
-	CurrentOperation string `json:"currentOperation,omitempty"`
-	ETA              int    `json:"eta,omitempty"`
+	JobID            string  `json:"jobId"`
+	Percent          int     `json:"percent"`
+	CurrentOperation string  `json:"currentOperation,omitempty"`
+	Speed            float64 `json:"speed,omitempty"`
+	ETA              int     `json:"eta,omitempty"`
 }
 
-// JobCompletedPayload represents a job completion
+// JobCompletedPayload represents a job completion. Multi-output jobs (e.g.
+// split) list every output file; OutputFileID is the first of them.
 type JobCompletedPayload struct {
-	JobID        string `json:"jobId"`
-	OutputFileID string `json:"outputFileId"`
+	JobID         string   `json:"jobId"`
+	OutputFileID  string   `json:"outputFileId"`
+	OutputFileIDs []string `json:"outputFileIds,omitempty"`
 }
 
 // JobFailedPayload represents a job failure
@@ -44,6 +54,11 @@ type JobFailedPayload struct {
 	Error string `json:"error"`
 }
 
+// JobAuthorizer checks that a user may access a job. Implemented by authz.Authorizer.
+type JobAuthorizer interface {
+	Authorize(ctx context.Context, resource authz.Resource, id, userID string) error
+}
+
 // Client represents a WebSocket client
 type Client struct {
 	hub          *Hub
@@ -51,6 +66,8 @@ type Client struct {
 	send         chan []byte
 	subscriptions map[string]bool
 	mu           sync.RWMutex
+	userID       string        // User the connection was opened by
+	authorizer   JobAuthorizer // Decides which jobs the user may subscribe to
 }
 
 // Hub manages WebSocket connections
@@ -108,8 +125,9 @@ func (h *Hub) Run() {
 	}
 }
 
-// HandleConnection handles a new WebSocket connection
-func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request) {
+// HandleConnection handles a new WebSocket connection for userID, who may
+// only subscribe to the jobs authorizer lets them access
+func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request, userID string, authorizer JobAuthorizer) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.logger.Error("WebSocket upgrade failed", zap.Error(err))
@@ -121,6 +139,8 @@ func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request) {
 		conn:          conn,
 		send:          make(chan []byte, 256),
 		subscriptions: make(map[string]bool),
+		userID:        userID,
+		authorizer:    authorizer,
 	}
 
 	h.register <- client
@@ -167,20 +187,22 @@ func (h *Hub) SendToJob(jobID string, msgType string, payload interface{}) error
 }
 
 // BroadcastJobProgress sends a progress update
-func (h *Hub) BroadcastJobProgress(jobID string, percent int, operation string, eta int) {
+func (h *Hub) BroadcastJobProgress(jobID string, percent int, operation string, speed float64, eta int) {
 	h.SendToJob(jobID, "job:progress", JobProgressPayload{
 		JobID:            jobID,
 		Percent:          percent,
 		CurrentOperation: operation,
+		Speed:            speed,
 		ETA:              eta,
 	})
 }
 
 // BroadcastJobCompleted sends a completion notification
-func (h *Hub) BroadcastJobCompleted(jobID, outputFileID string) {
+func (h *Hub) BroadcastJobCompleted(jobID, outputFileID string, outputFileIDs []string) {
 	h.SendToJob(jobID, "job:completed", JobCompletedPayload{
-		JobID:        jobID,
-		OutputFileID: outputFileID,
+		JobID:         jobID,
+		OutputFileID:  outputFileID,
+		OutputFileIDs: outputFileIDs,
 	})
 }
 
@@ -235,6 +257,10 @@ func (c *Client) handleMessage(msg Message) {
 			JobID string `json:"jobId"`
 		}
 		if err := json.Unmarshal(msg.Payload, &payload); err == nil {
+			// Someone else's job is ignored like a job that doesn't exist
+			if !c.canSubscribe(payload.JobID) {
+				return
+			}
 			c.mu.Lock()
 			c.subscriptions[payload.JobID] = true
 			c.mu.Unlock()
@@ -257,3 +283,18 @@ func (c *Client) handleMessage(msg Message) {
 		c.send <- response
 	}
 }
+
+// canSubscribe reports whether the client's user may follow a job's events
+func (c *Client) canSubscribe(jobID string) bool {
+	if c.authorizer == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), authorizeTimeout)
+	defer cancel()
+
+	err := c.authorizer.Authorize(ctx, authz.ResourceJob, jobID, c.userID)
+	if err != nil && !errors.Is(err, authz.ErrNotFound) {
+		c.hub.logger.Warn("Failed to check job ownership for subscription", zap.String("job_id", jobID), zap.Error(err))
+	}
+	return err == nil
+}
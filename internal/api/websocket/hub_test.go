@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nextconvert/backend/internal/shared/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// ownedJobs authorizes access to the jobs listed for each user
+type ownedJobs map[string][]string
+
+func (o ownedJobs) Authorize(_ context.Context, resource authz.Resource, id, userID string) error {
+	if resource == authz.ResourceJob {
+		for _, jobID := range o[userID] {
+			if jobID == id {
+				return nil
+			}
+		}
+	}
+	return authz.ErrNotFound
+}
+
+// dialHub opens a connection to a running hub as userID
+func dialHub(t *testing.T, hub *Hub, userID string, authorizer JobAuthorizer) *websocket.Conn {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.HandleConnection(w, r, userID, authorizer)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, msgType string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteJSON(Message{Type: msgType, Payload: data}))
+}
+
+func receive(t *testing.T, conn *websocket.Conn) Message {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var msg Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestSubscribeRequiresJobAccess(t *testing.T) {
+	hub := NewHub(zap.NewNop())
+	go hub.Run()
+
+	conn := dialHub(t, hub, "user_alice", ownedJobs{"user_alice": {"alice-job"}, "user_bob": {"bob-job"}})
+	send(t, conn, "subscribe", map[string]string{"jobId": "bob-job"})
+	send(t, conn, "subscribe", map[string]string{"jobId": "alice-job"})
+	send(t, conn, "ping", nil)
+	// Messages are handled in order, so both subscriptions are settled once the pong arrives
+	assert.Equal(t, "pong", receive(t, conn).Type)
+
+	hub.BroadcastJobFailed("bob-job", "bob's error")
+	hub.BroadcastJobFailed("alice-job", "alice's error")
+
+	msg := receive(t, conn)
+	assert.Equal(t, "job:failed", msg.Type)
+	var payload JobFailedPayload
+	require.NoError(t, json.Unmarshal(msg.Payload, &payload))
+	assert.Equal(t, "alice-job", payload.JobID, "another user's job events must not be delivered")
+}
+
+func TestSubscribeWithoutAuthorizer(t *testing.T) {
+	hub := NewHub(zap.NewNop())
+	go hub.Run()
+
+	conn := dialHub(t, hub, "user_alice", nil)
+	send(t, conn, "subscribe", map[string]string{"jobId": "alice-job"})
+	send(t, conn, "ping", nil)
+	assert.Equal(t, "pong", receive(t, conn).Type)
+
+	hub.BroadcastJobProgress("alice-job", 50, "Processing", 1, 10)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err, "nothing is delivered when access can't be checked")
+}
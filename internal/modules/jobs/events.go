@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nextconvert/backend/internal/api/websocket"
+	"github.com/nextconvert/backend/internal/shared/database"
+	"go.uber.org/zap"
+)
+
+// EventsChannel is the Redis pub/sub channel job events are published on
+const EventsChannel = "jobs:events"
+
+// EventType identifies a job lifecycle event
+type EventType string
+
+const (
+	EventProgress  EventType = "progress"
+	EventCompleted EventType = "completed"
+	EventFailed    EventType = "failed"
+)
+
+// Event is a job lifecycle event shared between workers and API servers
+type Event struct {
+	Type          EventType `json:"type"`
+	JobID         string    `json:"jobId"`
+	Progress      *Progress `json:"progress,omitempty"`
+	OutputFileID  string    `json:"outputFileId,omitempty"`
+	OutputFileIDs []string  `json:"outputFileIds,omitempty"` // Set for multi-output jobs
+	Error         string    `json:"error,omitempty"`
+}
+
+// EventBus publishes job events over Redis so that every API replica can
+// forward them to its own WebSocket clients, regardless of which process
+// (worker or API) produced them.
+type EventBus struct {
+	redis  *database.Redis
+	logger *zap.Logger
+}
+
+// NewEventBus creates a new event bus backed by Redis pub/sub
+func NewEventBus(redis *database.Redis, logger *zap.Logger) *EventBus {
+	return &EventBus{
+		redis:  redis,
+		logger: logger,
+	}
+}
+
+// Publish sends an event to all subscribers
+func (b *EventBus) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job event: %w", err)
+	}
+	return b.redis.Publish(ctx, EventsChannel, data)
+}
+
+// Relay forwards published events to subscribed WebSocket clients until ctx is done.
+// Run one relay per API server process.
+func (b *EventBus) Relay(ctx context.Context, hub *websocket.Hub) {
+	pubsub := b.redis.Subscribe(ctx, EventsChannel)
+	defer pubsub.Close()
+
+	b.logger.Info("Relaying job events to WebSocket clients", zap.String("channel", EventsChannel))
+
+	// Channel() reconnects transparently if the Redis connection drops
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				b.logger.Warn("Invalid job event", zap.Error(err))
+				continue
+			}
+			dispatchEvent(hub, event)
+		}
+	}
+}
+
+// dispatchEvent delivers an event to clients subscribed to its job
+func dispatchEvent(hub *websocket.Hub, event Event) {
+	switch event.Type {
+	case EventProgress:
+		if event.Progress != nil {
+			hub.BroadcastJobProgress(event.JobID, event.Progress.Percent, event.Progress.CurrentOperation, event.Progress.Speed, event.Progress.ETA)
+		}
+	case EventCompleted:
+		hub.BroadcastJobCompleted(event.JobID, event.OutputFileID, event.OutputFileIDs)
+	case EventFailed:
+		hub.BroadcastJobFailed(event.JobID, event.Error)
+	}
+}
@@ -0,0 +1,179 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	gorilla "github.com/gorilla/websocket"
+	"github.com/nextconvert/backend/internal/api/websocket"
+	"github.com/nextconvert/backend/internal/shared/authz"
+	"github.com/nextconvert/backend/internal/shared/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// anyJob lets every user subscribe to every job
+type anyJob struct{}
+
+func (anyJob) Authorize(context.Context, authz.Resource, string, string) error { return nil }
+
+// subscribeClient connects a WebSocket client to hub and subscribes it to jobID
+func subscribeClient(t *testing.T, hub *websocket.Hub, jobID string) *gorilla.Conn {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.HandleConnection(w, r, "user_alice", anyJob{})
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	payload, err := json.Marshal(map[string]string{"jobId": jobID})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteJSON(websocket.Message{Type: "subscribe", Payload: payload}))
+	// Messages are handled in order, so the subscription is settled once the pong arrives
+	require.NoError(t, conn.WriteJSON(websocket.Message{Type: "ping"}))
+	require.Equal(t, "pong", readMessage(t, conn).Type)
+	return conn
+}
+
+func readMessage(t *testing.T, conn *gorilla.Conn) websocket.Message {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var msg websocket.Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func newTestHub() *websocket.Hub {
+	hub := websocket.NewHub(zap.NewNop())
+	go hub.Run()
+	return hub
+}
+
+func TestEventJSON(t *testing.T) {
+	event := Event{
+		Type:          EventCompleted,
+		JobID:         testJobID,
+		Progress:      &Progress{Percent: 100, CurrentOperation: "Uploading", Speed: 1.5, ETA: 0},
+		OutputFileID:  "part-1",
+		OutputFileIDs: []string{"part-1", "part-2"},
+	}
+
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+	var decoded Event
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, event, decoded)
+
+	data, err = json.Marshal(Event{Type: EventFailed, JobID: testJobID, Error: "boom"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"failed","jobId":"`+testJobID+`","error":"boom"}`, string(data))
+}
+
+func TestDispatchEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		wantType string
+		want     string // Payload the client receives, or nothing when empty
+	}{
+		{
+			name:     "progress",
+			event:    Event{Type: EventProgress, JobID: testJobID, Progress: &Progress{Percent: 42, CurrentOperation: "Processing: resize", Speed: 2, ETA: 30}},
+			wantType: "job:progress",
+			want:     `{"jobId":"` + testJobID + `","percent":42,"currentOperation":"Processing: resize","speed":2,"eta":30}`,
+		},
+		{
+			name:  "progress without progress",
+			event: Event{Type: EventProgress, JobID: testJobID},
+		},
+		{
+			name:     "completed",
+			event:    Event{Type: EventCompleted, JobID: testJobID, OutputFileID: "out-1"},
+			wantType: "job:completed",
+			want:     `{"jobId":"` + testJobID + `","outputFileId":"out-1"}`,
+		},
+		{
+			name:     "completed with several outputs",
+			event:    Event{Type: EventCompleted, JobID: testJobID, OutputFileID: "part-1", OutputFileIDs: []string{"part-1", "part-2", "part-3"}},
+			wantType: "job:completed",
+			want:     `{"jobId":"` + testJobID + `","outputFileId":"part-1","outputFileIds":["part-1","part-2","part-3"]}`,
+		},
+		{
+			name:     "failed",
+			event:    Event{Type: EventFailed, JobID: testJobID, Error: "FFmpeg exited with status 1"},
+			wantType: "job:failed",
+			want:     `{"jobId":"` + testJobID + `","error":"FFmpeg exited with status 1"}`,
+		},
+		{
+			name:  "unknown type",
+			event: Event{Type: "paused", JobID: testJobID},
+		},
+		{
+			name:  "another job",
+			event: Event{Type: EventFailed, JobID: "another-job", Error: "boom"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := newTestHub()
+			conn := subscribeClient(t, hub, testJobID)
+
+			dispatchEvent(hub, tt.event)
+			if tt.want == "" {
+				// Nothing was queued for the client ahead of the pong
+				require.NoError(t, conn.WriteJSON(websocket.Message{Type: "ping"}))
+				assert.Equal(t, "pong", readMessage(t, conn).Type)
+				return
+			}
+
+			msg := readMessage(t, conn)
+			assert.Equal(t, tt.wantType, msg.Type)
+			assert.JSONEq(t, tt.want, string(msg.Payload))
+		})
+	}
+}
+
+func TestEventBusRelay(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redis, err := database.NewRedis(mr.Addr())
+	require.NoError(t, err)
+	t.Cleanup(func() { redis.Close() })
+
+	hub := newTestHub()
+	conn := subscribeClient(t, hub, testJobID)
+
+	bus := NewEventBus(redis, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	relayed := make(chan struct{})
+	go func() {
+		bus.Relay(ctx, hub)
+		close(relayed)
+	}()
+	require.Eventually(t, func() bool { return mr.PubSubNumSub(EventsChannel)[EventsChannel] == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	// A malformed message is skipped without stopping the relay
+	mr.Publish(EventsChannel, "not json")
+	require.NoError(t, bus.Publish(ctx, Event{Type: EventCompleted, JobID: testJobID, OutputFileID: "out-1", OutputFileIDs: []string{"out-1"}}))
+
+	msg := readMessage(t, conn)
+	assert.Equal(t, "job:completed", msg.Type)
+	var payload websocket.JobCompletedPayload
+	require.NoError(t, json.Unmarshal(msg.Payload, &payload))
+	assert.Equal(t, websocket.JobCompletedPayload{JobID: testJobID, OutputFileID: "out-1", OutputFileIDs: []string{"out-1"}}, payload)
+
+	cancel()
+	select {
+	case <-relayed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("relay did not stop when its context was cancelled")
+	}
+}
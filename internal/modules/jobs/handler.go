@@ -3,38 +3,72 @@ package jobs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/nextconvert/backend/internal/modules/uploads"
 	"github.com/nextconvert/backend/internal/shared/database"
 	"github.com/nextconvert/backend/internal/shared/storage"
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
 )
 
 // MediaProcessorInterface defines the interface for media processing
 type MediaProcessorInterface interface {
-	Process(ctx context.Context, opts MediaProcessOptions) error
+	Process(ctx context.Context, opts MediaProcessOptions) (*MediaProcessResult, error)
 	ProcessMerge(ctx context.Context, opts MergeProcessOptions) error
+	GenerateThumbnails(ctx context.Context, inputPath, dir string) (*MediaProcessResult, error)
+	GenerateWaveform(ctx context.Context, inputPath, dir string, opts WaveformOptions) (*MediaProcessResult, error)
+}
+
+// ThumbnailArtifactPrefix names the artifacts of an uploaded video that hold
+// its generated poster frames, preview sprite and storyboard
+const ThumbnailArtifactPrefix = "thumbnail/"
+
+// WaveformArtifactPrefix names the artifacts of a file that hold its waveform
+// peaks, as WaveformArtifactPrefix + variant + "/" + file name
+const WaveformArtifactPrefix = "waveform/"
+
+// MediaProcessResult mirrors media.ProcessResult: the files of a multi-file
+// output such as an HLS or DASH package, or the separate output files of an
+// operation such as split. Nil when the output is the single OutputPath file.
+type MediaProcessResult struct {
+	Dir       string
+	Entry     string
+	Artifacts []OutputArtifact
+	Outputs   []OutputArtifact // Each becomes an output file of its own
+}
+
+// OutputArtifact is one file of a multi-file output
+type OutputArtifact struct {
+	Name string // Path relative to the package root, as referenced from manifests
+	Path string // Local path written by the processor
 }
 
 // MediaProcessOptions mirrors media.ProcessOptions to avoid import
 type MediaProcessOptions struct {
+	JobID            string
 	InputPath        string
 	OutputPath       string
 	Operations       []Operation
-	OnProgress       func(percent int, operation string)
+	OnProgress       func(progress Progress)
+	OnCommand        func(run CommandRun) // Called after each FFmpeg run
 	UseHardwareAccel *bool
 }
 
 // MergeProcessOptions contains options for merging multiple videos
 type MergeProcessOptions struct {
+	JobID            string
 	InputPaths       []string
 	OutputPath       string
-	OnProgress       func(percent int, operation string)
+	Params           map[string]interface{} // The merge operation's parameters
+	OnProgress       func(progress Progress)
+	OnCommand        func(run CommandRun)
 	UseHardwareAccel *bool
 }
 
@@ -45,6 +79,7 @@ type HandlerConfig struct {
 	Storage        *storage.Service
 	MediaProcessor MediaProcessorInterface
 	JobsModule     *Module
+	Uploads        *uploads.Service
 	Logger         *zap.Logger
 }
 
@@ -55,6 +90,7 @@ type Handler struct {
 	storage        *storage.Service
 	mediaProcessor MediaProcessorInterface
 	jobsModule     *Module
+	uploads        *uploads.Service
 	logger         *zap.Logger
 }
 
@@ -66,6 +102,7 @@ func NewHandler(cfg HandlerConfig) *Handler {
 		storage:        cfg.Storage,
 		mediaProcessor: cfg.MediaProcessor,
 		jobsModule:     cfg.JobsModule,
+		uploads:        cfg.Uploads,
 		logger:         cfg.Logger,
 	}
 }
@@ -89,9 +126,18 @@ func (h *Handler) HandleMediaProcess(ctx context.Context, task *asynq.Task) erro
 		zap.Bool("is_merge", isMerge),
 	)
 
-	// Update job status to processing
+	// Update job status to processing (refused if the job was cancelled while queued)
 	if h.jobsModule != nil {
-		h.jobsModule.UpdateProgress(ctx, payload.JobID, 0, "Starting...", 0)
+		err := h.jobsModule.UpdateProgress(ctx, payload.JobID, Progress{Percent: 0, CurrentOperation: "Starting..."})
+		if errors.Is(err, ErrJobCancelled) {
+			h.logger.Info("Skipping cancelled job", zap.String("job_id", payload.JobID))
+			return fmt.Errorf("job %s cancelled: %w", payload.JobID, asynq.SkipRetry)
+		}
+		if retry, ok := asynq.GetRetryCount(ctx); ok && retry > 0 {
+			h.jobsModule.Log(ctx, payload.JobID, LogStageJob, LogInfo, "Processing started (retry %d)", retry)
+		} else {
+			h.jobsModule.Log(ctx, payload.JobID, LogStageJob, LogInfo, "Processing started")
+		}
 	}
 
 	var inputPath, outputPath string
@@ -99,6 +145,8 @@ func (h *Handler) HandleMediaProcess(ctx context.Context, task *asynq.Task) erro
 
 	// For remote storage: download inputs to temp, use temp for output
 	if h.storage.IsRemote() {
+		downloadStarted := time.Now()
+		h.logStage(ctx, payload.JobID, LogStageDownload, LogInfo, "Downloading input files")
 		if isMerge {
 			// Download all inputs for merge
 			localInputs := make([]string, len(payload.InputPaths))
@@ -128,6 +176,29 @@ func (h *Handler) HandleMediaProcess(ctx context.Context, task *asynq.Task) erro
 			cleanups = append(cleanups, cleanup)
 			inputPath = local
 		}
+		// Files referenced from operation params (e.g. an added audio track) are inputs too
+		for _, op := range payload.Operations {
+			name, ok := FileParams[op.Type]
+			if !ok {
+				continue
+			}
+			remotePath, ok := op.Params[name].(string)
+			if !ok || remotePath == "" {
+				continue
+			}
+			local, cleanup, err := h.storage.PrepareInputForProcessing(ctx, remotePath)
+			if err != nil {
+				for _, c := range cleanups {
+					c()
+				}
+				if h.jobsModule != nil {
+					h.jobsModule.FailJob(ctx, payload.JobID, err, true)
+				}
+				return err
+			}
+			cleanups = append(cleanups, cleanup)
+			op.Params[name] = local
+		}
 		// Temp output path for FFmpeg
 		tmpDir := filepath.Join(os.TempDir(), "conv")
 		if err := os.MkdirAll(tmpDir, 0755); err != nil {
@@ -140,6 +211,8 @@ func (h *Handler) HandleMediaProcess(ctx context.Context, task *asynq.Task) erro
 			return err
 		}
 		outputPath = filepath.Join(tmpDir, filepath.Base(payload.OutputPath))
+		h.logStage(ctx, payload.JobID, LogStageDownload, LogInfo, "Downloaded %d input file(s) in %s",
+			len(cleanups), time.Since(downloadStarted).Round(time.Millisecond))
 		if !isMerge {
 			payload.InputPath = inputPath
 		}
@@ -163,62 +236,120 @@ func (h *Handler) HandleMediaProcess(ctx context.Context, task *asynq.Task) erro
 	}()
 
 	var err error
+	var result *MediaProcessResult
 
+	// Every FFmpeg run goes into the job's log with the end of its stderr
+	var onCommand func(CommandRun)
+	if h.jobsModule != nil {
+		onCommand = func(run CommandRun) { h.jobsModule.LogCommand(ctx, payload.JobID, run) }
+	}
+
+	encodeStarted := time.Now()
+	h.logStage(ctx, payload.JobID, LogStageEncode, LogInfo, "Encoding started: %s", describeJobOperations(payload.Operations))
 	useGPU := payload.UseGPU
 	if isMerge {
 		// Execute merge operation
+		var mergeParams map[string]interface{}
+		for _, op := range payload.Operations {
+			if op.Type == "merge" {
+				mergeParams = op.Params
+			}
+		}
 		err = h.mediaProcessor.ProcessMerge(ctx, MergeProcessOptions{
+			JobID:            payload.JobID,
 			InputPaths:       payload.InputPaths,
 			OutputPath:       payload.OutputPath,
+			Params:           mergeParams,
 			UseHardwareAccel: &useGPU,
-			OnProgress: func(percent int, operation string) {
+			OnCommand:        onCommand,
+			OnProgress: func(progress Progress) {
 				h.logger.Debug("Merge processing progress",
 					zap.String("job_id", payload.JobID),
-					zap.Int("percent", percent),
-					zap.String("operation", operation),
+					zap.Int("percent", progress.Percent),
+					zap.String("operation", progress.CurrentOperation),
+					zap.Float64("speed", progress.Speed),
+					zap.Int("eta", progress.ETA),
 				)
 				if h.jobsModule != nil {
-					h.jobsModule.UpdateProgress(ctx, payload.JobID, percent, operation, 0)
+					h.jobsModule.UpdateProgress(ctx, payload.JobID, progress)
 				}
 			},
 		})
 	} else {
 		// Execute regular media processing with progress callback
-		err = h.mediaProcessor.Process(ctx, MediaProcessOptions{
+		result, err = h.mediaProcessor.Process(ctx, MediaProcessOptions{
+			JobID:            payload.JobID,
 			InputPath:        payload.InputPath,
 			OutputPath:       payload.OutputPath,
 			Operations:       payload.Operations,
 			UseHardwareAccel: &useGPU,
-			OnProgress: func(percent int, operation string) {
+			OnCommand:        onCommand,
+			OnProgress: func(progress Progress) {
 				h.logger.Debug("Media processing progress",
 					zap.String("job_id", payload.JobID),
-					zap.Int("percent", percent),
-					zap.String("operation", operation),
+					zap.Int("percent", progress.Percent),
+					zap.String("operation", progress.CurrentOperation),
+					zap.Float64("speed", progress.Speed),
+					zap.Int("eta", progress.ETA),
 				)
 				// Update progress in jobs module (which broadcasts via WebSocket)
 				if h.jobsModule != nil {
-					h.jobsModule.UpdateProgress(ctx, payload.JobID, percent, operation, 0)
+					h.jobsModule.UpdateProgress(ctx, payload.JobID, progress)
 				}
 			},
 		})
 	}
 
+	// Status updates must still go through if the task context was cancelled or timed out
+	statusCtx := context.WithoutCancel(ctx)
+
+	if h.isCancelled(statusCtx, payload.JobID) {
+		// Cancelled mid-run: FFmpeg was killed (or finished anyway); drop whatever it wrote
+		h.logger.Info("Job cancelled during processing, discarding output", zap.String("job_id", payload.JobID))
+		removePartialOutput(payload.OutputPath)
+		if result != nil && result.Dir != "" {
+			// Packages, split parts and waveforms are written to a directory of their own
+			os.RemoveAll(result.Dir)
+		}
+		return fmt.Errorf("job %s cancelled: %w", payload.JobID, asynq.SkipRetry)
+	}
+
 	if err != nil {
 		h.logger.Error("Media processing failed",
 			zap.String("job_id", payload.JobID),
 			zap.Error(err),
 		)
+		h.logStage(statusCtx, payload.JobID, LogStageEncode, LogError, "Encoding failed after %s", time.Since(encodeStarted).Round(time.Millisecond))
+		removePartialOutput(payload.OutputPath)
 		if h.jobsModule != nil {
-			h.jobsModule.FailJob(ctx, payload.JobID, err, true)
+			h.jobsModule.FailJob(statusCtx, payload.JobID, err, true)
 		}
 		return err
 	}
+	h.logStage(ctx, payload.JobID, LogStageEncode, LogInfo, "Encoding finished in %s", time.Since(encodeStarted).Round(time.Millisecond))
+
+	if result != nil && len(result.Outputs) > 0 {
+		return h.completeWithOutputs(ctx, statusCtx, payload.JobID, result)
+	}
 
 	// For remote storage: upload output to S3, get size from local first
 	storagePath := payload.OutputPath
 	var outputSize int64
-
-	if h.storage.IsRemote() {
+	var artifacts []storedArtifact
+
+	uploadStarted := time.Now()
+	if result != nil && len(result.Artifacts) > 0 {
+		// Packaged outputs are many files stored under the job's ID
+		h.logStage(ctx, payload.JobID, LogStageUpload, LogInfo, "Uploading %d packaged file(s)", len(result.Artifacts))
+		storagePath, outputSize, artifacts, err = h.storeArtifacts(ctx, payload.JobID, result)
+		if err != nil {
+			h.logger.Error("Failed to store packaged output", zap.Error(err))
+			if h.jobsModule != nil {
+				h.jobsModule.FailJob(ctx, payload.JobID, err, true)
+			}
+			return err
+		}
+	} else if h.storage.IsRemote() {
 		localOutputPath := payload.OutputPath
 		outputInfo, statErr := os.Stat(localOutputPath)
 		if statErr != nil {
@@ -230,6 +361,7 @@ func (h *Handler) HandleMediaProcess(ctx context.Context, task *asynq.Task) erro
 		}
 		outputSize = outputInfo.Size()
 		storagePath = h.storage.GetPath(storage.ZoneOutput, filepath.Base(payload.OutputPath))
+		h.logStage(ctx, payload.JobID, LogStageUpload, LogInfo, "Uploading output (%d bytes)", outputSize)
 		if err := h.storage.FinalizeOutputFromLocal(ctx, storagePath, localOutputPath); err != nil {
 			h.logger.Error("Failed to upload output to storage", zap.Error(err))
 			if h.jobsModule != nil {
@@ -249,6 +381,9 @@ func (h *Handler) HandleMediaProcess(ctx context.Context, task *asynq.Task) erro
 		}
 		outputSize = outputInfo.Size()
 	}
+	if h.storage.IsRemote() || len(artifacts) > 0 {
+		h.logStage(ctx, payload.JobID, LogStageUpload, LogInfo, "Uploaded %d bytes in %s", outputSize, time.Since(uploadStarted).Round(time.Millisecond))
+	}
 
 	// Insert output file into database, inheriting user_id from the job
 	outputFileID := payload.JobID
@@ -267,10 +402,20 @@ func (h *Handler) HandleMediaProcess(ctx context.Context, task *asynq.Task) erro
 	_, err = h.db.Pool.Exec(ctx, `
 		INSERT INTO files (id, original_name, storage_path, mime_type, size_bytes, zone, media_type, user_id, expires_at, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
-	`, outputFileID, outputFileName, storagePath, mimeType, outputSize, "output", mediaType, jobUserID, time.Now().Add(24*time.Hour))
+	`, outputFileID, outputFileName, storagePath, mimeType, outputSize, "output", nullString(mediaType), jobUserID, time.Now().Add(24*time.Hour))
 	if err != nil {
 		h.logger.Error("Failed to insert output file into database", zap.Error(err))
 		// Don't fail the job, the file exists
+	} else if len(artifacts) > 0 {
+		// Without its artifact records a package's playlists and segments can't be served
+		if err := h.recordArtifacts(ctx, outputFileID, artifacts); err != nil {
+			h.logger.Error("Failed to record packaged output files", zap.Error(err))
+			h.discardOutputFile(statusCtx, outputFileID, storagePath)
+			if h.jobsModule != nil {
+				h.jobsModule.FailJob(statusCtx, payload.JobID, err, true)
+			}
+			return err
+		}
 	}
 
 	// Mark job as completed
@@ -279,7 +424,12 @@ func (h *Handler) HandleMediaProcess(ctx context.Context, task *asynq.Task) erro
 			zap.String("job_id", payload.JobID),
 			zap.String("output_file_id", outputFileID),
 		)
-		if err := h.jobsModule.CompleteJob(ctx, payload.JobID, outputFileID); err != nil {
+		if err := h.jobsModule.CompleteJob(ctx, payload.JobID, []string{outputFileID}); errors.Is(err, ErrJobCancelled) {
+			// Cancelled between FFmpeg finishing and now: the output must not outlive the job
+			h.logger.Info("Job cancelled before completion, removing output", zap.String("job_id", payload.JobID))
+			h.discardOutputFile(statusCtx, outputFileID, storagePath)
+			return fmt.Errorf("job %s cancelled: %w", payload.JobID, asynq.SkipRetry)
+		} else if err != nil {
 			h.logger.Error("Failed to mark job as completed", zap.Error(err))
 		} else {
 			h.logger.Info("Job marked as completed successfully", zap.String("job_id", payload.JobID))
@@ -297,6 +447,326 @@ func (h *Handler) HandleMediaProcess(ctx context.Context, task *asynq.Task) erro
 	return nil
 }
 
+// completeWithOutputs stores the separate output files of a job (e.g. the
+// parts of a split) under the job's ID, registers each as a file of its own
+// and completes the job with all of them
+func (h *Handler) completeWithOutputs(ctx, statusCtx context.Context, jobID string, result *MediaProcessResult) error {
+	fail := func(err error) error {
+		if h.jobsModule != nil {
+			h.jobsModule.FailJob(statusCtx, jobID, err, true)
+		}
+		return err
+	}
+
+	uploadStarted := time.Now()
+	h.logStage(ctx, jobID, LogStageUpload, LogInfo, "Uploading %d output file(s)", len(result.Outputs))
+	_, total, stored, err := h.storeArtifacts(ctx, jobID, &MediaProcessResult{Dir: result.Dir, Artifacts: result.Outputs})
+	if err != nil {
+		h.logger.Error("Failed to store output files", zap.Error(err))
+		return fail(err)
+	}
+	h.logStage(ctx, jobID, LogStageUpload, LogInfo, "Uploaded %d bytes in %s", total, time.Since(uploadStarted).Round(time.Millisecond))
+
+	// Parts are named after the requested output name, like single outputs
+	var jobUserID *string
+	var baseName string
+	if err := h.db.Pool.QueryRow(ctx, `SELECT user_id, COALESCE(output_file_name, '') FROM jobs WHERE id = $1`, jobID).Scan(&jobUserID, &baseName); err != nil {
+		h.logger.Warn("Could not look up job user_id for output files", zap.Error(err), zap.String("job_id", jobID))
+	}
+	stem := strings.TrimSuffix(baseName, filepath.Ext(baseName))
+	if stem == "" {
+		stem = jobID
+	}
+
+	fileIDs := make([]string, 0, len(stored))
+	discard := func() {
+		for i, a := range stored {
+			if i < len(fileIDs) {
+				h.discardOutputFile(statusCtx, fileIDs[i], a.StoragePath)
+			} else if err := h.storage.Delete(statusCtx, a.StoragePath); err != nil {
+				h.logger.Warn("Failed to delete output from storage", zap.String("path", a.StoragePath), zap.Error(err))
+			}
+		}
+	}
+
+	for i, a := range stored {
+		fileID := uuid.New().String()
+		ext := strings.ToLower(filepath.Ext(a.Name))
+		mimeType, mediaType := detectMimeType(ext)
+		name := fmt.Sprintf("%s_part%03d%s", stem, i+1, ext)
+		_, err := h.db.Pool.Exec(ctx, `
+			INSERT INTO files (id, original_name, storage_path, mime_type, size_bytes, zone, media_type, user_id, expires_at, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		`, fileID, name, a.StoragePath, mimeType, a.Size, "output", nullString(mediaType), jobUserID, time.Now().Add(24*time.Hour))
+		if err != nil {
+			// Unlike a single output, parts without a record can't be downloaded at all
+			h.logger.Error("Failed to insert output file into database", zap.Error(err))
+			discard()
+			return fail(fmt.Errorf("failed to record output file %s: %w", name, err))
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+
+	if h.jobsModule != nil {
+		h.logger.Info("Marking job as completed",
+			zap.String("job_id", jobID),
+			zap.Strings("output_file_ids", fileIDs),
+		)
+		if err := h.jobsModule.CompleteJob(ctx, jobID, fileIDs); errors.Is(err, ErrJobCancelled) {
+			h.logger.Info("Job cancelled before completion, removing outputs", zap.String("job_id", jobID))
+			discard()
+			return fmt.Errorf("job %s cancelled: %w", jobID, asynq.SkipRetry)
+		} else if err != nil {
+			h.logger.Error("Failed to mark job as completed", zap.Error(err))
+		}
+	}
+
+	h.logger.Info("Media processing completed",
+		zap.String("job_id", jobID),
+		zap.Int("outputs", len(fileIDs)),
+		zap.Int64("output_size", total),
+	)
+	return nil
+}
+
+// logStage records a pipeline event in the job's log
+func (h *Handler) logStage(ctx context.Context, jobID, stage, level, format string, args ...interface{}) {
+	if h.jobsModule != nil {
+		h.jobsModule.Log(ctx, jobID, stage, level, format, args...)
+	}
+}
+
+// describeJobOperations lists a job's operation types for its log
+func describeJobOperations(ops []Operation) string {
+	if len(ops) == 0 {
+		return "transcode"
+	}
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = op.Type
+	}
+	return strings.Join(names, ", ")
+}
+
+// isCancelled reports whether the job was cancelled by the user
+func (h *Handler) isCancelled(ctx context.Context, jobID string) bool {
+	return h.jobsModule != nil && h.jobsModule.IsCancelled(ctx, jobID)
+}
+
+// removePartialOutput deletes a local FFmpeg output left behind by a failed or killed run
+func removePartialOutput(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		zap.L().Debug("Failed to remove partial output", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// storedArtifact is a file of a packaged output after it has been moved into storage
+type storedArtifact struct {
+	Name        string
+	StoragePath string
+	MimeType    string
+	Size        int64
+}
+
+// storeArtifacts moves every file of a packaged output into ZoneOutput under
+// dir (the job's ID for job outputs), keeping their relative layout so manifest
+// URIs still resolve. It returns the entry's storage path and the package's total size.
+func (h *Handler) storeArtifacts(ctx context.Context, dir string, result *MediaProcessResult) (string, int64, []storedArtifact, error) {
+	var entryPath string
+	var total int64
+	stored := make([]storedArtifact, 0, len(result.Artifacts))
+
+	for _, a := range result.Artifacts {
+		info, err := os.Stat(a.Path)
+		if err != nil {
+			return "", 0, nil, fmt.Errorf("packaged file %s not found: %w", a.Name, err)
+		}
+		storagePath := h.storage.GetPath(storage.ZoneOutput, dir+"/"+a.Name)
+		if err := h.storage.FinalizeOutputFromLocal(ctx, storagePath, a.Path); err != nil {
+			return "", 0, nil, fmt.Errorf("failed to upload %s: %w", a.Name, err)
+		}
+
+		mimeType, _ := detectMimeType(strings.ToLower(filepath.Ext(a.Name)))
+		stored = append(stored, storedArtifact{Name: a.Name, StoragePath: storagePath, MimeType: mimeType, Size: info.Size()})
+		total += info.Size()
+		if a.Name == result.Entry {
+			entryPath = storagePath
+		}
+	}
+
+	if h.storage.IsRemote() {
+		os.RemoveAll(result.Dir) // Clean up temp
+	}
+	return entryPath, total, stored, nil
+}
+
+// recordArtifacts registers the files of a packaged output against its file record
+func (h *Handler) recordArtifacts(ctx context.Context, fileID string, artifacts []storedArtifact) error {
+	for _, a := range artifacts {
+		_, err := h.db.Pool.Exec(ctx, `
+			INSERT INTO file_artifacts (file_id, name, storage_path, mime_type, size_bytes)
+			VALUES ($1, $2, $3, $4, $5)
+		`, fileID, a.Name, a.StoragePath, a.MimeType, a.Size)
+		if err != nil {
+			return fmt.Errorf("failed to record %s: %w", a.Name, err)
+		}
+	}
+	return nil
+}
+
+// deleteArtifacts removes the stored files of a packaged output, except the
+// entry at skipPath which the caller deletes with the file itself
+func (h *Handler) deleteArtifacts(ctx context.Context, fileID, skipPath string) {
+	rows, err := h.db.Pool.Query(ctx, "SELECT storage_path FROM file_artifacts WHERE file_id = $1", fileID)
+	if err != nil {
+		h.logger.Warn("Failed to list packaged output files", zap.String("file_id", fileID), zap.Error(err))
+		return
+	}
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err == nil && p != skipPath {
+			paths = append(paths, p)
+		}
+	}
+	rows.Close()
+
+	for _, p := range paths {
+		if err := h.storage.Delete(ctx, p); err != nil {
+			h.logger.Warn("Failed to delete packaged output file", zap.String("path", p), zap.Error(err))
+		}
+	}
+}
+
+// discardOutputFile removes a registered output file from storage and the database
+func (h *Handler) discardOutputFile(ctx context.Context, fileID, storagePath string) {
+	h.deleteArtifacts(ctx, fileID, storagePath)
+	if err := h.storage.Delete(ctx, storagePath); err != nil {
+		h.logger.Warn("Failed to delete output from storage", zap.String("path", storagePath), zap.Error(err))
+	}
+	if _, err := h.db.Pool.Exec(ctx, "DELETE FROM files WHERE id = $1", fileID); err != nil {
+		h.logger.Warn("Failed to delete output file record", zap.String("file_id", fileID), zap.Error(err))
+	}
+}
+
+// HandleGenerateThumbnails renders the poster frames, preview sprite and
+// storyboard of an uploaded video and records them as artifacts of its file,
+// so they are deleted along with it
+func (h *Handler) HandleGenerateThumbnails(ctx context.Context, task *asynq.Task) error {
+	var payload ThumbnailPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	var storagePath string
+	err := h.db.Pool.QueryRow(ctx, "SELECT storage_path FROM files WHERE id = $1", payload.FileID).Scan(&storagePath)
+	if err != nil {
+		// Deleted or expired before its turn came
+		h.logger.Info("Skipping thumbnails of missing file", zap.String("file_id", payload.FileID))
+		return fmt.Errorf("file %s not found: %w", payload.FileID, asynq.SkipRetry)
+	}
+
+	inputPath, cleanup, err := h.storage.PrepareInputForProcessing(ctx, storagePath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	dir := "thumbnails/" + payload.FileID
+	result, err := h.mediaProcessor.GenerateThumbnails(ctx, inputPath, h.previewOutputDir(dir))
+	if err != nil {
+		h.logger.Error("Thumbnail generation failed", zap.String("file_id", payload.FileID), zap.Error(err))
+		return err
+	}
+	count, err := h.recordPreviews(ctx, payload.FileID, dir, ThumbnailArtifactPrefix, result)
+	if err != nil {
+		return fmt.Errorf("failed to record thumbnails: %w", err)
+	}
+
+	h.logger.Info("Thumbnails generated",
+		zap.String("file_id", payload.FileID),
+		zap.Int("files", count),
+	)
+	return nil
+}
+
+// HandleGenerateWaveform computes the waveform peaks of a file's audio at one
+// resolution and records them as artifacts of the file
+func (h *Handler) HandleGenerateWaveform(ctx context.Context, task *asynq.Task) error {
+	var payload WaveformPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	var storagePath string
+	err := h.db.Pool.QueryRow(ctx, "SELECT storage_path FROM files WHERE id = $1", payload.FileID).Scan(&storagePath)
+	if err != nil {
+		h.logger.Info("Skipping waveform of missing file", zap.String("file_id", payload.FileID))
+		return fmt.Errorf("file %s not found: %w", payload.FileID, asynq.SkipRetry)
+	}
+
+	inputPath, cleanup, err := h.storage.PrepareInputForProcessing(ctx, storagePath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	dir := "waveforms/" + payload.FileID + "/" + payload.Variant
+	result, err := h.mediaProcessor.GenerateWaveform(ctx, inputPath, h.previewOutputDir(dir), payload.WaveformOptions)
+	if err != nil {
+		h.logger.Error("Waveform generation failed", zap.String("file_id", payload.FileID), zap.Error(err))
+		return err
+	}
+	if _, err := h.recordPreviews(ctx, payload.FileID, dir, WaveformArtifactPrefix+payload.Variant+"/", result); err != nil {
+		return fmt.Errorf("failed to record waveform: %w", err)
+	}
+
+	h.logger.Info("Waveform generated",
+		zap.String("file_id", payload.FileID),
+		zap.String("variant", payload.Variant),
+	)
+	return nil
+}
+
+// previewOutputDir returns where a generator writes the files that are stored
+// under dir: in place for local storage, or a temp directory they are uploaded from
+func (h *Handler) previewOutputDir(dir string) string {
+	if h.storage.IsRemote() {
+		return filepath.Join(os.TempDir(), "conv", strings.ReplaceAll(dir, "/", "-"))
+	}
+	return h.storage.GetPath(storage.ZoneOutput, dir)
+}
+
+// recordPreviews stores generated files under dir and records them as artifacts
+// of an upload named prefix + file name, so they are deleted along with it.
+// It returns the number of files recorded.
+func (h *Handler) recordPreviews(ctx context.Context, fileID, dir, prefix string, result *MediaProcessResult) (int, error) {
+	_, _, artifacts, err := h.storeArtifacts(ctx, dir, result)
+	if err != nil {
+		return 0, err
+	}
+	for i := range artifacts {
+		artifacts[i].Name = prefix + artifacts[i].Name
+	}
+
+	// A retry replaces whatever an earlier attempt recorded
+	_, err = h.db.Pool.Exec(ctx, "DELETE FROM file_artifacts WHERE file_id = $1 AND name LIKE $2",
+		fileID, prefix+"%")
+	if err == nil {
+		err = h.recordArtifacts(ctx, fileID, artifacts)
+	}
+	if err != nil {
+		// Most likely the file was deleted meanwhile; don't leave its previews behind
+		for _, a := range artifacts {
+			if delErr := h.storage.Delete(ctx, a.StoragePath); delErr != nil {
+				h.logger.Warn("Failed to delete unrecorded preview", zap.String("path", a.StoragePath), zap.Error(delErr))
+			}
+		}
+		return 0, err
+	}
+	return len(artifacts), nil
+}
+
 // HandleCleanupFiles handles file cleanup tasks - permanently deletes expired files from storage and DB
 func (h *Handler) HandleCleanupFiles(ctx context.Context, task *asynq.Task) error {
 	var payload CleanupPayload
@@ -319,14 +789,23 @@ func (h *Handler) HandleCleanupFiles(ctx context.Context, task *asynq.Task) erro
 	}
 	defer rows.Close()
 
-	var deletedCount int
+	type expiredFile struct{ id, storagePath string }
+	var expired []expiredFile
 	for rows.Next() {
-		var fileID, storagePath string
-		if err := rows.Scan(&fileID, &storagePath); err != nil {
+		var f expiredFile
+		if err := rows.Scan(&f.id, &f.storagePath); err != nil {
 			continue
 		}
+		expired = append(expired, f)
+	}
+	rows.Close()
 
-		// Delete from storage
+	var deletedCount int
+	for _, f := range expired {
+		fileID, storagePath := f.id, f.storagePath
+
+		// Delete from storage, including every file of a packaged output
+		h.deleteArtifacts(ctx, fileID, storagePath)
 		if err := h.storage.Delete(ctx, storagePath); err != nil {
 			h.logger.Warn("Failed to delete file from storage", zap.Error(err), zap.String("path", storagePath))
 		}
@@ -343,6 +822,21 @@ func (h *Handler) HandleCleanupFiles(ctx context.Context, task *asynq.Task) erro
 	return nil
 }
 
+// HandleCleanupUploads removes chunked upload sessions that have expired, deleting any chunks they hold
+func (h *Handler) HandleCleanupUploads(ctx context.Context, task *asynq.Task) error {
+	if h.uploads == nil {
+		return nil
+	}
+
+	removed, err := h.uploads.CleanupExpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info("Upload session cleanup completed", zap.Int("deleted", removed))
+	return nil
+}
+
 // HandleCleanupStaleJobs removes completed/failed jobs older than a threshold.
 // Anonymous user jobs are cleaned up after 7 days, authenticated user jobs after 30 days.
 func (h *Handler) HandleCleanupStaleJobs(ctx context.Context, task *asynq.Task) error {
@@ -470,7 +964,25 @@ func detectMimeType(ext string) (mimeType string, mediaType string) {
 	case ".aiff", ".aif":
 		return "audio/aiff", "audio"
 
-	// Image formats (for thumbnails, GIFs)
+	// Streaming packages
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl", "video"
+	case ".ts":
+		return "video/mp2t", "video"
+	case ".m4s":
+		return "video/iso.segment", "video"
+	case ".mpd":
+		return "application/dash+xml", "video"
+
+	// Subtitle formats
+	case ".srt":
+		return "application/x-subrip", "subtitle"
+	case ".vtt":
+		return "text/vtt", "subtitle"
+	case ".ass", ".ssa":
+		return "text/x-ssa", "subtitle"
+
+	// Image formats
 	case ".gif":
 		return "image/gif", "image"
 	case ".jpg", ".jpeg":
@@ -481,6 +993,12 @@ func detectMimeType(ext string) (mimeType string, mediaType string) {
 		return "image/webp", "image"
 	case ".bmp":
 		return "image/bmp", "image"
+	case ".avif":
+		return "image/avif", "image"
+
+	// Reports (e.g. removeSilence in detectOnly mode) aren't media
+	case ".json":
+		return "application/json", ""
 
 	// Default fallback
 	default:
@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/nextconvert/backend/internal/shared/config"
+	"github.com/nextconvert/backend/internal/shared/storage"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testJobID = "0c6c1d0e-5b7e-4f57-a0d4-2a7f6b1b9e42"
+
+// fakeProcessor runs a test's stand-in for media.Processor.Process
+type fakeProcessor struct {
+	process func(ctx context.Context, opts MediaProcessOptions) (*MediaProcessResult, error)
+}
+
+func (p *fakeProcessor) Process(ctx context.Context, opts MediaProcessOptions) (*MediaProcessResult, error) {
+	return p.process(ctx, opts)
+}
+
+func (p *fakeProcessor) ProcessMerge(context.Context, MergeProcessOptions) error {
+	return errors.New("not implemented")
+}
+
+func (p *fakeProcessor) GenerateThumbnails(context.Context, string, string) (*MediaProcessResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *fakeProcessor) GenerateWaveform(context.Context, string, string, WaveformOptions) (*MediaProcessResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+// newTestHandler returns a handler on local storage whose queries go to the
+// returned mock. Job log writes are accepted without expectations.
+func newTestHandler(t *testing.T, processor MediaProcessorInterface) (*Handler, pgxmock.PgxPoolIface, *usagePool) {
+	module, mock, usage := newTestModule(t)
+	store, err := storage.NewService(config.StorageConfig{Backend: "local", BasePath: t.TempDir()})
+	require.NoError(t, err)
+	module.storage = store
+
+	return NewHandler(HandlerConfig{DB: module.db, Storage: store, MediaProcessor: processor, JobsModule: module, Logger: zap.NewNop()}), mock, usage
+}
+
+func mediaTask(t *testing.T, payload MediaProcessPayload) *asynq.Task {
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	return asynq.NewTask(TypeMediaProcess, data)
+}
+
+// expectJobStarted expects the worker to move the job to processing
+func expectJobStarted(mock pgxmock.PgxPoolIface) {
+	mock.ExpectExec(`UPDATE jobs SET progress`).
+		WithArgs(pgxmock.AnyArg(), StatusProcessing, testJobID, StatusCancelled, StatusCompleted).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+}
+
+// expectJobStatus answers the worker's check for a cancelled job
+func expectJobStatus(mock pgxmock.PgxPoolIface, status string) {
+	mock.ExpectQuery(`SELECT status FROM jobs WHERE id = \$1`).
+		WithArgs(testJobID).
+		WillReturnRows(pgxmock.NewRows([]string{"status"}).AddRow(status))
+}
+
+func TestHandleMediaProcessCancelledAfterPackaging(t *testing.T) {
+	packageDir := filepath.Join(t.TempDir(), testJobID)
+	handler, mock, _ := newTestHandler(t, &fakeProcessor{process: func(_ context.Context, opts MediaProcessOptions) (*MediaProcessResult, error) {
+		// FFmpeg finished the package before the cancel reached the worker
+		require.NoError(t, os.MkdirAll(packageDir, 0755))
+		result := &MediaProcessResult{Dir: packageDir, Entry: "master.m3u8"}
+		for _, name := range []string{"master.m3u8", "segment_000.ts"} {
+			path := filepath.Join(packageDir, name)
+			require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+			result.Artifacts = append(result.Artifacts, OutputArtifact{Name: name, Path: path})
+		}
+		return result, nil
+	}})
+
+	expectJobStarted(mock)
+	expectJobStatus(mock, StatusCancelled)
+
+	err := handler.HandleMediaProcess(context.Background(), mediaTask(t, MediaProcessPayload{
+		JobID:      testJobID,
+		InputPath:  filepath.Join(t.TempDir(), "input.mp4"),
+		OutputPath: filepath.Join(t.TempDir(), testJobID+".m3u8"),
+		Operations: []Operation{{Type: "packageHLS"}},
+	}))
+
+	assert.ErrorIs(t, err, asynq.SkipRetry)
+	assert.NoDirExists(t, packageDir, "a cancelled job's package must not be left on the worker")
+	assert.NoError(t, mock.ExpectationsWereMet(), "nothing is stored or completed")
+}
+
+func TestHandleMediaProcessCancelledBeforeCompletion(t *testing.T) {
+	var outputPath string
+	handler, mock, usage := newTestHandler(t, &fakeProcessor{process: func(_ context.Context, opts MediaProcessOptions) (*MediaProcessResult, error) {
+		return nil, os.WriteFile(opts.OutputPath, []byte("encoded"), 0644)
+	}})
+	outputPath = handler.storage.GetPath(storage.ZoneOutput, testJobID+".mp4")
+
+	expectJobStarted(mock)
+	// FFmpeg exited before the cancel, which then lands while the output is registered
+	expectJobStatus(mock, StatusProcessing)
+	mock.ExpectQuery(`SELECT user_id FROM jobs`).WithArgs(testJobID).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(owner("user_alice")))
+	mock.ExpectExec(`INSERT INTO files`).WithArgs(testJobID, testJobID+".mp4", outputPath, "video/mp4", int64(7), "output", pgxmock.AnyArg(), owner("user_alice"), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	expectCompletion(mock, nil, 0)
+	mock.ExpectQuery(`SELECT storage_path FROM file_artifacts`).WithArgs(testJobID).
+		WillReturnRows(pgxmock.NewRows([]string{"storage_path"}))
+	mock.ExpectExec(`DELETE FROM files WHERE id = \$1`).WithArgs(testJobID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	err := handler.HandleMediaProcess(context.Background(), mediaTask(t, MediaProcessPayload{
+		JobID:      testJobID,
+		InputPath:  filepath.Join(t.TempDir(), "input.mp4"),
+		OutputPath: outputPath,
+		Operations: []Operation{{Type: "resize", Params: map[string]interface{}{"width": 640.0}}},
+	}))
+
+	assert.ErrorIs(t, err, asynq.SkipRetry)
+	assert.NoFileExists(t, outputPath, "the output must not outlive the cancelled job")
+	assert.Empty(t, usage.updates, "a cancelled job isn't charged")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleMediaProcessCancelledWhileQueued(t *testing.T) {
+	handler, mock, _ := newTestHandler(t, &fakeProcessor{process: func(context.Context, MediaProcessOptions) (*MediaProcessResult, error) {
+		t.Fatal("a cancelled job must not be processed")
+		return nil, nil
+	}})
+
+	// The cancel landed before a worker picked the task up
+	mock.ExpectExec(`UPDATE jobs SET progress`).
+		WithArgs(pgxmock.AnyArg(), StatusProcessing, testJobID, StatusCancelled, StatusCompleted).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	err := handler.HandleMediaProcess(context.Background(), mediaTask(t, MediaProcessPayload{
+		JobID:      testJobID,
+		OutputPath: filepath.Join(t.TempDir(), testJobID+".mp4"),
+	}))
+	assert.ErrorIs(t, err, asynq.SkipRetry)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
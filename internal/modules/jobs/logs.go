@@ -0,0 +1,194 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Job log stages
+const (
+	LogStageJob      = "job"
+	LogStageDownload = "download"
+	LogStageEncode   = "encode"
+	LogStageUpload   = "upload"
+)
+
+// Job log levels
+const (
+	LogInfo    = "info"
+	LogError   = "error"
+	LogCommand = "command" // An FFmpeg command line
+	LogStderr  = "stderr"  // A line FFmpeg wrote to stderr
+)
+
+// Job log page sizes
+const (
+	DefaultLogPageSize = 100
+	MaxLogPageSize     = 500
+)
+
+// LogEntry is one line of a job's log
+type LogEntry struct {
+	ID        int64     `json:"id"`
+	Stage     string    `json:"stage"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LogPage is a page of a job's log, oldest entries first
+type LogPage struct {
+	Logs    []LogEntry `json:"logs"`
+	Next    int64      `json:"next,omitempty"` // Pass as ?after= to read the following page
+	HasMore bool       `json:"hasMore"`
+}
+
+// CommandRun mirrors media.CommandLog to avoid import
+type CommandRun struct {
+	Operation string
+	Args      []string
+	Stderr    []string
+	Dropped   int
+	Err       error
+	Duration  time.Duration
+}
+
+// Log records a pipeline event in a job's log
+func (m *Module) Log(ctx context.Context, jobID, stage, level, format string, args ...interface{}) {
+	m.appendLogs(ctx, jobID, LogEntry{Stage: stage, Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+// LogCommand records an FFmpeg run in a job's log: its command line, the tail
+// of its stderr and how it ended
+func (m *Module) LogCommand(ctx context.Context, jobID string, run CommandRun) {
+	entries := []LogEntry{{Stage: LogStageEncode, Level: LogCommand, Message: "ffmpeg " + quoteArgs(run.Args)}}
+	if run.Dropped > 0 {
+		entries = append(entries, LogEntry{Stage: LogStageEncode, Level: LogStderr, Message: fmt.Sprintf("[%d earlier lines omitted]", run.Dropped)})
+	}
+	for _, line := range run.Stderr {
+		entries = append(entries, LogEntry{Stage: LogStageEncode, Level: LogStderr, Message: line})
+	}
+	if run.Err != nil {
+		entries = append(entries, LogEntry{Stage: LogStageEncode, Level: LogError,
+			Message: fmt.Sprintf("%s: FFmpeg failed after %s: %v", run.Operation, run.Duration.Round(time.Millisecond), run.Err)})
+	} else {
+		entries = append(entries, LogEntry{Stage: LogStageEncode, Level: LogInfo,
+			Message: fmt.Sprintf("%s: FFmpeg finished in %s", run.Operation, run.Duration.Round(time.Millisecond))})
+	}
+	m.appendLogs(ctx, jobID, entries...)
+}
+
+// appendLogs stores log entries with secrets and paths redacted. Logs are
+// best effort: a failed write only shows up in the worker's own log.
+func (m *Module) appendLogs(ctx context.Context, jobID string, entries ...LogEntry) {
+	// Entries about a cancelled or timed-out run matter the most
+	ctx = context.WithoutCancel(ctx)
+
+	batch := &pgx.Batch{}
+	for _, e := range entries {
+		batch.Queue(`INSERT INTO job_logs (job_id, stage, level, message) VALUES ($1, $2, $3, $4)`,
+			jobID, e.Stage, e.Level, redactLogMessage(e.Message))
+	}
+	if err := m.db.Pool.SendBatch(ctx, batch).Close(); err != nil {
+		m.logger.Warn("Failed to write job log", zap.String("job_id", jobID), zap.Error(err))
+	}
+}
+
+// GetJobLogs returns up to limit log entries of a job written after the entry
+// with ID after (0 for the first page)
+func (m *Module) GetJobLogs(ctx context.Context, jobID string, after int64, limit int) (*LogPage, error) {
+	if _, err := m.getJobFromDB(ctx, jobID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultLogPageSize
+	}
+	if limit > MaxLogPageSize {
+		limit = MaxLogPageSize
+	}
+
+	// One extra row tells whether there is another page
+	rows, err := m.db.Pool.Query(ctx, `
+		SELECT id, stage, level, message, created_at
+		FROM job_logs WHERE job_id = $1 AND id > $2
+		ORDER BY id LIMIT $3
+	`, jobID, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &LogPage{Logs: []LogEntry{}}
+	for rows.Next() {
+		var e LogEntry
+		if err := rows.Scan(&e.ID, &e.Stage, &e.Level, &e.Message, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		page.Logs = append(page.Logs, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Logs) > limit {
+		page.Logs = page.Logs[:limit]
+		page.HasMore = true
+	}
+	if len(page.Logs) > 0 {
+		page.Next = page.Logs[len(page.Logs)-1].ID
+	}
+	return page, nil
+}
+
+// quoteArgs joins a command line, quoting arguments that contain spaces or quotes
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t'\"") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+var (
+	// URLs of remote storage carry signatures and credentials
+	logURLPattern = regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s'"]+`)
+	// password=..., api_key: ..., Authorization: Bearer ...
+	logSecretPattern = regexp.MustCompile(`(?i)\b((?:password|passwd|secret|token|signature|credential|api[_-]?key|access[_-]?key|authorization)[a-z_-]*\s*[=:]\s*)(?:(?:bearer|basic)\s+)?(?:"[^"]*"|'[^']*'|[^\s&,;'"]+)`)
+	logBearerPattern = regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9._~+/=-]+`)
+	// Absolute paths give away the worker's layout and other users' file names
+	logPathPattern = regexp.MustCompile(`(^|[\s'"=(,\[])(/[^\s'":,)\]]+)`)
+)
+
+// redactLogMessage strips secrets from a log line and cuts URLs and absolute
+// paths down to their file names
+func redactLogMessage(msg string) string {
+	msg = logURLPattern.ReplaceAllStringFunc(msg, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return "[REDACTED URL]"
+		}
+		name := path.Base(u.Path)
+		if name == "/" || name == "." {
+			return u.Scheme + "://" + u.Hostname()
+		}
+		return u.Scheme + "://" + u.Hostname() + "/.../" + name
+	})
+	msg = logSecretPattern.ReplaceAllString(msg, "${1}[REDACTED]")
+	msg = logBearerPattern.ReplaceAllString(msg, "Bearer [REDACTED]")
+	return logPathPattern.ReplaceAllStringFunc(msg, func(match string) string {
+		sub := logPathPattern.FindStringSubmatch(match)
+		return sub[1] + filepath.Base(sub[2])
+	})
+}
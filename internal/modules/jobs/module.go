@@ -3,6 +3,7 @@ package jobs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -10,9 +11,11 @@ import (
 
 	"github.com/nextconvert/backend/internal/api/websocket"
 	"github.com/nextconvert/backend/internal/modules/subscription"
+	"github.com/nextconvert/backend/internal/shared/authz"
 	"github.com/nextconvert/backend/internal/shared/database"
 	"github.com/nextconvert/backend/internal/shared/storage"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 )
 
@@ -34,17 +37,37 @@ type Job struct {
 	Priority       int         `json:"priority"`
 	InputFileID    string      `json:"inputFileId"`
 	InputFilePath  string      `json:"inputFilePath,omitempty"`
-	OutputFileID   string      `json:"outputFileId,omitempty"`
+	OutputFileID   string      `json:"outputFileId,omitempty"`  // First output file
+	OutputFileIDs  []string    `json:"outputFileIds,omitempty"` // Every output file of a multi-output job, in order
+	Outputs        []Output    `json:"outputs,omitempty"`       // Details of OutputFileIDs, for downloads
 	Operations     []Operation `json:"operations"`
 	OutputFormat   string      `json:"outputFormat"`
 	OutputFileName string      `json:"outputFileName"`
 	Progress       Progress    `json:"progress"`
+	Artifacts      []Artifact  `json:"artifacts,omitempty"` // Files of a packaged output (HLS, DASH)
 	Error          *JobError   `json:"error,omitempty"`
 	CreatedAt      time.Time   `json:"createdAt"`
 	StartedAt      *time.Time  `json:"startedAt,omitempty"`
 	CompletedAt    *time.Time  `json:"completedAt,omitempty"`
 }
 
+// Artifact is one file of a job's packaged output, downloadable from
+// /files/{outputFileId}/download/{name}
+type Artifact struct {
+	Name      string `json:"name"`
+	MimeType  string `json:"mimeType"`
+	SizeBytes int64  `json:"sizeBytes"`
+}
+
+// Output is one output file of a multi-output job (e.g. a part of a split),
+// downloadable from /files/{fileId}/download
+type Output struct {
+	FileID    string `json:"fileId"`
+	Name      string `json:"name"`
+	MimeType  string `json:"mimeType"`
+	SizeBytes int64  `json:"sizeBytes"`
+}
+
 // Operation represents a media operation
 type Operation struct {
 	Type   string                 `json:"type"`
@@ -53,9 +76,10 @@ type Operation struct {
 
 // Progress represents job progress
 type Progress struct {
-	Percent          int    `json:"percent"`
-	CurrentOperation string `json:"currentOperation,omitempty"`
-	ETA              int    `json:"eta,omitempty"`
+	Percent          int     `json:"percent"`
+	CurrentOperation string  `json:"currentOperation,omitempty"`
+	Speed            float64 `json:"speed,omitempty"` // Encoding speed relative to realtime
+	ETA              int     `json:"eta,omitempty"`   // Estimated seconds remaining
 }
 
 // JobError represents a job error
@@ -65,6 +89,29 @@ type JobError struct {
 	Retryable bool   `json:"retryable"`
 }
 
+// FileParams names the operation parameters that reference another uploaded
+// file by ID; CreateJob resolves them to storage paths
+var FileParams = map[string]string{
+	"addAudio":     "audioPath",
+	"addSubtitles": "subtitlePath",
+	"overlay":      "overlayPath",
+}
+
+// unmeteredOperations copy a stream out of the input without encoding, and
+// aren't charged conversion minutes when they make up the whole job
+var unmeteredOperations = map[string]bool{
+	"extractSubtitles": true,
+}
+
+func unmetered(ops []Operation) bool {
+	for _, op := range ops {
+		if !unmeteredOperations[op.Type] {
+			return false
+		}
+	}
+	return len(ops) > 0
+}
+
 // CreateJobParams contains parameters for creating a job
 type CreateJobParams struct {
 	UserID                string
@@ -73,8 +120,63 @@ type CreateJobParams struct {
 	Operations            []Operation
 	OutputFormat          string
 	OutputFileName        string
-	InputDurationSeconds  float64 // From probe, for conversion minutes
-	ConversionMinutes     int     // ceil(duration/60), or 1 for audio/image
+}
+
+// ErrJobCancelled is returned when a state change is refused because the job was cancelled
+var ErrJobCancelled = errors.New("job was cancelled")
+
+// ErrNoDecodableStreams is returned when an input file has no audio or video stream FFmpeg can read
+var ErrNoDecodableStreams = errors.New("input file has no decodable audio or video streams")
+
+// ErrInvalidOperations is returned when a job's operations can't run on its input or output format
+var ErrInvalidOperations = errors.New("invalid operations")
+
+// OperationValidator checks an operation chain against the operation registry,
+// the output container and the workers' FFmpeg build, returning the problems found.
+// Implemented by an adapter around media.Module.
+type OperationValidator interface {
+	ValidateOperations(ctx context.Context, operations []Operation, inputType, outputFormat string) []string
+}
+
+// MediaProber probes a file and persists its media metadata on the files row.
+// Implemented by media.Module; used for files uploaded before probing at upload time.
+type MediaProber interface {
+	ProbeFile(ctx context.Context, fileID string) error
+}
+
+// inputFile is an input file with the server-side probe results stored at upload
+type inputFile struct {
+	StoragePath  string
+	OriginalName string
+	MediaType    *string
+	Metadata     struct {
+		Duration float64 `json:"duration"`
+		Width    int     `json:"width"`
+		Height   int     `json:"height"`
+		Streams  *[]struct {
+			Type  string `json:"type"`
+			Codec string `json:"codec"`
+		} `json:"streams"` // nil when the file has never been probed
+	}
+}
+
+// hasDecodableStreams mirrors media.MediaInfo.HasDecodableStreams
+func (f *inputFile) hasDecodableStreams() bool {
+	if f.Metadata.Streams == nil {
+		return false
+	}
+	for _, s := range *f.Metadata.Streams {
+		if (s.Type == "video" || s.Type == "audio") && s.Codec != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// stillImage reports whether the input is a single picture. Animated images
+// (GIF, WebP) have a duration and are charged like video.
+func (f *inputFile) stillImage() bool {
+	return f.MediaType != nil && *f.MediaType == "image" && f.Metadata.Duration < 1
 }
 
 // Module handles job management
@@ -84,6 +186,10 @@ type Module struct {
 	storage   *storage.Service
 	queue     *QueueClient
 	wsHub     *websocket.Hub
+	events    *EventBus // Publishes job events to all API replicas (nil without Redis)
+	prober    MediaProber
+	validator OperationValidator
+	authz     *authz.Authorizer
 	subSvc    *subscription.Service
 	logger    *zap.Logger
 	jobs      map[string]*Job // In-memory cache (also stored in DB)
@@ -91,67 +197,169 @@ type Module struct {
 
 // NewModule creates a new jobs module
 func NewModule(db *database.Postgres, redis *database.Redis, storage *storage.Service, queue *QueueClient, wsHub *websocket.Hub, subSvc *subscription.Service, logger *zap.Logger) *Module {
-	return &Module{
+	m := &Module{
 		db:      db,
 		redis:   redis,
 		storage: storage,
 		queue:   queue,
 		wsHub:   wsHub,
+		authz:   authz.NewAuthorizer(db),
 		subSvc:  subSvc,
 		logger:  logger,
 		jobs:    make(map[string]*Job),
 	}
+	if redis != nil {
+		m.events = NewEventBus(redis, logger)
+	}
+	return m
 }
 
-// CreateJob creates a new media processing job
-func (m *Module) CreateJob(ctx context.Context, params CreateJobParams) (*Job, error) {
-	// Check conversion minutes limit before creating job
-	if m.subSvc != nil && params.UserID != "" {
-		convMin := params.ConversionMinutes
-		if convMin <= 0 {
-			convMin = 1 // Default 1 min for unknown/audio/image
+// SetMediaProber sets the prober used for input files that have no stored metadata
+func (m *Module) SetMediaProber(prober MediaProber) {
+	m.prober = prober
+}
+
+// SetOperationValidator sets the validator operation chains must pass before a job is queued
+func (m *Module) SetOperationValidator(validator OperationValidator) {
+	m.validator = validator
+}
+
+// loadInputFile looks up an input file and its probe results, probing it first if needed
+func (m *Module) loadInputFile(ctx context.Context, fileID string) (*inputFile, error) {
+	var file inputFile
+	var metadata []byte
+
+	query := `SELECT storage_path, original_name, media_type, metadata FROM files WHERE id = $1`
+	if err := m.db.Pool.QueryRow(ctx, query, fileID).Scan(&file.StoragePath, &file.OriginalName, &file.MediaType, &metadata); err != nil {
+		return nil, err
+	}
+	if len(metadata) > 0 {
+		json.Unmarshal(metadata, &file.Metadata)
+	}
+
+	if file.Metadata.Streams == nil && m.prober != nil {
+		// Legacy upload without stored metadata; the prober records a result even for undecodable files
+		if err := m.prober.ProbeFile(ctx, fileID); err != nil {
+			m.logger.Warn("Failed to probe input file", zap.String("file_id", fileID), zap.Error(err))
 		}
-		if err := m.subSvc.CheckLimit(ctx, params.UserID, "conversion_minutes", int64(convMin)); err != nil {
-			return nil, fmt.Errorf("conversion minutes limit exceeded: %w", err)
+		if err := m.db.Pool.QueryRow(ctx, `SELECT metadata FROM files WHERE id = $1`, fileID).Scan(&metadata); err != nil {
+			return nil, err
 		}
+		if len(metadata) > 0 {
+			json.Unmarshal(metadata, &file.Metadata)
+		}
+	}
+
+	return &file, nil
+}
+
+// RelayEvents forwards job events published by any process to this process's
+// WebSocket hub. Blocks until ctx is cancelled.
+func (m *Module) RelayEvents(ctx context.Context) {
+	if m.events == nil || m.wsHub == nil {
+		return
 	}
+	m.events.Relay(ctx, m.wsHub)
+}
 
+// emit publishes a job event. With Redis available the event goes through
+// pub/sub (and comes back to this process via RelayEvents); otherwise it is
+// delivered to the local hub directly.
+func (m *Module) emit(ctx context.Context, event Event) {
+	if m.events != nil {
+		if err := m.events.Publish(ctx, event); err != nil {
+			m.logger.Warn("Failed to publish job event",
+				zap.String("job_id", event.JobID),
+				zap.String("type", string(event.Type)),
+				zap.Error(err),
+			)
+		}
+		return
+	}
+	if m.wsHub != nil {
+		dispatchEvent(m.wsHub, event)
+	}
+}
+
+// CreateJob creates a new media processing job
+func (m *Module) CreateJob(ctx context.Context, params CreateJobParams) (*Job, error) {
 	// Check if this is a merge operation (multiple input files)
 	isMerge := len(params.InputFileIDs) > 1
 
 	var inputFilePath string
 	var inputFilePaths []string
 	var originalName string
+	var inputDuration float64
+	var imageMinutes int
+	var inputType string
+	timed := false
 
+	inputIDs := []string{params.InputFileID}
 	if isMerge {
-		// Look up all input files for merge
-		inputFilePaths = make([]string, 0, len(params.InputFileIDs))
-		for i, fileID := range params.InputFileIDs {
-			var storagePath, name string
-			err := m.db.Pool.QueryRow(ctx, `
-				SELECT storage_path, original_name FROM files WHERE id = $1
-			`, fileID).Scan(&storagePath, &name)
-			if err != nil {
-				return nil, fmt.Errorf("input file %d not found: %w", i+1, err)
-			}
-			inputFilePaths = append(inputFilePaths, storagePath)
-			if i == 0 {
-				originalName = name
-				inputFilePath = storagePath
-			}
-		}
+		inputIDs = params.InputFileIDs
 		// Use first file ID as primary input
-		if params.InputFileID == "" && len(params.InputFileIDs) > 0 {
+		if params.InputFileID == "" {
 			params.InputFileID = params.InputFileIDs[0]
 		}
-	} else {
-		// Single input file
-		err := m.db.Pool.QueryRow(ctx, `
-			SELECT storage_path, original_name FROM files WHERE id = $1
-		`, params.InputFileID).Scan(&inputFilePath, &originalName)
+	}
+
+	for i, fileID := range inputIDs {
+		// Jobs may only read the requesting user's own files
+		if err := m.authz.Authorize(ctx, authz.ResourceFile, fileID, params.UserID); err != nil {
+			if isMerge {
+				return nil, fmt.Errorf("input file %d not found: %w", i+1, err)
+			}
+			return nil, fmt.Errorf("input file not found: %w", err)
+		}
+
+		input, err := m.loadInputFile(ctx, fileID)
 		if err != nil {
+			if isMerge {
+				return nil, fmt.Errorf("input file %d not found: %w", i+1, err)
+			}
 			return nil, fmt.Errorf("input file not found: %w", err)
 		}
+		if !input.hasDecodableStreams() {
+			return nil, fmt.Errorf("%w: %s", ErrNoDecodableStreams, input.OriginalName)
+		}
+
+		// Conversion minutes are charged on the server-probed duration of every
+		// input, or on the size of still images, which have none
+		if input.stillImage() {
+			imageMinutes += subscription.ConversionMinutesForImage(input.Metadata.Width, input.Metadata.Height)
+		} else {
+			inputDuration += input.Metadata.Duration
+			timed = true
+		}
+		if isMerge {
+			inputFilePaths = append(inputFilePaths, input.StoragePath)
+		}
+		if i == 0 {
+			originalName = input.OriginalName
+			inputFilePath = input.StoragePath
+			if input.MediaType != nil {
+				inputType = *input.MediaType
+			}
+		}
+	}
+
+	// Reject chains the worker would fail on before they are charged or queued
+	if m.validator != nil {
+		if problems := m.validator.ValidateOperations(ctx, params.Operations, inputType, params.OutputFormat); len(problems) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidOperations, strings.Join(problems, "; "))
+		}
+	}
+
+	convMin := imageMinutes
+	if timed && !unmetered(params.Operations) {
+		convMin += subscription.ConversionMinutesFromDuration(inputDuration)
+	}
+
+	// Check conversion minutes limit before creating job
+	if m.subSvc != nil && params.UserID != "" {
+		if err := m.subSvc.CheckLimit(ctx, params.UserID, "conversion_minutes", int64(convMin)); err != nil {
+			return nil, fmt.Errorf("conversion minutes limit exceeded: %w", err)
+		}
 	}
 
 	// Generate output filename if not provided
@@ -166,30 +374,40 @@ func (m *Module) CreateJob(ctx context.Context, params CreateJobParams) (*Job, e
 		}
 	}
 
-	// Resolve file references in operation params (e.g., audioPath for addAudio)
+	// Resolve file references in operation params (e.g., audioPath for addAudio).
+	// The job keeps the file IDs so it can be retried; only the worker gets paths.
+	resolvedOps := make([]Operation, len(params.Operations))
+	copy(resolvedOps, params.Operations)
 	for i, op := range params.Operations {
-		if op.Type == "addAudio" {
-			if audioFileID, ok := op.Params["audioPath"].(string); ok && audioFileID != "" {
-				var audioStoragePath string
-				err := m.db.Pool.QueryRow(ctx, `
-					SELECT storage_path FROM files WHERE id = $1
-				`, audioFileID).Scan(&audioStoragePath)
-				if err != nil {
-					return nil, fmt.Errorf("audio file not found: %w", err)
-				}
-				// Update the operation params with resolved path
-				params.Operations[i].Params["audioPath"] = audioStoragePath
-			}
+		name, ok := FileParams[op.Type]
+		if !ok {
+			continue
+		}
+		fileID, ok := op.Params[name].(string)
+		if !ok || fileID == "" {
+			continue
+		}
+		if err := m.authz.Authorize(ctx, authz.ResourceFile, fileID, params.UserID); err != nil {
+			return nil, fmt.Errorf("%s file not found: %w", op.Type, err)
+		}
+		var storagePath string
+		err := m.db.Pool.QueryRow(ctx, `
+			SELECT storage_path FROM files WHERE id = $1
+		`, fileID).Scan(&storagePath)
+		if err != nil {
+			return nil, fmt.Errorf("%s file not found: %w", op.Type, err)
 		}
+		resolved := make(map[string]interface{}, len(op.Params))
+		for k, v := range op.Params {
+			resolved[k] = v
+		}
+		resolved[name] = storagePath
+		resolvedOps[i].Params = resolved
 	}
 
 	jobID := uuid.New().String()
 	now := time.Now()
 
-	convMin := params.ConversionMinutes
-	if convMin <= 0 {
-		convMin = 1
-	}
 	priority := 5
 	queuePriority := "default"
 	if m.subSvc != nil && params.UserID != "" {
@@ -225,7 +443,7 @@ func (m *Module) CreateJob(ctx context.Context, params CreateJobParams) (*Job, e
 	_, err := m.db.Pool.Exec(ctx, `
 		INSERT INTO jobs (id, user_id, status, priority, input_file_id, output_format, output_file_name, operations, progress, input_duration_seconds, conversion_minutes, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`, jobID, nullString(params.UserID), job.Status, priority, params.InputFileID, params.OutputFormat, outputFileName, operationsJSON, progressJSON, params.InputDurationSeconds, convMin, now)
+	`, jobID, nullString(params.UserID), job.Status, priority, params.InputFileID, params.OutputFormat, outputFileName, operationsJSON, progressJSON, inputDuration, convMin, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert job: %w", err)
 	}
@@ -246,7 +464,7 @@ func (m *Module) CreateJob(ctx context.Context, params CreateJobParams) (*Job, e
 		JobID:      jobID,
 		InputPath:  inputFilePath,
 		OutputPath: outputPath,
-		Operations: params.Operations,
+		Operations: resolvedOps,
 		UseGPU:     useGPU,
 	}
 	// Add multiple input paths for merge
@@ -280,13 +498,78 @@ func (m *Module) GetJob(ctx context.Context, jobID string) (*Job, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(job.OutputFileIDs) > 1 {
+		if job.Outputs, err = m.getOutputs(ctx, job.OutputFileIDs); err != nil {
+			return nil, err
+		}
+	} else if job.OutputFileID != "" {
+		if job.Artifacts, err = m.getArtifacts(ctx, job.OutputFileID); err != nil {
+			return nil, err
+		}
+	}
 	return job, nil
 }
 
+// getOutputs describes the output files of a multi-output job in the given
+// order; files that have since been deleted are left out
+func (m *Module) getOutputs(ctx context.Context, fileIDs []string) ([]Output, error) {
+	rows, err := m.db.Pool.Query(ctx, `
+		SELECT id::text, original_name, COALESCE(mime_type, 'application/octet-stream'), size_bytes
+		FROM files WHERE id::text = ANY($1)
+	`, fileIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]Output, len(fileIDs))
+	for rows.Next() {
+		var o Output
+		if err := rows.Scan(&o.FileID, &o.Name, &o.MimeType, &o.SizeBytes); err != nil {
+			return nil, err
+		}
+		found[o.FileID] = o
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	outputs := make([]Output, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		if o, ok := found[id]; ok {
+			outputs = append(outputs, o)
+		}
+	}
+	return outputs, nil
+}
+
+// getArtifacts lists the files of a packaged output, manifest and segments alike;
+// single-file outputs have none
+func (m *Module) getArtifacts(ctx context.Context, fileID string) ([]Artifact, error) {
+	rows, err := m.db.Pool.Query(ctx, `
+		SELECT name, COALESCE(mime_type, 'application/octet-stream'), size_bytes
+		FROM file_artifacts WHERE file_id = $1 ORDER BY name
+	`, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artifacts []Artifact
+	for rows.Next() {
+		var a Artifact
+		if err := rows.Scan(&a.Name, &a.MimeType, &a.SizeBytes); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
+
 // ListJobs returns jobs for a user
 func (m *Module) ListJobs(ctx context.Context, userID, status, jobType string) ([]*Job, error) {
 	query := `
-		SELECT id, user_id, status, priority, input_file_id, output_file_id, output_format, output_file_name, 
+		SELECT id, user_id, status, priority, input_file_id, output_file_id, output_file_ids, output_format, output_file_name, 
 		       operations, progress, error, created_at, started_at, completed_at
 		FROM jobs
 		WHERE (user_id = $1 OR (user_id IS NULL AND $1 LIKE 'anon:%'))
@@ -316,35 +599,52 @@ func (m *Module) ListJobs(ctx context.Context, userID, status, jobType string) (
 
 // CancelJob cancels a job
 func (m *Module) CancelJob(ctx context.Context, jobID string) error {
-	job, err := m.GetJob(ctx, jobID)
+	// Only unfinished jobs can be cancelled; the condition makes this safe against a
+	// worker completing the job at the same moment
+	now := time.Now()
+	result, err := m.db.Pool.Exec(ctx, `
+		UPDATE jobs SET status = $1, completed_at = $2
+		WHERE id = $3 AND status NOT IN ($4, $1)
+	`, StatusCancelled, now, jobID, StatusCompleted)
 	if err != nil {
 		return err
 	}
-
-	if job.Status == StatusCompleted || job.Status == StatusCancelled {
+	if result.RowsAffected() == 0 {
+		job, err := m.GetJob(ctx, jobID)
+		if err != nil {
+			return err
+		}
 		return fmt.Errorf("job cannot be cancelled: status is %s", job.Status)
 	}
 
-	now := time.Now()
-	_, err = m.db.Pool.Exec(ctx, `
-		UPDATE jobs SET status = $1, completed_at = $2 WHERE id = $3
-	`, StatusCancelled, now, jobID)
-	if err != nil {
-		return err
-	}
-
 	// Update cache
-	job.Status = StatusCancelled
-	job.CompletedAt = &now
+	if job, ok := m.jobs[jobID]; ok {
+		job.Status = StatusCancelled
+		job.CompletedAt = &now
+	}
 
-	// Notify via WebSocket (if hub available)
-	if m.wsHub != nil {
-		m.wsHub.BroadcastJobFailed(jobID, "Job cancelled by user")
+	// Stop the task: drop it from the queue or kill the running FFmpeg
+	if m.queue != nil {
+		if err := m.queue.CancelMediaProcess(jobID); err != nil {
+			m.logger.Warn("Failed to cancel media task", zap.String("job_id", jobID), zap.Error(err))
+		}
 	}
 
+	// Notify WebSocket subscribers
+	m.emit(ctx, Event{Type: EventFailed, JobID: jobID, Error: "Job cancelled by user"})
+
 	return nil
 }
 
+// IsCancelled reports whether a job has been cancelled
+func (m *Module) IsCancelled(ctx context.Context, jobID string) bool {
+	var status string
+	if err := m.db.Pool.QueryRow(ctx, `SELECT status FROM jobs WHERE id = $1`, jobID).Scan(&status); err != nil {
+		return false
+	}
+	return status == StatusCancelled
+}
+
 // DeleteJob removes a job from the database
 func (m *Module) DeleteJob(ctx context.Context, jobID string) error {
 	// Delete from database
@@ -363,8 +663,8 @@ func (m *Module) DeleteJob(ctx context.Context, jobID string) error {
 	return nil
 }
 
-// RetryJob retries a failed job
-func (m *Module) RetryJob(ctx context.Context, jobID string) (*Job, error) {
+// RetryJob retries a failed job on behalf of userID, who must be allowed to access it
+func (m *Module) RetryJob(ctx context.Context, jobID, userID string) (*Job, error) {
 	oldJob, err := m.GetJob(ctx, jobID)
 	if err != nil {
 		return nil, err
@@ -375,8 +675,9 @@ func (m *Module) RetryJob(ctx context.Context, jobID string) (*Job, error) {
 	}
 
 	// Create a new job with the same parameters
+	// The retry belongs to the requester, which also covers legacy jobs without an owner
 	return m.CreateJob(ctx, CreateJobParams{
-		UserID:         oldJob.UserID,
+		UserID:         userID,
 		InputFileID:    oldJob.InputFileID,
 		Operations:     oldJob.Operations,
 		OutputFormat:   oldJob.OutputFormat,
@@ -384,52 +685,21 @@ func (m *Module) RetryJob(ctx context.Context, jobID string) (*Job, error) {
 	})
 }
 
-// GetJobLogs returns logs for a job
-func (m *Module) GetJobLogs(ctx context.Context, jobID string) ([]string, error) {
-	job, err := m.GetJob(ctx, jobID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Build logs from job state
-	logs := []string{
-		fmt.Sprintf("[%s] Job created", job.CreatedAt.Format(time.RFC3339)),
-	}
-
-	if job.StartedAt != nil {
-		logs = append(logs, fmt.Sprintf("[%s] Processing started", job.StartedAt.Format(time.RFC3339)))
-	}
-
-	if job.Progress.CurrentOperation != "" {
-		logs = append(logs, fmt.Sprintf("[%s] %s (%d%%)", time.Now().Format(time.RFC3339), job.Progress.CurrentOperation, job.Progress.Percent))
-	}
-
-	if job.CompletedAt != nil {
-		if job.Status == StatusCompleted {
-			logs = append(logs, fmt.Sprintf("[%s] Job completed successfully", job.CompletedAt.Format(time.RFC3339)))
-		} else if job.Status == StatusFailed && job.Error != nil {
-			logs = append(logs, fmt.Sprintf("[%s] Job failed: %s", job.CompletedAt.Format(time.RFC3339), job.Error.Message))
-		}
-	}
-
-	return logs, nil
-}
-
 // UpdateProgress updates job progress
-func (m *Module) UpdateProgress(ctx context.Context, jobID string, percent int, operation string, eta int) error {
-	progress := Progress{
-		Percent:          percent,
-		CurrentOperation: operation,
-		ETA:              eta,
-	}
+func (m *Module) UpdateProgress(ctx context.Context, jobID string, progress Progress) error {
 	progressJSON, _ := json.Marshal(progress)
 
-	_, err := m.db.Pool.Exec(ctx, `
-		UPDATE jobs SET progress = $1, status = $2, started_at = COALESCE(started_at, NOW()) WHERE id = $3
-	`, progressJSON, StatusProcessing, jobID)
+	// Never resurrect a cancelled (or already completed) job; failed jobs may be retried by asynq
+	result, err := m.db.Pool.Exec(ctx, `
+		UPDATE jobs SET progress = $1, status = $2, started_at = COALESCE(started_at, NOW())
+		WHERE id = $3 AND status NOT IN ($4, $5)
+	`, progressJSON, StatusProcessing, jobID, StatusCancelled, StatusCompleted)
 	if err != nil {
 		return err
 	}
+	if result.RowsAffected() == 0 {
+		return ErrJobCancelled
+	}
 
 	// Update cache
 	if job, ok := m.jobs[jobID]; ok {
@@ -437,61 +707,78 @@ func (m *Module) UpdateProgress(ctx context.Context, jobID string, percent int,
 		job.Status = StatusProcessing
 	}
 
-	// Notify via WebSocket (if hub available)
-	if m.wsHub != nil {
-		m.wsHub.BroadcastJobProgress(jobID, percent, operation, eta)
-	}
+	// Notify WebSocket subscribers
+	m.emit(ctx, Event{Type: EventProgress, JobID: jobID, Progress: &progress})
 
 	return nil
 }
 
-// CompleteJob marks a job as completed
-func (m *Module) CompleteJob(ctx context.Context, jobID, outputFileID string) error {
-	// Get user_id and conversion_minutes for usage recording
-	var jobUserID *string
-	var convMin int
-	m.db.Pool.QueryRow(ctx, `SELECT user_id, COALESCE(conversion_minutes, 0) FROM jobs WHERE id = $1`, jobID).Scan(&jobUserID, &convMin)
-	if m.subSvc != nil && jobUserID != nil && *jobUserID != "" && convMin > 0 {
-		if err := m.subSvc.RecordConversionMinutes(ctx, *jobUserID, convMin); err != nil {
-			m.logger.Warn("Failed to record conversion minutes", zap.Error(err), zap.String("user_id", *jobUserID))
-		}
+// CompleteJob marks a job as completed with its output files, in order. Jobs
+// have a single output file except for operations such as split.
+func (m *Module) CompleteJob(ctx context.Context, jobID string, outputFileIDs []string) error {
+	if len(outputFileIDs) == 0 {
+		return fmt.Errorf("job %s completed without output files", jobID)
 	}
-
 	now := time.Now()
 	progress := Progress{Percent: 100}
 	progressJSON, _ := json.Marshal(progress)
+	outputFileID := outputFileIDs[0]
+	var outputFileIDsJSON []byte
+	if len(outputFileIDs) > 1 {
+		outputFileIDsJSON, _ = json.Marshal(outputFileIDs)
+	}
 
 	m.logger.Info("CompleteJob: Updating job in database",
 		zap.String("job_id", jobID),
-		zap.String("output_file_id", outputFileID),
+		zap.Strings("output_file_ids", outputFileIDs),
 		zap.String("status", StatusCompleted),
 	)
 
-	result, err := m.db.Pool.Exec(ctx, `
-		UPDATE jobs SET status = $1, output_file_id = $2, progress = $3, completed_at = $4 WHERE id = $5
-	`, StatusCompleted, outputFileID, progressJSON, now, jobID)
+	// A cancelled job stays cancelled even if FFmpeg finished first
+	var jobUserID *string
+	var convMin int
+	err := m.db.Pool.QueryRow(ctx, `
+		UPDATE jobs SET status = $1, output_file_id = $2, output_file_ids = $3, progress = $4, completed_at = $5
+		WHERE id = $6 AND status <> $7
+		RETURNING user_id, COALESCE(conversion_minutes, 0)
+	`, StatusCompleted, outputFileID, outputFileIDsJSON, progressJSON, now, jobID, StatusCancelled).Scan(&jobUserID, &convMin)
+	if errors.Is(err, pgx.ErrNoRows) {
+		m.logger.Info("CompleteJob: Job was cancelled, not completing", zap.String("job_id", jobID))
+		return ErrJobCancelled
+	}
 	if err != nil {
 		m.logger.Error("CompleteJob: Failed to update job in database", zap.Error(err))
 		return err
 	}
 
-	m.logger.Info("CompleteJob: Database update successful",
-		zap.String("job_id", jobID),
-		zap.Int64("rows_affected", result.RowsAffected()),
-	)
+	m.logger.Info("CompleteJob: Database update successful", zap.String("job_id", jobID))
+
+	// Usage is only recorded for jobs that actually completed
+	if m.subSvc != nil && jobUserID != nil && *jobUserID != "" && convMin > 0 {
+		if err := m.subSvc.RecordConversionMinutes(ctx, *jobUserID, convMin); err != nil {
+			m.logger.Warn("Failed to record conversion minutes", zap.Error(err), zap.String("user_id", *jobUserID))
+		}
+	}
 
 	// Update cache
 	if job, ok := m.jobs[jobID]; ok {
 		job.Status = StatusCompleted
 		job.OutputFileID = outputFileID
+		if len(outputFileIDs) > 1 {
+			job.OutputFileIDs = outputFileIDs
+		}
 		job.Progress.Percent = 100
 		job.CompletedAt = &now
 	}
 
-	// Notify via WebSocket (if hub available)
-	if m.wsHub != nil {
-		m.wsHub.BroadcastJobCompleted(jobID, outputFileID)
+	m.Log(ctx, jobID, LogStageJob, LogInfo, "Job completed with %d output file(s)", len(outputFileIDs))
+
+	// Notify WebSocket subscribers
+	event := Event{Type: EventCompleted, JobID: jobID, OutputFileID: outputFileID}
+	if len(outputFileIDs) > 1 {
+		event.OutputFileIDs = outputFileIDs
 	}
+	m.emit(ctx, event)
 
 	return nil
 }
@@ -499,19 +786,23 @@ func (m *Module) CompleteJob(ctx context.Context, jobID, outputFileID string) er
 // FailJob marks a job as failed
 func (m *Module) FailJob(ctx context.Context, jobID string, err error, retryable bool) error {
 	now := time.Now()
+	// FFmpeg errors quote its stderr, which names worker paths
 	jobError := JobError{
 		Code:      "PROCESSING_ERROR",
-		Message:   err.Error(),
+		Message:   redactLogMessage(err.Error()),
 		Retryable: retryable,
 	}
 	errorJSON, _ := json.Marshal(jobError)
 
-	_, dbErr := m.db.Pool.Exec(ctx, `
-		UPDATE jobs SET status = $1, error = $2, completed_at = $3 WHERE id = $4
-	`, StatusFailed, errorJSON, now, jobID)
+	result, dbErr := m.db.Pool.Exec(ctx, `
+		UPDATE jobs SET status = $1, error = $2, completed_at = $3 WHERE id = $4 AND status <> $5
+	`, StatusFailed, errorJSON, now, jobID, StatusCancelled)
 	if dbErr != nil {
 		return dbErr
 	}
+	if result.RowsAffected() == 0 {
+		return ErrJobCancelled
+	}
 
 	// Update cache
 	if job, ok := m.jobs[jobID]; ok {
@@ -520,10 +811,10 @@ func (m *Module) FailJob(ctx context.Context, jobID string, err error, retryable
 		job.CompletedAt = &now
 	}
 
-	// Notify via WebSocket (if hub available)
-	if m.wsHub != nil {
-		m.wsHub.BroadcastJobFailed(jobID, err.Error())
-	}
+	m.Log(ctx, jobID, LogStageJob, LogError, "Job failed: %s", jobError.Message)
+
+	// Notify WebSocket subscribers
+	m.emit(ctx, Event{Type: EventFailed, JobID: jobID, Error: jobError.Message})
 
 	return nil
 }
@@ -531,7 +822,7 @@ func (m *Module) FailJob(ctx context.Context, jobID string, err error, retryable
 // getJobFromDB retrieves a job from the database
 func (m *Module) getJobFromDB(ctx context.Context, jobID string) (*Job, error) {
 	row := m.db.Pool.QueryRow(ctx, `
-		SELECT id, user_id, status, priority, input_file_id, output_file_id, output_format, output_file_name, 
+		SELECT id, user_id, status, priority, input_file_id, output_file_id, output_file_ids, output_format, output_file_name, 
 		       operations, progress, error, created_at, started_at, completed_at
 		FROM jobs WHERE id = $1
 	`, jobID)
@@ -547,11 +838,11 @@ func (m *Module) scanJobSingleRow(row rowScanner) (*Job, error) {
 	var job Job
 	var userID *string
 	var outputFileID *string
-	var operationsJSON, progressJSON, errorJSON []byte
+	var outputFileIDsJSON, operationsJSON, progressJSON, errorJSON []byte
 	var startedAt, completedAt *time.Time
 
 	err := row.Scan(
-		&job.ID, &userID, &job.Status, &job.Priority, &job.InputFileID, &outputFileID,
+		&job.ID, &userID, &job.Status, &job.Priority, &job.InputFileID, &outputFileID, &outputFileIDsJSON,
 		&job.OutputFormat, &job.OutputFileName, &operationsJSON, &progressJSON, &errorJSON,
 		&job.CreatedAt, &startedAt, &completedAt,
 	)
@@ -572,6 +863,9 @@ func (m *Module) scanJobSingleRow(row rowScanner) (*Job, error) {
 		job.CompletedAt = completedAt
 	}
 
+	if outputFileIDsJSON != nil {
+		json.Unmarshal(outputFileIDsJSON, &job.OutputFileIDs)
+	}
 	json.Unmarshal(operationsJSON, &job.Operations)
 	json.Unmarshal(progressJSON, &job.Progress)
 	if errorJSON != nil {
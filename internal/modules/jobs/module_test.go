@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nextconvert/backend/internal/modules/subscription"
+	"github.com/nextconvert/backend/internal/shared/database"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// usagePool stands in for the subscription service's database and records
+// every usage update made through it
+type usagePool struct {
+	database.Pool
+	updates [][]interface{}
+}
+
+func (p *usagePool) Exec(_ context.Context, _ string, args ...interface{}) (pgconn.CommandTag, error) {
+	p.updates = append(p.updates, args)
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+// newTestModule returns a jobs module whose queries go to the returned mock.
+// Job log writes are accepted without expectations.
+func newTestModule(t *testing.T) (*Module, pgxmock.PgxPoolIface, *usagePool) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+
+	usage := &usagePool{}
+	m := &Module{
+		db:     &database.Postgres{Pool: &batchPool{Pool: mock}},
+		subSvc: subscription.NewService(&database.Postgres{Pool: usage}),
+		logger: zap.NewNop(),
+		jobs:   make(map[string]*Job),
+	}
+	return m, mock, usage
+}
+
+// expectCompletion answers CompleteJob's update; a nil user means the job was cancelled
+func expectCompletion(mock pgxmock.PgxPoolIface, userID *string, convMin int) {
+	query := mock.ExpectQuery(`UPDATE jobs SET status = \$1, output_file_id`).
+		WithArgs(StatusCompleted, pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), testJobID, StatusCancelled)
+	if userID == nil {
+		query.WillReturnError(pgx.ErrNoRows)
+		return
+	}
+	query.WillReturnRows(pgxmock.NewRows([]string{"user_id", "conversion_minutes"}).AddRow(userID, convMin))
+}
+
+func TestCancelJobRemovesQueuedTask(t *testing.T) {
+	m, mock, _ := newTestModule(t)
+	m.queue, _ = newTestQueue(t)
+	m.jobs[testJobID] = &Job{ID: testJobID, Status: StatusPending}
+
+	_, err := m.queue.EnqueueMediaProcess(MediaProcessPayload{JobID: testJobID}, "high")
+	require.NoError(t, err)
+	_, err = m.queue.inspector.GetTaskInfo("critical", testJobID)
+	require.NoError(t, err)
+	mock.ExpectExec(`UPDATE jobs SET status = \$1, completed_at = \$2`).
+		WithArgs(StatusCancelled, pgxmock.AnyArg(), testJobID, StatusCompleted).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	require.NoError(t, m.CancelJob(context.Background(), testJobID))
+
+	_, err = m.queue.inspector.GetTaskInfo("critical", testJobID)
+	assert.ErrorIs(t, err, asynq.ErrTaskNotFound)
+	assert.Equal(t, StatusCancelled, m.jobs[testJobID].Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateProgressKeepsJobCancelled(t *testing.T) {
+	m, mock, _ := newTestModule(t)
+	m.jobs[testJobID] = &Job{ID: testJobID, Status: StatusCancelled}
+
+	// The update only matches unfinished jobs, so a cancelled job is left alone
+	mock.ExpectExec(`UPDATE jobs SET progress`).
+		WithArgs(pgxmock.AnyArg(), StatusProcessing, testJobID, StatusCancelled, StatusCompleted).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	err := m.UpdateProgress(context.Background(), testJobID, Progress{Percent: 40})
+	assert.ErrorIs(t, err, ErrJobCancelled)
+	assert.Equal(t, StatusCancelled, m.jobs[testJobID].Status)
+	assert.Zero(t, m.jobs[testJobID].Progress.Percent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompleteJob(t *testing.T) {
+	t.Run("keeps a cancelled job cancelled", func(t *testing.T) {
+		m, mock, usage := newTestModule(t)
+		m.jobs[testJobID] = &Job{ID: testJobID, Status: StatusCancelled}
+		expectCompletion(mock, nil, 0)
+
+		err := m.CompleteJob(context.Background(), testJobID, []string{testJobID})
+		assert.ErrorIs(t, err, ErrJobCancelled)
+		assert.Equal(t, StatusCancelled, m.jobs[testJobID].Status)
+		assert.Empty(t, usage.updates, "a cancelled job isn't charged")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("records usage", func(t *testing.T) {
+		m, mock, usage := newTestModule(t)
+		expectCompletion(mock, owner("user_alice"), 3)
+
+		require.NoError(t, m.CompleteJob(context.Background(), testJobID, []string{testJobID}))
+		assert.Equal(t, [][]interface{}{{3, "user_alice"}}, usage.updates)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func owner(userID string) *string { return &userID }
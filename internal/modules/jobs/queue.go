@@ -2,6 +2,7 @@ package jobs
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 
 	"strings"
@@ -16,12 +17,19 @@ const (
 	TypeCleanupFiles       = "files:cleanup"
 	TypeCleanupStaleJobs   = "jobs:cleanup"
 	TypeCleanupAnonProfiles = "profiles:cleanup_anon"
+	TypeCleanupUploads     = "uploads:cleanup"
+	TypeGenerateThumbnails = "media:thumbnails"
+	TypeGenerateWaveform   = "media:waveform"
 )
 
+// mediaQueues lists the queues media tasks may be enqueued on
+var mediaQueues = []string{"critical", "default", "low"}
+
 // QueueClient handles job queue operations
 type QueueClient struct {
-	client *asynq.Client
-	logger *zap.Logger
+	client    *asynq.Client
+	inspector *asynq.Inspector
+	logger    *zap.Logger
 }
 
 // NewQueueClient creates a new queue client
@@ -40,16 +48,42 @@ func NewQueueClient(redisAddr string, logger *zap.Logger) *QueueClient {
 
 	client := asynq.NewClient(opts)
 	return &QueueClient{
-		client: client,
-		logger: logger,
+		client:    client,
+		inspector: asynq.NewInspector(opts),
+		logger:    logger,
 	}
 }
 
 // Close closes the queue client
 func (q *QueueClient) Close() error {
+	q.inspector.Close()
 	return q.client.Close()
 }
 
+// CancelMediaProcess stops the media task for a job. A task that has not started
+// yet is removed from its queue; a running task has its context cancelled on
+// whichever worker owns it, which kills FFmpeg.
+func (q *QueueClient) CancelMediaProcess(jobID string) error {
+	for _, queue := range mediaQueues {
+		err := q.inspector.DeleteTask(queue, jobID)
+		if err == nil {
+			q.logger.Info("Removed queued media task", zap.String("job_id", jobID), zap.String("queue", queue))
+			return nil
+		}
+		if !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound) {
+			// Most likely the task is active and can't be deleted; cancel it instead
+			q.logger.Debug("Could not delete media task", zap.String("job_id", jobID), zap.String("queue", queue), zap.Error(err))
+		}
+	}
+
+	// Broadcasts to all workers; a no-op if no worker is running the task
+	if err := q.inspector.CancelProcessing(jobID); err != nil {
+		return err
+	}
+	q.logger.Info("Requested cancellation of running media task", zap.String("job_id", jobID))
+	return nil
+}
+
 // MediaProcessPayload contains media processing task data
 type MediaProcessPayload struct {
 	JobID      string      `json:"jobId"`
@@ -60,6 +94,26 @@ type MediaProcessPayload struct {
 	UseGPU     bool        `json:"useGpu,omitempty"` // Pro tier: enable hardware acceleration
 }
 
+// ThumbnailPayload contains thumbnail generation task data
+type ThumbnailPayload struct {
+	FileID string `json:"fileId"`
+}
+
+// WaveformOptions mirrors media.WaveformOptions: the resolution and channel
+// layout of a waveform
+type WaveformOptions struct {
+	SamplesPerPixel int    `json:"samplesPerPixel"`
+	Channels        string `json:"channels"`
+	Bits            int    `json:"bits"`
+}
+
+// WaveformPayload contains waveform generation task data
+type WaveformPayload struct {
+	FileID  string `json:"fileId"`
+	Variant string `json:"variant"` // Names the options; the peaks are stored under it
+	WaveformOptions
+}
+
 // CleanupPayload contains file cleanup task data
 type CleanupPayload struct {
 	Zone      string `json:"zone"`
@@ -87,6 +141,7 @@ func (q *QueueClient) EnqueueMediaProcess(payload MediaProcessPayload, priority
 	task := asynq.NewTask(TypeMediaProcess, data)
 
 	opts := []asynq.Option{
+		asynq.TaskID(payload.JobID), // Lets CancelMediaProcess find the task
 		asynq.MaxRetry(3),
 		asynq.Timeout(2 * time.Hour),
 	}
@@ -114,6 +169,93 @@ func (q *QueueClient) EnqueueMediaProcess(payload MediaProcessPayload, priority
 	return info, nil
 }
 
+// EnqueueThumbnails queues poster, sprite and storyboard generation for an
+// uploaded video. Previews are nice to have, so they wait behind conversions.
+func (q *QueueClient) EnqueueThumbnails(payload ThumbnailPayload) (*asynq.TaskInfo, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	task := asynq.NewTask(TypeGenerateThumbnails, data)
+
+	opts := []asynq.Option{
+		asynq.TaskID("thumbnails:" + payload.FileID), // One generation per file
+		asynq.MaxRetry(2),
+		asynq.Timeout(30 * time.Minute),
+		asynq.Queue("low"),
+	}
+
+	info, err := q.client.Enqueue(task, opts...)
+	if err != nil {
+		q.logger.Error("Failed to enqueue thumbnail task", zap.Error(err), zap.String("file_id", payload.FileID))
+		return nil, err
+	}
+	return info, nil
+}
+
+// ErrWaveformFailed is returned when the task computing a waveform variant ran
+// out of retries (e.g. the audio can't be decoded)
+var ErrWaveformFailed = errors.New("waveform generation failed")
+
+// EnqueueWaveform queues peak computation for one waveform variant of a file.
+// The editor is waiting for it, so it runs with normal priority. Asking for a
+// variant that is already queued or running is not an error; asking for one
+// whose task failed for good returns ErrWaveformFailed.
+func (q *QueueClient) EnqueueWaveform(payload WaveformPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	task := asynq.NewTask(TypeGenerateWaveform, data)
+
+	taskID := "waveform:" + payload.FileID + ":" + payload.Variant
+	opts := []asynq.Option{
+		asynq.TaskID(taskID),
+		asynq.MaxRetry(2),
+		asynq.Timeout(30 * time.Minute),
+		asynq.Queue("default"),
+	}
+
+	_, err = q.client.Enqueue(task, opts...)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return q.waveformTaskState(taskID, task, opts)
+	}
+	if err != nil {
+		q.logger.Error("Failed to enqueue waveform task", zap.Error(err), zap.String("file_id", payload.FileID))
+		return err
+	}
+	return nil
+}
+
+// waveformTaskState checks on the task holding a waveform's task ID. Pending,
+// retrying and running tasks are on their way. An archived task failed for
+// good: it is deleted, so the failure is reported once and a later request
+// starts over.
+func (q *QueueClient) waveformTaskState(taskID string, task *asynq.Task, opts []asynq.Option) error {
+	info, err := q.inspector.GetTaskInfo("default", taskID)
+	if errors.Is(err, asynq.ErrTaskNotFound) {
+		// Finished (or was deleted) since the conflict
+		if _, err := q.client.Enqueue(task, opts...); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.State != asynq.TaskStateArchived {
+		return nil
+	}
+
+	q.logger.Warn("Waveform task failed", zap.String("task_id", taskID), zap.String("error", info.LastErr))
+	if err := q.inspector.DeleteTask("default", taskID); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+		q.logger.Warn("Failed to delete failed waveform task", zap.String("task_id", taskID), zap.Error(err))
+	}
+	return ErrWaveformFailed
+}
+
 // EnqueueCleanup queues a file cleanup task
 func (q *QueueClient) EnqueueCleanup(payload CleanupPayload) (*asynq.TaskInfo, error) {
 	data, err := json.Marshal(payload)
@@ -133,6 +275,7 @@ func (q *QueueClient) EnqueueCleanup(payload CleanupPayload) (*asynq.TaskInfo, e
 
 // ScheduleCleanup schedules periodic cleanup tasks:
 // - Hourly: permanently deletes files past 24h expiry
+// - Hourly: removes abandoned chunked upload sessions and their chunks
 // - Daily: removes stale completed/failed jobs and inactive anonymous profiles
 func (q *QueueClient) ScheduleCleanup(redisAddr string) (*asynq.Scheduler, error) {
 	var opts asynq.RedisConnOpt
@@ -158,6 +301,11 @@ func (q *QueueClient) ScheduleCleanup(redisAddr string) (*asynq.Scheduler, error
 		return nil, err
 	}
 
+	// Hourly (offset from file cleanup): abandoned upload sessions
+	if _, err := scheduler.Register("30 * * * *", asynq.NewTask(TypeCleanupUploads, nil)); err != nil {
+		return nil, err
+	}
+
 	// Daily at 3 AM: stale job cleanup
 	jobPayload, _ := json.Marshal(StaleJobCleanupPayload{AnonMaxAgeDays: 7, AuthMaxAgeDays: 30})
 	if _, err := scheduler.Register("0 3 * * *", asynq.NewTask(TypeCleanupStaleJobs, jobPayload)); err != nil {
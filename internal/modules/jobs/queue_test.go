@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestQueue(t *testing.T) (*QueueClient, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	q := NewQueueClient(mr.Addr(), zap.NewNop())
+	t.Cleanup(func() { q.Close() })
+	return q, mr
+}
+
+func TestCancelMediaProcessQueued(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	_, err := q.EnqueueMediaProcess(MediaProcessPayload{JobID: testJobID}, "low")
+	require.NoError(t, err)
+
+	require.NoError(t, q.CancelMediaProcess(testJobID))
+	_, err = q.inspector.GetTaskInfo("low", testJobID)
+	assert.ErrorIs(t, err, asynq.ErrTaskNotFound, "a task that hasn't started is removed from its queue")
+}
+
+func TestCancelMediaProcessActive(t *testing.T) {
+	q, mr := newTestQueue(t)
+
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(TypeMediaProcess, func(ctx context.Context, _ *asynq.Task) error {
+		close(started)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return ctx.Err()
+	})
+	srv := asynq.NewServer(asynq.RedisClientOpt{Addr: mr.Addr()}, asynq.Config{
+		Queues:   map[string]int{"critical": 1, "default": 1, "low": 1},
+		LogLevel: asynq.FatalLevel,
+	})
+	require.NoError(t, srv.Start(mux))
+	t.Cleanup(srv.Shutdown)
+
+	_, err := q.EnqueueMediaProcess(MediaProcessPayload{JobID: testJobID}, "")
+	require.NoError(t, err)
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("task was not picked up")
+	}
+
+	require.NoError(t, q.CancelMediaProcess(testJobID))
+	select {
+	case err := <-stopped:
+		assert.ErrorIs(t, err, context.Canceled, "a running task has its context cancelled")
+	case <-time.After(10 * time.Second):
+		t.Fatal("running task was not cancelled")
+	}
+}
@@ -0,0 +1,372 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/nextconvert/backend/internal/shared/database"
+)
+
+// ErrUnsupported is returned when the FFmpeg build lacks an encoder, filter
+// or muxer an operation needs
+var ErrUnsupported = errors.New("not supported by this FFmpeg build")
+
+// CapabilitiesKey is the Redis key workers publish their FFmpeg capabilities under
+const CapabilitiesKey = "media:ffmpeg_capabilities"
+
+// Capabilities are the encoders, decoders, filters and formats an FFmpeg build
+// includes. A nil *Capabilities means they are unknown, and everything is
+// assumed to be available.
+type Capabilities struct {
+	Version      string          `json:"version"`
+	Encoders     map[string]bool `json:"encoders"`
+	Decoders     map[string]bool `json:"decoders"`
+	Filters      map[string]bool `json:"filters"`
+	Muxers       map[string]bool `json:"muxers"`
+	Demuxers     map[string]bool `json:"demuxers"`
+	DiscoveredAt time.Time       `json:"discoveredAt"`
+}
+
+// HasEncoder reports whether the build includes an encoder
+func (c *Capabilities) HasEncoder(name string) bool { return c == nil || c.Encoders[name] }
+
+// HasDecoder reports whether the build includes a decoder
+func (c *Capabilities) HasDecoder(name string) bool { return c == nil || c.Decoders[name] }
+
+// HasFilter reports whether the build includes a filter
+func (c *Capabilities) HasFilter(name string) bool { return c == nil || c.Filters[name] }
+
+// HasMuxer reports whether the build can write a format
+func (c *Capabilities) HasMuxer(name string) bool { return c == nil || c.Muxers[name] }
+
+// HasDemuxer reports whether the build can read a format
+func (c *Capabilities) HasDemuxer(name string) bool { return c == nil || c.Demuxers[name] }
+
+// AV1Encoder returns the preferred AV1 encoder, or the other one when the
+// build only has that
+func (c *Capabilities) AV1Encoder(preferred string) string {
+	if preferred == "" {
+		preferred = AV1EncoderSVT
+	}
+	if c.HasEncoder(preferred) {
+		return preferred
+	}
+	for _, alt := range []string{AV1EncoderSVT, AV1EncoderAOM} {
+		if c.HasEncoder(alt) {
+			return alt
+		}
+	}
+	return preferred
+}
+
+// containerMuxers names the FFmpeg muxer for containers where it differs from the extension
+var containerMuxers = map[string]string{
+	"mkv":  "matroska",
+	"aac":  "adts",
+	"m4a":  "ipod",
+	"m3u8": "hls",
+	"mpd":  "dash",
+	"jpg":  "image2",
+	"png":  "image2",
+	"webp": "image2",
+	"bmp":  "image2",
+	"avif": "image2",
+}
+
+// containerDemuxers names the FFmpeg demuxer for containers where it differs from the extension
+var containerDemuxers = map[string]string{
+	"mkv":  "matroska",
+	"aac":  "aac",
+	"m4a":  "mov",
+	"jpg":  "image2",
+	"png":  "image2",
+	"webp": "image2",
+	"bmp":  "image2",
+	"avif": "image2",
+}
+
+// muxerFor returns the muxer that writes a container
+func muxerFor(container string) string {
+	if name, ok := containerMuxers[container]; ok {
+		return name
+	}
+	return container
+}
+
+// demuxerFor returns the demuxer that reads a container
+func demuxerFor(container string) string {
+	if name, ok := containerDemuxers[container]; ok {
+		return name
+	}
+	return container
+}
+
+// formatEncoders names the encoder single-image and GIF formats are written with
+var formatEncoders = map[string]string{
+	"gif":  "gif",
+	"jpg":  "mjpeg",
+	"png":  "png",
+	"webp": "libwebp",
+	"avif": "libaom-av1",
+	"bmp":  "bmp",
+}
+
+// codecDecoders lists the decoders that read each codec, any one of which will do
+var codecDecoders = map[string][]string{
+	"h264":   {"h264"},
+	"h265":   {"hevc"},
+	"vp8":    {"vp8", "libvpx"},
+	"vp9":    {"vp9", "libvpx-vp9"},
+	"av1":    {"libdav1d", "av1", "libaom-av1"},
+	"mpeg4":  {"mpeg4"},
+	"aac":    {"aac"},
+	"mp3":    {"mp3float", "mp3"},
+	"opus":   {"opus", "libopus"},
+	"vorbis": {"vorbis", "libvorbis"},
+	"flac":   {"flac"},
+	"alac":   {"alac"},
+	"pcm":    {"pcm_s16le"},
+}
+
+// canEncode reports whether any encoder writes a codec
+func (c *Capabilities) canEncode(codec string) bool {
+	for encoder, family := range encoderFamilies {
+		if family == codec && c.HasEncoder(encoder) {
+			return true
+		}
+	}
+	return false
+}
+
+// canDecode reports whether any decoder reads a codec
+func (c *Capabilities) canDecode(codec string) bool {
+	for _, decoder := range codecDecoders[codec] {
+		if c.HasDecoder(decoder) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSpec fails with ErrUnsupported if the build lacks an encoder, filter
+// or muxer the plan uses
+func (c *Capabilities) checkSpec(s *OutputSpec) error {
+	if c == nil {
+		return nil
+	}
+	if !s.DropVideo && s.VideoCodec != "" && s.VideoCodec != "copy" && !c.HasEncoder(s.VideoCodec) {
+		return fmt.Errorf("%w: no %s video encoder", ErrUnsupported, s.VideoCodec)
+	}
+	if !s.DropAudio && s.AudioCodec != "" && s.AudioCodec != "copy" && !c.HasEncoder(s.AudioCodec) {
+		return fmt.Errorf("%w: no %s audio encoder", ErrUnsupported, s.AudioCodec)
+	}
+
+	graphs := append([]string{strings.Join(s.VideoFilters, ","), strings.Join(s.AudioFilters, ",")}, s.FilterComplex...)
+	for _, graph := range graphs {
+		for _, name := range filterNames(graph) {
+			if !c.HasFilter(name) {
+				return fmt.Errorf("%w: no %s filter", ErrUnsupported, name)
+			}
+		}
+	}
+
+	if s.Container != "" && !c.HasMuxer(muxerFor(s.Container)) {
+		return fmt.Errorf("%w: can't write %s files", ErrUnsupported, s.Container)
+	}
+	return nil
+}
+
+// requireFilters is an OperationSpec.Requires for operations that run filters of their own
+func requireFilters(names ...string) func(Params, *Capabilities) error {
+	return func(_ Params, c *Capabilities) error {
+		for _, name := range names {
+			if !c.HasFilter(name) {
+				return fmt.Errorf("%w: no %s filter", ErrUnsupported, name)
+			}
+		}
+		return nil
+	}
+}
+
+// requireMuxer is an OperationSpec.Requires for operations that write a format of their own
+func requireMuxer(name string) func(Params, *Capabilities) error {
+	return func(_ Params, c *Capabilities) error {
+		if !c.HasMuxer(name) {
+			return fmt.Errorf("%w: can't write %s output", ErrUnsupported, name)
+		}
+		return nil
+	}
+}
+
+// filterNames lists the filters used by a filtergraph. Quoted and escaped
+// text is skipped, so option values containing ',' or ';' don't split a filter.
+func filterNames(graph string) []string {
+	var names []string
+	start, quoted := 0, false
+	for i := 0; i < len(graph); i++ {
+		switch graph[i] {
+		case '\\':
+			i++
+		case '\'':
+			quoted = !quoted
+		case ',', ';':
+			if !quoted {
+				if name := filterName(graph[start:i]); name != "" {
+					names = append(names, name)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if name := filterName(graph[start:]); name != "" {
+		names = append(names, name)
+	}
+	return names
+}
+
+// filterName returns the name from one "[in]name=options[out]" filter
+func filterName(filter string) string {
+	filter = strings.TrimSpace(filter)
+	for strings.HasPrefix(filter, "[") {
+		end := strings.IndexByte(filter, ']')
+		if end < 0 {
+			return ""
+		}
+		filter = strings.TrimSpace(filter[end+1:])
+	}
+	if end := strings.IndexAny(filter, "=@[ "); end >= 0 {
+		filter = filter[:end]
+	}
+	return filter
+}
+
+// DiscoverCapabilities asks an FFmpeg binary what it was built with
+func DiscoverCapabilities(ctx context.Context, ffmpegPath string) (*Capabilities, error) {
+	list := func(flag string) ([]byte, error) {
+		output, err := exec.CommandContext(ctx, ffmpegPath, "-hide_banner", flag).Output()
+		if err != nil {
+			return nil, fmt.Errorf("ffmpeg %s failed: %w", flag, err)
+		}
+		return output, nil
+	}
+
+	caps := &Capabilities{DiscoveredAt: time.Now().UTC()}
+	version, err := list("-version")
+	if err != nil {
+		return nil, err
+	}
+	caps.Version = parseFFmpegVersion(version)
+
+	for _, l := range []struct {
+		flag  string
+		dst   *map[string]bool
+		parse func([]byte) map[string]bool
+	}{
+		{"-encoders", &caps.Encoders, parseCodecList},
+		{"-decoders", &caps.Decoders, parseCodecList},
+		{"-filters", &caps.Filters, parseFilterList},
+		{"-muxers", &caps.Muxers, parseFormatList},
+		{"-demuxers", &caps.Demuxers, parseFormatList},
+	} {
+		output, err := list(l.flag)
+		if err != nil {
+			return nil, err
+		}
+		*l.dst = l.parse(output)
+	}
+	return caps, nil
+}
+
+// parseFFmpegVersion reads the version from "ffmpeg version 6.1.1 Copyright ..."
+func parseFFmpegVersion(output []byte) string {
+	fields := strings.Fields(string(firstLine(output)))
+	if len(fields) >= 3 && fields[1] == "version" {
+		return fields[2]
+	}
+	return ""
+}
+
+func firstLine(output []byte) []byte {
+	line, _, _ := bytes.Cut(output, []byte("\n"))
+	return line
+}
+
+// parseCodecList reads -encoders or -decoders output, where the codecs follow
+// a "------" line as " V....D libx264   libx264 H.264 / AVC ..."
+func parseCodecList(output []byte) map[string]bool {
+	names := map[string]bool{}
+	listed := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 1 && strings.HasPrefix(fields[0], "---"):
+			listed = true
+		case listed && len(fields) >= 2:
+			names[fields[1]] = true
+		}
+	}
+	return names
+}
+
+// parseFilterList reads -filters output: " TS. vidstabdetect  V->V  Extract ..."
+func parseFilterList(output []byte) map[string]bool {
+	names := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) >= 3 && strings.Contains(fields[2], "->") {
+			names[fields[1]] = true
+		}
+	}
+	return names
+}
+
+// parseFormatList reads -muxers or -demuxers output, where the formats follow
+// a "--" line as "  E mp4   MP4 (MPEG-4 Part 14)" or " D  mov,mp4,m4a,3gp  QuickTime / MOV"
+func parseFormatList(output []byte) map[string]bool {
+	names := map[string]bool{}
+	listed := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 1 && fields[0] == "--":
+			listed = true
+		case listed && len(fields) >= 2:
+			for _, name := range strings.Split(fields[1], ",") {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
+// PublishCapabilities stores a worker's capabilities for the API servers,
+// which validate operations against them
+func PublishCapabilities(ctx context.Context, redis *database.Redis, caps *Capabilities) error {
+	data, err := json.Marshal(caps)
+	if err != nil {
+		return err
+	}
+	return redis.Set(ctx, CapabilitiesKey, data, 0)
+}
+
+// loadPublishedCapabilities reads the capabilities last published by a worker
+func loadPublishedCapabilities(ctx context.Context, redis *database.Redis) (*Capabilities, error) {
+	data, err := redis.Get(ctx, CapabilitiesKey)
+	if err != nil {
+		return nil, err
+	}
+	var caps Capabilities
+	if err := json.Unmarshal([]byte(data), &caps); err != nil {
+		return nil, err
+	}
+	return &caps, nil
+}
@@ -0,0 +1,169 @@
+package media
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const encodersOutput = `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ V....D libaom-av1           libaom AV1 (codec av1)
+ A....D aac                  AAC (Advanced Audio Coding)
+`
+
+const filtersOutput = `Filters:
+  T.. = Timeline support
+  A = Audio input/output
+  | = Source or sink filter
+ TS. scale             V->V       Scale the input video size and/or convert the image format.
+ T.C acrossfade        AA->A      Cross fade two input audio streams.
+ ... color             |->V       Provide an uniformly colored input.
+`
+
+const muxersOutput = `File formats:
+ D. = Demuxing supported
+ .E = Muxing supported
+ --
+  E mp4             MP4 (MPEG-4 Part 14)
+  E matroska        Matroska
+ D  mov,mp4,m4a,3gp,3g2,mj2 QuickTime / MOV
+`
+
+func TestParseCapabilities(t *testing.T) {
+	assert.Equal(t, map[string]bool{"libx264": true, "libaom-av1": true, "aac": true}, parseCodecList([]byte(encodersOutput)))
+	assert.Equal(t, map[string]bool{"scale": true, "acrossfade": true, "color": true}, parseFilterList([]byte(filtersOutput)))
+	assert.Equal(t, map[string]bool{"mp4": true, "matroska": true, "mov": true, "m4a": true, "3gp": true, "3g2": true, "mj2": true},
+		parseFormatList([]byte(muxersOutput)))
+	assert.Equal(t, "6.1.1", parseFFmpegVersion([]byte("ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc")))
+}
+
+func TestFilterNames(t *testing.T) {
+	assert.Equal(t, []string{"scale", "pad", "drawtext", "fps"},
+		filterNames(`scale=1280:-2,pad=1280:720:(ow-iw)/2:0,drawtext=text='a, b; c':x=10,fps=30`))
+	assert.Equal(t, []string{"scale2ref", "overlay", "null"},
+		filterNames("[1:v:0][0:v:0]scale2ref=w=main_w*0.1:h=ow/dar[ovs0][ref0];[ref0][ovs0]overlay=x=W-w-20:y=10[ov0];[ov0]null[vout]"))
+	assert.Equal(t, []string{"subtitles"}, filterNames(`subtitles=filename=/tmp/a\,b.srt`))
+	assert.Empty(t, filterNames(""))
+}
+
+// testCapabilities is an FFmpeg build without libsvtav1, libvidstab or xfade
+func testCapabilities() *Capabilities {
+	return &Capabilities{
+		Encoders: map[string]bool{"libx264": true, "libaom-av1": true, "aac": true, "libopus": true, "libvpx-vp9": true},
+		Decoders: map[string]bool{"h264": true, "aac": true},
+		Filters:  map[string]bool{"scale": true, "deshake": true, "concat": true, "silencedetect": true},
+		Muxers:   map[string]bool{"mp4": true, "webm": true, "matroska": true},
+		Demuxers: map[string]bool{"mov": true, "mp4": true},
+	}
+}
+
+func TestCapabilitiesCheckSpec(t *testing.T) {
+	caps := testCapabilities()
+
+	spec, err := resolveOutputSpec("in.mp4", "out.mp4", []Operation{op("resize", map[string]interface{}{"width": 640.0})}, testEncoder)
+	require.NoError(t, err)
+	assert.NoError(t, caps.checkSpec(spec))
+
+	spec, err = resolveOutputSpec("in.mp4", "out.mp4", []Operation{op("convertFormat", map[string]interface{}{"videoCodec": "h265"})}, testEncoder)
+	require.NoError(t, err)
+	assert.ErrorIs(t, caps.checkSpec(spec), ErrUnsupported)
+
+	spec, err = resolveOutputSpec("in.mp4", "out.mp4", []Operation{op("reverse", nil)}, testEncoder)
+	require.NoError(t, err)
+	assert.EqualError(t, caps.checkSpec(spec), "not supported by this FFmpeg build: no reverse filter")
+
+	spec, err = resolveOutputSpec("in.mp4", "out.mov", nil, testEncoder)
+	require.NoError(t, err)
+	assert.ErrorIs(t, caps.checkSpec(spec), ErrUnsupported, "no mov muxer")
+
+	var unknown *Capabilities
+	assert.NoError(t, unknown.checkSpec(spec))
+}
+
+func TestCapabilitiesAV1Encoder(t *testing.T) {
+	caps := testCapabilities()
+	assert.Equal(t, AV1EncoderAOM, caps.AV1Encoder(""), "falls back to the encoder the build has")
+	assert.Equal(t, AV1EncoderAOM, caps.AV1Encoder(AV1EncoderSVT))
+
+	var unknown *Capabilities
+	assert.Equal(t, AV1EncoderSVT, unknown.AV1Encoder(""))
+}
+
+func TestProcessorCapabilitiesBackoff(t *testing.T) {
+	p := NewProcessor(nil, "/nonexistent/ffmpeg", zap.NewNop())
+	assert.Nil(t, p.Capabilities(context.Background()))
+	retryAt := p.capsRetryAt
+	assert.True(t, retryAt.After(time.Now()))
+
+	assert.Nil(t, p.Capabilities(context.Background()))
+	assert.Equal(t, retryAt, p.capsRetryAt, "FFmpeg isn't asked again before the retry time")
+}
+
+func TestValidateOperationsCapabilities(t *testing.T) {
+	m := &Module{caps: testCapabilities()}
+	ctx := context.Background()
+
+	t.Run("AV1 uses the encoder the build has", func(t *testing.T) {
+		result := m.ValidateOperations(ctx, []Operation{op("convertFormat", map[string]interface{}{"targetFormat": "webm", "videoCodec": "av1"})}, "video", "")
+		assert.True(t, result.Valid, result.Errors)
+	})
+
+	t.Run("missing encoder", func(t *testing.T) {
+		result := m.ValidateOperations(ctx, []Operation{op("convertFormat", map[string]interface{}{"videoCodec": "vp8"})}, "video", "")
+		assert.False(t, result.Valid)
+		assert.Equal(t, []string{"not supported by this FFmpeg build: no libvpx video encoder"}, result.Errors)
+	})
+
+	t.Run("stabilize falls back to deshake", func(t *testing.T) {
+		assert.True(t, m.ValidateOperations(ctx, []Operation{op("stabilize", nil)}, "video", "").Valid)
+
+		without := &Module{caps: &Capabilities{Filters: map[string]bool{"scale": true}}}
+		assert.False(t, without.ValidateOperations(ctx, []Operation{op("stabilize", nil)}, "video", "").Valid)
+	})
+
+	t.Run("merge transitions need xfade", func(t *testing.T) {
+		assert.True(t, m.ValidateOperations(ctx, []Operation{op("merge", nil)}, "video", "").Valid)
+
+		result := m.ValidateOperations(ctx, []Operation{op("merge", map[string]interface{}{
+			"transitions": []interface{}{map[string]interface{}{"type": "crossfade"}},
+		})}, "video", "")
+		assert.False(t, result.Valid)
+		assert.Contains(t, result.Errors[0], "no xfade filter")
+	})
+}
+
+func TestAvailableCodecsFollowCapabilities(t *testing.T) {
+	m := &Module{caps: testCapabilities()}
+	codecs := m.GetAvailableCodecs(context.Background())
+
+	byName := map[string]CodecInfo{}
+	for _, list := range codecs {
+		for _, c := range list {
+			byName[c.Name] = c
+		}
+	}
+	assert.True(t, byName["av1"].Encoding, "libaom-av1")
+	assert.False(t, byName["av1"].Decoding)
+	assert.False(t, byName["h265"].Encoding)
+	assert.True(t, byName["h264"].Decoding)
+
+	formats := m.GetSupportedFormats(context.Background())
+	for _, f := range formats["video"] {
+		switch f.Extension {
+		case "mp4":
+			assert.True(t, f.Encodable)
+			assert.True(t, f.Decodable)
+		case "avi":
+			assert.False(t, f.Encodable)
+			assert.False(t, f.Decodable)
+		}
+	}
+}
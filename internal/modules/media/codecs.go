@@ -0,0 +1,90 @@
+package media
+
+import "fmt"
+
+// AV1 encoders a processor can be configured with
+const (
+	AV1EncoderSVT = "libsvtav1"  // SVT-AV1: fast enough for production encodes
+	AV1EncoderAOM = "libaom-av1" // libaom: the reference encoder, slower but in every FFmpeg build
+)
+
+// audioEncoders maps the audio codecs clients choose onto FFmpeg encoders
+var audioEncoders = map[string]string{
+	"aac":    "aac",
+	"mp3":    "libmp3lame",
+	"opus":   "libopus",
+	"vorbis": "libvorbis",
+	"flac":   "flac",
+	"alac":   "alac",
+	"pcm":    "pcm_s16le",
+}
+
+// encoderFamilies maps FFmpeg encoders back onto the codec they write
+var encoderFamilies = map[string]string{
+	"libx264":           "h264",
+	"h264_videotoolbox": "h264",
+	"libx265":           "h265",
+	"hevc_videotoolbox": "h265",
+	"libvpx":            "vp8",
+	"libvpx-vp9":        "vp9",
+	AV1EncoderSVT:       "av1",
+	AV1EncoderAOM:       "av1",
+	"mpeg4":             "mpeg4",
+	"gif":               "gif",
+	"aac":               "aac",
+	"libmp3lame":        "mp3",
+	"libopus":           "opus",
+	"libvorbis":         "vorbis",
+	"flac":              "flac",
+	"alac":              "alac",
+	"pcm_s16le":         "pcm",
+}
+
+// codecSupport lists the codecs a container can hold
+type codecSupport struct {
+	Video []string
+	Audio []string
+}
+
+// containerCodecs is the container × codec compatibility matrix. Containers
+// that aren't listed (mkv, images) hold any codec.
+var containerCodecs = map[string]codecSupport{
+	"mp4":  {Video: []string{"h264", "h265", "av1", "vp9", "mpeg4"}, Audio: []string{"aac", "mp3", "opus", "flac", "alac"}},
+	"mov":  {Video: []string{"h264", "h265", "mpeg4"}, Audio: []string{"aac", "mp3", "alac", "pcm"}},
+	"webm": {Video: []string{"vp8", "vp9", "av1"}, Audio: []string{"opus", "vorbis"}},
+	"avi":  {Video: []string{"h264", "mpeg4"}, Audio: []string{"mp3", "aac", "pcm"}},
+	"gif":  {Video: []string{"gif"}},
+	"mp3":  {Audio: []string{"mp3"}},
+	"aac":  {Audio: []string{"aac"}},
+	"wav":  {Audio: []string{"pcm"}},
+	"flac": {Audio: []string{"flac"}},
+	"ogg":  {Audio: []string{"vorbis", "opus", "flac"}},
+	"m4a":  {Audio: []string{"aac", "alac"}},
+	"opus": {Audio: []string{"opus"}},
+}
+
+// containerHolds reports whether a container can hold a video or audio codec
+func containerHolds(container, kind, codec string) bool {
+	support, ok := containerCodecs[container]
+	if !ok {
+		return true
+	}
+	if kind == "video" {
+		return contains(support.Video, codec)
+	}
+	return contains(support.Audio, codec)
+}
+
+// checkContainerCodecs rejects codecs requested for a container that can't hold them
+func checkContainerCodecs(container, video, audio string) error {
+	if video != "" && !containerHolds(container, "video", video) {
+		if len(containerCodecs[container].Video) == 0 {
+			return fmt.Errorf("%w: %s files can't hold video", ErrInvalidOperation, container)
+		}
+		return fmt.Errorf("%w: %s files can't hold %s video", ErrInvalidOperation, container, video)
+	}
+	if audio != "" && !containerHolds(container, "audio", audio) {
+		return fmt.Errorf("%w: %s files can't hold %s audio", ErrInvalidOperation, container, audio)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package media
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertFormatCodecs(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		params map[string]interface{}
+		video  string
+		audio  string
+	}{
+		{"av1 in mp4", "out.mp4", map[string]interface{}{"targetFormat": "mp4", "videoCodec": "av1"}, "libsvtav1", "aac"},
+		{"av1 in webm", "out.webm", map[string]interface{}{"targetFormat": "webm", "videoCodec": "av1"}, "libsvtav1", "libopus"},
+		{"av1 in mkv with flac", "out.mkv", map[string]interface{}{"targetFormat": "mkv", "videoCodec": "av1", "audioCodec": "flac"}, "libsvtav1", "flac"},
+		{"hevc in mov", "out.mov", map[string]interface{}{"targetFormat": "mov", "videoCodec": "h265"}, "libx265", "aac"},
+		{"vp8 with vorbis", "out.webm", map[string]interface{}{"targetFormat": "webm", "videoCodec": "vp8", "audioCodec": "vorbis"}, "libvpx", "libvorbis"},
+		{"codecs follow the output file", "out.mp4", map[string]interface{}{"videoCodec": "h265", "audioCodec": "opus"}, "libx265", "libopus"},
+		{"audio codec for an audio format", "out.ogg", map[string]interface{}{"targetFormat": "ogg", "audioCodec": "opus"}, "", "libopus"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := resolveArgs(t, tt.output, op("convertFormat", tt.params))
+			assert.Equal(t, tt.video, flagValue(args, "-c:v"))
+			assert.Equal(t, tt.audio, flagValue(args, "-c:a"))
+		})
+	}
+
+	t.Run("configured AV1 encoder", func(t *testing.T) {
+		spec, err := resolveOutputSpec("in.mp4", "out.mp4", []Operation{
+			op("convertFormat", map[string]interface{}{"videoCodec": "av1"}),
+		}, encoderSettings{AV1Encoder: AV1EncoderAOM})
+		require.NoError(t, err)
+		assert.Equal(t, "libaom-av1", spec.VideoCodec)
+	})
+}
+
+func TestConvertFormatIncompatibleCodecs(t *testing.T) {
+	for name, params := range map[string]map[string]interface{}{
+		"vp9 in mov":     {"targetFormat": "mov", "videoCodec": "vp9"},
+		"h264 in webm":   {"targetFormat": "webm", "videoCodec": "h264"},
+		"aac in webm":    {"targetFormat": "webm", "audioCodec": "aac"},
+		"pcm in mp4":     {"targetFormat": "mp4", "audioCodec": "pcm"},
+		"video in audio": {"targetFormat": "mp3", "videoCodec": "h264"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := resolveOutputSpec("in.mp4", "", []Operation{op("convertFormat", params)}, testEncoder)
+			assert.ErrorIs(t, err, ErrInvalidOperation)
+		})
+	}
+
+	t.Run("is a validation error", func(t *testing.T) {
+		result := (&Module{}).ValidateOperations(context.Background(), []Operation{
+			op("convertFormat", map[string]interface{}{"targetFormat": "mov", "videoCodec": "vp9"}),
+		}, "video", "")
+		assert.False(t, result.Valid)
+		assert.Contains(t, result.Errors[0], "mov files can't hold vp9 video")
+	})
+
+	t.Run("is checked against the output format", func(t *testing.T) {
+		m := &Module{}
+		ops := []Operation{op("convertFormat", map[string]interface{}{"videoCodec": "vp9"})}
+		assert.True(t, m.ValidateOperations(context.Background(), ops, "video", "mp4").Valid)
+
+		result := m.ValidateOperations(context.Background(), ops, "video", ".MOV")
+		assert.False(t, result.Valid)
+		assert.Contains(t, result.Errors[0], "output file")
+	})
+
+	t.Run("output file container", func(t *testing.T) {
+		_, err := resolveOutputSpec("in.mp4", "out.mov", []Operation{
+			op("convertFormat", map[string]interface{}{"videoCodec": "vp9"}),
+		}, testEncoder)
+		assert.ErrorIs(t, err, ErrConflictingOperations)
+	})
+}
+
+func TestAV1EncoderArgs(t *testing.T) {
+	assert.Equal(t, []string{"-preset", "8", "-crf", "32"}, videoEncoderArgs(AV1EncoderSVT, RateControl{Quality: 50}))
+	assert.Equal(t, []string{"-cpu-used", "6", "-row-mt", "1", "-crf", "32", "-b:v", "0"}, videoEncoderArgs(AV1EncoderAOM, RateControl{Quality: 50}))
+	assert.Equal(t, []string{"-preset", "8", "-b:v", "900000"}, videoEncoderArgs(AV1EncoderSVT, RateControl{VideoBitrate: 900000, Pass: 2, PassLogFile: "/tmp/p"}),
+		"SVT-AV1 encodes targets in a single pass")
+}
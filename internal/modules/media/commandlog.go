@@ -0,0 +1,99 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// Bounds on the stderr kept from one FFmpeg run
+const (
+	stderrTailLines   = 100  // Earlier lines are dropped; errors are almost always at the end
+	stderrMaxLineSize = 1024 // Longer lines (e.g. a dumped filtergraph) are cut short
+)
+
+// CommandLog records one finished FFmpeg run
+type CommandLog struct {
+	Operation string   // Progress label of the run, e.g. "Processing: resize"
+	Args      []string // Command line, without the binary
+	Stderr    []string // Last stderrTailLines lines FFmpeg wrote to stderr
+	Dropped   int      // Stderr lines dropped before the tail
+	Err       error    // nil when FFmpeg exited cleanly
+	Duration  time.Duration
+}
+
+type commandLogKey struct{}
+
+// withCommandLog returns a context whose FFmpeg runs are reported to onCommand
+func withCommandLog(ctx context.Context, onCommand func(CommandLog)) context.Context {
+	if onCommand == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, commandLogKey{}, onCommand)
+}
+
+// reportCommand passes a finished run to the context's OnCommand hook, if any
+func reportCommand(ctx context.Context, log CommandLog) {
+	if onCommand, ok := ctx.Value(commandLogKey{}).(func(CommandLog)); ok {
+		onCommand(log)
+	}
+}
+
+// stderrTail is an io.Writer that keeps the last lines written to it
+type stderrTail struct {
+	mu      sync.Mutex
+	lines   []string
+	partial []byte
+	dropped int
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		// FFmpeg rewrites status lines in place with '\r'
+		i := bytes.IndexAny(p, "\r\n")
+		if i < 0 {
+			t.appendPartial(p)
+			break
+		}
+		t.appendPartial(p[:i])
+		t.endLine()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (t *stderrTail) appendPartial(p []byte) {
+	if room := stderrMaxLineSize - len(t.partial); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		t.partial = append(t.partial, p...)
+	}
+}
+
+func (t *stderrTail) endLine() {
+	line := string(bytes.TrimSpace(t.partial))
+	t.partial = t.partial[:0]
+	if line == "" {
+		return
+	}
+	if len(t.lines) == stderrTailLines {
+		t.lines = append(t.lines[:0], t.lines[1:]...)
+		t.dropped++
+	}
+	t.lines = append(t.lines, line)
+}
+
+// Lines returns the kept lines, including an unterminated last line
+func (t *stderrTail) Lines() ([]string, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.endLine()
+	return append([]string(nil), t.lines...), t.dropped
+}
@@ -0,0 +1,53 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStderrTail(t *testing.T) {
+	t.Run("splits writes into lines", func(t *testing.T) {
+		tail := &stderrTail{}
+		fmt.Fprint(tail, "Input #0, mov,mp4 from 'in.mp4':\n  Dura")
+		fmt.Fprint(tail, "tion: 00:00:10.00\r\n\nframe=  10\rframe=  20")
+
+		lines, dropped := tail.Lines()
+		assert.Equal(t, []string{"Input #0, mov,mp4 from 'in.mp4':", "Duration: 00:00:10.00", "frame=  10", "frame=  20"}, lines)
+		assert.Zero(t, dropped)
+	})
+
+	t.Run("keeps the last lines", func(t *testing.T) {
+		tail := &stderrTail{}
+		for i := 0; i < stderrTailLines+5; i++ {
+			fmt.Fprintf(tail, "line %d\n", i)
+		}
+
+		lines, dropped := tail.Lines()
+		assert.Len(t, lines, stderrTailLines)
+		assert.Equal(t, "line 5", lines[0])
+		assert.Equal(t, 5, dropped)
+	})
+
+	t.Run("cuts long lines", func(t *testing.T) {
+		tail := &stderrTail{}
+		fmt.Fprint(tail, strings.Repeat("x", 3*stderrMaxLineSize)+"\n")
+
+		lines, _ := tail.Lines()
+		assert.Len(t, lines[0], stderrMaxLineSize)
+	})
+}
+
+func TestReportCommand(t *testing.T) {
+	var got []CommandLog
+	ctx := withCommandLog(context.Background(), func(l CommandLog) { got = append(got, l) })
+
+	reportCommand(ctx, CommandLog{Operation: "Merging"})
+	reportCommand(context.Background(), CommandLog{Operation: "ignored"})
+	assert.Equal(t, []CommandLog{{Operation: "Merging"}}, got)
+
+	assert.Equal(t, context.Background(), withCommandLog(context.Background(), nil))
+}
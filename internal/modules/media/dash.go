@@ -0,0 +1,92 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// dashManifest is the entry point of a DASH package
+const dashManifest = "manifest.mpd"
+
+// dashOptions configures a DASH package
+type dashOptions struct {
+	Ladder          []Rendition
+	SegmentDuration float64
+	WithAudio       bool
+}
+
+func runPackageDASH(ctx context.Context, proc *Processor, opts ProcessOptions, p Params) (*ProcessResult, error) {
+	info, err := probePackageSource(ctx, proc, opts.InputPath, "packageDASH")
+	if err != nil {
+		return nil, err
+	}
+
+	dir := packageDir(opts.OutputPath)
+	dash := dashOptions{
+		Ladder:          buildLadder(p.List("renditions"), displayHeight(info)),
+		SegmentDuration: p.Float("segmentDuration"),
+		WithAudio:       info.AudioCodec != "",
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create package directory: %w", err)
+	}
+
+	args := dashArgs(opts.InputPath, dir, dash, proc.encoderSettings(&opts))
+	proc.logger.Info("Packaging DASH",
+		zap.String("input", opts.InputPath),
+		zap.String("output_dir", dir),
+		zap.Int("renditions", len(dash.Ladder)),
+		zap.Strings("args", args),
+	)
+
+	if err := proc.runFFmpeg(ctx, args, info.Duration, "Packaging DASH", opts.OnProgress); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("FFmpeg DASH packaging failed: %w", err)
+	}
+
+	return collectArtifacts(dir, dashManifest)
+}
+
+// dashArgs builds a single FFmpeg run that writes an MPD with one video
+// adaptation set holding every rendition and, when the source has audio, one
+// audio adaptation set. Unlike HLS the audio is encoded once and shared by all
+// renditions, at the top rung's audio bitrate.
+func dashArgs(inputPath, dir string, dash dashOptions, enc encoderSettings) []string {
+	args := []string{"-y"}
+	if enc.Threads > 0 {
+		args = append(args, "-threads", strconv.Itoa(enc.Threads))
+	}
+	args = append(args, "-i", inputPath, "-filter_complex", ladderFilter(dash.Ladder))
+
+	for i := range dash.Ladder {
+		args = append(args, "-map", fmt.Sprintf("[v%d]", i))
+	}
+	adaptationSets := "id=0,streams=v"
+	if dash.WithAudio {
+		args = append(args, "-map", "0:a:0")
+		adaptationSets += " id=1,streams=a"
+	}
+
+	args = append(args, ladderVideoArgs(enc)...)
+	if dash.WithAudio {
+		args = append(args, "-c:a", "aac", "-ac", "2", "-b:a:0", fmt.Sprint(dash.Ladder[0].AudioBitrate))
+	}
+	args = append(args, ladderEncoderArgs(dash.Ladder, false)...)
+	args = append(args, keyframeArgs(dash.SegmentDuration)...)
+
+	return append(args,
+		"-f", "dash",
+		"-seg_duration", strconv.FormatFloat(dash.SegmentDuration, 'f', -1, 64),
+		"-use_template", "1",
+		"-use_timeline", "1",
+		"-init_seg_name", "init-$RepresentationID$.m4s",
+		"-media_seg_name", "chunk-$RepresentationID$-$Number%05d$.m4s",
+		"-adaptation_sets", adaptationSets,
+		filepath.Join(dir, dashManifest),
+	)
+}
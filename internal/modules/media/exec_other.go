@@ -0,0 +1,8 @@
+//go:build !unix
+
+package media
+
+import "os/exec"
+
+// killOnCancel falls back to killing only the FFmpeg process itself
+func killOnCancel(cmd *exec.Cmd) {}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package media
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killOnCancel runs the command in its own process group and kills the whole
+// group when the context is cancelled, so helper processes FFmpeg spawns
+// (e.g. for pipes or protocols) don't outlive a cancelled job.
+func killOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
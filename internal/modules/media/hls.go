@@ -0,0 +1,106 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// hlsMasterPlaylist is the entry point of an HLS package
+const hlsMasterPlaylist = "master.m3u8"
+
+// hlsOptions configures an HLS package
+type hlsOptions struct {
+	Ladder          []Rendition
+	SegmentDuration float64
+	SegmentType     string // "ts" or "fmp4"
+	WithAudio       bool
+}
+
+func runPackageHLS(ctx context.Context, proc *Processor, opts ProcessOptions, p Params) (*ProcessResult, error) {
+	info, err := probePackageSource(ctx, proc, opts.InputPath, "packageHLS")
+	if err != nil {
+		return nil, err
+	}
+
+	dir := packageDir(opts.OutputPath)
+	hls := hlsOptions{
+		Ladder:          buildLadder(p.List("renditions"), displayHeight(info)),
+		SegmentDuration: p.Float("segmentDuration"),
+		SegmentType:     p.String("segmentType"),
+		WithAudio:       info.AudioCodec != "",
+	}
+
+	// FFmpeg doesn't create the per-rendition directories itself
+	for i := range hls.Ladder {
+		if err := os.MkdirAll(filepath.Join(dir, fmt.Sprintf("stream_%d", i)), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create package directory: %w", err)
+		}
+	}
+
+	args := hlsArgs(opts.InputPath, dir, hls, proc.encoderSettings(&opts))
+	proc.logger.Info("Packaging HLS",
+		zap.String("input", opts.InputPath),
+		zap.String("output_dir", dir),
+		zap.Int("renditions", len(hls.Ladder)),
+		zap.Strings("args", args),
+	)
+
+	if err := proc.runFFmpeg(ctx, args, info.Duration, "Packaging HLS", opts.OnProgress); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("FFmpeg HLS packaging failed: %w", err)
+	}
+
+	return collectArtifacts(dir, hlsMasterPlaylist)
+}
+
+// hlsArgs builds a single FFmpeg run that encodes every rendition and writes
+// the media playlists, segments and master playlist into dir
+func hlsArgs(inputPath, dir string, hls hlsOptions, enc encoderSettings) []string {
+	args := []string{"-y"}
+	if enc.Threads > 0 {
+		args = append(args, "-threads", strconv.Itoa(enc.Threads))
+	}
+	args = append(args, "-i", inputPath, "-filter_complex", ladderFilter(hls.Ladder))
+
+	streamMap := make([]string, len(hls.Ladder))
+	for i := range hls.Ladder {
+		args = append(args, "-map", fmt.Sprintf("[v%d]", i))
+		streamMap[i] = fmt.Sprintf("v:%d", i)
+		if hls.WithAudio {
+			args = append(args, "-map", "0:a:0")
+			streamMap[i] += fmt.Sprintf(",a:%d", i)
+		}
+	}
+
+	args = append(args, ladderVideoArgs(enc)...)
+	if hls.WithAudio {
+		args = append(args, "-c:a", "aac", "-ac", "2")
+	}
+	args = append(args, ladderEncoderArgs(hls.Ladder, hls.WithAudio)...)
+	args = append(args, keyframeArgs(hls.SegmentDuration)...)
+
+	segment := "segment_%05d.ts"
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", strconv.FormatFloat(hls.SegmentDuration, 'f', -1, 64),
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+	)
+	if hls.SegmentType == "fmp4" {
+		segment = "segment_%05d.m4s"
+		args = append(args, "-hls_segment_type", "fmp4", "-hls_fmp4_init_filename", "init.mp4")
+	}
+
+	return append(args,
+		"-hls_segment_filename", filepath.Join(dir, "stream_%v", segment),
+		"-master_pl_name", hlsMasterPlaylist,
+		"-var_stream_map", strings.Join(streamMap, " "),
+		filepath.Join(dir, "stream_%v", "playlist.m3u8"),
+	)
+}
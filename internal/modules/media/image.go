@@ -0,0 +1,280 @@
+package media
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"strconv"
+)
+
+// imageEncoding holds the settings chosen by convertImageFormat
+type imageEncoding struct {
+	Quality     int  // 1-100 (0 = format default)
+	Lossless    bool // WebP and AVIF only
+	Compression int  // Encoder effort 0-9 (-1 = encoder default)
+}
+
+// imageCodecs names the encoder for image containers where FFmpeg's default
+// isn't the right one; JPEG, PNG and BMP use the muxer's default
+var imageCodecs = map[string]string{
+	"webp": "libwebp", // Not libwebp_anim, which FFmpeg may otherwise pick
+	"avif": "libaom-av1",
+}
+
+// defaultAVIFQuality is used for AVIF output when no quality is given;
+// libaom's own default targets a bitrate, which makes no sense for a still image
+const defaultAVIFQuality = 75
+
+func applyConvertImageFormat(b *PlanBuilder, p Params) error {
+	if err := b.Claim("image encoding"); err != nil {
+		return err
+	}
+	if p.Has("format") {
+		container := p.String("format")
+		if alias, ok := containerAliases[container]; ok {
+			container = alias
+		}
+		if err := b.Set("container", &b.Spec.Container, container); err != nil {
+			return err
+		}
+	}
+
+	b.image = &imageEncoding{
+		Quality:     p.Int("quality"),
+		Lossless:    p.Bool("lossless"),
+		Compression: -1,
+	}
+	if p.Has("compression") {
+		b.image.Compression = p.Int("compression")
+	}
+	return nil
+}
+
+func applyStripMetadata(b *PlanBuilder, p Params) error {
+	b.stripMetadata = true
+	return nil
+}
+
+// encodeImage sets up a single-image output: one frame, no audio, and the
+// format's encoder with the quality settings from convertImageFormat
+func (b *PlanBuilder) encodeImage() error {
+	s := b.Spec
+	enc := imageEncoding{Compression: -1}
+	if b.image != nil {
+		enc = *b.image
+	}
+	owner := b.owners["image encoding"]
+	unsupported := func(setting string) error {
+		return conflictf("%s sets the %s, which %s images don't have", owner, setting, s.Container)
+	}
+
+	if s.MaxFrames == 0 {
+		s.MaxFrames = 1
+	}
+	s.DropAudio = true
+	if codec := imageCodecs[s.Container]; s.VideoCodec != codec {
+		if s.VideoCodec != "" {
+			return conflictf("%s files (from %s) can't hold %s video", s.Container, b.owners["container"], s.VideoCodec)
+		}
+		s.VideoCodec = codec
+	}
+
+	var args []string
+	switch s.Container {
+	case "jpg":
+		if enc.Lossless {
+			return unsupported("lossless mode")
+		}
+		if enc.Compression >= 0 {
+			return unsupported("compression effort")
+		}
+		q := 2 // High quality by default
+		if enc.Quality > 0 {
+			q = 31 - enc.Quality*29/100
+		}
+		args = append(args, "-q:v", strconv.Itoa(q))
+
+	case "png":
+		if enc.Quality > 0 {
+			return unsupported("quality") // PNG is always lossless
+		}
+		if enc.Compression >= 0 {
+			args = append(args, "-compression_level", strconv.Itoa(enc.Compression))
+		}
+
+	case "webp":
+		if enc.Lossless {
+			args = append(args, "-lossless", "1")
+		}
+		if enc.Quality > 0 {
+			args = append(args, "-quality", strconv.Itoa(enc.Quality))
+		}
+		if enc.Compression >= 0 {
+			// libwebp's method runs 0-6
+			args = append(args, "-compression_level", strconv.Itoa(enc.Compression*6/9))
+		}
+
+	case "avif":
+		quality := enc.Quality
+		if quality == 0 {
+			quality = defaultAVIFQuality
+		}
+		crf := 63 - quality*63/100
+		if enc.Lossless {
+			crf = 0
+		}
+		cpuUsed := 6
+		if enc.Compression >= 0 {
+			cpuUsed = 8 - enc.Compression*8/9
+		}
+		args = append(args, "-crf", strconv.Itoa(crf), "-b:v", "0", "-cpu-used", strconv.Itoa(cpuUsed), "-still-picture", "1")
+
+	default:
+		if enc.Quality > 0 {
+			return unsupported("quality")
+		}
+		if enc.Lossless {
+			return unsupported("lossless mode")
+		}
+		if enc.Compression >= 0 {
+			return unsupported("compression effort")
+		}
+	}
+
+	s.OutputOptions = append(s.OutputOptions, args...)
+	return nil
+}
+
+// orientImage bakes a JPEG's EXIF orientation into the pixels, ahead of every
+// other filter, so crops and rotations apply to the picture as it is shown.
+// FFmpeg's own autorotation is turned off so it can't be applied twice.
+func orientImage(spec *OutputSpec, orientation int) {
+	filters := orientationFilters(orientation)
+	if len(filters) == 0 || spec.DropVideo {
+		return
+	}
+	spec.Inputs[0].Options = append(spec.Inputs[0].Options, "-noautorotate")
+	spec.VideoFilters = append(filters, spec.VideoFilters...)
+}
+
+// orientationFilters returns the filters that turn an image stored with an
+// EXIF orientation (1-8) upright
+func orientationFilters(orientation int) []string {
+	switch orientation {
+	case 2:
+		return []string{"hflip"}
+	case 3:
+		return []string{"hflip", "vflip"}
+	case 4:
+		return []string{"vflip"}
+	case 5:
+		return []string{"transpose=0"} // Transpose
+	case 6:
+		return []string{"transpose=1"} // 90° clockwise
+	case 7:
+		return []string{"transpose=3"} // Transverse
+	case 8:
+		return []string{"transpose=2"} // 90° counterclockwise
+	}
+	return nil
+}
+
+// exifOrientation reads the EXIF orientation of a JPEG file. It returns 1
+// (upright) for other files and for JPEGs without an orientation tag.
+func exifOrientation(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 1
+	}
+	defer f.Close()
+	return readJPEGOrientation(bufio.NewReader(f))
+}
+
+func readJPEGOrientation(r *bufio.Reader) int {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return 1
+	}
+
+	for {
+		// Markers are 0xFF followed by a code; extra 0xFF bytes are padding
+		b, err := r.ReadByte()
+		if err != nil || b != 0xFF {
+			return 1
+		}
+		marker := byte(0xFF)
+		for marker == 0xFF {
+			if marker, err = r.ReadByte(); err != nil {
+				return 1
+			}
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return 1 // Image data starts; EXIF always comes before it
+		}
+
+		var size [2]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(size[:])) - 2
+		if length < 0 {
+			return 1
+		}
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return 1
+		}
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+	}
+}
+
+// tiffOrientation finds the Orientation tag (0x0112) in the first IFD of an EXIF TIFF block
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:4]) != 42 {
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) != 0x0112 {
+			continue
+		}
+		if v := int(order.Uint16(tiff[entry+8 : entry+10])); v >= 1 && v <= 8 {
+			return v
+		}
+		return 1
+	}
+	return 1
+}
+
+// checkImageOutput fails if convertImageFormat is combined with a non-image output
+func (b *PlanBuilder) checkImageOutput() error {
+	s := b.Spec
+	if b.image != nil && s.Container != "" && !imageContainers[s.Container] {
+		return conflictf("%s produces an image, but %s asks for %s", b.owners["image encoding"], b.owners["container"], s.Container)
+	}
+	return nil
+}
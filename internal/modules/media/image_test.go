@@ -0,0 +1,211 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageOutputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		ops    []Operation
+		codec  string
+		want   []string // Expected consecutive output options
+	}{
+		{
+			name:   "jpeg defaults to high quality",
+			output: "out.jpeg",
+			want:   []string{"-q:v", "2"},
+		},
+		{
+			name:   "jpeg quality",
+			output: "out.jpg",
+			ops:    []Operation{op("convertImageFormat", map[string]interface{}{"quality": 50.0})},
+			want:   []string{"-q:v", "17"},
+		},
+		{
+			name:   "png compression",
+			output: "out.png",
+			ops:    []Operation{op("convertImageFormat", map[string]interface{}{"format": "png", "compression": 9.0})},
+			want:   []string{"-compression_level", "9"},
+		},
+		{
+			name:   "lossless webp",
+			output: "out.webp",
+			ops:    []Operation{op("convertImageFormat", map[string]interface{}{"lossless": true, "compression": 9.0})},
+			codec:  "libwebp",
+			want:   []string{"-lossless", "1", "-compression_level", "6"},
+		},
+		{
+			name:   "avif quality",
+			output: "out.avif",
+			ops:    []Operation{op("convertImageFormat", map[string]interface{}{"quality": 60.0})},
+			codec:  "libaom-av1",
+			want:   []string{"-crf", "26", "-b:v", "0", "-cpu-used", "6", "-still-picture", "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := resolveArgs(t, tt.output, tt.ops...)
+			assert.Equal(t, "1", flagValue(args, "-frames:v"))
+			assert.Contains(t, args, "-an")
+			assert.Equal(t, tt.codec, flagValue(args, "-c:v"))
+			i := indexOf(args, tt.want[0])
+			require.GreaterOrEqual(t, i, 0, args)
+			assert.Equal(t, tt.want, args[i:i+len(tt.want)])
+			assert.Equal(t, tt.output, args[len(args)-1])
+		})
+	}
+}
+
+func TestImageOutputConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		ops    []Operation
+		want   string
+	}{
+		{
+			name:   "png has no quality",
+			output: "out.png",
+			ops:    []Operation{op("convertImageFormat", map[string]interface{}{"quality": 80.0})},
+			want:   "quality, which png images don't have",
+		},
+		{
+			name:   "jpeg can't be lossless",
+			output: "out.jpg",
+			ops:    []Operation{op("convertImageFormat", map[string]interface{}{"lossless": true})},
+			want:   "lossless mode",
+		},
+		{
+			name:   "format disagrees with the output file",
+			output: "out.png",
+			ops:    []Operation{op("convertImageFormat", map[string]interface{}{"format": "webp"})},
+			want:   "container",
+		},
+		{
+			name:   "image settings for a video output",
+			output: "out.mp4",
+			ops:    []Operation{op("convertImageFormat", map[string]interface{}{"quality": 80.0})},
+			want:   "produces an image",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolveOutputSpec("in.png", tt.output, tt.ops, testEncoder)
+			assert.ErrorIs(t, err, ErrConflictingOperations)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestResizeModes(t *testing.T) {
+	tests := []struct {
+		params map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"width": 800.0, "height": 600.0}, "scale=800:600:force_original_aspect_ratio=decrease"},
+		{map[string]interface{}{"width": 800.0, "height": 600.0, "maintainAspect": false}, "scale=800:600"},
+		{map[string]interface{}{"width": 800.0, "height": 600.0, "mode": "fill"}, "scale=800:600"},
+		{map[string]interface{}{"width": 800.0, "height": 600.0, "mode": "cover"}, "scale=800:600:force_original_aspect_ratio=increase,crop=800:600"},
+		{map[string]interface{}{"width": 800.0, "mode": "cover"}, "scale=800:-2"},
+		{map[string]interface{}{"height": 600.0, "maintainAspect": false, "mode": "fit"}, "scale=-2:600"},
+	}
+
+	for _, tt := range tests {
+		args := resolveArgs(t, "out.png", op("resize", tt.params))
+		assert.Equal(t, tt.want, flagValue(args, "-vf"), tt.params)
+	}
+}
+
+func TestStripMetadata(t *testing.T) {
+	for _, output := range []string{"out.jpg", "out.mp4", "out.mp3"} {
+		args := resolveArgs(t, output, op("stripMetadata", nil))
+		assert.Equal(t, "-1", flagValue(args, "-map_metadata"), output)
+		assert.Equal(t, "-1", flagValue(args, "-map_chapters"), output)
+	}
+}
+
+// exifJPEG builds the start of a JPEG file whose EXIF block has the given orientation
+func exifJPEG(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := new(bytes.Buffer)
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	binary.Write(tiff, order, uint16(42))
+	binary.Write(tiff, order, uint32(8))
+	binary.Write(tiff, order, uint16(2)) // Entries
+	// ImageWidth, then Orientation
+	binary.Write(tiff, order, []uint16{0x0100, 3})
+	binary.Write(tiff, order, uint32(1))
+	binary.Write(tiff, order, []uint16{640, 0})
+	binary.Write(tiff, order, []uint16{0x0112, 3})
+	binary.Write(tiff, order, uint32(1))
+	binary.Write(tiff, order, []uint16{orientation, 0})
+	binary.Write(tiff, order, uint32(0)) // No next IFD
+
+	app1 := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	jpeg := []byte{0xFF, 0xD8}
+	// A JFIF APP0 segment comes first in most files
+	jpeg = append(jpeg, 0xFF, 0xE0, 0x00, 0x06, 'J', 'F', 'I', 'F')
+	jpeg = append(jpeg, 0xFF, 0xE1, byte((len(app1)+2)>>8), byte(len(app1)+2))
+	jpeg = append(jpeg, app1...)
+	return append(jpeg, 0xFF, 0xDA, 0x00, 0x02)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"little endian", exifJPEG(binary.LittleEndian, 6), 6},
+		{"big endian", exifJPEG(binary.BigEndian, 8), 8},
+		{"out of range", exifJPEG(binary.BigEndian, 12), 1},
+		{"no exif", []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}, 1},
+		{"not a jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"truncated", exifJPEG(binary.LittleEndian, 6)[:20], 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, readJPEGOrientation(bufio.NewReader(bytes.NewReader(tt.data))))
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	require.NoError(t, os.WriteFile(path, exifJPEG(binary.LittleEndian, 3), 0644))
+	assert.Equal(t, 3, exifOrientation(path))
+	assert.Equal(t, 1, exifOrientation(filepath.Join(t.TempDir(), "missing.jpg")))
+}
+
+func TestOrientImage(t *testing.T) {
+	spec, err := resolveOutputSpec("in.jpg", "out.png", []Operation{
+		op("crop", map[string]interface{}{"width": 100.0, "height": 50.0}),
+	}, testEncoder)
+	require.NoError(t, err)
+
+	orientImage(spec, 6)
+	args := spec.Args()
+	assert.Equal(t, "transpose=1,crop=100:50:0:0", flagValue(args, "-vf"))
+	assert.Equal(t, "-noautorotate", args[indexOf(args, "-i")-1])
+
+	// Upright images are left alone
+	spec, err = resolveOutputSpec("in.jpg", "out.png", nil, testEncoder)
+	require.NoError(t, err)
+	orientImage(spec, 1)
+	assert.NotContains(t, spec.Args(), "-noautorotate")
+	assert.NotContains(t, spec.Args(), "-vf")
+}
@@ -0,0 +1,496 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Defaults for merge targets the inputs don't determine
+const (
+	mergeDefaultFPS        = 30
+	mergeDefaultSampleRate = 48000
+	mergeMaxFPS            = 120
+)
+
+// copyCodecs lists the codecs each container accepts by stream copy. Matroska
+// takes any codec; containers missing here are always re-encoded into.
+var copyCodecs = map[string]map[string]bool{
+	"mp4":  {"h264": true, "hevc": true, "av1": true, "mpeg4": true, "aac": true, "mp3": true, "alac": true, "ac3": true, "eac3": true, "opus": true, "flac": true},
+	"mov":  {"h264": true, "hevc": true, "prores": true, "mjpeg": true, "mpeg4": true, "aac": true, "alac": true, "mp3": true, "pcm_s16le": true, "pcm_s24le": true},
+	"webm": {"vp8": true, "vp9": true, "av1": true, "opus": true, "vorbis": true},
+	"m4a":  {"aac": true, "alac": true},
+	"mp3":  {"mp3": true},
+	"flac": {"flac": true},
+	"ogg":  {"vorbis": true, "opus": true, "flac": true},
+	"wav":  {"pcm_s16le": true, "pcm_s24le": true, "pcm_f32le": true},
+}
+
+// canStreamCopy reports whether container accepts codec without re-encoding
+func canStreamCopy(container, codec string) bool {
+	if container == "mkv" {
+		return true
+	}
+	return copyCodecs[container][codec]
+}
+
+// mergeTransitionTypes maps merge's transition names onto xfade transitions;
+// "cut" joins the clips without one
+var mergeTransitionTypes = map[string]string{
+	"cut":        "",
+	"crossfade":  "fade",
+	"fadeblack":  "fadeblack",
+	"fadewhite":  "fadewhite",
+	"wipeleft":   "wipeleft",
+	"wiperight":  "wiperight",
+	"wipeup":     "wipeup",
+	"wipedown":   "wipedown",
+	"slideleft":  "slideleft",
+	"slideright": "slideright",
+	"slideup":    "slideup",
+	"slidedown":  "slidedown",
+}
+
+// mergeTransition is the join between two consecutive clips
+type mergeTransition struct {
+	Type     string  // xfade transition, or "" for a cut
+	Duration float64 // Seconds the clips overlap
+}
+
+// mergeTransitionsParam lists the transition at each boundary: the first item
+// joins clips 1 and 2, the second clips 2 and 3, and so on
+func mergeTransitionsParam() ParamSpec {
+	names := make([]string, 0, len(mergeTransitionTypes))
+	for name := range mergeTransitionTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	_, hi := numberRange(0, 99)
+	return ParamSpec{
+		Name:        "transitions",
+		Type:        ParamList,
+		Description: "Transition at each boundary between clips, in order; missing ones are cuts",
+		Max:         hi,
+		Fields: []ParamSpec{
+			enumParam("type", "crossfade", "Transition between the two clips", names...),
+			numberParam("duration", 1, 0.1, 10, "Length of the transition in seconds"),
+		},
+	}
+}
+
+// parseMergeTransitions returns one transition per boundary between count clips
+func parseMergeTransitions(p Params, count int) ([]mergeTransition, error) {
+	items := p.List("transitions")
+	if len(items) > count-1 {
+		return nil, fmt.Errorf("%w: merge of %d clips takes at most %d transitions, got %d", ErrInvalidOperation, count, count-1, len(items))
+	}
+	transitions := make([]mergeTransition, count-1)
+	for i, item := range items {
+		xfade := mergeTransitionTypes[item.String("type")]
+		if xfade != "" {
+			transitions[i] = mergeTransition{Type: xfade, Duration: item.Float("duration")}
+		}
+	}
+	return transitions, nil
+}
+
+// hasTransitions reports whether any boundary is more than a cut
+func hasTransitions(transitions []mergeTransition) bool {
+	for _, t := range transitions {
+		if t.Type != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// requireMergeFilters checks for the filters merge needs when it has to re-encode
+func requireMergeFilters(p Params, caps *Capabilities) error {
+	needs := []string{"concat"}
+	for _, item := range p.List("transitions") {
+		if mergeTransitionTypes[item.String("type")] != "" {
+			needs = append(needs, "xfade", "acrossfade")
+			break
+		}
+	}
+	return requireFilters(needs...)(p, caps)
+}
+
+// mergeTimeline checks that each clip is long enough for the transitions into
+// and out of it and returns the length of the merged output: transitions
+// overlap the end of one clip with the start of the next
+func mergeTimeline(durations []float64, transitions []mergeTransition) (float64, error) {
+	var total float64
+	for i, d := range durations {
+		total += d
+		var in, out float64
+		if i > 0 {
+			in = transitions[i-1].Duration
+		}
+		if i < len(transitions) {
+			out = transitions[i].Duration
+			total -= out
+		}
+		if in+out > 0 && in+out >= d {
+			return 0, fmt.Errorf("%w: clip %d (%gs) is too short for %gs of transitions", ErrInvalidOperation, i+1, d, in+out)
+		}
+	}
+	return total, nil
+}
+
+// mergeTarget is the common format inputs are re-encoded to when they can't be copied
+type mergeTarget struct {
+	Width, Height int
+	FPS           float64
+	SampleRate    int
+	Layout        string // "mono" or "stereo"
+}
+
+func (p *Processor) processMerge(ctx context.Context, opts ProcessOptions, params Params) error {
+	// Collect all input paths
+	inputPaths := opts.InputPaths
+	if len(inputPaths) == 0 && opts.InputPath != "" {
+		inputPaths = []string{opts.InputPath}
+	}
+
+	if len(inputPaths) < 2 {
+		return fmt.Errorf("merge requires at least 2 input files")
+	}
+
+	transitions, err := parseMergeTransitions(params, len(inputPaths))
+	if err != nil {
+		return err
+	}
+
+	infos := make([]*MediaInfo, len(inputPaths))
+	durations := make([]float64, len(inputPaths))
+	for i, path := range inputPaths {
+		info, err := p.Probe(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to probe merge input %d: %w", i+1, err)
+		}
+		infos[i], durations[i] = info, info.Duration
+	}
+	// The merged output is the inputs laid end to end, less the transitions' overlap
+	total, err := mergeTimeline(durations, transitions)
+	if err != nil {
+		return err
+	}
+
+	p.logger.Info("Merging videos",
+		zap.Int("count", len(inputPaths)),
+		zap.Strings("inputs", inputPaths),
+		zap.String("output", opts.OutputPath),
+	)
+
+	container := containerFromPath(opts.OutputPath)
+	enc := p.encoderSettings(&opts)
+	reencode := params.Has("width") || params.Has("height") || params.Has("fps") || hasTransitions(transitions)
+
+	// Inputs from the same source join losslessly and in a fraction of the time
+	if !reencode && mergeCopyCompatible(infos, container) {
+		scratch, err := os.MkdirTemp("", "merge-"+opts.JobID+"-")
+		if err != nil {
+			return fmt.Errorf("failed to create scratch directory: %w", err)
+		}
+		defer os.RemoveAll(scratch)
+
+		list := filepath.Join(scratch, "inputs.txt")
+		if err := os.WriteFile(list, []byte(concatList(inputPaths)), 0644); err != nil {
+			return fmt.Errorf("failed to write concat list: %w", err)
+		}
+		args := mergeCopySpec(list, opts.OutputPath, infos[0], enc).Args()
+		p.logger.Info("Executing FFmpeg merge with stream copy", zap.Strings("args", args))
+		if err := p.runFFmpeg(ctx, args, total, "Merging", opts.OnProgress); err != nil {
+			return fmt.Errorf("FFmpeg merge failed: %w", err)
+		}
+		return nil
+	}
+
+	spec, err := mergeEncodeSpec(inputPaths, infos, opts.OutputPath, deriveMergeTarget(infos, params), transitions, enc)
+	if err != nil {
+		return err
+	}
+	args := spec.Args()
+	p.logger.Info("Executing FFmpeg merge with re-encoding",
+		zap.Strings("args", args),
+		zap.Float64("expected_duration", total),
+	)
+	if err := p.runFFmpeg(ctx, args, total, "Merging", opts.OnProgress); err != nil {
+		return fmt.Errorf("FFmpeg merge failed: %w", err)
+	}
+	return nil
+}
+
+// mergeCopyCompatible reports whether the inputs can be joined by the concat
+// demuxer without re-encoding: every audio and video stream must match the
+// first input's in codec, picture size, pixel format, frame rate, time base
+// and audio layout, and the output container must accept the codecs
+func mergeCopyCompatible(infos []*MediaInfo, container string) bool {
+	first := mediaStreams(infos[0])
+	if len(first) == 0 {
+		return false
+	}
+	for _, s := range first {
+		if !canStreamCopy(container, s.Codec) {
+			return false
+		}
+		if s.Type == "video" && audioContainers[container] {
+			return false // The video would have to be dropped, so nothing is gained
+		}
+	}
+	for _, info := range infos[1:] {
+		streams := mediaStreams(info)
+		if len(streams) != len(first) {
+			return false
+		}
+		for i, s := range streams {
+			if !sameStreamFormat(first[i], s) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// mediaStreams returns the audio and video streams of an input, in file order
+func mediaStreams(info *MediaInfo) []StreamInfo {
+	var streams []StreamInfo
+	for _, s := range info.Streams {
+		if s.Type == "video" || s.Type == "audio" {
+			streams = append(streams, s)
+		}
+	}
+	return streams
+}
+
+// sameStreamFormat compares what the concat demuxer needs to be identical
+func sameStreamFormat(a, b StreamInfo) bool {
+	if a.Index != b.Index || a.Type != b.Type || a.Codec != b.Codec || a.TimeBase != b.TimeBase {
+		return false
+	}
+	if a.Type == "video" {
+		return a.Width == b.Width && a.Height == b.Height &&
+			a.PixelFormat == b.PixelFormat && a.Rotation == b.Rotation &&
+			math.Abs(a.FrameRate-b.FrameRate) < 0.01
+	}
+	return a.SampleRate == b.SampleRate && a.Channels == b.Channels && a.ChannelLayout == b.ChannelLayout
+}
+
+// concatList writes the concat demuxer's input list, quoting each path
+func concatList(paths []string) string {
+	var b strings.Builder
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs // Relative entries resolve against the list's own directory
+		}
+		fmt.Fprintf(&b, "file '%s'\n", strings.ReplaceAll(path, "'", `'\''`))
+	}
+	return b.String()
+}
+
+// mergeCopySpec joins the inputs listed in list with the concat demuxer,
+// copying their first video and audio streams
+func mergeCopySpec(list, outputPath string, first *MediaInfo, enc encoderSettings) *OutputSpec {
+	spec := &OutputSpec{
+		Inputs:     []InputSpec{{Path: list, Options: []string{"-f", "concat", "-safe", "0"}}},
+		Container:  containerFromPath(outputPath),
+		OutputPath: outputPath,
+		Threads:    enc.Threads,
+	}
+	if first.VideoCodec != "" {
+		spec.Maps = append(spec.Maps, "0:v:0")
+		spec.VideoCodec = "copy"
+	}
+	if first.AudioCodec != "" {
+		spec.Maps = append(spec.Maps, "0:a:0")
+		spec.AudioCodec = "copy"
+	}
+	return spec
+}
+
+// deriveMergeTarget picks the re-encoding format from the inputs, so nothing
+// is downscaled or dropped to a lower frame rate: the largest picture, the
+// highest frame rate and sample rate, and stereo unless every input is mono.
+// An explicit width, height or fps on the merge operation wins.
+func deriveMergeTarget(infos []*MediaInfo, params Params) mergeTarget {
+	t := mergeTarget{SampleRate: mergeDefaultSampleRate, Layout: "mono"}
+	sampleRate := 0
+	anyAudio := false
+	for _, info := range infos {
+		if info.VideoCodec != "" {
+			w, h := info.Width, info.Height
+			if info.Rotation == 90 || info.Rotation == 270 {
+				w, h = h, w // Decoding applies the rotation
+			}
+			if w*h > t.Width*t.Height {
+				t.Width, t.Height = w, h
+			}
+			t.FPS = math.Max(t.FPS, info.FrameRate)
+		}
+		for _, s := range info.Streams {
+			if s.Type == "audio" {
+				anyAudio = true
+				sampleRate = max(sampleRate, s.SampleRate)
+				if s.Channels != 1 {
+					t.Layout = "stereo"
+				}
+				break
+			}
+		}
+	}
+	if sampleRate > 0 {
+		t.SampleRate = sampleRate
+	}
+	if !anyAudio {
+		t.Layout = "stereo"
+	}
+	if t.FPS <= 0 {
+		t.FPS = mergeDefaultFPS
+	}
+	t.FPS = math.Min(t.FPS, mergeMaxFPS)
+
+	w, h := params.Int("width"), params.Int("height")
+	switch {
+	case w > 0 && h > 0:
+		t.Width, t.Height = w, h
+	case w > 0 && t.Width > 0:
+		t.Width, t.Height = w, int(math.Round(float64(w)*float64(t.Height)/float64(t.Width)))
+	case h > 0 && t.Height > 0:
+		t.Width, t.Height = int(math.Round(float64(h)*float64(t.Width)/float64(t.Height))), h
+	}
+	// 4:2:0 chroma needs even dimensions
+	t.Width, t.Height = t.Width&^1, t.Height&^1
+	if params.Has("fps") {
+		t.FPS = params.Float("fps")
+	}
+	return t
+}
+
+// mergeEncodeSpec joins the inputs in a filtergraph after bringing each to the
+// target format. Inputs without a video or audio stream are given black
+// frames or silence for their duration, so every segment has both.
+func mergeEncodeSpec(inputPaths []string, infos []*MediaInfo, outputPath string, t mergeTarget, transitions []mergeTransition, enc encoderSettings) (*OutputSpec, error) {
+	spec := &OutputSpec{
+		Container:  containerFromPath(outputPath),
+		OutputPath: outputPath,
+		Threads:    enc.Threads,
+	}
+
+	anyVideo, anyAudio := false, false
+	for _, info := range infos {
+		anyVideo = anyVideo || info.VideoCodec != ""
+		anyAudio = anyAudio || info.AudioCodec != ""
+	}
+	withVideo := anyVideo && t.Width > 0 && t.Height > 0 && !audioContainers[spec.Container]
+	if !withVideo && !anyAudio {
+		return nil, fmt.Errorf("%w: merge inputs have no streams the output can hold", ErrInvalidOperation)
+	}
+
+	fps := strconv.FormatFloat(t.FPS, 'f', -1, 64)
+	var segments strings.Builder
+	for i, path := range inputPaths {
+		spec.Inputs = append(spec.Inputs, InputSpec{Path: path})
+		info := infos[i]
+		missing := (withVideo && info.VideoCodec == "") || (anyAudio && info.AudioCodec == "")
+		if missing && info.Duration <= 0 {
+			return nil, fmt.Errorf("%w: merge input %d has no audio or video and an unknown duration", ErrInvalidOperation, i+1)
+		}
+
+		if withVideo {
+			if info.VideoCodec != "" {
+				spec.FilterComplex = append(spec.FilterComplex, fmt.Sprintf(
+					"[%d:v:0]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%s,format=yuv420p,settb=AVTB[v%d]",
+					i, t.Width, t.Height, t.Width, t.Height, fps, i))
+			} else {
+				spec.FilterComplex = append(spec.FilterComplex, fmt.Sprintf(
+					"color=c=black:s=%dx%d:r=%s:d=%g,setsar=1,format=yuv420p,settb=AVTB[v%d]", t.Width, t.Height, fps, info.Duration, i))
+			}
+			fmt.Fprintf(&segments, "[v%d]", i)
+		}
+		if anyAudio {
+			if info.AudioCodec != "" {
+				spec.FilterComplex = append(spec.FilterComplex, fmt.Sprintf(
+					"[%d:a:0]aformat=sample_fmts=fltp:sample_rates=%d:channel_layouts=%s[a%d]", i, t.SampleRate, t.Layout, i))
+			} else {
+				spec.FilterComplex = append(spec.FilterComplex, fmt.Sprintf(
+					"anullsrc=r=%d:cl=%s,atrim=duration=%g,aformat=sample_fmts=fltp[a%d]", t.SampleRate, t.Layout, info.Duration, i))
+			}
+			fmt.Fprintf(&segments, "[a%d]", i)
+		}
+	}
+
+	v, a, outputs := 0, 0, ""
+	if withVideo {
+		v, outputs = 1, "[outv]"
+		spec.Maps = append(spec.Maps, "[outv]")
+	}
+	if anyAudio {
+		a, outputs = 1, outputs+"[outa]"
+		spec.Maps = append(spec.Maps, "[outa]")
+	}
+	if hasTransitions(transitions) {
+		durations := make([]float64, len(infos))
+		for i, info := range infos {
+			durations[i] = info.Duration
+		}
+		if withVideo {
+			spec.FilterComplex = append(spec.FilterComplex, transitionChains("v", durations, transitions)...)
+		}
+		if anyAudio {
+			spec.FilterComplex = append(spec.FilterComplex, transitionChains("a", durations, transitions)...)
+		}
+	} else {
+		spec.FilterComplex = append(spec.FilterComplex,
+			fmt.Sprintf("%sconcat=n=%d:v=%d:a=%d%s", segments.String(), len(inputPaths), v, a, outputs))
+	}
+
+	if !withVideo {
+		// The audio encoder follows the container, as for any audio conversion
+		spec.DropVideo = true
+		return spec, nil
+	}
+	if !anyAudio {
+		spec.DropAudio = true
+	}
+	useDefaultEncoders(spec, enc)
+	if spec.AudioCodec == "aac" {
+		spec.AudioBitrate = "192k"
+	}
+	return spec, nil
+}
+
+// transitionChains joins the normalized clips of one stream kind ("v" for
+// [v0][v1].., "a" for [a0][a1]..) pairwise into [outv] or [outa]. Each xfade
+// starts where the output so far ends, less the transition's overlap.
+func transitionChains(kind string, durations []float64, transitions []mergeTransition) []string {
+	chains := make([]string, 0, len(transitions))
+	prev, length := kind+"0", durations[0]
+	for i, t := range transitions {
+		next := fmt.Sprintf("%sx%d", kind, i+1)
+		if i == len(transitions)-1 {
+			next = "out" + kind
+		}
+		var join string
+		switch {
+		case t.Type == "" && kind == "v":
+			join = "concat=n=2:v=1:a=0"
+		case t.Type == "":
+			join = "concat=n=2:v=0:a=1"
+		case kind == "v":
+			join = fmt.Sprintf("xfade=transition=%s:duration=%g:offset=%s", t.Type, t.Duration, strconv.FormatFloat(length-t.Duration, 'f', 3, 64))
+		default:
+			join = fmt.Sprintf("acrossfade=d=%g", t.Duration)
+		}
+		chains = append(chains, fmt.Sprintf("[%s][%s%d]%s[%s]", prev, kind, i+1, join, next))
+		prev, length = next, length+durations[i+1]-t.Duration
+	}
+	return chains
+}
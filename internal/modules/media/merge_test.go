@@ -0,0 +1,196 @@
+package media
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cameraClip is a probed 4K 60 fps H.264 clip with stereo AAC audio
+func cameraClip(duration float64) *MediaInfo {
+	return &MediaInfo{
+		Duration: duration, VideoCodec: "h264", AudioCodec: "aac",
+		Width: 3840, Height: 2160, FrameRate: 59.94,
+		Streams: []StreamInfo{
+			{Index: 0, Type: "video", Codec: "h264", Width: 3840, Height: 2160, FrameRate: 59.94, PixelFormat: "yuv420p", TimeBase: "1/60000"},
+			{Index: 1, Type: "audio", Codec: "aac", Channels: 2, SampleRate: 48000, ChannelLayout: "stereo", TimeBase: "1/48000"},
+		},
+	}
+}
+
+func TestMergeCopyCompatible(t *testing.T) {
+	t.Run("clips from the same camera", func(t *testing.T) {
+		assert.True(t, mergeCopyCompatible([]*MediaInfo{cameraClip(10), cameraClip(20), cameraClip(5)}, "mp4"))
+		assert.True(t, mergeCopyCompatible([]*MediaInfo{cameraClip(10), cameraClip(20)}, "mkv"))
+	})
+
+	t.Run("different resolution", func(t *testing.T) {
+		other := cameraClip(10)
+		other.Streams[0].Width, other.Streams[0].Height = 1920, 1080
+		assert.False(t, mergeCopyCompatible([]*MediaInfo{cameraClip(10), other}, "mp4"))
+	})
+
+	t.Run("different audio layout", func(t *testing.T) {
+		other := cameraClip(10)
+		other.Streams[1].Channels, other.Streams[1].ChannelLayout = 1, "mono"
+		assert.False(t, mergeCopyCompatible([]*MediaInfo{cameraClip(10), other}, "mp4"))
+	})
+
+	t.Run("missing audio stream", func(t *testing.T) {
+		silent := cameraClip(10)
+		silent.AudioCodec, silent.Streams = "", silent.Streams[:1]
+		assert.False(t, mergeCopyCompatible([]*MediaInfo{cameraClip(10), silent}, "mp4"))
+	})
+
+	t.Run("codecs the container can't hold", func(t *testing.T) {
+		assert.False(t, mergeCopyCompatible([]*MediaInfo{cameraClip(10), cameraClip(20)}, "webm"))
+		assert.False(t, mergeCopyCompatible([]*MediaInfo{cameraClip(10), cameraClip(20)}, "m4a"))
+	})
+}
+
+func TestMergeCopySpec(t *testing.T) {
+	args := strings.Join(mergeCopySpec("/tmp/merge/inputs.txt", "/out/merged.mp4", cameraClip(10), encoderSettings{}).Args(), " ")
+
+	assert.Contains(t, args, "-f concat -safe 0 -i /tmp/merge/inputs.txt")
+	assert.Contains(t, args, "-map 0:v:0 -map 0:a:0 -c:v copy -c:a copy")
+	assert.Contains(t, args, "-movflags +faststart /out/merged.mp4")
+}
+
+func TestConcatList(t *testing.T) {
+	assert.Equal(t, "file '/data/a.mp4'\nfile '/data/it'\\''s.mp4'\n", concatList([]string{"/data/a.mp4", "/data/it's.mp4"}))
+}
+
+func TestDeriveMergeTarget(t *testing.T) {
+	phone := &MediaInfo{
+		VideoCodec: "hevc", AudioCodec: "aac", Width: 1920, Height: 1080, FrameRate: 30, Rotation: 90,
+		Streams: []StreamInfo{{Type: "video"}, {Type: "audio", Channels: 1, SampleRate: 44100}},
+	}
+
+	t.Run("follows the inputs", func(t *testing.T) {
+		target := deriveMergeTarget([]*MediaInfo{phone, cameraClip(10)}, Params{})
+		assert.Equal(t, mergeTarget{Width: 3840, Height: 2160, FPS: 59.94, SampleRate: 48000, Layout: "stereo"}, target)
+	})
+
+	t.Run("rotation swaps the picture", func(t *testing.T) {
+		target := deriveMergeTarget([]*MediaInfo{phone, phone}, Params{})
+		assert.Equal(t, mergeTarget{Width: 1080, Height: 1920, FPS: 30, SampleRate: 44100, Layout: "mono"}, target)
+	})
+
+	t.Run("explicit width keeps the aspect ratio", func(t *testing.T) {
+		target := deriveMergeTarget([]*MediaInfo{cameraClip(10), cameraClip(10)}, Params{"width": 1280, "fps": 25.0})
+		assert.Equal(t, 1280, target.Width)
+		assert.Equal(t, 720, target.Height)
+		assert.Equal(t, 25.0, target.FPS)
+	})
+
+	t.Run("audio only", func(t *testing.T) {
+		song := &MediaInfo{AudioCodec: "mp3", Streams: []StreamInfo{{Type: "audio", Channels: 2, SampleRate: 44100}}}
+		target := deriveMergeTarget([]*MediaInfo{song, song}, Params{})
+		assert.Zero(t, target.Width)
+		assert.Equal(t, 44100, target.SampleRate)
+	})
+}
+
+func TestMergeEncodeSpec(t *testing.T) {
+	target := mergeTarget{Width: 1920, Height: 1080, FPS: 30, SampleRate: 48000, Layout: "stereo"}
+	silent := &MediaInfo{Duration: 12.5, VideoCodec: "h264", Width: 1280, Height: 720, Streams: []StreamInfo{{Type: "video"}}}
+
+	t.Run("input without audio gets silence", func(t *testing.T) {
+		spec, err := mergeEncodeSpec([]string{"a.mp4", "b.mp4"}, []*MediaInfo{cameraClip(10), silent}, "/out/merged.mp4", target, nil, encoderSettings{Preset: "fast"})
+		require.NoError(t, err)
+
+		graph := strings.Join(spec.FilterComplex, ";")
+		assert.Contains(t, graph, "[0:v:0]scale=1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=30,format=yuv420p,settb=AVTB[v0]")
+		assert.Contains(t, graph, "[0:a:0]aformat=sample_fmts=fltp:sample_rates=48000:channel_layouts=stereo[a0]")
+		assert.Contains(t, graph, "anullsrc=r=48000:cl=stereo,atrim=duration=12.5,aformat=sample_fmts=fltp[a1]")
+		assert.NotContains(t, graph, "[1:a")
+		assert.Contains(t, graph, "[v0][a0][v1][a1]concat=n=2:v=1:a=1[outv][outa]")
+		assert.Equal(t, []string{"[outv]", "[outa]"}, spec.Maps)
+		assert.Equal(t, "libx264", spec.VideoCodec)
+		assert.Equal(t, "fast", spec.Rate.Preset)
+	})
+
+	t.Run("no input has audio", func(t *testing.T) {
+		spec, err := mergeEncodeSpec([]string{"a.mp4", "b.mp4"}, []*MediaInfo{silent, silent}, "/out/merged.mp4", target, nil, encoderSettings{})
+		require.NoError(t, err)
+
+		assert.Contains(t, spec.FilterComplex[len(spec.FilterComplex)-1], "[v0][v1]concat=n=2:v=1:a=0[outv]")
+		assert.Equal(t, []string{"[outv]"}, spec.Maps)
+		assert.True(t, spec.DropAudio)
+	})
+
+	t.Run("audio output drops the video", func(t *testing.T) {
+		spec, err := mergeEncodeSpec([]string{"a.mp4", "b.mp4"}, []*MediaInfo{cameraClip(10), cameraClip(5)}, "/out/merged.mp3", target, nil, encoderSettings{})
+		require.NoError(t, err)
+
+		assert.True(t, spec.DropVideo)
+		assert.Equal(t, []string{"[outa]"}, spec.Maps)
+		assert.NotContains(t, strings.Join(spec.FilterComplex, ";"), "[0:v:0]")
+	})
+}
+
+func TestParseMergeTransitions(t *testing.T) {
+	spec, _ := LookupOperation("merge")
+	params, problems := spec.validate(map[string]interface{}{
+		"transitions": []interface{}{
+			map[string]interface{}{"type": "crossfade", "duration": 0.5},
+			map[string]interface{}{"type": "cut"},
+			map[string]interface{}{"type": "wipeleft"},
+		},
+	})
+	require.Empty(t, problems)
+
+	transitions, err := parseMergeTransitions(params, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []mergeTransition{{Type: "fade", Duration: 0.5}, {}, {Type: "wipeleft", Duration: 1}, {}}, transitions)
+
+	_, err = parseMergeTransitions(params, 3)
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+}
+
+func TestMergeTimeline(t *testing.T) {
+	transitions := []mergeTransition{{Type: "fade", Duration: 1}, {}, {Type: "fadeblack", Duration: 2}}
+
+	total, err := mergeTimeline([]float64{10, 5, 8, 6}, transitions)
+	require.NoError(t, err)
+	assert.Equal(t, 26.0, total)
+
+	total, err = mergeTimeline([]float64{10, 5}, []mergeTransition{{}})
+	require.NoError(t, err)
+	assert.Equal(t, 15.0, total, "cuts don't overlap")
+
+	_, err = mergeTimeline([]float64{10, 2.5, 8}, []mergeTransition{{Type: "fade", Duration: 1.5}, {Type: "fade", Duration: 1}})
+	assert.ErrorIs(t, err, ErrInvalidOperation, "the middle clip can't hold both transitions")
+}
+
+func TestTransitionChains(t *testing.T) {
+	durations := []float64{10, 5, 8, 6}
+	transitions := []mergeTransition{{Type: "fade", Duration: 1}, {}, {Type: "fadeblack", Duration: 2}}
+
+	assert.Equal(t, []string{
+		"[v0][v1]xfade=transition=fade:duration=1:offset=9.000[vx1]",
+		"[vx1][v2]concat=n=2:v=1:a=0[vx2]",
+		"[vx2][v3]xfade=transition=fadeblack:duration=2:offset=20.000[outv]",
+	}, transitionChains("v", durations, transitions))
+
+	assert.Equal(t, []string{
+		"[a0][a1]acrossfade=d=1[ax1]",
+		"[ax1][a2]concat=n=2:v=0:a=1[ax2]",
+		"[ax2][a3]acrossfade=d=2[outa]",
+	}, transitionChains("a", durations, transitions))
+}
+
+func TestMergeEncodeSpecTransitions(t *testing.T) {
+	target := mergeTarget{Width: 1920, Height: 1080, FPS: 30, SampleRate: 48000, Layout: "stereo"}
+	spec, err := mergeEncodeSpec([]string{"a.mp4", "b.mp4"}, []*MediaInfo{cameraClip(10), cameraClip(5)}, "/out/merged.mp4", target,
+		[]mergeTransition{{Type: "slideleft", Duration: 0.5}}, encoderSettings{})
+	require.NoError(t, err)
+
+	graph := strings.Join(spec.FilterComplex, ";")
+	assert.Contains(t, graph, "[v0][v1]xfade=transition=slideleft:duration=0.5:offset=9.500[outv]")
+	assert.Contains(t, graph, "[a0][a1]acrossfade=d=0.5[outa]")
+	assert.NotContains(t, graph, "concat")
+	assert.Equal(t, []string{"[outv]", "[outa]"}, spec.Maps)
+}
@@ -3,24 +3,36 @@ package media
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nextconvert/backend/internal/modules/jobs"
 	"github.com/nextconvert/backend/internal/shared/database"
 	"github.com/nextconvert/backend/internal/shared/storage"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
 // Module handles media operations
 type Module struct {
-	db       *database.Postgres
-	storage  *storage.Service
-	jobQueue *jobs.QueueClient
-	logger   *zap.Logger
-	presets  map[string]Preset
+	db          *database.Postgres
+	storage     *storage.Service
+	jobQueue    *jobs.QueueClient
+	logger      *zap.Logger
+	presets     map[string]Preset
+	ffprobePath string
+
+	// FFmpeg capabilities published by the workers (see SetCapabilityStore)
+	redis       *database.Redis
+	capsMu      sync.Mutex
+	caps        *Capabilities
+	capsFetched time.Time
 }
 
 // Operation represents a media operation
@@ -40,17 +52,38 @@ type MediaInfo struct {
 	Width      int          `json:"width,omitempty"`
 	Height     int          `json:"height,omitempty"`
 	FrameRate  float64      `json:"frameRate,omitempty"`
+	Rotation   int          `json:"rotation,omitempty"` // Display rotation in degrees clockwise (0, 90, 180, 270)
 	Streams    []StreamInfo `json:"streams"`
 }
 
+// HasDecodableStreams reports whether ffprobe found any audio or video stream
+func (i *MediaInfo) HasDecodableStreams() bool {
+	for _, s := range i.Streams {
+		if (s.Type == "video" || s.Type == "audio") && s.Codec != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // StreamInfo contains information about a media stream
 type StreamInfo struct {
-	Index      int    `json:"index"`
-	Type       string `json:"type"`
-	Codec      string `json:"codec"`
-	BitRate    int    `json:"bitRate,omitempty"`
-	Channels   int    `json:"channels,omitempty"`
-	SampleRate int    `json:"sampleRate,omitempty"`
+	Index      int     `json:"index"`
+	Type       string  `json:"type"`
+	Codec      string  `json:"codec"`
+	BitRate    int     `json:"bitRate,omitempty"`
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	FrameRate  float64 `json:"frameRate,omitempty"`
+	Rotation   int     `json:"rotation,omitempty"`
+	Channels   int     `json:"channels,omitempty"`
+	SampleRate int     `json:"sampleRate,omitempty"`
+	Language   string  `json:"language,omitempty"`
+
+	// Stream parameters that must match for files to be joined without re-encoding
+	PixelFormat   string `json:"pixelFormat,omitempty"`
+	TimeBase      string `json:"timeBase,omitempty"`
+	ChannelLayout string `json:"channelLayout,omitempty"`
 }
 
 // Preset represents a predefined operation set
@@ -92,17 +125,59 @@ type CodecInfo struct {
 // NewModule creates a new media module
 func NewModule(db *database.Postgres, storage *storage.Service, jobQueue *jobs.QueueClient, logger *zap.Logger) *Module {
 	m := &Module{
-		db:       db,
-		storage:  storage,
-		jobQueue: jobQueue,
-		logger:   logger,
-		presets:  make(map[string]Preset),
+		db:          db,
+		storage:     storage,
+		jobQueue:    jobQueue,
+		logger:      logger,
+		presets:     make(map[string]Preset),
+		ffprobePath: "ffprobe",
 	}
 
 	m.initPresets()
 	return m
 }
 
+// SetFFprobePath sets the ffprobe binary Probe runs (default "ffprobe" on the PATH)
+func (m *Module) SetFFprobePath(path string) {
+	if path != "" {
+		m.ffprobePath = path
+	}
+}
+
+// SetCapabilityStore makes validation and the format and codec lists follow
+// the FFmpeg capabilities workers publish to Redis
+func (m *Module) SetCapabilityStore(redis *database.Redis) {
+	m.redis = redis
+}
+
+// capabilitiesRefresh is how long published capabilities are reused before
+// being read again, so a redeployed worker image is picked up
+const capabilitiesRefresh = time.Minute
+
+// capabilities returns the workers' FFmpeg capabilities, or nil if none
+// have been published
+func (m *Module) capabilities(ctx context.Context) *Capabilities {
+	m.capsMu.Lock()
+	defer m.capsMu.Unlock()
+	if m.redis == nil || time.Since(m.capsFetched) < capabilitiesRefresh {
+		return m.caps
+	}
+
+	caps, err := loadPublishedCapabilities(ctx, m.redis)
+	switch {
+	case errors.Is(err, redis.Nil):
+		m.caps = nil
+	case err != nil:
+		// Keep what we had; Redis being briefly unavailable shouldn't fail validation
+		m.logger.Warn("Failed to load FFmpeg capabilities", zap.Error(err))
+		return m.caps
+	default:
+		m.caps = caps
+	}
+	m.capsFetched = time.Now()
+	return m.caps
+}
+
 func (m *Module) initPresets() {
 	// Mobile optimized preset
 	m.presets["mobile"] = Preset{
@@ -182,20 +257,35 @@ type ffprobeOutput struct {
 		BitRate    string `json:"bit_rate"`
 	} `json:"format"`
 	Streams []struct {
-		Index        int    `json:"index"`
-		CodecType    string `json:"codec_type"`
-		CodecName    string `json:"codec_name"`
-		Width        int    `json:"width,omitempty"`
-		Height       int    `json:"height,omitempty"`
-		RFrameRate   string `json:"r_frame_rate,omitempty"`
-		AvgFrameRate string `json:"avg_frame_rate,omitempty"`
-		BitRate      string `json:"bit_rate,omitempty"`
-		Channels     int    `json:"channels,omitempty"`
-		SampleRate   string `json:"sample_rate,omitempty"`
+		Index         int    `json:"index"`
+		CodecType     string `json:"codec_type"`
+		CodecName     string `json:"codec_name"`
+		Width         int    `json:"width,omitempty"`
+		Height        int    `json:"height,omitempty"`
+		RFrameRate    string `json:"r_frame_rate,omitempty"`
+		AvgFrameRate  string `json:"avg_frame_rate,omitempty"`
+		BitRate       string `json:"bit_rate,omitempty"`
+		Channels      int    `json:"channels,omitempty"`
+		SampleRate    string `json:"sample_rate,omitempty"`
+		ChannelLayout string `json:"channel_layout,omitempty"`
+		PixFmt        string `json:"pix_fmt,omitempty"`
+		TimeBase      string `json:"time_base,omitempty"`
+		Disposition   struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+		Tags struct {
+			Rotate   string `json:"rotate,omitempty"`
+			Language string `json:"language,omitempty"`
+		} `json:"tags"`
+		SideDataList []struct {
+			SideDataType string  `json:"side_data_type"`
+			Rotation     float64 `json:"rotation"`
+		} `json:"side_data_list,omitempty"`
 	} `json:"streams"`
 }
 
-// Probe extracts metadata from a media file
+// Probe extracts metadata from a media file and persists it on the file record,
+// where job creation reads it to charge conversion minutes.
 func (m *Module) Probe(ctx context.Context, fileID string) (*MediaInfo, error) {
 	// Get file path from database
 	var storagePath string
@@ -207,13 +297,13 @@ func (m *Module) Probe(ctx context.Context, fileID string) (*MediaInfo, error) {
 		return nil, fmt.Errorf("file not found: %w", err)
 	}
 
-	// For remote storage (S3), download file to temp location first
-	localPath, cleanup, err := m.storage.PrepareInputForProcessing(ctx, storagePath)
+	// For remote storage (S3), ffprobe reads the object through a presigned URL
+	// instead of the upload request downloading all of it
+	input, err := m.storage.PrepareInputForProbing(ctx, storagePath)
 	if err != nil {
 		m.logger.Error("Failed to prepare file for probing", zap.Error(err), zap.String("storage_path", storagePath))
 		return nil, fmt.Errorf("failed to prepare file: %w", err)
 	}
-	defer cleanup()
 
 	// Run ffprobe
 	args := []string{
@@ -221,30 +311,119 @@ func (m *Module) Probe(ctx context.Context, fileID string) (*MediaInfo, error) {
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
-		localPath,
+		input,
 	}
 
-	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+	cmd := exec.CommandContext(ctx, m.ffprobePath, args...)
 	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// ffprobe ran but could not read the file: record it as having no decodable streams
+		m.logger.Warn("ffprobe could not decode file", zap.Error(err), zap.String("file_id", fileID))
+		info := &MediaInfo{Size: size, Streams: []StreamInfo{}}
+		if storeErr := m.storeMediaInfo(ctx, fileID, info); storeErr != nil {
+			return nil, storeErr
+		}
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
 	if err != nil {
-		m.logger.Error("ffprobe failed", zap.Error(err), zap.String("path", localPath))
+		m.logger.Error("ffprobe failed", zap.Error(err), zap.String("storage_path", storagePath))
 		return nil, fmt.Errorf("ffprobe failed: %w", err)
 	}
 
-	// Parse JSON output
+	info, err := parseProbeOutput(output)
+	if err != nil {
+		m.logger.Error("Failed to parse ffprobe output", zap.Error(err))
+		return nil, err
+	}
+	info.Size = size
+
+	if err := m.storeMediaInfo(ctx, fileID, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// ProbeFile probes a file and persists its metadata (satisfies jobs.MediaProber)
+func (m *Module) ProbeFile(ctx context.Context, fileID string) error {
+	_, err := m.Probe(ctx, fileID)
+	return err
+}
+
+// QueueThumbnails schedules poster, sprite and storyboard generation for an
+// uploaded video. Other files, including audio with cover art, are skipped.
+func (m *Module) QueueThumbnails(ctx context.Context, fileID string, info *MediaInfo) error {
+	if m.jobQueue == nil || info == nil || info.VideoCodec == "" || info.Duration <= 0 {
+		return nil
+	}
+	var mediaType *string
+	if err := m.db.Pool.QueryRow(ctx, "SELECT media_type FROM files WHERE id = $1", fileID).Scan(&mediaType); err != nil {
+		return fmt.Errorf("file not found: %w", err)
+	}
+	if mediaType == nil || *mediaType != "video" {
+		return nil
+	}
+	_, err := m.jobQueue.EnqueueThumbnails(jobs.ThumbnailPayload{FileID: fileID})
+	return err
+}
+
+// QueueWaveform schedules computing a file's waveform peaks with the given
+// options. Invalid options and files without an audio stream are rejected
+// with ErrInvalidOperation.
+func (m *Module) QueueWaveform(fileID string, info *MediaInfo, opts WaveformOptions) error {
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOperation, err)
+	}
+	if info == nil || info.AudioCodec == "" {
+		return fmt.Errorf("%w: file has no audio to draw a waveform from", ErrInvalidOperation)
+	}
+	if m.jobQueue == nil {
+		return errors.New("job queue not configured")
+	}
+	return m.jobQueue.EnqueueWaveform(jobs.WaveformPayload{
+		FileID:  fileID,
+		Variant: opts.Variant(),
+		WaveformOptions: jobs.WaveformOptions{
+			SamplesPerPixel: opts.SamplesPerPixel,
+			Channels:        opts.Channels,
+			Bits:            opts.Bits,
+		},
+	})
+}
+
+// storeMediaInfo saves probe results in files.metadata
+func (m *Module) storeMediaInfo(ctx context.Context, fileID string, info *MediaInfo) error {
+	infoJSON, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal media info: %w", err)
+	}
+	if _, err := m.db.Pool.Exec(ctx, "UPDATE files SET metadata = $2 WHERE id = $1", fileID, infoJSON); err != nil {
+		m.logger.Error("Failed to store media info", zap.Error(err), zap.String("file_id", fileID))
+		return fmt.Errorf("failed to store media info: %w", err)
+	}
+	return nil
+}
+
+// parseProbeOutput builds MediaInfo from ffprobe's JSON output
+func parseProbeOutput(output []byte) (*MediaInfo, error) {
 	var probeData ffprobeOutput
 	if err := json.Unmarshal(output, &probeData); err != nil {
-		m.logger.Error("Failed to parse ffprobe output", zap.Error(err))
 		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
 	}
 
 	// Build MediaInfo
 	info := &MediaInfo{
 		Format:  probeData.Format.FormatName,
-		Size:    size,
 		Streams: make([]StreamInfo, 0),
 	}
 
+	if probeData.Format.Size != "" {
+		if sz, err := strconv.ParseInt(probeData.Format.Size, 10, 64); err == nil {
+			info.Size = sz
+		}
+	}
+
 	// Parse duration
 	if probeData.Format.Duration != "" {
 		if d, err := strconv.ParseFloat(probeData.Format.Duration, 64); err == nil {
@@ -272,11 +451,13 @@ func (m *Module) Probe(ctx context.Context, fileID string) (*MediaInfo, error) {
 				streamInfo.BitRate = br
 			}
 		}
+		streamInfo.Language = stream.Tags.Language
+		streamInfo.TimeBase = stream.TimeBase
 
 		if stream.CodecType == "video" {
-			info.VideoCodec = stream.CodecName
-			info.Width = stream.Width
-			info.Height = stream.Height
+			streamInfo.Width = stream.Width
+			streamInfo.Height = stream.Height
+			streamInfo.PixelFormat = stream.PixFmt
 
 			// Parse frame rate (format: "30000/1001" or "30/1")
 			frameRateStr := stream.AvgFrameRate
@@ -289,13 +470,38 @@ func (m *Module) Probe(ctx context.Context, fileID string) (*MediaInfo, error) {
 					num, _ := strconv.ParseFloat(parts[0], 64)
 					den, _ := strconv.ParseFloat(parts[1], 64)
 					if den > 0 {
-						info.FrameRate = num / den
+						streamInfo.FrameRate = num / den
 					}
 				}
 			}
+
+			// Rotation: older muxers use a "rotate" tag (clockwise), newer FFmpeg
+			// reports a display matrix whose rotation is counter-clockwise
+			if stream.Tags.Rotate != "" {
+				if r, err := strconv.Atoi(stream.Tags.Rotate); err == nil {
+					streamInfo.Rotation = normalizeRotation(r)
+				}
+			}
+			for _, sd := range stream.SideDataList {
+				if sd.SideDataType == "Display Matrix" {
+					streamInfo.Rotation = normalizeRotation(-int(math.Round(sd.Rotation)))
+				}
+			}
+
+			// The first real video stream (not embedded cover art) describes the file
+			if info.VideoCodec == "" && stream.Disposition.AttachedPic == 0 {
+				info.VideoCodec = stream.CodecName
+				info.Width = streamInfo.Width
+				info.Height = streamInfo.Height
+				info.FrameRate = streamInfo.FrameRate
+				info.Rotation = streamInfo.Rotation
+			}
 		} else if stream.CodecType == "audio" {
-			info.AudioCodec = stream.CodecName
+			if info.AudioCodec == "" {
+				info.AudioCodec = stream.CodecName
+			}
 			streamInfo.Channels = stream.Channels
+			streamInfo.ChannelLayout = stream.ChannelLayout
 			if stream.SampleRate != "" {
 				if sr, err := strconv.Atoi(stream.SampleRate); err == nil {
 					streamInfo.SampleRate = sr
@@ -309,6 +515,15 @@ func (m *Module) Probe(ctx context.Context, fileID string) (*MediaInfo, error) {
 	return info, nil
 }
 
+// normalizeRotation maps any angle in degrees onto [0, 360)
+func normalizeRotation(deg int) int {
+	deg %= 360
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
 // GetPresets returns all presets
 func (m *Module) GetPresets() []Preset {
 	presets := make([]Preset, 0, len(m.presets))
@@ -327,35 +542,91 @@ func (m *Module) GetPreset(id string) (*Preset, error) {
 	return &preset, nil
 }
 
-// ValidateOperations validates a chain of operations
-func (m *Module) ValidateOperations(operations []Operation, inputType string) ValidationResult {
+// ValidateOperations validates a chain of operations against the operation
+// registry and the FFmpeg capabilities of the workers. An outputFormat (file
+// extension) also checks the chain against the container it will be written to.
+func (m *Module) ValidateOperations(ctx context.Context, operations []Operation, inputType, outputFormat string) ValidationResult {
 	result := ValidationResult{Valid: true}
+	var standalone *OperationSpec
+	var standaloneParams Params
 
 	for _, op := range operations {
-		switch op.Type {
-		case "trim", "resize", "compress", "convertFormat", "rotate", "crop",
-			"addWatermark", "addSubtitles", "extractAudio", "changeSpeed", "createGif":
-			// Valid video operations
-			if inputType != "video" && inputType != "" {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Operation '%s' is intended for video", op.Type))
-			}
-		case "changeBitrate", "adjustVolume", "fadeInOut", "merge", "removeSilence":
-			// Valid audio operations
-			if inputType != "audio" && inputType != "" {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Operation '%s' is intended for audio", op.Type))
-			}
-		default:
+		spec, ok := LookupOperation(op.Type)
+		if !ok {
 			result.Valid = false
 			result.Errors = append(result.Errors, fmt.Sprintf("Unknown operation: %s", op.Type))
+			continue
+		}
+
+		params, problems := spec.validate(op.Params)
+		if len(problems) > 0 {
+			result.Valid = false
+			result.Errors = append(result.Errors, problems...)
+		}
+		if inputType != "" && !spec.AppliesTo(inputType) {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Operation '%s' is intended for %s", op.Type, strings.Join(spec.MediaTypes, " or ")))
+		}
+		if spec.Apply == nil {
+			standalone, standaloneParams = spec, params
+		}
+	}
+
+	if !result.Valid {
+		return result
+	}
+	caps := m.capabilities(ctx)
+
+	// Operations that run on their own (merge, packaging) can't share a chain
+	if standalone != nil {
+		if len(operations) > 1 {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("Operation '%s' must be the only operation in a chain", standalone.Type))
+		} else if standalone.Requires != nil {
+			if err := standalone.Requires(standaloneParams, caps); err != nil {
+				result.Valid = false
+				result.Errors = append(result.Errors, err.Error())
+			}
 		}
+		return result
+	}
+
+	// Operations must also agree on a single encoding plan the FFmpeg build can run
+	var outputPath string
+	if outputFormat != "" {
+		outputPath = "output." + strings.TrimPrefix(outputFormat, ".")
+	}
+	spec, err := resolveOutputSpec("", outputPath, operations, encoderSettings{AV1Encoder: caps.AV1Encoder("")})
+	if err == nil {
+		err = caps.checkSpec(spec)
+	}
+	if err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, err.Error())
 	}
 
 	return result
 }
 
-// GetSupportedFormats returns supported media formats
-func (m *Module) GetSupportedFormats() map[string][]FormatInfo {
-	return map[string][]FormatInfo{
+// GetOperations returns the registered operations, optionally only those for a media type
+func (m *Module) GetOperations(mediaType string) []OperationSpec {
+	ops := Operations()
+	if mediaType == "" {
+		return ops
+	}
+
+	filtered := make([]OperationSpec, 0, len(ops))
+	for _, op := range ops {
+		if op.AppliesTo(mediaType) {
+			filtered = append(filtered, op)
+		}
+	}
+	return filtered
+}
+
+// GetSupportedFormats returns supported media formats, as far as the
+// workers' FFmpeg build can read and write them
+func (m *Module) GetSupportedFormats(ctx context.Context) map[string][]FormatInfo {
+	formats := map[string][]FormatInfo{
 		"video": {
 			{Name: "MP4", Extension: "mp4", MimeTypes: []string{"video/mp4"}, Type: "video", Encodable: true, Decodable: true},
 			{Name: "WebM", Extension: "webm", MimeTypes: []string{"video/webm"}, Type: "video", Encodable: true, Decodable: true},
@@ -371,18 +642,46 @@ func (m *Module) GetSupportedFormats() map[string][]FormatInfo {
 			{Name: "FLAC", Extension: "flac", MimeTypes: []string{"audio/flac"}, Type: "audio", Encodable: true, Decodable: true},
 			{Name: "OGG", Extension: "ogg", MimeTypes: []string{"audio/ogg"}, Type: "audio", Encodable: true, Decodable: true},
 		},
+		"image": {
+			{Name: "JPEG", Extension: "jpg", MimeTypes: []string{"image/jpeg"}, Type: "image", Encodable: true, Decodable: true},
+			{Name: "PNG", Extension: "png", MimeTypes: []string{"image/png"}, Type: "image", Encodable: true, Decodable: true},
+			{Name: "WebP", Extension: "webp", MimeTypes: []string{"image/webp"}, Type: "image", Encodable: true, Decodable: true},
+			{Name: "AVIF", Extension: "avif", MimeTypes: []string{"image/avif"}, Type: "image", Encodable: true, Decodable: true},
+			{Name: "BMP", Extension: "bmp", MimeTypes: []string{"image/bmp"}, Type: "image", Encodable: true, Decodable: true},
+		},
+		// Multi-file packages, produced by the packaging operations
+		"streaming": {
+			{Name: "HLS", Extension: "m3u8", MimeTypes: []string{"application/vnd.apple.mpegurl"}, Type: "video", Encodable: true, Decodable: false},
+			{Name: "DASH", Extension: "mpd", MimeTypes: []string{"application/dash+xml"}, Type: "video", Encodable: true, Decodable: false},
+		},
 	}
+
+	caps := m.capabilities(ctx)
+	for _, list := range formats {
+		for i := range list {
+			f := &list[i]
+			f.Encodable = f.Encodable && caps.HasMuxer(muxerFor(f.Extension))
+			if encoder, ok := formatEncoders[f.Extension]; ok {
+				f.Encodable = f.Encodable && caps.HasEncoder(encoder)
+			}
+			f.Decodable = f.Decodable && caps.HasDemuxer(demuxerFor(f.Extension))
+		}
+	}
+	return formats
 }
 
-// GetAvailableCodecs returns available codecs
-func (m *Module) GetAvailableCodecs() map[string][]CodecInfo {
-	return map[string][]CodecInfo{
+// GetAvailableCodecs returns available codecs, named as convertFormat's
+// videoCodec and audioCodec take them, as far as the workers' FFmpeg build
+// can encode and decode them
+func (m *Module) GetAvailableCodecs(ctx context.Context) map[string][]CodecInfo {
+	codecs := map[string][]CodecInfo{
 		"video": {
 			{Name: "h264", LongName: "H.264 / AVC", Type: "video", Encoding: true, Decoding: true},
 			{Name: "h265", LongName: "H.265 / HEVC", Type: "video", Encoding: true, Decoding: true},
 			{Name: "vp8", LongName: "VP8", Type: "video", Encoding: true, Decoding: true},
 			{Name: "vp9", LongName: "VP9", Type: "video", Encoding: true, Decoding: true},
 			{Name: "av1", LongName: "AV1", Type: "video", Encoding: true, Decoding: true},
+			{Name: "mpeg4", LongName: "MPEG-4 Part 2", Type: "video", Encoding: true, Decoding: true},
 		},
 		"audio": {
 			{Name: "aac", LongName: "AAC (Advanced Audio Coding)", Type: "audio", Encoding: true, Decoding: true},
@@ -390,6 +689,18 @@ func (m *Module) GetAvailableCodecs() map[string][]CodecInfo {
 			{Name: "opus", LongName: "Opus", Type: "audio", Encoding: true, Decoding: true},
 			{Name: "flac", LongName: "FLAC (Free Lossless Audio Codec)", Type: "audio", Encoding: true, Decoding: true},
 			{Name: "vorbis", LongName: "Vorbis", Type: "audio", Encoding: true, Decoding: true},
+			{Name: "alac", LongName: "ALAC (Apple Lossless Audio Codec)", Type: "audio", Encoding: true, Decoding: true},
+			{Name: "pcm", LongName: "PCM (uncompressed)", Type: "audio", Encoding: true, Decoding: true},
 		},
 	}
+
+	caps := m.capabilities(ctx)
+	for _, list := range codecs {
+		for i := range list {
+			c := &list[i]
+			c.Encoding = c.Encoding && caps.canEncode(c.Name)
+			c.Decoding = c.Decoding && caps.canDecode(c.Name)
+		}
+	}
+	return codecs
 }
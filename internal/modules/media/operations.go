@@ -0,0 +1,1042 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Media types an operation can apply to
+const (
+	MediaVideo = "video"
+	MediaAudio = "audio"
+	MediaImage = "image"
+)
+
+var (
+	videoOnly     = []string{MediaVideo}
+	audioOnly     = []string{MediaAudio}
+	imageOnly     = []string{MediaImage}
+	videoAndAudio = []string{MediaVideo, MediaAudio}
+	videoAndImage = []string{MediaVideo, MediaImage}
+	allMedia      = []string{MediaVideo, MediaAudio, MediaImage}
+)
+
+// builtinRegistry registers the operations the processor ships with
+func builtinRegistry() *Registry {
+	r := NewRegistry()
+	for _, spec := range builtinOperations() {
+		if err := r.Register(spec); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+func builtinOperations() []OperationSpec {
+	return []OperationSpec{
+		{
+			Type:        "trim",
+			Description: "Keep only the part between a start and end time",
+			MediaTypes:  videoAndAudio,
+			Params: []ParamSpec{
+				{Name: "startTime", Type: ParamTime, Description: "Start of the kept section"},
+				{Name: "endTime", Type: ParamTime, Description: "End of the kept section"},
+			},
+			Apply: applyTrim,
+		},
+		{
+			Type:        "resize",
+			Description: "Scale the video or image",
+			MediaTypes:  videoAndImage,
+			Params: []ParamSpec{
+				intParam("width", 0, 0, 7680, "Target width in pixels (0 = follow height)"),
+				intParam("height", 0, 0, 4320, "Target height in pixels (0 = follow width)"),
+				boolParam("maintainAspect", true, "Fit inside width x height instead of stretching"),
+				optional(enumParam("mode", "", "How to fill width x height: fit inside it, fill it by stretching, or cover it and crop the overflow (overrides maintainAspect)", "fit", "fill", "cover")),
+			},
+			Apply: applyResize,
+		},
+		{
+			Type:        "compress",
+			Description: "Re-encode the video at a quality level, or to fit a size or bitrate",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				optional(intParam("quality", 0, 1, 100, "Higher is better quality and larger files (default 70)")),
+				optional(intParam("targetSize", 0, 100000, 50e9, "Output size in bytes, reached with a two-pass encode")),
+				optional(intParam("targetBitrate", 0, 50000, 100e6, "Average audio+video bitrate in bits/s")),
+			},
+			Apply: applyCompress,
+		},
+		{
+			Type:        "convertFormat",
+			Description: "Change the container and codecs",
+			MediaTypes:  videoAndAudio,
+			Params: []ParamSpec{
+				enumParam("targetFormat", "", "Output container", "mp4", "m4v", "mov", "mkv", "webm", "avi", "mp3", "aac", "wav", "flac", "ogg"),
+				enumParam("videoCodec", "", "Video codec (default: the container's usual codec)", "h264", "h265", "vp8", "vp9", "av1", "mpeg4"),
+				enumParam("audioCodec", "", "Audio codec (default: the container's usual codec)", "aac", "mp3", "opus", "vorbis", "flac", "alac", "pcm"),
+				enumParam("codec", "", "Deprecated: use videoCodec", "h264", "h265", "vp9", "mpeg4"),
+			},
+			Apply: applyConvertFormat,
+		},
+		{
+			Type:        "rotate",
+			Description: "Rotate and flip the video or image (images are first turned upright from their EXIF orientation)",
+			MediaTypes:  videoAndImage,
+			Params: []ParamSpec{
+				{Name: "degrees", Type: ParamInteger, Description: "Clockwise rotation", Default: 0.0, Enum: []interface{}{0, 90, 180, 270}},
+				boolParam("flipHorizontal", false, "Mirror left to right"),
+				boolParam("flipVertical", false, "Mirror top to bottom"),
+			},
+			Apply: applyRotate,
+		},
+		{
+			Type:        "crop",
+			Description: "Cut a rectangle out of the video or image",
+			MediaTypes:  videoAndImage,
+			Params: []ParamSpec{
+				intParam("x", 0, 0, 7680, "Left edge in pixels"),
+				intParam("y", 0, 0, 4320, "Top edge in pixels"),
+				required(intParam("width", 0, 1, 7680, "Width in pixels")),
+				required(intParam("height", 0, 1, 4320, "Height in pixels")),
+			},
+			Apply: applyCrop,
+		},
+		{
+			Type:        "extractAudio",
+			Description: "Save the audio track as an audio file",
+			MediaTypes:  videoOnly,
+			Params:      audioFormatParams(),
+			Apply:       applyAudioFormat,
+		},
+		{
+			Type:        "convertAudioFormat",
+			Description: "Convert to another audio format",
+			MediaTypes:  audioOnly,
+			Params:      audioFormatParams(),
+			Apply:       applyAudioFormat,
+		},
+		{
+			Type:        "convertImageFormat",
+			Description: "Convert to another image format, with quality and compression settings",
+			MediaTypes:  imageOnly,
+			Params: []ParamSpec{
+				optional(enumParam("format", "", "Image format (default: the output file's)", "jpg", "jpeg", "png", "webp", "avif")),
+				optional(intParam("quality", 0, 1, 100, "Higher is better quality and larger files (JPEG, WebP, AVIF)")),
+				boolParam("lossless", false, "Encode without any loss (WebP, AVIF)"),
+				optional(intParam("compression", 0, 0, 9, "Encoder effort: higher is smaller and slower (PNG, WebP, AVIF)")),
+			},
+			Apply: applyConvertImageFormat,
+		},
+		{
+			Type:        "stripMetadata",
+			Description: "Remove metadata such as EXIF, GPS location, titles and chapters",
+			MediaTypes:  allMedia,
+			Apply:       applyStripMetadata,
+		},
+		{
+			Type:        "changeSpeed",
+			Description: "Speed up or slow down playback",
+			MediaTypes:  videoAndAudio,
+			Params: []ParamSpec{
+				numberParam("multiplier", 1, 0.5, 100, "Playback speed (2 = twice as fast)"),
+			},
+			Apply: applyChangeSpeed,
+		},
+		{
+			Type:        "createGif",
+			Description: "Turn the video into an animated GIF",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				intParam("fps", 10, 1, 50, "Frames per second"),
+				intParam("width", 480, 16, 1920, "Width in pixels"),
+			},
+			Apply: applyCreateGif,
+		},
+		{
+			Type:        "changeBitrate",
+			Description: "Set the audio bitrate",
+			MediaTypes:  videoAndAudio,
+			Params: []ParamSpec{
+				intParam("bitrate", 128000, 8000, 512000, "Bits per second"),
+			},
+			Apply: applyChangeBitrate,
+		},
+		{
+			Type:        "adjustVolume",
+			Description: "Make the audio louder or quieter",
+			MediaTypes:  videoAndAudio,
+			Params: []ParamSpec{
+				numberParam("db", 0, -60, 60, "Gain in decibels"),
+			},
+			Apply: applyAdjustVolume,
+		},
+		{
+			Type:        "fadeInOut",
+			Description: "Fade the audio in and out",
+			MediaTypes:  audioOnly,
+			Params: []ParamSpec{
+				numberParam("fadeIn", 0, 0, 60, "Fade-in length in seconds"),
+				numberParam("fadeOut", 0, 0, 60, "Fade-out length in seconds"),
+			},
+			Apply: applyFadeInOut,
+		},
+		{
+			Type:        "addWatermark",
+			Description: "Draw a text watermark in a corner",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				required(stringParam("text", "", "Watermark text")),
+				enumParam("position", "bottomright", "Where to draw the text", "topleft", "topright", "bottomleft", "bottomright", "center"),
+				intParam("fontSize", 24, 8, 200, "Font size in pixels"),
+				colorParam("fontColor", "white", "FFmpeg color name or hex value"),
+				numberParam("opacity", 0.8, 0, 1, "Text opacity"),
+			},
+			Apply: applyAddWatermark,
+		},
+		{
+			Type:        "overlay",
+			Description: "Lay an image (e.g. a logo watermark) or a picture-in-picture video over the picture",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				required(stringParam("overlayPath", "", "Uploaded image or video to lay over the picture")),
+				enumParam("position", "bottomright", "Where to place the overlay", "topleft", "top", "topright", "left", "center", "right", "bottomleft", "bottom", "bottomright"),
+				optional(intParam("x", 0, 0, 7680, "Left edge in pixels (overrides position)")),
+				optional(intParam("y", 0, 0, 4320, "Top edge in pixels (overrides position)")),
+				optional(numberParam("scale", 0, 0.01, 1, "Overlay width as a fraction of the frame width (default: its own size)")),
+				numberParam("opacity", 1, 0, 1, "Overlay opacity"),
+				intParam("margin", 20, 0, 1000, "Distance in pixels from the frame edges the position aligns to"),
+				{Name: "start", Type: ParamTime, Description: "When the overlay appears (default: from the start)"},
+				{Name: "end", Type: ParamTime, Description: "When the overlay disappears (default: at the end)"},
+			},
+			Apply: applyOverlay,
+		},
+		{
+			Type:        "filters",
+			Description: "Color adjustments and visual effects",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				numberParam("brightness", 0, -1, 1, "0 = no change"),
+				numberParam("contrast", 1, 0, 2, "1 = no change"),
+				numberParam("saturation", 1, 0, 3, "1 = no change"),
+				numberParam("gamma", 1, 0.1, 10, "1 = no change"),
+				numberParam("hue", 0, -180, 180, "Hue rotation in degrees"),
+				numberParam("blur", 0, 0, 10, "0 = no blur"),
+				numberParam("sharpen", 0, 0, 5, "0 = no sharpening"),
+				boolParam("vignette", false, "Darken the edges"),
+				boolParam("grayscale", false, "Remove color"),
+				boolParam("sepia", false, "Apply a sepia tone"),
+				boolParam("negative", false, "Invert colors"),
+			},
+			Apply: applyFilters,
+		},
+		{
+			Type:        "thumbnail",
+			Description: "Extract a single frame as an image",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				{Name: "timestamp", Type: ParamTime, Description: "Time of the frame", Default: "00:00:01"},
+				intParam("width", 320, 16, 3840, "Width in pixels"),
+			},
+			Apply: applyThumbnail,
+		},
+		{
+			Type:        "addAudio",
+			Description: "Mix in or replace the audio with another file",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				required(stringParam("audioPath", "", "Audio file to add")),
+				enumParam("mode", "mix", "Mix with or replace the original audio", "mix", "replace"),
+				numberParam("volume", 1, 0, 4, "Volume of the added audio"),
+			},
+			Apply: applyAddAudio,
+		},
+		{
+			Type:        "addSubtitles",
+			Description: "Burn subtitles into the picture or add them as a selectable track",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				required(stringParam("subtitlePath", "", "Subtitle file to add (SRT, WebVTT or ASS)")),
+				enumParam("mode", "burn", "Render into the picture or mux as a subtitle track", "burn", "soft"),
+				optional(stringParam("fontName", "", "Font family (burn)")),
+				optional(intParam("fontSize", 0, 8, 200, "Font size (burn)")),
+				optional(stringParam("fontColor", "", "Color name or RRGGBB hex value (burn)")),
+				optional(stringParam("outlineColor", "", "Color name or RRGGBB hex value (burn)")),
+				optional(intParam("outline", 0, 0, 10, "Outline width (burn)")),
+				optional(enumParam("position", "", "Where to draw the subtitles (burn)", "bottom", "middle", "top")),
+				optional(intParam("margin", 0, 0, 500, "Distance from the top or bottom edge (burn)")),
+				stringParam("language", "und", "ISO 639 language tag of the track (soft)"),
+			},
+			Apply: applyAddSubtitles,
+		},
+		{
+			Type:        "addText",
+			Description: "Draw a text overlay, optionally animated",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				required(stringParam("text", "", "Overlay text")),
+				enumParam("position", "center", "Where to draw the text",
+					"topleft", "topcenter", "topright", "centerleft", "center", "centerright", "bottomleft", "bottomcenter", "bottomright"),
+				intParam("fontSize", 48, 8, 300, "Font size in pixels"),
+				colorParam("fontColor", "white", "FFmpeg color name or hex value"),
+				colorParam("bgColor", "", "Background box color (none if empty)"),
+				numberParam("bgOpacity", 0.5, 0, 1, "Background box opacity"),
+				numberParam("startTime", 0, 0, 86400, "Show from this many seconds"),
+				numberParam("endTime", 0, 0, 86400, "Hide after this many seconds (0 = until the end)"),
+				enumParam("animation", "none", "Text animation", "none", "scrollLeft", "scrollRight", "scrollUp", "scrollDown", "fadeIn"),
+			},
+			Apply: applyAddText,
+		},
+		{
+			Type:        "removeAudio",
+			Description: "Strip the audio track",
+			MediaTypes:  videoOnly,
+			Apply:       applyRemoveAudio,
+		},
+		{
+			Type:        "reverse",
+			Description: "Play backwards",
+			MediaTypes:  videoAndAudio,
+			Apply:       applyReverse,
+		},
+		{
+			Type:        "loop",
+			Description: "Repeat the input",
+			MediaTypes:  videoAndAudio,
+			Params: []ParamSpec{
+				intParam("count", 2, 1, 50, "Total number of plays"),
+			},
+			Apply: applyLoop,
+		},
+		{
+			Type:        "fade",
+			Description: "Fade the picture and sound in and out",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				numberParam("fadeIn", 0, 0, 60, "Fade-in length in seconds"),
+				numberParam("fadeOut", 0, 0, 60, "Fade-out length in seconds"),
+				numberParam("duration", 0, 0, 86400, "Video length in seconds, needed for the fade-out"),
+				colorParam("color", "black", "Color to fade from and to"),
+			},
+			Apply: applyFade,
+		},
+		{
+			Type:        "frameRate",
+			Description: "Change the frame rate",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				intParam("fps", 30, 1, 120, "Frames per second"),
+				enumParam("interpolation", "duplicate", "How new frames are made", "duplicate", "blend", "motion"),
+			},
+			Apply: applyFrameRate,
+		},
+		{
+			Type:        "normalize",
+			Description: "Even out loudness",
+			MediaTypes:  videoAndAudio,
+			Params: []ParamSpec{
+				enumParam("type", "loudnorm", "Normalization method", "loudnorm", "dynaudnorm", "peak"),
+				numberParam("targetLevel", -14, -70, -5, "Target loudness in LUFS (loudnorm only)"),
+			},
+			Apply: applyNormalize,
+		},
+		{
+			Type:        "noiseReduction",
+			Description: "Reduce video grain and audio hiss",
+			MediaTypes:  videoAndAudio,
+			Params: []ParamSpec{
+				enumParam("strength", "medium", "How aggressively to denoise", "low", "medium", "high"),
+				enumParam("applyTo", "both", "Which streams to denoise", "both", "video", "audio"),
+			},
+			Apply: applyNoiseReduction,
+		},
+		{
+			Type:        "packageHLS",
+			Description: "Package as adaptive-bitrate HLS: a master playlist over one segmented stream per rendition",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				renditionParams(),
+				numberParam("segmentDuration", 6, 1, 30, "Target segment length in seconds"),
+				enumParam("segmentType", "ts", "Segment container", "ts", "fmp4"),
+			},
+			Run:      runPackageHLS,
+			Requires: requireMuxer("hls"),
+		},
+		{
+			Type:        "packageDASH",
+			Description: "Package as MPEG-DASH: an MPD over segmented video and audio adaptation sets",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				renditionParams(),
+				numberParam("segmentDuration", 6, 1, 30, "Target segment length in seconds"),
+			},
+			Run:      runPackageDASH,
+			Requires: requireMuxer("dash"),
+		},
+		{
+			Type:        "removeSilence",
+			Description: "Cut out pauses, keeping video in sync, or only report where they are (write to a .json output)",
+			MediaTypes:  videoAndAudio,
+			Params: []ParamSpec{
+				numberParam("threshold", -50, -90, 0, "Level in dB below which audio counts as silence"),
+				numberParam("minDuration", 0.5, 0.05, 60, "Shortest pause in seconds that is cut"),
+				numberParam("padding", 0.1, 0, 5, "Seconds of quiet kept on each side of the remaining sound"),
+				boolParam("detectOnly", false, "Write the detected silent intervals as JSON instead of cutting them"),
+			},
+			Run:      runRemoveSilence,
+			Requires: requireFilters("silencedetect"),
+		},
+		{
+			Type:        "stabilize",
+			Description: "Smooth out camera shake in handheld footage (two passes with libvidstab, otherwise deshake)",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				intParam("shakiness", 5, 1, 10, "How shaky the footage is; higher detects faster motion"),
+				intParam("smoothing", 10, 1, 100, "Frames averaged on each side of every frame; higher gives a steadier camera"),
+				optional(numberParam("zoom", 0, -50, 50, "Zoom in percent (default: just enough to hide the moving borders)")),
+				enumParam("crop", "black", "How borders moved into view are filled: black, or the content of earlier frames", "black", "keep"),
+			},
+			Run:      runStabilize,
+			Requires: requireStabilizeFilters,
+		},
+		{
+			Type:        "split",
+			Description: "Cut the input into several output files at fixed intervals, given timestamps, scene changes or chapters",
+			MediaTypes:  videoAndAudio,
+			Params: []ParamSpec{
+				enumParam("mode", "duration", "Where to cut", "duration", "timestamps", "scenes", "chapters"),
+				numberParam("segmentDuration", 60, 1, 86400, "Length of each part in seconds (duration mode)"),
+				splitTimestampsParam(),
+				numberParam("sceneThreshold", 0.4, 0.05, 1, "Scene change score that starts a new part (scenes mode)"),
+				numberParam("minPartDuration", 2, 0.5, 3600, "Shortest part in seconds; closer scene changes are ignored (scenes mode)"),
+				boolParam("precise", false, "Re-encode so parts start exactly at the split points rather than at the next keyframe"),
+			},
+			Run:      runSplit,
+			Requires: requireMuxer("segment"),
+		},
+		{
+			Type:        "extractSubtitles",
+			Description: "Copy a text subtitle stream out of a video into a standalone SRT, WebVTT or ASS file",
+			MediaTypes:  videoOnly,
+			Params: []ParamSpec{
+				intParam("stream", 0, 0, 99, "Index among the video's subtitle streams"),
+			},
+			Run: runExtractSubtitles,
+		},
+		{
+			// Merge runs on its own over the job's input files (see Processor.processMerge)
+			Type:        "merge",
+			Description: "Join several files end to end, without re-encoding when their formats match",
+			MediaTypes:  videoAndAudio,
+			MinInputs:   2,
+			Params: []ParamSpec{
+				optional(intParam("width", 0, 16, 7680, "Output width in pixels (default: the largest input; forces re-encoding)")),
+				optional(intParam("height", 0, 16, 4320, "Output height in pixels (default: the largest input; forces re-encoding)")),
+				optional(numberParam("fps", 0, 1, 120, "Output frame rate (default: the highest input frame rate; forces re-encoding)")),
+				mergeTransitionsParam(),
+			},
+			Run:      runMerge,
+			Requires: requireMergeFilters,
+		},
+	}
+}
+
+func runMerge(ctx context.Context, proc *Processor, opts ProcessOptions, p Params) (*ProcessResult, error) {
+	return nil, proc.processMerge(ctx, opts, p)
+}
+
+// audioFormatCodecs maps extractAudio/convertAudioFormat formats to an encoder and whether it takes a bitrate
+var audioFormatCodecs = map[string]struct {
+	codec     string
+	bitrate   bool
+	container string
+}{
+	"mp3":  {"libmp3lame", true, "mp3"},
+	"aac":  {"aac", true, "aac"},
+	"wav":  {"pcm_s16le", false, "wav"},
+	"flac": {"flac", false, "flac"},
+	"ogg":  {"libvorbis", true, "ogg"},
+}
+
+func audioFormatParams() []ParamSpec {
+	return []ParamSpec{
+		enumParam("format", "mp3", "Audio format", "mp3", "aac", "wav", "flac", "ogg"),
+		intParam("bitrate", 192000, 32000, 320000, "Bits per second (lossy formats only)"),
+	}
+}
+
+// timeValue formats a trim bound given as a timestamp string or a number of seconds
+func timeValue(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, val != ""
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(val), true
+	}
+	return "", false
+}
+
+func applyTrim(b *PlanBuilder, p Params) error {
+	if err := b.Claim("trim"); err != nil {
+		return err
+	}
+	if start, ok := timeValue(p["startTime"]); ok {
+		b.Spec.Start = start
+	}
+	if end, ok := timeValue(p["endTime"]); ok {
+		b.Spec.End = end
+	}
+	return nil
+}
+
+func applyResize(b *PlanBuilder, p Params) error {
+	width, height := p.Int("width"), p.Int("height")
+	if width == 0 && height == 0 {
+		return nil
+	}
+
+	mode := p.String("mode")
+	if mode == "" {
+		mode = "fit"
+		if !p.Bool("maintainAspect") {
+			mode = "fill"
+		}
+	}
+
+	switch {
+	case mode == "fill":
+		// A side left at 0 keeps the input's size
+		b.AddVideoFilter(fmt.Sprintf("scale=%d:%d", width, height))
+	case width == 0:
+		b.AddVideoFilter(fmt.Sprintf("scale=-2:%d", height))
+	case height == 0:
+		b.AddVideoFilter(fmt.Sprintf("scale=%d:-2", width))
+	case mode == "cover":
+		b.AddVideoFilter(fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d", width, height, width, height))
+	default:
+		b.AddVideoFilter(fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", width, height))
+	}
+	return nil
+}
+
+// defaultCompressQuality is used when compress is given neither a quality nor a target
+const defaultCompressQuality = 70
+
+func applyCompress(b *PlanBuilder, p Params) error {
+	// Only the rate is decided here; the codec follows the container or convertFormat
+	modes := 0
+	for _, name := range []string{"quality", "targetSize", "targetBitrate"} {
+		if p.Has(name) {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("%w: compress takes only one of quality, targetSize and targetBitrate", ErrInvalidOperation)
+	}
+	if err := b.Claim("video quality"); err != nil {
+		return err
+	}
+
+	rate := &b.Spec.Rate
+	switch {
+	case p.Has("targetSize"):
+		rate.TargetSize = int64(p.Float("targetSize"))
+	case p.Has("targetBitrate"):
+		rate.TargetBitrate = p.Int("targetBitrate")
+	case p.Has("quality"):
+		rate.Quality = p.Int("quality")
+	default:
+		rate.Quality = defaultCompressQuality
+	}
+	return nil
+}
+
+func applyConvertFormat(b *PlanBuilder, p Params) error {
+	s := b.Spec
+	video, audio := p.String("videoCodec"), p.String("audioCodec")
+	if video == "" && !p.Has("targetFormat") {
+		// Backward compatibility: codec was only honoured without a targetFormat
+		video = p.String("codec")
+	}
+
+	if p.Has("targetFormat") {
+		container := strings.ToLower(p.String("targetFormat"))
+		if alias, ok := containerAliases[container]; ok {
+			container = alias
+		}
+		if err := checkContainerCodecs(container, video, audio); err != nil {
+			return err
+		}
+
+		if target, ok := audioFormatCodecs[container]; ok {
+			// Audio targets keep only the audio track
+			b.DropVideo()
+			b.UseAudio()
+			if err := b.Set("container", &s.Container, target.container); err != nil {
+				return err
+			}
+			codec := target.codec
+			if audio != "" {
+				codec = audioEncoders[audio]
+			}
+			return b.Set("audio codec", &s.AudioCodec, codec)
+		}
+
+		videoCodec, audioCodec := b.defaultCodecs(container)
+		if video != "" {
+			videoCodec = b.VideoEncoder(video)
+		}
+		if audio != "" {
+			audioCodec = audioEncoders[audio]
+		}
+		if err := b.Set("container", &s.Container, container); err != nil {
+			return err
+		}
+		if err := b.Set("video codec", &s.VideoCodec, videoCodec); err != nil {
+			return err
+		}
+		return b.Set("audio codec", &s.AudioCodec, audioCodec)
+	}
+
+	// Without a targetFormat the codecs are checked against the output file's container in finalize
+	if video != "" {
+		if err := b.Set("video codec", &s.VideoCodec, b.VideoEncoder(video)); err != nil {
+			return err
+		}
+	}
+	if audio != "" {
+		return b.Set("audio codec", &s.AudioCodec, audioEncoders[audio])
+	}
+	return nil
+}
+
+func applyRotate(b *PlanBuilder, p Params) error {
+	switch p.Int("degrees") {
+	case 90:
+		b.AddVideoFilter("transpose=1")
+	case 180:
+		b.AddVideoFilter("transpose=1,transpose=1")
+	case 270:
+		b.AddVideoFilter("transpose=2")
+	}
+	if p.Bool("flipHorizontal") {
+		b.AddVideoFilter("hflip")
+	}
+	if p.Bool("flipVertical") {
+		b.AddVideoFilter("vflip")
+	}
+	return nil
+}
+
+func applyCrop(b *PlanBuilder, p Params) error {
+	b.AddVideoFilter(fmt.Sprintf("crop=%d:%d:%d:%d", p.Int("width"), p.Int("height"), p.Int("x"), p.Int("y")))
+	return nil
+}
+
+func applyAudioFormat(b *PlanBuilder, p Params) error {
+	s := b.Spec
+	target := audioFormatCodecs[p.String("format")]
+
+	b.DropVideo()
+	b.UseAudio()
+	if err := b.Set("audio codec", &s.AudioCodec, target.codec); err != nil {
+		return err
+	}
+	if target.bitrate {
+		if err := b.Set("audio bitrate", &s.AudioBitrate, fmt.Sprintf("%dk", p.Int("bitrate")/1000)); err != nil {
+			return err
+		}
+	}
+	if target.codec == "flac" {
+		// FLAC is lossless, compression level instead of bitrate
+		s.OutputOptions = append(s.OutputOptions, "-compression_level", "5")
+	}
+	return nil
+}
+
+func applyChangeSpeed(b *PlanBuilder, p Params) error {
+	// Affects both streams; whichever survives gets its filter
+	multiplier := p.Float("multiplier")
+	if multiplier != 1.0 {
+		b.AddTimelineFilters(fmt.Sprintf("setpts=%.2f*PTS", 1/multiplier), fmt.Sprintf("atempo=%.2f", multiplier))
+	}
+	return nil
+}
+
+func applyCreateGif(b *PlanBuilder, p Params) error {
+	s := b.Spec
+	if err := b.Set("container", &s.Container, "gif"); err != nil {
+		return err
+	}
+	if err := b.Set("video codec", &s.VideoCodec, "gif"); err != nil {
+		return err
+	}
+	b.DropAudio()
+	b.AddVideoFilter(fmt.Sprintf("fps=%d,scale=%d:-1:flags=lanczos", p.Int("fps"), p.Int("width")))
+	return nil
+}
+
+func applyChangeBitrate(b *PlanBuilder, p Params) error {
+	b.UseAudio()
+	return b.Set("audio bitrate", &b.Spec.AudioBitrate, strconv.Itoa(p.Int("bitrate")))
+}
+
+func applyAdjustVolume(b *PlanBuilder, p Params) error {
+	if db := p.Float("db"); db != 0 {
+		b.AddAudioFilter(fmt.Sprintf("volume=%.1fdB", db))
+	}
+	return nil
+}
+
+func applyFadeInOut(b *PlanBuilder, p Params) error {
+	if fadeIn := p.Float("fadeIn"); fadeIn > 0 {
+		b.AddAudioFilter(fmt.Sprintf("afade=t=in:st=0:d=%.1f", fadeIn))
+	}
+	if fadeOut := p.Float("fadeOut"); fadeOut > 0 {
+		b.AddAudioFilter(fmt.Sprintf("afade=t=out:st=0:d=%.1f", fadeOut))
+	}
+	return nil
+}
+
+func applyAddWatermark(b *PlanBuilder, p Params) error {
+	// Map position to FFmpeg coordinates
+	var x, y string
+	switch p.String("position") {
+	case "topleft":
+		x, y = "10", "10"
+	case "topright":
+		x, y = "w-tw-10", "10"
+	case "bottomleft":
+		x, y = "10", "h-th-10"
+	case "center":
+		x, y = "(w-tw)/2", "(h-th)/2"
+	default:
+		x, y = "w-tw-10", "h-th-10"
+	}
+
+	// Use DejaVu Sans which is installed in the Docker container
+	b.AddVideoFilter(fmt.Sprintf(
+		"drawtext=text='%s':fontfile=/usr/share/fonts/ttf-dejavu/DejaVuSans.ttf:fontsize=%d:fontcolor=%s:alpha=%.2f:x=%s:y=%s",
+		escapeDrawtext(p.String("text")),
+		p.Int("fontSize"),
+		p.String("fontColor"),
+		p.Float("opacity"),
+		x, y,
+	))
+	return nil
+}
+
+func applyFilters(b *PlanBuilder, p Params) error {
+	if filters := colorFilters(p); len(filters) > 0 {
+		b.AddVideoFilter(filters...)
+	}
+	return nil
+}
+
+func applyThumbnail(b *PlanBuilder, p Params) error {
+	s := b.Spec
+	if err := b.Claim("thumbnail"); err != nil {
+		return err
+	}
+	timestamp, _ := timeValue(p["timestamp"])
+
+	b.thumbnail = true
+	b.DropAudio()
+	s.MaxFrames = 1
+	// Fast input seek; the frame is decoded exactly from there
+	s.Inputs[0].Options = append(s.Inputs[0].Options, "-ss", timestamp)
+	b.AddVideoFilter(fmt.Sprintf("scale=%d:-1", p.Int("width")))
+	return nil
+}
+
+func applyAddAudio(b *PlanBuilder, p Params) error {
+	s := b.Spec
+	if err := b.Claim("added audio track"); err != nil {
+		return err
+	}
+	volume := p.Float("volume")
+
+	b.UseAudio()
+	s.Inputs = append(s.Inputs, InputSpec{Path: p.String("audioPath")})
+	input := len(s.Inputs) - 1
+	if p.String("mode") == "replace" {
+		// Remove original audio, use new audio
+		s.Maps = append(s.Maps, "0:v", fmt.Sprintf("%d:a", input))
+		if volume != 1.0 {
+			s.AudioFilters = append(s.AudioFilters, fmt.Sprintf("volume=%.2f", volume))
+		}
+		s.OutputOptions = append(s.OutputOptions, "-shortest")
+		return nil
+	}
+
+	// Mix both audio tracks; other audio filters are appended to this chain in finalize
+	b.audioMix = fmt.Sprintf("[0:a][%d:a]amix=inputs=2:duration=first", input)
+	if volume != 1.0 {
+		b.audioMix += fmt.Sprintf(",volume=%.2f", volume)
+	}
+	s.Maps = append(s.Maps, "0:v", "[aout]")
+	return nil
+}
+
+func applyAddText(b *PlanBuilder, p Params) error {
+	b.AddVideoFilter(textOverlayFilter(p))
+	return nil
+}
+
+func applyRemoveAudio(b *PlanBuilder, p Params) error {
+	if err := b.Claim("audio removal"); err != nil {
+		return err
+	}
+	b.Spec.DropAudio = true
+	return nil
+}
+
+func applyReverse(b *PlanBuilder, p Params) error {
+	// Reverse playback of whichever streams are kept
+	b.AddTimelineFilters("reverse", "areverse")
+	return nil
+}
+
+func applyLoop(b *PlanBuilder, p Params) error {
+	if err := b.Claim("loop count"); err != nil {
+		return err
+	}
+	in := &b.Spec.Inputs[0]
+	in.Options = append(in.Options, "-stream_loop", strconv.Itoa(p.Int("count")-1))
+	return nil
+}
+
+func applyFade(b *PlanBuilder, p Params) error {
+	fadeIn, fadeOut := p.Float("fadeIn"), p.Float("fadeOut")
+	duration := p.Float("duration") // Total video duration for fade out calculation
+	color := p.String("color")
+
+	if fadeIn > 0 {
+		b.AddTimelineFilters(
+			fmt.Sprintf("fade=t=in:st=0:d=%.2f:color=%s", fadeIn, color),
+			fmt.Sprintf("afade=t=in:st=0:d=%.2f", fadeIn),
+		)
+	}
+	if fadeOut > 0 && duration > 0 {
+		start := duration - fadeOut
+		if start < 0 {
+			start = 0
+		}
+		b.AddTimelineFilters(
+			fmt.Sprintf("fade=t=out:st=%.2f:d=%.2f:color=%s", start, fadeOut, color),
+			fmt.Sprintf("afade=t=out:st=%.2f:d=%.2f", start, fadeOut),
+		)
+	}
+	return nil
+}
+
+func applyFrameRate(b *PlanBuilder, p Params) error {
+	fps := p.Int("fps")
+	switch p.String("interpolation") {
+	case "blend":
+		// Blend frames for smoother motion (motion blur effect)
+		b.AddVideoFilter(fmt.Sprintf("minterpolate=fps=%d:mi_mode=blend", fps))
+	case "motion":
+		// Motion interpolation for smoother slow-mo
+		b.AddVideoFilter(fmt.Sprintf("minterpolate=fps=%d:mi_mode=mci:mc_mode=aobmc:me_mode=bidir:vsbmc=1", fps))
+	default:
+		// Simple frame duplication/dropping
+		b.AddVideoFilter(fmt.Sprintf("fps=%d", fps))
+	}
+	return nil
+}
+
+func applyNormalize(b *PlanBuilder, p Params) error {
+	switch p.String("type") {
+	case "loudnorm":
+		// EBU R128 loudness normalization (broadcast standard)
+		b.AddAudioFilter(fmt.Sprintf("loudnorm=I=%.1f:TP=-1.5:LRA=11", p.Float("targetLevel")))
+	case "dynaudnorm":
+		// Dynamic audio normalization (adjusts volume in real-time)
+		b.AddAudioFilter("dynaudnorm=p=0.9:s=5")
+	case "peak":
+		// Peak normalization to 0dB
+		b.AddAudioFilter("acompressor=threshold=0.5:ratio=2:attack=5:release=50,volume=2dB")
+	}
+	return nil
+}
+
+func applyNoiseReduction(b *PlanBuilder, p Params) error {
+	// Noise reduction for video (hqdn3d) and/or audio (afftdn)
+	afftdn, hqdn3d := "afftdn=nr=12:nf=-40", "hqdn3d=4:4:6:4"
+	switch p.String("strength") {
+	case "low":
+		afftdn, hqdn3d = "afftdn=nr=8:nf=-40", "hqdn3d=2:2:3:2"
+	case "high":
+		afftdn, hqdn3d = "afftdn=nr=20:nf=-40", "hqdn3d=8:6:12:9"
+	}
+
+	switch p.String("applyTo") {
+	case "audio":
+		b.AddAudioFilter(afftdn)
+	case "video":
+		b.AddVideoFilter(hqdn3d)
+	default:
+		b.AddTimelineFilters(hqdn3d, afftdn)
+	}
+	return nil
+}
+
+// escapeDrawtext escapes text for the FFmpeg drawtext filter
+func escapeDrawtext(text string) string {
+	text = strings.ReplaceAll(text, "\\", "\\\\")
+	text = strings.ReplaceAll(text, "'", "'\\''")
+	return strings.ReplaceAll(text, ":", "\\:")
+}
+
+// colorFilters builds the video filters for the "filters" operation
+func colorFilters(params map[string]interface{}) []string {
+	brightness := getFloatParam(params, "brightness", 0)  // -1 to 1 (0 = no change)
+	contrast := getFloatParam(params, "contrast", 1)      // 0 to 2 (1 = no change)
+	saturation := getFloatParam(params, "saturation", 1)  // 0 to 3 (1 = no change)
+	gamma := getFloatParam(params, "gamma", 1)            // 0.1 to 10 (1 = no change)
+	hue := getFloatParam(params, "hue", 0)                // -180 to 180 degrees (0 = no change)
+	blur := getFloatParam(params, "blur", 0)              // 0 to 10 (0 = no blur)
+	sharpen := getFloatParam(params, "sharpen", 0)        // 0 to 5 (0 = no sharpen)
+	vignette := getBoolParam(params, "vignette", false)   // Add vignette effect
+	grayscale := getBoolParam(params, "grayscale", false) // Convert to grayscale
+	sepia := getBoolParam(params, "sepia", false)         // Apply sepia tone
+	negative := getBoolParam(params, "negative", false)   // Invert colors
+
+	var filters []string
+
+	// Build eq filter for brightness, contrast, saturation, gamma
+	var eqParts []string
+	if brightness != 0 {
+		eqParts = append(eqParts, fmt.Sprintf("brightness=%.2f", brightness))
+	}
+	if contrast != 1 {
+		eqParts = append(eqParts, fmt.Sprintf("contrast=%.2f", contrast))
+	}
+	if saturation != 1 {
+		eqParts = append(eqParts, fmt.Sprintf("saturation=%.2f", saturation))
+	}
+	if gamma != 1 {
+		eqParts = append(eqParts, fmt.Sprintf("gamma=%.2f", gamma))
+	}
+	if len(eqParts) > 0 {
+		filters = append(filters, "eq="+strings.Join(eqParts, ":"))
+	}
+
+	if hue != 0 {
+		filters = append(filters, fmt.Sprintf("hue=h=%.1f", hue))
+	}
+
+	// Blur effect (using boxblur)
+	if blur > 0 {
+		blurRadius := int(blur * 2) // Scale for more visible effect
+		if blurRadius < 1 {
+			blurRadius = 1
+		}
+		filters = append(filters, fmt.Sprintf("boxblur=%d:%d", blurRadius, blurRadius))
+	}
+
+	// Sharpen effect (using unsharp mask)
+	if sharpen > 0 {
+		// unsharp=lx:ly:la where l=luma, a=amount
+		filters = append(filters, fmt.Sprintf("unsharp=5:5:%.1f:5:5:0", sharpen*1.5))
+	}
+
+	if vignette {
+		filters = append(filters, "vignette=PI/4")
+	}
+	if grayscale {
+		filters = append(filters, "colorchannelmixer=.3:.4:.3:0:.3:.4:.3:0:.3:.4:.3")
+	}
+	// Sepia tone (apply after grayscale-like transform)
+	if sepia {
+		filters = append(filters, "colorchannelmixer=.393:.769:.189:0:.349:.686:.168:0:.272:.534:.131")
+	}
+	if negative {
+		filters = append(filters, "negate")
+	}
+
+	return filters
+}
+
+// textOverlayFilter builds the drawtext filter for the "addText" operation
+func textOverlayFilter(params map[string]interface{}) string {
+	text := getStringParam(params, "text", "")
+	if text == "" {
+		return ""
+	}
+
+	position := getStringParam(params, "position", "center")
+	fontSize := getIntParam(params, "fontSize", 48)
+	fontColor := getStringParam(params, "fontColor", "white")
+	bgColor := getStringParam(params, "bgColor", "")
+	bgOpacity := getFloatParam(params, "bgOpacity", 0.5)
+	startTime := getFloatParam(params, "startTime", 0)
+	endTime := getFloatParam(params, "endTime", 0) // 0 means entire video
+	animation := getStringParam(params, "animation", "none")
+
+	// Map position to coordinates
+	var x, y string
+	switch position {
+	case "topleft":
+		x, y = "20", "20"
+	case "topcenter":
+		x, y = "(w-tw)/2", "20"
+	case "topright":
+		x, y = "w-tw-20", "20"
+	case "centerleft":
+		x, y = "20", "(h-th)/2"
+	case "centerright":
+		x, y = "w-tw-20", "(h-th)/2"
+	case "bottomleft":
+		x, y = "20", "h-th-20"
+	case "bottomcenter":
+		x, y = "(w-tw)/2", "h-th-20"
+	case "bottomright":
+		x, y = "w-tw-20", "h-th-20"
+	default:
+		x, y = "(w-tw)/2", "(h-th)/2"
+	}
+
+	// Apply animation to position
+	switch animation {
+	case "scrollLeft":
+		x = fmt.Sprintf("w-%d*t", fontSize*2) // Scroll from right to left
+	case "scrollRight":
+		x = fmt.Sprintf("-%d+%d*t", fontSize*5, fontSize*2) // Scroll from left to right
+	case "scrollUp":
+		y = fmt.Sprintf("h-%d*t", fontSize) // Scroll from bottom to top
+	case "scrollDown":
+		y = fmt.Sprintf("-%d+%d*t", fontSize*2, fontSize) // Scroll from top to bottom
+	}
+
+	filter := fmt.Sprintf(
+		"drawtext=text='%s':fontfile=/usr/share/fonts/ttf-dejavu/DejaVuSans-Bold.ttf:fontsize=%d:fontcolor=%s:x=%s:y=%s",
+		escapeDrawtext(text), fontSize, fontColor, x, y,
+	)
+
+	// Add background box if specified
+	if bgColor != "" {
+		filter += fmt.Sprintf(":box=1:boxcolor=%s@%.2f:boxborderw=10", bgColor, bgOpacity)
+	}
+
+	// Add time constraints
+	if startTime > 0 || endTime > 0 {
+		if endTime > 0 {
+			filter += fmt.Sprintf(":enable='between(t,%.2f,%.2f)'", startTime, endTime)
+		} else {
+			filter += fmt.Sprintf(":enable='gte(t,%.2f)'", startTime)
+		}
+	}
+
+	// Fade animation via alpha
+	if animation == "fadeIn" {
+		filter += ":alpha='if(lt(t,1),t,1)'"
+	}
+
+	return filter
+}
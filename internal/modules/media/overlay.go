@@ -0,0 +1,168 @@
+package media
+
+import (
+	"fmt"
+	"strings"
+)
+
+// overlayImageFormats are still images; they're looped so they last as long as the video
+var overlayImageFormats = map[string]bool{"png": true, "jpg": true, "webp": true, "bmp": true}
+
+// overlayPosition places the overlay (w x h) on the frame (W x H), margin
+// pixels from the edges it is aligned to
+func overlayPosition(position string, margin int) (x, y string) {
+	x, y = "(W-w)/2", "(H-h)/2"
+	if strings.HasSuffix(position, "left") {
+		x = fmt.Sprint(margin)
+	} else if strings.HasSuffix(position, "right") {
+		x = fmt.Sprintf("W-w-%d", margin)
+	}
+	if strings.HasPrefix(position, "top") {
+		y = fmt.Sprint(margin)
+	} else if strings.HasPrefix(position, "bottom") {
+		y = fmt.Sprintf("H-h-%d", margin)
+	}
+	return x, y
+}
+
+// overlayLayer is an image or video composited over the picture by the overlay operation
+type overlayLayer struct {
+	Input   int    // FFmpeg input index of the overlay file
+	Video   bool   // A moving picture rather than a looped still image
+	After   int    // Number of the chain's video filters applied before compositing
+	Prepare string // Filters applied to the overlay itself (may be empty)
+	Scale   float64
+	X, Y    string
+	Enable  string // Timeline expression limiting when the overlay shows
+}
+
+func applyOverlay(b *PlanBuilder, p Params) error {
+	s := b.Spec
+	path := p.String("overlayPath")
+	layer := overlayLayer{
+		Video: !overlayImageFormats[containerFromPath(path)],
+		After: len(s.VideoFilters),
+		Scale: p.Float("scale"),
+	}
+
+	layer.X, layer.Y = overlayPosition(p.String("position"), p.Int("margin"))
+	if p.Has("x") {
+		layer.X = fmt.Sprint(p.Int("x"))
+	}
+	if p.Has("y") {
+		layer.Y = fmt.Sprint(p.Int("y"))
+	}
+
+	var start, end float64
+	var err error
+	if p.Has("start") {
+		if start, err = parseTimeParam(p["start"]); err != nil {
+			return fmt.Errorf("%w: overlay start: %v", ErrInvalidOperation, err)
+		}
+	}
+	if p.Has("end") {
+		if end, err = parseTimeParam(p["end"]); err != nil {
+			return fmt.Errorf("%w: overlay end: %v", ErrInvalidOperation, err)
+		}
+		if end <= start {
+			return fmt.Errorf("%w: overlay end must be after its start", ErrInvalidOperation)
+		}
+	}
+	switch {
+	case p.Has("end"):
+		layer.Enable = fmt.Sprintf("between(t,%g,%g)", start, end)
+	case start > 0:
+		layer.Enable = fmt.Sprintf("gte(t,%g)", start)
+	}
+
+	in := InputSpec{Path: path}
+	if !layer.Video {
+		in.Options = []string{"-loop", "1"}
+	} else if start > 0 {
+		// A picture-in-picture clip starts playing when it appears
+		in.Options = []string{"-itsoffset", fmt.Sprintf("%g", start)}
+	}
+	s.Inputs = append(s.Inputs, in)
+	layer.Input = len(s.Inputs) - 1
+
+	var prepare []string
+	if layer.Video && p.Has("end") {
+		// Hidden after its end anyway; cut there so a long clip can't outlast the video
+		prepare = append(prepare, fmt.Sprintf("trim=end=%g", end))
+	}
+	if opacity := p.Float("opacity"); opacity < 1 {
+		prepare = append(prepare, fmt.Sprintf("format=rgba,colorchannelmixer=aa=%.2f", opacity))
+	}
+	layer.Prepare = strings.Join(prepare, ",")
+
+	if b.videoUser == "" {
+		b.videoUser = b.op
+	}
+	b.overlays = append(b.overlays, layer)
+	return nil
+}
+
+// overlayGraph composites the overlays into the video in a complex
+// filtergraph ending in [vout]. The chain's other video filters keep their
+// order relative to the overlays, so e.g. a resize before an overlay scales
+// only the main picture.
+func (b *PlanBuilder) overlayGraph() {
+	s := b.Spec
+	var chains []string
+	current, done := "0:v:0", 0
+	link := func(filters []string, label string) {
+		chains = append(chains, fmt.Sprintf("[%s]%s[%s]", current, strings.Join(filters, ","), label))
+		current = label
+	}
+
+	for i, o := range b.overlays {
+		if o.After > done {
+			link(s.VideoFilters[done:o.After], fmt.Sprintf("base%d", i))
+			done = o.After
+		}
+
+		layer := fmt.Sprintf("%d:v:0", o.Input)
+		if o.Prepare != "" {
+			chains = append(chains, fmt.Sprintf("[%s]%s[ovl%d]", layer, o.Prepare, i))
+			layer = fmt.Sprintf("ovl%d", i)
+		}
+		if o.Scale > 0 {
+			// Sized against the frame it's laid over, whatever came before
+			chains = append(chains, fmt.Sprintf("[%s][%s]scale2ref=w=main_w*%g:h=ow/dar[ovs%d][ref%d]", layer, current, o.Scale, i, i))
+			layer, current = fmt.Sprintf("ovs%d", i), fmt.Sprintf("ref%d", i)
+		}
+
+		overlay := fmt.Sprintf("overlay=x=%s:y=%s", o.X, o.Y)
+		if o.Video {
+			// A clip that ends early disappears and the main video plays on
+			overlay += ":eof_action=pass"
+		} else {
+			// A looped still never ends; stop with the main video
+			overlay += ":shortest=1"
+		}
+		if o.Enable != "" {
+			overlay += fmt.Sprintf(":enable='%s'", o.Enable)
+		}
+		label := fmt.Sprintf("ov%d", i)
+		chains = append(chains, fmt.Sprintf("[%s][%s]%s[%s]", current, layer, overlay, label))
+		current = label
+	}
+
+	rest := s.VideoFilters[done:]
+	if len(rest) == 0 {
+		rest = []string{"null"}
+	}
+	link(rest, "vout")
+	s.FilterComplex = append(s.FilterComplex, chains...)
+	s.VideoFilters = nil
+
+	// Whatever mapped the original video now maps the composited one
+	if len(s.Maps) == 0 {
+		s.Maps = []string{"[vout]", "0:a?"}
+	}
+	for i, m := range s.Maps {
+		if m == "0:v" || m == "0:v?" || m == "0:v:0" {
+			s.Maps[i] = "[vout]"
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package media
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayPosition(t *testing.T) {
+	x, y := overlayPosition("bottomright", 20)
+	assert.Equal(t, "W-w-20", x)
+	assert.Equal(t, "H-h-20", y)
+
+	x, y = overlayPosition("top", 10)
+	assert.Equal(t, "(W-w)/2", x)
+	assert.Equal(t, "10", y)
+
+	x, y = overlayPosition("center", 10)
+	assert.Equal(t, "(W-w)/2", x)
+	assert.Equal(t, "(H-h)/2", y)
+}
+
+func TestOverlay(t *testing.T) {
+	t.Run("logo watermark", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4", op("overlay", map[string]interface{}{
+			"overlayPath": "/uploads/logo.png",
+			"scale":       0.15,
+			"opacity":     0.5,
+		}))
+		joined := strings.Join(args, " ")
+
+		assert.Contains(t, joined, "-i in.mp4 -loop 1 -i /uploads/logo.png")
+		assert.Equal(t, "[1:v:0]format=rgba,colorchannelmixer=aa=0.50[ovl0];"+
+			"[ovl0][0:v:0]scale2ref=w=main_w*0.15:h=ow/dar[ovs0][ref0];"+
+			"[ref0][ovs0]overlay=x=W-w-20:y=H-h-20:shortest=1[ov0];"+
+			"[ov0]null[vout]", flagValue(args, "-filter_complex"))
+		assert.Contains(t, joined, "-map [vout] -map 0:a?")
+		assert.Equal(t, "libx264", flagValue(args, "-c:v"))
+		assert.NotContains(t, args, "-vf")
+	})
+
+	t.Run("picture-in-picture for a time range", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4", op("overlay", map[string]interface{}{
+			"overlayPath": "/uploads/camera.mp4",
+			"x":           40.0,
+			"y":           30.0,
+			"start":       "00:00:05",
+			"end":         12.5,
+		}))
+		joined := strings.Join(args, " ")
+
+		assert.Contains(t, joined, "-itsoffset 5 -i /uploads/camera.mp4")
+		assert.NotContains(t, joined, "-loop")
+		assert.Contains(t, flagValue(args, "-filter_complex"),
+			"[1:v:0]trim=end=12.5[ovl0];[0:v:0][ovl0]overlay=x=40:y=30:eof_action=pass:enable='between(t,5,12.5)'[ov0]")
+		assert.NotContains(t, flagValue(args, "-filter_complex"), "shortest", "a short clip must not cut the video")
+	})
+
+	t.Run("keeps the order of the other video filters", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4",
+			op("rotate", map[string]interface{}{"degrees": 90.0}),
+			op("overlay", map[string]interface{}{"overlayPath": "logo.png"}),
+			op("filters", map[string]interface{}{"grayscale": true}),
+		)
+		graph := flagValue(args, "-filter_complex")
+
+		assert.True(t, strings.HasPrefix(graph, "[0:v:0]transpose=1[base0];[base0][1:v:0]overlay="), graph)
+		assert.True(t, strings.HasSuffix(graph, "[ov0]colorchannelmixer=.3:.4:.3:0:.3:.4:.3:0:.3:.4:.3[vout]"), graph)
+	})
+
+	t.Run("replaces the video map of added audio", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4",
+			op("addAudio", map[string]interface{}{"audioPath": "music.mp3"}),
+			op("overlay", map[string]interface{}{"overlayPath": "logo.png"}),
+		)
+		joined := strings.Join(args, " ")
+
+		assert.Contains(t, joined, "-map [vout] -map [aout]")
+		assert.Equal(t, 1, countFlag(args, "-filter_complex"))
+	})
+
+	t.Run("end before start", func(t *testing.T) {
+		_, err := resolveOutputSpec("in.mp4", "out.mp4", []Operation{op("overlay", map[string]interface{}{
+			"overlayPath": "logo.png", "start": 10.0, "end": 5.0,
+		})}, testEncoder)
+		require.ErrorIs(t, err, ErrInvalidOperation)
+	})
+
+	t.Run("conflicts with removing the video", func(t *testing.T) {
+		_, err := resolveOutputSpec("in.mp4", "out.mp3", []Operation{
+			op("overlay", map[string]interface{}{"overlayPath": "logo.png"}),
+			op("extractAudio", map[string]interface{}{"format": "mp3"}),
+		}, testEncoder)
+		assert.ErrorIs(t, err, ErrConflictingOperations)
+	})
+}
@@ -0,0 +1,189 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Rendition is one rung of an adaptive-bitrate ladder
+type Rendition struct {
+	Height       int
+	VideoBitrate int // bits/s
+	AudioBitrate int // bits/s
+}
+
+// defaultLadder is used when a packaging operation doesn't specify renditions
+var defaultLadder = []Rendition{
+	{Height: 1080, VideoBitrate: 5000000, AudioBitrate: 192000},
+	{Height: 720, VideoBitrate: 2800000, AudioBitrate: 128000},
+	{Height: 480, VideoBitrate: 1400000, AudioBitrate: 128000},
+	{Height: 360, VideoBitrate: 800000, AudioBitrate: 96000},
+}
+
+// renditionParams is the schema shared by the packaging operations
+func renditionParams() ParamSpec {
+	lo, hi := numberRange(1, 8)
+	return ParamSpec{
+		Name:        "renditions",
+		Type:        ParamList,
+		Description: "Rendition ladder, highest first; rungs above the source resolution are dropped",
+		Min:         lo,
+		Max:         hi,
+		Fields: []ParamSpec{
+			required(intParam("height", 0, 144, 4320, "Frame height in pixels")),
+			required(intParam("videoBitrate", 0, 100000, 50000000, "Video bitrate in bits/s")),
+			intParam("audioBitrate", 128000, 32000, 320000, "Audio bitrate in bits/s"),
+		},
+	}
+}
+
+// buildLadder turns validated rendition params into a ladder capped at the
+// source height. If every rung is taller than the source, the smallest rung is
+// kept at the source height so there is always something to play.
+func buildLadder(items []Params, sourceHeight int) []Rendition {
+	ladder := defaultLadder
+	if len(items) > 0 {
+		ladder = make([]Rendition, len(items))
+		for i, item := range items {
+			ladder[i] = Rendition{
+				Height:       item.Int("height"),
+				VideoBitrate: item.Int("videoBitrate"),
+				AudioBitrate: item.Int("audioBitrate"),
+			}
+		}
+	}
+
+	sorted := append([]Rendition(nil), ladder...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Height > sorted[j].Height })
+	if sourceHeight <= 0 {
+		return sorted
+	}
+
+	capped := make([]Rendition, 0, len(sorted))
+	seen := map[int]bool{}
+	for _, r := range sorted {
+		if r.Height <= sourceHeight && !seen[r.Height] {
+			capped = append(capped, r)
+			seen[r.Height] = true
+		}
+	}
+	if len(capped) == 0 {
+		smallest := sorted[len(sorted)-1]
+		smallest.Height = sourceHeight
+		capped = append(capped, smallest)
+	}
+	return capped
+}
+
+// probePackageSource probes a packaging operation's input, which must have video
+func probePackageSource(ctx context.Context, proc *Processor, inputPath, opType string) (*MediaInfo, error) {
+	info, err := proc.Probe(ctx, inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe input for packaging: %w", err)
+	}
+	if info.VideoCodec == "" {
+		return nil, fmt.Errorf("%w: %s needs a video stream", ErrInvalidOperation, opType)
+	}
+	return info, nil
+}
+
+// displayHeight is the frame height after applying the display rotation
+func displayHeight(info *MediaInfo) int {
+	if info.Rotation == 90 || info.Rotation == 270 {
+		return info.Width
+	}
+	return info.Height
+}
+
+// ladderFilter splits the video stream and scales one copy per rendition,
+// labelled [v0], [v1], ...
+func ladderFilter(ladder []Rendition) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[0:v]split=%d", len(ladder))
+	for i := range ladder {
+		fmt.Fprintf(&b, "[s%d]", i)
+	}
+	for i, r := range ladder {
+		// Even widths keep yuv420p encoders happy
+		fmt.Fprintf(&b, ";[s%d]scale=-2:%d[v%d]", i, evenHeight(r.Height), i)
+	}
+	return b.String()
+}
+
+func evenHeight(h int) int {
+	return h - h%2
+}
+
+// ladderVideoArgs picks the H.264 encoder every rendition is encoded with;
+// H.264 is what every HLS and DASH player can decode
+func ladderVideoArgs(enc encoderSettings) []string {
+	if enc.HWAccel {
+		return []string{"-c:v", "h264_videotoolbox", "-pix_fmt", "yuv420p"}
+	}
+	args := []string{"-c:v", "libx264", "-pix_fmt", "yuv420p"}
+	if enc.Preset != "" {
+		args = append(args, "-preset", enc.Preset)
+	}
+	return args
+}
+
+// ladderEncoderArgs sets per-rendition bitrates with a capped VBV so players can
+// rely on each rung's advertised bandwidth
+func ladderEncoderArgs(ladder []Rendition, withAudio bool) []string {
+	var args []string
+	for i, r := range ladder {
+		args = append(args,
+			fmt.Sprintf("-b:v:%d", i), fmt.Sprint(r.VideoBitrate),
+			fmt.Sprintf("-maxrate:v:%d", i), fmt.Sprint(r.VideoBitrate*107/100),
+			fmt.Sprintf("-bufsize:v:%d", i), fmt.Sprint(r.VideoBitrate*3/2),
+		)
+		if withAudio {
+			args = append(args, fmt.Sprintf("-b:a:%d", i), fmt.Sprint(r.AudioBitrate))
+		}
+	}
+	return args
+}
+
+// keyframeArgs aligns keyframes with segment boundaries so every rung can switch there
+func keyframeArgs(segmentDuration float64) []string {
+	return []string{
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%g)", segmentDuration),
+		"-sc_threshold", "0",
+	}
+}
+
+// packageDir returns the directory a packaged output is written to: the output
+// path without its extension (output/<job>.m3u8 becomes output/<job>/)
+func packageDir(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+}
+
+// collectArtifacts lists every file under dir as a ProcessResult
+func collectArtifacts(dir, entry string) (*ProcessResult, error) {
+	result := &ProcessResult{Dir: dir, Entry: entry}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		result.Artifacts = append(result.Artifacts, Artifact{Name: filepath.ToSlash(name), Path: path})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packaged files: %w", err)
+	}
+
+	for _, a := range result.Artifacts {
+		if a.Name == entry {
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("packager did not write %s", entry)
+}
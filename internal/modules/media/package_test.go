@@ -0,0 +1,137 @@
+package media
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildLadder(t *testing.T) {
+	heights := func(ladder []Rendition) []int {
+		out := make([]int, len(ladder))
+		for i, r := range ladder {
+			out[i] = r.Height
+		}
+		return out
+	}
+
+	t.Run("default ladder capped at source", func(t *testing.T) {
+		assert.Equal(t, []int{720, 480, 360}, heights(buildLadder(nil, 720)))
+		assert.Equal(t, []int{1080, 720, 480, 360}, heights(buildLadder(nil, 2160)))
+	})
+
+	t.Run("custom rungs sorted and deduplicated", func(t *testing.T) {
+		items := []Params{
+			{"height": 360, "videoBitrate": 800000, "audioBitrate": 96000},
+			{"height": 720, "videoBitrate": 2800000, "audioBitrate": 128000},
+			{"height": 720, "videoBitrate": 3500000, "audioBitrate": 128000},
+		}
+		ladder := buildLadder(items, 1080)
+		assert.Equal(t, []int{720, 360}, heights(ladder))
+		assert.Equal(t, 2800000, ladder[0].VideoBitrate)
+	})
+
+	t.Run("source smaller than every rung", func(t *testing.T) {
+		ladder := buildLadder(nil, 240)
+		require.Len(t, ladder, 1)
+		assert.Equal(t, 240, ladder[0].Height)
+		assert.Equal(t, 800000, ladder[0].VideoBitrate)
+	})
+}
+
+func TestHLSArgs(t *testing.T) {
+	ladder := []Rendition{
+		{Height: 720, VideoBitrate: 2800000, AudioBitrate: 128000},
+		{Height: 361, VideoBitrate: 800000, AudioBitrate: 96000},
+	}
+
+	t.Run("ts with audio", func(t *testing.T) {
+		args := strings.Join(hlsArgs("in.mp4", "/out/job", hlsOptions{
+			Ladder: ladder, SegmentDuration: 6, SegmentType: "ts", WithAudio: true,
+		}, encoderSettings{Preset: "fast"}), " ")
+
+		assert.Contains(t, args, "[0:v]split=2[s0][s1];[s0]scale=-2:720[v0];[s1]scale=-2:360[v1]")
+		assert.Contains(t, args, "-var_stream_map v:0,a:0 v:1,a:1")
+		assert.Contains(t, args, "-b:v:1 800000 -maxrate:v:1 856000 -bufsize:v:1 1200000 -b:a:1 96000")
+		assert.Contains(t, args, "-force_key_frames expr:gte(t,n_forced*6)")
+		assert.Contains(t, args, "-hls_segment_filename /out/job/stream_%v/segment_%05d.ts")
+		assert.Contains(t, args, "-master_pl_name master.m3u8")
+		assert.True(t, strings.HasSuffix(args, "/out/job/stream_%v/playlist.m3u8"))
+		assert.NotContains(t, args, "fmp4")
+	})
+
+	t.Run("fmp4 without audio", func(t *testing.T) {
+		args := strings.Join(hlsArgs("in.mp4", "/out/job", hlsOptions{
+			Ladder: ladder, SegmentDuration: 4, SegmentType: "fmp4",
+		}, encoderSettings{}), " ")
+
+		assert.Contains(t, args, "-var_stream_map v:0 v:1")
+		assert.Contains(t, args, "-hls_segment_type fmp4 -hls_fmp4_init_filename init.mp4")
+		assert.Contains(t, args, "segment_%05d.m4s")
+		assert.NotContains(t, args, "-c:a")
+		assert.NotContains(t, args, "0:a:0")
+	})
+}
+
+func TestRenditionParamsValidation(t *testing.T) {
+	params, problems := registry.Validate(op("packageHLS", map[string]interface{}{
+		"renditions": []interface{}{
+			map[string]interface{}{"height": 720.0, "videoBitrate": 2800000.0},
+		},
+	}))
+	require.Empty(t, problems)
+	items := params.List("renditions")
+	require.Len(t, items, 1)
+	assert.Equal(t, 128000, items[0].Int("audioBitrate"))
+	assert.Equal(t, 6.0, params.Float("segmentDuration"))
+
+	tests := []struct {
+		name       string
+		renditions interface{}
+		want       string
+	}{
+		{"not a list", "720p", "renditions must be a list"},
+		{"missing field", []interface{}{map[string]interface{}{"height": 720.0}}, "videoBitrate is required"},
+		{"field out of range", []interface{}{map[string]interface{}{"height": 100.0, "videoBitrate": 800000.0}}, "height must be at least 144"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, problems := registry.Validate(op("packageHLS", map[string]interface{}{"renditions": tt.renditions}))
+			require.NotEmpty(t, problems)
+			assert.Contains(t, problems[0], tt.want)
+		})
+	}
+}
+
+func TestDASHArgs(t *testing.T) {
+	ladder := []Rendition{
+		{Height: 720, VideoBitrate: 2800000, AudioBitrate: 160000},
+		{Height: 360, VideoBitrate: 800000, AudioBitrate: 96000},
+	}
+
+	t.Run("video and audio adaptation sets", func(t *testing.T) {
+		args := strings.Join(dashArgs("in.mp4", "/out/job", dashOptions{
+			Ladder: ladder, SegmentDuration: 4, WithAudio: true,
+		}, encoderSettings{}), " ")
+
+		assert.Contains(t, args, "-map [v0] -map [v1] -map 0:a:0")
+		assert.Contains(t, args, "-adaptation_sets id=0,streams=v id=1,streams=a")
+		// Audio is encoded once, at the top rung's bitrate
+		assert.Contains(t, args, "-b:a:0 160000")
+		assert.NotContains(t, args, "-b:a:1")
+		assert.Contains(t, args, "-f dash -seg_duration 4")
+		assert.True(t, strings.HasSuffix(args, "/out/job/manifest.mpd"))
+	})
+
+	t.Run("video only", func(t *testing.T) {
+		args := strings.Join(dashArgs("in.mp4", "/out/job", dashOptions{
+			Ladder: ladder, SegmentDuration: 6,
+		}, encoderSettings{HWAccel: true}), " ")
+
+		assert.Contains(t, args, "-adaptation_sets id=0,streams=v /out/job/manifest.mpd")
+		assert.Contains(t, args, "-c:v h264_videotoolbox")
+		assert.NotContains(t, args, "0:a:0")
+	})
+}
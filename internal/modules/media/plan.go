@@ -0,0 +1,556 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrConflictingOperations is returned when two operations in a chain make
+// incompatible demands on the output (e.g. two different video codecs)
+var ErrConflictingOperations = errors.New("conflicting operations")
+
+// OutputSpec is the encoding plan for a single FFmpeg run. An operation chain is
+// first resolved into one OutputSpec, which is then rendered into arguments, so
+// every output setting is decided exactly once.
+type OutputSpec struct {
+	Inputs        []InputSpec
+	Container     string // Output format, from the output file extension or convertFormat
+	VideoCodec    string // FFmpeg encoder name, "copy", or "" for FFmpeg's default
+	AudioCodec    string
+	DropVideo     bool // -vn
+	DropAudio     bool // -an
+	Rate          RateControl
+	AudioBitrate  string
+	VideoFilters  []string // Simple filter chain for the video stream (-vf)
+	AudioFilters  []string // Simple filter chain for the audio stream (-af)
+	FilterComplex []string // Complex filtergraph chains, joined with ';'
+	FilterScript  string   // File holding a complex filtergraph too long for the command line
+	Maps          []string
+	Start         string // Output-side trim start (-ss)
+	End           string // Output-side trim end (-to)
+	MaxFrames     int    // Number of video frames to write (0 = all)
+	OutputOptions []string
+	OutputPath    string
+	Threads       int
+}
+
+// InputSpec is one FFmpeg input and the options placed before its -i
+type InputSpec struct {
+	Path    string
+	Options []string
+}
+
+// RateControl describes how the video encoder trades size for quality
+type RateControl struct {
+	Quality int    // 1-100, mapped onto the codec's own quality scale (0 = encoder default)
+	Preset  string // x264/x265 speed preset
+
+	// Size targets are turned into a VideoBitrate by the processor once the
+	// output duration is known
+	TargetSize    int64 // Output size in bytes
+	TargetBitrate int   // Average audio+video bitrate in bits/s
+
+	VideoBitrate int    // Average video bitrate in bits/s (0 = quality-based)
+	Pass         int    // Two-pass encoding pass, 1 or 2 (0 = single pass)
+	PassLogFile  string // Prefix for two-pass statistics files
+}
+
+// Targeted reports whether the encode aims for a size or bitrate rather than a quality
+func (r RateControl) Targeted() bool {
+	return r.TargetSize > 0 || r.TargetBitrate > 0
+}
+
+// encoderSettings are processor-level choices that affect how an operation chain is encoded
+type encoderSettings struct {
+	HWAccel    bool
+	Preset     string
+	Threads    int
+	AV1Encoder string // AV1EncoderSVT or AV1EncoderAOM ("" = SVT-AV1)
+}
+
+// Args renders the spec as FFmpeg arguments
+func (s *OutputSpec) Args() []string {
+	args := []string{"-y"}
+	if s.Threads > 0 {
+		args = append(args, "-threads", strconv.Itoa(s.Threads))
+	}
+
+	for _, in := range s.Inputs {
+		args = append(args, in.Options...)
+		args = append(args, "-i", in.Path)
+	}
+
+	if s.Start != "" {
+		args = append(args, "-ss", s.Start)
+	}
+	if s.End != "" {
+		args = append(args, "-to", s.End)
+	}
+
+	if len(s.FilterComplex) > 0 {
+		args = append(args, "-filter_complex", strings.Join(s.FilterComplex, ";"))
+	}
+	if s.FilterScript != "" {
+		args = append(args, "-filter_complex_script", s.FilterScript)
+	}
+	for _, m := range s.Maps {
+		args = append(args, "-map", m)
+	}
+
+	if s.DropVideo {
+		args = append(args, "-vn")
+	} else {
+		if len(s.VideoFilters) > 0 {
+			args = append(args, "-vf", strings.Join(s.VideoFilters, ","))
+		}
+		if s.MaxFrames > 0 {
+			args = append(args, "-frames:v", strconv.Itoa(s.MaxFrames))
+		}
+		if s.VideoCodec != "" {
+			args = append(args, "-c:v", s.VideoCodec)
+			args = append(args, videoEncoderArgs(s.VideoCodec, s.Rate)...)
+		}
+	}
+
+	if s.DropAudio || s.Rate.Pass == 1 {
+		// The first pass only analyses the video
+		args = append(args, "-an")
+	} else {
+		if len(s.AudioFilters) > 0 {
+			args = append(args, "-af", strings.Join(s.AudioFilters, ","))
+		}
+		if s.AudioCodec != "" {
+			args = append(args, "-c:a", s.AudioCodec)
+		}
+		if s.AudioBitrate != "" {
+			args = append(args, "-b:a", s.AudioBitrate)
+		}
+	}
+
+	args = append(args, s.OutputOptions...)
+
+	if s.Rate.Pass == 1 {
+		// Only the pass log matters; discard the encoded output
+		return append(args, "-f", "null", os.DevNull)
+	}
+
+	// Enables progressive download/playback of MP4-family outputs
+	switch s.Container {
+	case "mp4", "mov", "m4a":
+		args = append(args, "-movflags", "+faststart")
+	}
+
+	return append(args, s.OutputPath)
+}
+
+// videoEncoderArgs returns the speed and quality options for an encoder
+func videoEncoderArgs(codec string, rate RateControl) []string {
+	var args []string
+	switch codec {
+	case "libx264", "libx265":
+		if rate.Preset != "" {
+			args = append(args, "-preset", rate.Preset)
+		}
+		if rate.VideoBitrate > 0 {
+			args = append(args, "-b:v", strconv.Itoa(rate.VideoBitrate))
+		} else if rate.Quality > 0 {
+			args = append(args, "-crf", strconv.Itoa(51-rate.Quality*51/100))
+		}
+	case "libvpx-vp9":
+		// VP9 has no hardware encoder on macOS; limit CPU usage and use row-mt for better threading
+		args = append(args, "-cpu-used", "4", "-row-mt", "1")
+		if rate.VideoBitrate > 0 {
+			args = append(args, "-b:v", strconv.Itoa(rate.VideoBitrate))
+		} else if rate.Quality > 0 {
+			// Constant quality mode requires -b:v 0
+			args = append(args, "-crf", strconv.Itoa(63-rate.Quality*63/100), "-b:v", "0")
+		}
+	case "libvpx":
+		args = append(args, "-cpu-used", "4")
+		if rate.VideoBitrate > 0 {
+			args = append(args, "-b:v", strconv.Itoa(rate.VideoBitrate))
+		} else if rate.Quality > 0 {
+			// VP8 has no pure constant quality mode; -crf is capped by a bitrate
+			args = append(args, "-crf", strconv.Itoa(63-rate.Quality*59/100), "-b:v", "8M")
+		}
+	case AV1EncoderSVT:
+		// Presets run 0 (slowest) to 13; 8 is a practical speed for server-side encodes
+		args = append(args, "-preset", "8")
+		if rate.VideoBitrate > 0 {
+			args = append(args, "-b:v", strconv.Itoa(rate.VideoBitrate))
+		} else if rate.Quality > 0 {
+			args = append(args, "-crf", strconv.Itoa(63-rate.Quality*62/100))
+		}
+	case AV1EncoderAOM:
+		args = append(args, "-cpu-used", "6", "-row-mt", "1")
+		if rate.VideoBitrate > 0 {
+			args = append(args, "-b:v", strconv.Itoa(rate.VideoBitrate))
+		} else if rate.Quality > 0 {
+			args = append(args, "-crf", strconv.Itoa(63-rate.Quality*63/100), "-b:v", "0")
+		}
+	case "h264_videotoolbox", "hevc_videotoolbox":
+		if rate.VideoBitrate > 0 {
+			args = append(args, "-b:v", strconv.Itoa(rate.VideoBitrate))
+		} else if rate.Quality > 0 {
+			args = append(args, "-q:v", strconv.Itoa(rate.Quality))
+		}
+	case "mpeg4":
+		if rate.VideoBitrate > 0 {
+			args = append(args, "-b:v", strconv.Itoa(rate.VideoBitrate))
+		} else if rate.Quality > 0 {
+			args = append(args, "-q:v", strconv.Itoa(31-rate.Quality*29/100))
+		}
+	}
+
+	if rate.Pass > 0 && supportsTwoPass(codec) {
+		if codec == "libx265" {
+			// x265 keeps its own statistics and ignores -pass
+			args = append(args, "-x265-params", fmt.Sprintf("pass=%d:stats=%s.log", rate.Pass, rate.PassLogFile))
+		} else {
+			args = append(args, "-pass", strconv.Itoa(rate.Pass), "-passlogfile", rate.PassLogFile)
+		}
+	}
+	return args
+}
+
+// supportsQuality reports whether videoEncoderArgs can map a quality or bitrate onto the codec
+func supportsQuality(codec string) bool {
+	switch codec {
+	case "libx264", "libx265", "libvpx", "libvpx-vp9", AV1EncoderSVT, AV1EncoderAOM, "h264_videotoolbox", "hevc_videotoolbox", "mpeg4":
+		return true
+	}
+	return false
+}
+
+// supportsTwoPass reports whether the encoder can refine its bitrate over two passes.
+// Hardware encoders only do single-pass average bitrate.
+func supportsTwoPass(codec string) bool {
+	switch codec {
+	case "libx264", "libx265", "libvpx", "libvpx-vp9", AV1EncoderAOM, "mpeg4":
+		return true
+	}
+	return false
+}
+
+// containerAliases maps equivalent file extensions onto one container name
+var containerAliases = map[string]string{
+	"m4v":  "mp4",
+	"jpeg": "jpg",
+	"oga":  "ogg",
+}
+
+// imageContainers are single-image output formats
+var imageContainers = map[string]bool{"jpg": true, "png": true, "webp": true, "avif": true, "bmp": true}
+
+// audioContainers are output formats that hold only audio
+var audioContainers = map[string]bool{"mp3": true, "aac": true, "wav": true, "flac": true, "ogg": true, "m4a": true, "opus": true}
+
+// containerFromPath returns the normalized container for an output path
+func containerFromPath(path string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if alias, ok := containerAliases[ext]; ok {
+		return alias
+	}
+	return ext
+}
+
+// resolveOutputSpec turns an operation chain into a single encoding plan using
+// the operation registry. It fails with ErrConflictingOperations if operations
+// make incompatible demands, or ErrInvalidOperation for bad operations.
+func resolveOutputSpec(inputPath, outputPath string, ops []Operation, enc encoderSettings) (*OutputSpec, error) {
+	b := &PlanBuilder{
+		Spec: &OutputSpec{
+			Inputs:     []InputSpec{{Path: inputPath}},
+			Container:  containerFromPath(outputPath),
+			OutputPath: outputPath,
+			Threads:    enc.Threads,
+		},
+		enc:    enc,
+		owners: map[string]string{},
+	}
+	if b.Spec.Container != "" {
+		b.owners["container"] = "output file"
+	}
+
+	for _, op := range ops {
+		spec, params, err := registry.resolve(op)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Apply == nil {
+			return nil, fmt.Errorf("%w: %s can't be combined into a single-output plan", ErrInvalidOperation, op.Type)
+		}
+		b.op = op.Type
+		if err := spec.Apply(b, params); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.finalize(); err != nil {
+		return nil, err
+	}
+	return b.Spec, nil
+}
+
+// PlanBuilder accumulates an OutputSpec while operations are applied, and
+// remembers which operation decided each setting so conflicts can name both
+type PlanBuilder struct {
+	Spec *OutputSpec
+
+	enc    encoderSettings
+	owners map[string]string
+	op     string // Operation currently being applied
+
+	videoUser string // First operation that only makes sense with a video stream
+	audioUser string // First operation that only makes sense with an audio stream
+	thumbnail bool
+	audioMix  string         // amix chain added by addAudio, completed in finalize
+	subtitles *subtitleTrack // Soft subtitles added by addSubtitles, mapped in finalize
+	overlays  []overlayLayer // Images and videos from overlay, composited in finalize
+	image     *imageEncoding // Image settings from convertImageFormat, applied in finalize
+
+	stripMetadata bool
+}
+
+// conflictf reports operations that disagree about the output
+func conflictf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrConflictingOperations, fmt.Sprintf(format, args...))
+}
+
+// Set assigns a setting, failing if another operation already chose a different value
+func (b *PlanBuilder) Set(setting string, dst *string, value string) error {
+	if owner, ok := b.owners[setting]; ok && *dst != value {
+		return conflictf("%s wants %s %q but %s already set %q", b.op, setting, value, owner, *dst)
+	}
+	if _, ok := b.owners[setting]; !ok {
+		b.owners[setting] = b.op
+	}
+	*dst = value
+	return nil
+}
+
+// Claim marks a setting that may only be decided once per chain
+func (b *PlanBuilder) Claim(setting string) error {
+	if owner, ok := b.owners[setting]; ok {
+		return conflictf("%s and %s both set the %s", owner, b.op, setting)
+	}
+	b.owners[setting] = b.op
+	return nil
+}
+
+// AddVideoFilter appends filters that need the video stream
+func (b *PlanBuilder) AddVideoFilter(filters ...string) {
+	if b.videoUser == "" {
+		b.videoUser = b.op
+	}
+	b.Spec.VideoFilters = append(b.Spec.VideoFilters, filters...)
+}
+
+// AddAudioFilter appends filters that need the audio stream
+func (b *PlanBuilder) AddAudioFilter(filters ...string) {
+	b.UseAudio()
+	b.Spec.AudioFilters = append(b.Spec.AudioFilters, filters...)
+}
+
+// AddTimelineFilters appends filters for operations that affect both streams;
+// each is only applied if its stream ends up in the output
+func (b *PlanBuilder) AddTimelineFilters(video, audio string) {
+	b.Spec.VideoFilters = append(b.Spec.VideoFilters, video)
+	b.Spec.AudioFilters = append(b.Spec.AudioFilters, audio)
+}
+
+// UseAudio records that the current operation needs the audio stream
+func (b *PlanBuilder) UseAudio() {
+	if b.audioUser == "" {
+		b.audioUser = b.op
+	}
+}
+
+// DropVideo removes the video stream from the output
+func (b *PlanBuilder) DropVideo() {
+	if _, ok := b.owners["video removal"]; !ok {
+		b.owners["video removal"] = b.op
+	}
+	b.Spec.DropVideo = true
+}
+
+// DropAudio removes the audio stream from the output
+func (b *PlanBuilder) DropAudio() {
+	if _, ok := b.owners["audio removal"]; !ok {
+		b.owners["audio removal"] = b.op
+	}
+	b.Spec.DropAudio = true
+}
+
+// VideoEncoder picks the encoder for a codec family, honouring hardware acceleration
+func (b *PlanBuilder) VideoEncoder(codec string) string {
+	switch codec {
+	case "h264":
+		if b.enc.HWAccel {
+			return "h264_videotoolbox"
+		}
+		return "libx264"
+	case "h265":
+		if b.enc.HWAccel {
+			return "hevc_videotoolbox"
+		}
+		return "libx265"
+	case "vp8":
+		return "libvpx"
+	case "vp9":
+		return "libvpx-vp9"
+	case "av1":
+		if b.enc.AV1Encoder != "" {
+			return b.enc.AV1Encoder
+		}
+		return AV1EncoderSVT
+	case "mpeg4":
+		return "mpeg4"
+	}
+	return ""
+}
+
+// defaultCodecs returns the video and audio encoders used for a container when no operation chose one
+func (b *PlanBuilder) defaultCodecs(container string) (video, audio string) {
+	switch container {
+	case "webm":
+		return "libvpx-vp9", "libopus"
+	case "avi":
+		return "mpeg4", "libmp3lame"
+	case "gif":
+		return "gif", ""
+	}
+	if imageContainers[container] {
+		return "", ""
+	}
+	return b.VideoEncoder("h264"), "aac"
+}
+
+// finalize checks stream-level conflicts and fills in codec defaults
+func (b *PlanBuilder) finalize() error {
+	s := b.Spec
+
+	if s.DropVideo && s.DropAudio {
+		return conflictf("%s and %s leave the output with no streams", b.owners["video removal"], b.owners["audio removal"])
+	}
+	if s.DropVideo && b.videoUser != "" {
+		return conflictf("%s removes the video stream that %s changes", b.owners["video removal"], b.videoUser)
+	}
+	if s.DropAudio && b.audioUser != "" {
+		return conflictf("%s removes the audio stream that %s changes", b.owners["audio removal"], b.audioUser)
+	}
+	if b.thumbnail {
+		for _, setting := range []string{"trim", "loop count", "video quality", "video codec"} {
+			if owner, ok := b.owners[setting]; ok {
+				return conflictf("thumbnail produces a single image, but %s sets the %s", owner, setting)
+			}
+		}
+		if s.Container != "" && !imageContainers[s.Container] {
+			return conflictf("thumbnail produces an image, but %s asks for %s", b.owners["container"], s.Container)
+		}
+	}
+
+	// Filters from operations that affect both streams only apply to the streams that are kept
+	if s.DropVideo {
+		s.VideoFilters = nil
+	}
+	if s.DropAudio {
+		s.AudioFilters = nil
+	}
+
+	if b.audioMix != "" {
+		// The mixed track comes out of the complex filtergraph, so its audio filters must run there too
+		chain := b.audioMix
+		if len(s.AudioFilters) > 0 {
+			chain += "," + strings.Join(s.AudioFilters, ",")
+			s.AudioFilters = nil
+		}
+		s.FilterComplex = append(s.FilterComplex, chain+"[aout]")
+	}
+	if b.subtitles != nil {
+		if err := b.muxSubtitles(); err != nil {
+			return err
+		}
+	}
+	reencode := len(s.VideoFilters) > 0 || len(b.overlays) > 0 || s.Rate.Quality > 0 || s.Rate.Targeted()
+	if len(b.overlays) > 0 && !s.DropVideo {
+		b.overlayGraph()
+	}
+
+	defaultVideo, defaultAudio := b.defaultCodecs(s.Container)
+	if !s.DropVideo && s.VideoCodec == "" && !b.thumbnail {
+		if reencode {
+			s.VideoCodec = defaultVideo
+			if !s.DropAudio && s.AudioCodec == "" {
+				s.AudioCodec = defaultAudio
+			}
+		} else if len(s.Maps) > 0 {
+			// Only the audio or subtitle tracks change; keep the original video untouched
+			s.VideoCodec = "copy"
+			if _, added := b.owners["added audio track"]; !added && !s.DropAudio && s.AudioCodec == "" && len(s.AudioFilters) == 0 {
+				s.AudioCodec = "copy"
+			}
+		}
+	}
+
+	if s.Rate.Quality > 0 || s.Rate.Targeted() {
+		if s.DropVideo {
+			return conflictf("%s removes the video stream that compress encodes", b.owners["video removal"])
+		}
+		if !supportsQuality(s.VideoCodec) {
+			return conflictf("compress can't set the quality of %s video", s.VideoCodec)
+		}
+	}
+	if s.Rate.Targeted() && !s.DropAudio && s.AudioBitrate == "" {
+		// The audio share of the size budget has to be known up front
+		s.AudioBitrate = "128k"
+	}
+	if strings.HasPrefix(s.VideoCodec, "libx26") {
+		s.Rate.Preset = b.enc.Preset
+	}
+
+	if family, ok := encoderFamilies[s.VideoCodec]; ok && !s.DropVideo && !containerHolds(s.Container, "video", family) {
+		return conflictf("%s files (from %s) can't hold %s video", s.Container, b.owners["container"], s.VideoCodec)
+	}
+	if family, ok := encoderFamilies[s.AudioCodec]; ok && !s.DropAudio && !containerHolds(s.Container, "audio", family) {
+		return conflictf("%s files (from %s) can't hold %s audio", s.Container, b.owners["container"], s.AudioCodec)
+	}
+
+	if err := b.checkImageOutput(); err != nil {
+		return err
+	}
+	if imageContainers[s.Container] {
+		if err := b.encodeImage(); err != nil {
+			return err
+		}
+	} else if b.thumbnail && s.Container == "" {
+		s.OutputOptions = append(s.OutputOptions, "-q:v", "2") // High quality JPEG
+	}
+	if b.stripMetadata {
+		s.OutputOptions = append(s.OutputOptions, "-map_metadata", "-1", "-map_chapters", "-1")
+	}
+
+	return nil
+}
+
+// useDefaultEncoders encodes a spec built outside the planner (merge, silence
+// removal, split, stabilize) with its container's default encoders
+func useDefaultEncoders(spec *OutputSpec, enc encoderSettings) {
+	b := &PlanBuilder{Spec: spec, enc: enc}
+	spec.VideoCodec, spec.AudioCodec = b.defaultCodecs(spec.Container)
+	if strings.HasPrefix(spec.VideoCodec, "libx26") {
+		spec.Rate.Preset = enc.Preset
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,345 @@
+package media
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEncoder = encoderSettings{Preset: "faster", Threads: 2}
+
+func resolveArgs(t *testing.T, output string, ops ...Operation) []string {
+	t.Helper()
+	spec, err := resolveOutputSpec("in.mp4", output, ops, testEncoder)
+	require.NoError(t, err)
+	return spec.Args()
+}
+
+// countFlag returns how often a flag appears in an argument list
+func countFlag(args []string, flag string) int {
+	n := 0
+	for _, a := range args {
+		if a == flag {
+			n++
+		}
+	}
+	return n
+}
+
+// flagValue returns the value following the first occurrence of a flag
+func flagValue(args []string, flag string) string {
+	for i, a := range args {
+		if a == flag && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func op(opType string, params map[string]interface{}) Operation {
+	return Operation{Type: opType, Params: params}
+}
+
+func TestResolveOutputSpec(t *testing.T) {
+	t.Run("compress then convertFormat emits one codec", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4",
+			op("compress", map[string]interface{}{"quality": 70.0}),
+			op("convertFormat", map[string]interface{}{"targetFormat": "mp4"}),
+		)
+		assert.Equal(t, 1, countFlag(args, "-c:v"))
+		assert.Equal(t, "libx264", flagValue(args, "-c:v"))
+		assert.Equal(t, "16", flagValue(args, "-crf"))
+		assert.Equal(t, "faster", flagValue(args, "-preset"))
+		assert.Equal(t, "+faststart", flagValue(args, "-movflags"))
+		assert.Equal(t, "out.mp4", args[len(args)-1])
+	})
+
+	t.Run("compress to webm uses VP9 quality scale", func(t *testing.T) {
+		args := resolveArgs(t, "out.webm", op("compress", map[string]interface{}{"quality": 50.0}))
+		assert.Equal(t, "libvpx-vp9", flagValue(args, "-c:v"))
+		assert.Equal(t, "libopus", flagValue(args, "-c:a"))
+		assert.Equal(t, "32", flagValue(args, "-crf"))
+		assert.Equal(t, "0", flagValue(args, "-b:v"))
+		assert.Zero(t, countFlag(args, "-preset"))
+		assert.Zero(t, countFlag(args, "-movflags"))
+	})
+
+	t.Run("hardware acceleration picks VideoToolbox", func(t *testing.T) {
+		spec, err := resolveOutputSpec("in.mp4", "out.mp4", []Operation{
+			op("compress", map[string]interface{}{"quality": 60.0}),
+		}, encoderSettings{HWAccel: true})
+		require.NoError(t, err)
+		args := spec.Args()
+		assert.Equal(t, "h264_videotoolbox", flagValue(args, "-c:v"))
+		assert.Equal(t, "60", flagValue(args, "-q:v"))
+	})
+
+	t.Run("filters are merged into single chains", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4",
+			op("resize", map[string]interface{}{"width": 640.0}),
+			op("rotate", map[string]interface{}{"degrees": 90.0}),
+			op("changeSpeed", map[string]interface{}{"multiplier": 2.0}),
+		)
+		assert.Equal(t, 1, countFlag(args, "-vf"))
+		assert.Equal(t, "scale=640:-2,transpose=1,setpts=0.50*PTS", flagValue(args, "-vf"))
+		assert.Equal(t, "atempo=2.00", flagValue(args, "-af"))
+		assert.Equal(t, "libx264", flagValue(args, "-c:v"))
+	})
+
+	t.Run("operations without re-encoding leave codecs to FFmpeg", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4", op("trim", map[string]interface{}{"startTime": "00:00:05", "endTime": 20.0}))
+		assert.Equal(t, "00:00:05", flagValue(args, "-ss"))
+		assert.Equal(t, "20", flagValue(args, "-to"))
+		assert.Zero(t, countFlag(args, "-c:v"))
+	})
+
+	t.Run("loop is an input option", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4", op("loop", map[string]interface{}{"count": 3.0}))
+		assert.Equal(t, []string{"-y", "-threads", "2", "-stream_loop", "2", "-i", "in.mp4"}, args[:7])
+	})
+
+	t.Run("thumbnail keeps earlier video filters", func(t *testing.T) {
+		args := resolveArgs(t, "out.jpg",
+			op("crop", map[string]interface{}{"width": 100.0, "height": 100.0}),
+			op("thumbnail", map[string]interface{}{"timestamp": "00:00:03", "width": 200.0}),
+		)
+		assert.Equal(t, "00:00:03", args[indexOf(args, "-i")-1])
+		assert.Equal(t, "crop=100:100:0:0,scale=200:-1", flagValue(args, "-vf"))
+		assert.Equal(t, "1", flagValue(args, "-frames:v"))
+		assert.Equal(t, 1, countFlag(args, "-an"))
+		assert.Zero(t, countFlag(args, "-c:v"))
+	})
+
+	t.Run("addAudio replace keeps earlier operations", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4",
+			op("trim", map[string]interface{}{"startTime": "00:00:01"}),
+			op("addAudio", map[string]interface{}{"audioPath": "music.mp3", "mode": "replace"}),
+		)
+		assert.Equal(t, 2, countFlag(args, "-i"))
+		assert.Equal(t, "00:00:01", flagValue(args, "-ss"))
+		assert.Equal(t, "copy", flagValue(args, "-c:v"))
+		assert.Contains(t, args, "-shortest")
+	})
+
+	t.Run("addAudio mix folds audio filters into the filtergraph", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4",
+			op("addAudio", map[string]interface{}{"audioPath": "music.mp3", "volume": 0.5}),
+			op("adjustVolume", map[string]interface{}{"db": 3.0}),
+			op("resize", map[string]interface{}{"height": 720.0}),
+		)
+		assert.Equal(t, "[0:a][1:a]amix=inputs=2:duration=first,volume=0.50,volume=3.0dB[aout]", flagValue(args, "-filter_complex"))
+		assert.Zero(t, countFlag(args, "-af"))
+		assert.Equal(t, "scale=-2:720", flagValue(args, "-vf"))
+		assert.Equal(t, "libx264", flagValue(args, "-c:v"))
+	})
+
+	t.Run("extractAudio drops video and timeline video filters", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp3",
+			op("changeSpeed", map[string]interface{}{"multiplier": 2.0}),
+			op("extractAudio", map[string]interface{}{"format": "mp3", "bitrate": 128000.0}),
+		)
+		assert.Contains(t, args, "-vn")
+		assert.Zero(t, countFlag(args, "-vf"))
+		assert.Equal(t, "libmp3lame", flagValue(args, "-c:a"))
+		assert.Equal(t, "128k", flagValue(args, "-b:a"))
+	})
+
+	t.Run("target size renders both passes", func(t *testing.T) {
+		spec, err := resolveOutputSpec("in.mp4", "out.mp4", []Operation{
+			op("compress", map[string]interface{}{"targetSize": 25000000.0}),
+		}, testEncoder)
+		require.NoError(t, err)
+		assert.Equal(t, int64(25000000), spec.Rate.TargetSize)
+		assert.Equal(t, "128k", spec.AudioBitrate)
+
+		spec.Rate.VideoBitrate = 800000
+		spec.Rate.PassLogFile = "/tmp/job/ffmpeg2pass"
+
+		spec.Rate.Pass = 1
+		first := spec.Args()
+		assert.Equal(t, "800000", flagValue(first, "-b:v"))
+		assert.Equal(t, "1", flagValue(first, "-pass"))
+		assert.Equal(t, "/tmp/job/ffmpeg2pass", flagValue(first, "-passlogfile"))
+		assert.Contains(t, first, "-an")
+		assert.Equal(t, "null", flagValue(first, "-f"))
+		assert.Zero(t, countFlag(first, "-crf"))
+
+		spec.Rate.Pass = 2
+		second := spec.Args()
+		assert.Equal(t, "2", flagValue(second, "-pass"))
+		assert.Equal(t, "aac", flagValue(second, "-c:a"))
+		assert.Equal(t, "128k", flagValue(second, "-b:a"))
+		assert.Equal(t, "out.mp4", second[len(second)-1])
+	})
+
+	t.Run("x265 passes go through x265-params", func(t *testing.T) {
+		spec, err := resolveOutputSpec("in.mp4", "out.mkv", []Operation{
+			op("compress", map[string]interface{}{"targetBitrate": 1000000.0}),
+			op("convertFormat", map[string]interface{}{"codec": "h265"}),
+		}, testEncoder)
+		require.NoError(t, err)
+		spec.Rate.VideoBitrate, spec.Rate.Pass, spec.Rate.PassLogFile = 872000, 2, "/tmp/p"
+		args := spec.Args()
+		assert.Equal(t, "pass=2:stats=/tmp/p.log", flagValue(args, "-x265-params"))
+		assert.Zero(t, countFlag(args, "-pass"))
+	})
+
+	t.Run("createGif drops audio", func(t *testing.T) {
+		args := resolveArgs(t, "out.gif",
+			op("reverse", nil),
+			op("createGif", map[string]interface{}{"fps": 12.0}),
+		)
+		assert.Equal(t, "gif", flagValue(args, "-c:v"))
+		assert.Equal(t, "reverse,fps=12,scale=480:-1:flags=lanczos", flagValue(args, "-vf"))
+		assert.Zero(t, countFlag(args, "-af"))
+		assert.Contains(t, args, "-an")
+	})
+}
+
+func TestResolveOutputSpecConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		ops    []Operation
+		want   string
+	}{
+		{
+			name:   "different video codecs",
+			output: "out.mkv",
+			ops: []Operation{
+				op("convertFormat", map[string]interface{}{"codec": "h265"}),
+				op("convertFormat", map[string]interface{}{"targetFormat": "mkv"}),
+			},
+			want: "video codec",
+		},
+		{
+			name:   "target format differs from output file",
+			output: "out.mp4",
+			ops:    []Operation{op("convertFormat", map[string]interface{}{"targetFormat": "webm"})},
+			want:   "container",
+		},
+		{
+			name:   "codec not allowed in container",
+			output: "out.webm",
+			ops:    []Operation{op("convertFormat", map[string]interface{}{"codec": "h264"})},
+			want:   "can't hold libx264 video",
+		},
+		{
+			name:   "two compress qualities",
+			output: "out.mp4",
+			ops: []Operation{
+				op("compress", map[string]interface{}{"quality": 40.0}),
+				op("compress", map[string]interface{}{"quality": 80.0}),
+			},
+			want: "video quality",
+		},
+		{
+			name:   "compress with gif",
+			output: "out.gif",
+			ops: []Operation{
+				op("compress", map[string]interface{}{"quality": 40.0}),
+				op("createGif", nil),
+			},
+			want: "quality of gif video",
+		},
+		{
+			name:   "video filter on extracted audio",
+			output: "out.mp3",
+			ops: []Operation{
+				op("resize", map[string]interface{}{"width": 640.0}),
+				op("extractAudio", map[string]interface{}{"format": "mp3"}),
+			},
+			want: "extractAudio removes the video stream that resize changes",
+		},
+		{
+			name:   "audio filter on removed audio",
+			output: "out.mp4",
+			ops: []Operation{
+				op("removeAudio", nil),
+				op("adjustVolume", map[string]interface{}{"db": 6.0}),
+			},
+			want: "removeAudio removes the audio stream that adjustVolume changes",
+		},
+		{
+			name:   "no streams left",
+			output: "out.mp4",
+			ops: []Operation{
+				op("extractAudio", nil),
+				op("removeAudio", nil),
+			},
+			want: "no streams",
+		},
+		{
+			name:   "thumbnail with trim",
+			output: "out.jpg",
+			ops: []Operation{
+				op("trim", map[string]interface{}{"startTime": "00:00:01"}),
+				op("thumbnail", nil),
+			},
+			want: "thumbnail produces a single image",
+		},
+		{
+			name:   "thumbnail into video file",
+			output: "out.mp4",
+			ops:    []Operation{op("thumbnail", nil)},
+			want:   "thumbnail produces an image",
+		},
+		{
+			name:   "target size for gif",
+			output: "out.gif",
+			ops: []Operation{
+				op("compress", map[string]interface{}{"targetSize": 1000000.0}),
+				op("createGif", nil),
+			},
+			want: "quality of gif video",
+		},
+		{
+			name:   "audio codec not allowed in container",
+			output: "out.wav",
+			ops:    []Operation{op("extractAudio", map[string]interface{}{"format": "mp3"})},
+			want:   "can't hold libmp3lame audio",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolveOutputSpec("in.mp4", tt.output, tt.ops, testEncoder)
+			require.ErrorIs(t, err, ErrConflictingOperations)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+
+	t.Run("same choice twice is not a conflict", func(t *testing.T) {
+		_, err := resolveOutputSpec("in.mp4", "out.webm", []Operation{
+			op("convertFormat", map[string]interface{}{"targetFormat": "webm"}),
+			op("convertFormat", map[string]interface{}{"codec": "vp9"}),
+		}, testEncoder)
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid parameters are errors, not conflicts", func(t *testing.T) {
+		_, err := resolveOutputSpec("in.mp4", "out.mp4", []Operation{
+			op("convertFormat", map[string]interface{}{"targetFormat": "xyz"}),
+		}, testEncoder)
+		require.Error(t, err)
+		assert.False(t, strings.Contains(err.Error(), ErrConflictingOperations.Error()))
+	})
+
+	t.Run("quality and target size together are invalid", func(t *testing.T) {
+		_, err := resolveOutputSpec("in.mp4", "out.mp4", []Operation{
+			op("compress", map[string]interface{}{"quality": 50.0, "targetSize": 1000000.0}),
+		}, testEncoder)
+		require.ErrorIs(t, err, ErrInvalidOperation)
+	})
+}
+
+func indexOf(args []string, flag string) int {
+	for i, a := range args {
+		if a == flag {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,13 +1,14 @@
 package media
 
 import (
-	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nextconvert/backend/internal/shared/storage"
 	"go.uber.org/zap"
@@ -17,28 +18,43 @@ import (
 type Processor struct {
 	storage           *storage.Service
 	ffmpegPath        string
+	ffprobePath       string
 	logger            *zap.Logger
-	maxThreads        int  // Limit CPU threads (0 = auto/unlimited)
-	useHardwareAccel  bool // Use hardware acceleration when available
-	preferFastPresets bool // Use faster presets to reduce CPU load
+	maxThreads        int    // Limit CPU threads (0 = auto/unlimited)
+	useHardwareAccel  bool   // Use hardware acceleration when available
+	preferFastPresets bool   // Use faster presets to reduce CPU load
+	av1Encoder        string // AV1EncoderSVT or AV1EncoderAOM
+
+	capsMu      sync.Mutex
+	caps        *Capabilities // Discovered on first use
+	capsRetryAt time.Time     // After a failed discovery, when to ask FFmpeg again
 }
 
+// capabilityRetryInterval spaces out discovery attempts while FFmpeg can't be
+// asked, instead of running it again for every operation
+const capabilityRetryInterval = time.Minute
+
 // ProcessorConfig configures processor behavior
 type ProcessorConfig struct {
 	FFmpegPath        string
-	MaxThreads        int  // 0 = unlimited, recommended: 2-4 for background processing
-	UseHardwareAccel  bool // Use VideoToolbox on macOS, NVENC on Linux/Windows
-	PreferFastPresets bool // Use "veryfast" instead of "medium" preset
+	FFprobePath       string
+	MaxThreads        int    // 0 = unlimited, recommended: 2-4 for background processing
+	UseHardwareAccel  bool   // Use VideoToolbox on macOS, NVENC on Linux/Windows
+	PreferFastPresets bool   // Use "veryfast" instead of "medium" preset
+	AV1Encoder        string // "libsvtav1" (default) or "libaom-av1"
 }
 
 // ProcessOptions contains options for media processing
 type ProcessOptions struct {
+	JobID             string   // Names per-job scratch files (e.g. two-pass logs)
 	InputPath         string
 	InputPaths        []string // For merge operations with multiple inputs
+	InputDuration     float64  // Source duration in seconds (probed when zero)
 	OutputPath        string
 	Operations        []Operation
-	OnProgress        func(percent int, operation string)
-	UseHardwareAccel  *bool    // Override processor default (e.g. for Pro tier)
+	OnProgress        func(progress ProgressUpdate)
+	OnCommand         func(CommandLog) // Called after each FFmpeg run, e.g. to keep job logs
+	UseHardwareAccel  *bool            // Override processor default (e.g. for Pro tier)
 }
 
 // NewProcessor creates a new media processor with cloud-friendly defaults
@@ -49,6 +65,7 @@ func NewProcessor(storage *storage.Service, ffmpegPath string, logger *zap.Logge
 	return &Processor{
 		storage:           storage,
 		ffmpegPath:        ffmpegPath,
+		ffprobePath:       "ffprobe",
 		logger:            logger,
 		maxThreads:        0,     // Default: 0 = auto (use available cores)
 		useHardwareAccel:  false, // Default: disabled for cloud servers
@@ -61,13 +78,18 @@ func NewProcessorWithConfig(storage *storage.Service, config ProcessorConfig, lo
 	if config.FFmpegPath == "" {
 		config.FFmpegPath = "ffmpeg"
 	}
+	if config.FFprobePath == "" {
+		config.FFprobePath = "ffprobe"
+	}
 	return &Processor{
 		storage:           storage,
 		ffmpegPath:        config.FFmpegPath,
+		ffprobePath:       config.FFprobePath,
 		logger:            logger,
 		maxThreads:        config.MaxThreads,
 		useHardwareAccel:  config.UseHardwareAccel,
 		preferFastPresets: config.PreferFastPresets,
+		av1Encoder:        config.AV1Encoder,
 	}
 }
 
@@ -79,824 +101,245 @@ func (p *Processor) useHWAccel(opts *ProcessOptions) bool {
 	return p.useHardwareAccel
 }
 
-// Process executes media operations
-func (p *Processor) Process(ctx context.Context, opts ProcessOptions) error {
-	// Check if this is a merge operation
+// Capabilities returns what the FFmpeg build includes, asking FFmpeg the
+// first time. It's nil (everything assumed available) if FFmpeg can't be
+// asked; a failed discovery is retried after capabilityRetryInterval.
+func (p *Processor) Capabilities(ctx context.Context) *Capabilities {
+	p.capsMu.Lock()
+	defer p.capsMu.Unlock()
+	if p.caps == nil && time.Now().After(p.capsRetryAt) {
+		caps, err := DiscoverCapabilities(ctx, p.ffmpegPath)
+		if err != nil {
+			p.logger.Warn("Failed to discover FFmpeg capabilities", zap.Error(err))
+			p.capsRetryAt = time.Now().Add(capabilityRetryInterval)
+			return nil
+		}
+		p.caps = caps
+	}
+	return p.caps
+}
+
+// knownCapabilities returns the capabilities if they have been discovered
+func (p *Processor) knownCapabilities() *Capabilities {
+	p.capsMu.Lock()
+	defer p.capsMu.Unlock()
+	return p.caps
+}
+
+// ProcessResult describes the files a run wrote when it produced more than
+// OutputPath alone: either one package of many files (e.g. HLS) or several
+// separate outputs (e.g. the parts of a split)
+type ProcessResult struct {
+	Dir       string     // Local directory holding every artifact
+	Entry     string     // Name of the artifact clients open first (e.g. master.m3u8)
+	Artifacts []Artifact // Every file written, including the entry
+	Outputs   []Artifact // Files that are each an output of their own, in order
+}
+
+// Artifact is one file of a multi-file output
+type Artifact struct {
+	Name string // Path relative to Dir, as referenced from manifests
+	Path string // Local path
+}
+
+// Process executes media operations. The result is nil when the output is the
+// single file at OutputPath.
+func (p *Processor) Process(ctx context.Context, opts ProcessOptions) (*ProcessResult, error) {
+	ctx = withCommandLog(ctx, opts.OnCommand)
+
+	// Anything the FFmpeg build lacks fails the job before encoding starts
+	caps := p.Capabilities(ctx)
+
+	// Operations such as merge and packaging run on their own
 	for _, op := range opts.Operations {
-		if op.Type == "merge" {
-			return p.processMerge(ctx, opts)
+		if spec, ok := LookupOperation(op.Type); ok && spec.Run != nil {
+			// Running one would silently drop the rest of the chain
+			if len(opts.Operations) > 1 {
+				return nil, fmt.Errorf("%w: operation '%s' must be the only operation in a chain", ErrInvalidOperation, op.Type)
+			}
+			params, problems := spec.validate(op.Params)
+			if len(problems) > 0 {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidOperation, strings.Join(problems, "; "))
+			}
+			if spec.Requires != nil {
+				if err := spec.Requires(params, caps); err != nil {
+					return nil, err
+				}
+			}
+			return spec.Run(ctx, p, opts, params)
 		}
 	}
 
-	// Build FFmpeg command for standard operations
-	args := p.buildFFmpegArgs(opts)
+	// Resolve the operation chain into a single encoding plan
+	spec, err := resolveOutputSpec(opts.InputPath, opts.OutputPath, opts.Operations, p.encoderSettings(&opts))
+	if err != nil {
+		return nil, err
+	}
+	if err := caps.checkSpec(spec); err != nil {
+		return nil, err
+	}
+	if imageContainers[spec.Container] {
+		// Photos are often stored sideways with an EXIF orientation tag
+		orientImage(spec, exifOrientation(opts.InputPath))
+	}
 
+	// Work out how long the output will be so progress can be reported as a percentage
+	inputDuration := opts.InputDuration
+	if inputDuration <= 0 {
+		inputDuration = p.probeDuration(ctx, opts.InputPath)
+	}
+	total := expectedOutputDuration(opts.Operations, inputDuration)
+
+	// Size and bitrate targets are budgeted against the output duration
+	if spec.Rate.Targeted() {
+		if err := p.encodeToTarget(ctx, spec, total, opts); err != nil {
+			return nil, fmt.Errorf("FFmpeg execution failed: %w", err)
+		}
+		return nil, nil
+	}
+
+	args := spec.Args()
 	p.logger.Info("Executing FFmpeg",
 		zap.String("input", opts.InputPath),
 		zap.String("output", opts.OutputPath),
 		zap.Strings("args", args),
+		zap.Float64("expected_duration", total),
 	)
 
-	cmd := exec.CommandContext(ctx, p.ffmpegPath, args...)
+	if err := p.runFFmpeg(ctx, args, total, describeOperations(opts.Operations), opts.OnProgress); err != nil {
+		return nil, fmt.Errorf("FFmpeg execution failed: %w", err)
+	}
 
-	// Capture stderr for progress
-	stderr, err := cmd.StderrPipe()
+	return nil, nil
+}
+
+// ffmpegWaitDelay bounds how long Wait blocks on I/O after FFmpeg is killed
+const ffmpegWaitDelay = 5 * time.Second
+
+// runFFmpeg executes FFmpeg and reports progress parsed from its -progress output.
+// total is the expected output duration in seconds (0 if unknown).
+func (p *Processor) runFFmpeg(ctx context.Context, args []string, total float64, operation string, onProgress func(ProgressUpdate)) error {
+	// Machine-readable progress goes to stdout; -nostats silences the human-readable stderr line
+	fullArgs := append([]string{"-hide_banner", "-progress", "pipe:1", "-nostats"}, args...)
+
+	cmd := exec.CommandContext(ctx, p.ffmpegPath, fullArgs...)
+	killOnCancel(cmd)
+	// Don't block on the progress pipe forever if a killed FFmpeg leaves it open
+	cmd.WaitDelay = ffmpegWaitDelay
+	// Keep the end of stderr, where FFmpeg explains why it failed
+	stderr := &stderrTail{}
+	cmd.Stderr = stderr
+	startedAt := time.Now()
+
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start FFmpeg: %w", err)
 	}
 
-	// Parse progress from stderr
-	go p.parseProgress(stderr, opts.OnProgress)
+	// Progress must be fully read before Wait closes the pipe
+	p.parseProgress(stdout, total, operation, onProgress)
 
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("FFmpeg execution failed: %w", err)
+	err = cmd.Wait()
+	lines, dropped := stderr.Lines()
+	reportCommand(ctx, CommandLog{
+		Operation: operation,
+		Args:      fullArgs,
+		Stderr:    lines,
+		Dropped:   dropped,
+		Err:       err,
+		Duration:  time.Since(startedAt),
+	})
+	if err != nil && len(lines) > 0 {
+		// "exit status 1" alone says nothing; FFmpeg's last line usually does
+		return fmt.Errorf("%w: %s", err, lines[len(lines)-1])
 	}
+	return err
+}
 
-	return nil
+// probeDuration returns the duration of a media file, or 0 if it cannot be probed
+func (p *Processor) probeDuration(ctx context.Context, inputPath string) float64 {
+	info, err := p.Probe(ctx, inputPath)
+	if err != nil {
+		p.logger.Warn("Could not probe input duration, progress will be indeterminate",
+			zap.String("input", inputPath),
+			zap.Error(err),
+		)
+		return 0
+	}
+	return info.Duration
+}
+
+// describeOperations returns a human-readable label for progress updates
+func describeOperations(ops []Operation) string {
+	if len(ops) == 0 {
+		return "Processing"
+	}
+	names := make([]string, 0, len(ops))
+	for _, op := range ops {
+		names = append(names, op.Type)
+	}
+	return "Processing: " + strings.Join(names, ", ")
 }
 
-// processMerge handles video merging using FFmpeg concat demuxer
 // MergeOptions contains options for merging videos
 type MergeOptions struct {
+	JobID             string
 	InputPaths        []string
 	OutputPath        string
-	OnProgress        func(percent int, operation string)
+	Params            map[string]interface{} // The merge operation's parameters (width, height, fps)
+	OnProgress        func(progress ProgressUpdate)
+	OnCommand         func(CommandLog)
 	UseHardwareAccel  *bool
 }
 
 // ProcessMerge merges multiple videos into one (public interface method)
 func (p *Processor) ProcessMerge(ctx context.Context, opts MergeOptions) error {
-	return p.processMerge(ctx, ProcessOptions{
+	spec, _ := LookupOperation("merge")
+	params, problems := spec.validate(opts.Params)
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidOperation, strings.Join(problems, "; "))
+	}
+	if err := spec.Requires(params, p.Capabilities(ctx)); err != nil {
+		return err
+	}
+	return p.processMerge(withCommandLog(ctx, opts.OnCommand), ProcessOptions{
+		JobID:            opts.JobID,
 		InputPaths:       opts.InputPaths,
 		OutputPath:       opts.OutputPath,
 		OnProgress:       opts.OnProgress,
 		UseHardwareAccel: opts.UseHardwareAccel,
-	})
-}
-
-func (p *Processor) processMerge(ctx context.Context, opts ProcessOptions) error {
-	// Collect all input paths
-	inputPaths := opts.InputPaths
-	if len(inputPaths) == 0 && opts.InputPath != "" {
-		inputPaths = []string{opts.InputPath}
-	}
-
-	if len(inputPaths) < 2 {
-		return fmt.Errorf("merge requires at least 2 input files")
-	}
-
-	p.logger.Info("Merging videos",
-		zap.Int("count", len(inputPaths)),
-		zap.Strings("inputs", inputPaths),
-		zap.String("output", opts.OutputPath),
-	)
-
-	// Use filter_complex concat to handle videos with different codecs/resolutions
-	// This re-encodes everything to ensure compatibility
-	args := []string{"-y"}
-
-	// Limit threads to prevent OOM
-	threads := p.maxThreads
-	if threads <= 0 {
-		threads = 1
-	}
-	args = append(args, "-threads", strconv.Itoa(threads))
-
-	// Add all input files
-	for _, inputPath := range inputPaths {
-		args = append(args, "-i", inputPath)
-	}
-
-	// Build the filter_complex string for concat
-	// First, scale all videos to the same resolution (use first video's resolution or 1080p)
-	// and normalize audio to stereo 44.1kHz
-	var filterParts []string
-	for i := range inputPaths {
-		// Scale video to 1920x1080, pad if needed to maintain aspect ratio
-		// Also normalize pixel format to yuv420p for compatibility
-		filterParts = append(filterParts,
-			fmt.Sprintf("[%d:v]scale=1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=30,format=yuv420p[v%d]", i, i),
-		)
-		// Normalize audio to stereo 44.1kHz
-		filterParts = append(filterParts,
-			fmt.Sprintf("[%d:a]aformat=sample_fmts=fltp:sample_rates=44100:channel_layouts=stereo[a%d]", i, i),
-		)
-	}
-
-	// Build concat input string
-	var concatInputs string
-	for i := range inputPaths {
-		concatInputs += fmt.Sprintf("[v%d][a%d]", i, i)
-	}
-
-	// Add the concat filter
-	filterParts = append(filterParts,
-		fmt.Sprintf("%sconcat=n=%d:v=1:a=1[outv][outa]", concatInputs, len(inputPaths)),
-	)
-
-	filterComplex := strings.Join(filterParts, ";")
-
-	args = append(args, "-filter_complex", filterComplex)
-	args = append(args, "-map", "[outv]", "-map", "[outa]")
-
-	// Encode with H.264 and AAC for maximum compatibility
-	preset := "medium"
-	if p.preferFastPresets {
-		preset = "faster"
-	}
-
-	if p.useHWAccel(&opts) {
-		args = append(args, "-c:v", "h264_videotoolbox", "-b:v", "5M")
-	} else {
-		args = append(args, "-c:v", "libx264", "-preset", preset, "-crf", "23")
-	}
-	args = append(args, "-c:a", "aac", "-b:a", "192k")
-
-	// Add -movflags +faststart for MP4 outputs (enables progressive download/playback)
-	if strings.HasSuffix(strings.ToLower(opts.OutputPath), ".mp4") {
-		args = append(args, "-movflags", "+faststart")
-	}
-
-	args = append(args, opts.OutputPath)
-
-	p.logger.Info("Executing FFmpeg merge with re-encoding",
-		zap.Strings("args", args),
-	)
-
-	cmd := exec.CommandContext(ctx, p.ffmpegPath, args...)
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
-	}
-
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start FFmpeg: %w", err)
-	}
-
-	go p.parseProgress(stderr, opts.OnProgress)
-
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("FFmpeg merge failed: %w", err)
-	}
-
-	return nil
+	}, params)
 }
 
-func (p *Processor) buildFFmpegArgs(opts ProcessOptions) []string {
-	args := []string{"-y"}
-
+// encoderSettings returns the processor-level encoding choices for a run
+func (p *Processor) encoderSettings(opts *ProcessOptions) encoderSettings {
 	// Limit CPU threads for predictable resource usage
 	threads := p.maxThreads
 	if threads <= 0 {
 		threads = 2 // Default to 2 threads for good balance of speed and memory
 	}
-	args = append(args, "-threads", strconv.Itoa(threads))
 
-	args = append(args, "-i", opts.InputPath)
-
-	var videoFilters []string
-	var audioFilters []string
-
-	// Determine the preset to use based on configuration
 	preset := "medium"
 	if p.preferFastPresets {
 		preset = "faster" // ~20% slower than veryfast but ~15% smaller output with better quality
 	}
 
-	for _, op := range opts.Operations {
-		switch op.Type {
-		case "trim":
-			if start, ok := op.Params["startTime"].(string); ok {
-				args = append(args, "-ss", start)
-			}
-			if end, ok := op.Params["endTime"].(string); ok {
-				args = append(args, "-to", end)
-			}
-
-		case "resize":
-			width := getIntParam(op.Params, "width", 0)
-			height := getIntParam(op.Params, "height", 0)
-			maintainAspect := getBoolParam(op.Params, "maintainAspect", true)
-
-			if width > 0 || height > 0 {
-				if maintainAspect {
-					if width > 0 && height > 0 {
-						videoFilters = append(videoFilters, fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", width, height))
-					} else if width > 0 {
-						videoFilters = append(videoFilters, fmt.Sprintf("scale=%d:-2", width))
-					} else {
-						videoFilters = append(videoFilters, fmt.Sprintf("scale=-2:%d", height))
-					}
-				} else {
-					videoFilters = append(videoFilters, fmt.Sprintf("scale=%d:%d", width, height))
-				}
-			}
-
-		case "compress":
-			quality := getIntParam(op.Params, "quality", 70)
-			// Convert quality (1-100) to CRF (0-51, lower is better)
-			crf := 51 - (quality * 51 / 100)
-			args = append(args, "-crf", strconv.Itoa(crf))
-			// Add codec with fast preset for compression
-			if p.useHWAccel(&opts) {
-				// Try macOS VideoToolbox hardware encoder (very fast, low CPU)
-				args = append(args, "-c:v", "h264_videotoolbox", "-c:a", "aac")
-			} else {
-				args = append(args, "-c:v", "libx264", "-preset", preset, "-c:a", "aac")
-			}
-
-		case "convertFormat":
-			// Handle targetFormat (mp4, webm, mov, avi, mkv) from frontend
-			if targetFormat, ok := op.Params["targetFormat"].(string); ok {
-				switch targetFormat {
-				case "mp4", "mov":
-					if p.useHWAccel(&opts) {
-						// macOS VideoToolbox - hardware H.264 encoder (minimal CPU usage)
-						args = append(args, "-c:v", "h264_videotoolbox", "-c:a", "aac")
-					} else {
-						args = append(args, "-c:v", "libx264", "-preset", preset, "-c:a", "aac")
-					}
-				case "webm":
-					// VP9 has no hardware encoder on macOS, but we can limit CPU usage
-					// by using lower quality settings and row-mt for better threading
-					args = append(args, "-c:v", "libvpx-vp9", "-cpu-used", "4", "-row-mt", "1", "-c:a", "libopus")
-				case "avi":
-					// MPEG-4 for AVI format
-					args = append(args, "-c:v", "mpeg4", "-c:a", "mp3")
-				case "mkv":
-					if p.useHWAccel(&opts) {
-						args = append(args, "-c:v", "h264_videotoolbox", "-c:a", "aac")
-					} else {
-						args = append(args, "-c:v", "libx264", "-preset", preset, "-c:a", "aac")
-					}
-				}
-			} else if codec, ok := op.Params["codec"].(string); ok {
-				// Backward compatibility: handle codec param directly
-				switch codec {
-				case "h264":
-					if p.useHWAccel(&opts) {
-						args = append(args, "-c:v", "h264_videotoolbox")
-					} else {
-						args = append(args, "-c:v", "libx264", "-preset", preset)
-					}
-				case "h265":
-					if p.useHWAccel(&opts) {
-						// macOS VideoToolbox HEVC encoder
-						args = append(args, "-c:v", "hevc_videotoolbox")
-					} else {
-						args = append(args, "-c:v", "libx265", "-preset", preset)
-					}
-				case "vp9":
-					args = append(args, "-c:v", "libvpx-vp9", "-cpu-used", "4", "-row-mt", "1")
-				}
-			}
-
-		case "rotate":
-			degrees := getIntParam(op.Params, "degrees", 0)
-			flipH := getBoolParam(op.Params, "flipHorizontal", false)
-			flipV := getBoolParam(op.Params, "flipVertical", false)
-
-			// Apply rotation
-			switch degrees {
-			case 90:
-				videoFilters = append(videoFilters, "transpose=1")
-			case 180:
-				videoFilters = append(videoFilters, "transpose=1,transpose=1")
-			case 270:
-				videoFilters = append(videoFilters, "transpose=2")
-			}
-
-			// Apply flips
-			if flipH {
-				videoFilters = append(videoFilters, "hflip")
-			}
-			if flipV {
-				videoFilters = append(videoFilters, "vflip")
-			}
-
-		case "crop":
-			x := getIntParam(op.Params, "x", 0)
-			y := getIntParam(op.Params, "y", 0)
-			w := getIntParam(op.Params, "width", 0)
-			h := getIntParam(op.Params, "height", 0)
-			if w > 0 && h > 0 {
-				videoFilters = append(videoFilters, fmt.Sprintf("crop=%d:%d:%d:%d", w, h, x, y))
-			}
-
-		case "extractAudio":
-			args = append(args, "-vn") // Remove video stream
-			format := getStringParam(op.Params, "format", "mp3")
-			bitrate := getIntParam(op.Params, "bitrate", 192000)
-
-			switch format {
-			case "mp3":
-				args = append(args, "-acodec", "libmp3lame")
-				args = append(args, "-b:a", fmt.Sprintf("%dk", bitrate/1000))
-			case "aac":
-				args = append(args, "-acodec", "aac")
-				args = append(args, "-b:a", fmt.Sprintf("%dk", bitrate/1000))
-			case "wav":
-				args = append(args, "-acodec", "pcm_s16le")
-				// WAV doesn't use bitrate compression
-			case "flac":
-				args = append(args, "-acodec", "flac")
-				// FLAC is lossless, compression level instead of bitrate
-				args = append(args, "-compression_level", "5")
-			case "ogg":
-				args = append(args, "-acodec", "libvorbis")
-				args = append(args, "-b:a", fmt.Sprintf("%dk", bitrate/1000))
-			default:
-				args = append(args, "-acodec", "libmp3lame")
-				args = append(args, "-b:a", "192k")
-			}
-
-		case "changeSpeed":
-			multiplier := getFloatParam(op.Params, "multiplier", 1.0)
-			if multiplier != 1.0 {
-				videoFilters = append(videoFilters, fmt.Sprintf("setpts=%.2f*PTS", 1/multiplier))
-				audioFilters = append(audioFilters, fmt.Sprintf("atempo=%.2f", multiplier))
-			}
-
-		case "createGif":
-			fps := getIntParam(op.Params, "fps", 10)
-			width := getIntParam(op.Params, "width", 480)
-			videoFilters = append(videoFilters, fmt.Sprintf("fps=%d,scale=%d:-1:flags=lanczos", fps, width))
-
-		case "changeBitrate":
-			bitrate := getIntParam(op.Params, "bitrate", 128000)
-			args = append(args, "-b:a", fmt.Sprintf("%d", bitrate))
-
-		case "adjustVolume":
-			db := getFloatParam(op.Params, "db", 0)
-			if db != 0 {
-				audioFilters = append(audioFilters, fmt.Sprintf("volume=%.1fdB", db))
-			}
-
-		case "fadeInOut":
-			fadeIn := getFloatParam(op.Params, "fadeIn", 0)
-			fadeOut := getFloatParam(op.Params, "fadeOut", 0)
-			if fadeIn > 0 {
-				audioFilters = append(audioFilters, fmt.Sprintf("afade=t=in:st=0:d=%.1f", fadeIn))
-			}
-			if fadeOut > 0 {
-				audioFilters = append(audioFilters, fmt.Sprintf("afade=t=out:st=0:d=%.1f", fadeOut))
-			}
-
-		case "addWatermark":
-			// Text watermark support
-			if text, ok := op.Params["text"].(string); ok && text != "" {
-				position := getStringParam(op.Params, "position", "bottomright")
-				fontSize := getIntParam(op.Params, "fontSize", 24)
-				fontColor := getStringParam(op.Params, "fontColor", "white")
-				opacity := getFloatParam(op.Params, "opacity", 0.8)
-
-				// Map position to FFmpeg coordinates
-				var x, y string
-				switch position {
-				case "topleft":
-					x, y = "10", "10"
-				case "topright":
-					x, y = "w-tw-10", "10"
-				case "bottomleft":
-					x, y = "10", "h-th-10"
-				case "bottomright":
-					x, y = "w-tw-10", "h-th-10"
-				case "center":
-					x, y = "(w-tw)/2", "(h-th)/2"
-				default:
-					x, y = "w-tw-10", "h-th-10" // default to bottom right
-				}
-
-				// Escape special characters for FFmpeg drawtext filter
-				// Colons and backslashes need escaping
-				escapedText := text
-				escapedText = strings.ReplaceAll(escapedText, "\\", "\\\\")
-				escapedText = strings.ReplaceAll(escapedText, "'", "'\\''")
-				escapedText = strings.ReplaceAll(escapedText, ":", "\\:")
-
-				// Build drawtext filter with font file
-				// Use DejaVu Sans which is installed in the Docker container
-				filter := fmt.Sprintf(
-					"drawtext=text='%s':fontfile=/usr/share/fonts/ttf-dejavu/DejaVuSans.ttf:fontsize=%d:fontcolor=%s:alpha=%.2f:x=%s:y=%s",
-					escapedText,
-					fontSize,
-					fontColor,
-					opacity,
-					x, y,
-				)
-				videoFilters = append(videoFilters, filter)
-			}
-
-		case "filters":
-			// Video filter adjustments using FFmpeg eq filter
-			brightness := getFloatParam(op.Params, "brightness", 0)  // -1 to 1 (0 = no change)
-			contrast := getFloatParam(op.Params, "contrast", 1)      // 0 to 2 (1 = no change)
-			saturation := getFloatParam(op.Params, "saturation", 1)  // 0 to 3 (1 = no change)
-			gamma := getFloatParam(op.Params, "gamma", 1)            // 0.1 to 10 (1 = no change)
-			hue := getFloatParam(op.Params, "hue", 0)                // -180 to 180 degrees (0 = no change)
-			blur := getFloatParam(op.Params, "blur", 0)              // 0 to 10 (0 = no blur)
-			sharpen := getFloatParam(op.Params, "sharpen", 0)        // 0 to 5 (0 = no sharpen)
-			vignette := getBoolParam(op.Params, "vignette", false)   // Add vignette effect
-			grayscale := getBoolParam(op.Params, "grayscale", false) // Convert to grayscale
-			sepia := getBoolParam(op.Params, "sepia", false)         // Apply sepia tone
-			negative := getBoolParam(op.Params, "negative", false)   // Invert colors
-
-			// Build eq filter for brightness, contrast, saturation, gamma
-			eqParts := []string{}
-			if brightness != 0 {
-				eqParts = append(eqParts, fmt.Sprintf("brightness=%.2f", brightness))
-			}
-			if contrast != 1 {
-				eqParts = append(eqParts, fmt.Sprintf("contrast=%.2f", contrast))
-			}
-			if saturation != 1 {
-				eqParts = append(eqParts, fmt.Sprintf("saturation=%.2f", saturation))
-			}
-			if gamma != 1 {
-				eqParts = append(eqParts, fmt.Sprintf("gamma=%.2f", gamma))
-			}
-			if len(eqParts) > 0 {
-				videoFilters = append(videoFilters, "eq="+strings.Join(eqParts, ":"))
-			}
-
-			// Hue adjustment
-			if hue != 0 {
-				videoFilters = append(videoFilters, fmt.Sprintf("hue=h=%.1f", hue))
-			}
-
-			// Blur effect (using boxblur)
-			if blur > 0 {
-				blurRadius := int(blur * 2) // Scale for more visible effect
-				if blurRadius < 1 {
-					blurRadius = 1
-				}
-				videoFilters = append(videoFilters, fmt.Sprintf("boxblur=%d:%d", blurRadius, blurRadius))
-			}
-
-			// Sharpen effect (using unsharp mask)
-			if sharpen > 0 {
-				// unsharp=lx:ly:la where l=luma, a=amount
-				amount := sharpen * 1.5 // Scale for better effect
-				videoFilters = append(videoFilters, fmt.Sprintf("unsharp=5:5:%.1f:5:5:0", amount))
-			}
-
-			// Vignette effect
-			if vignette {
-				videoFilters = append(videoFilters, "vignette=PI/4")
-			}
-
-			// Grayscale
-			if grayscale {
-				videoFilters = append(videoFilters, "colorchannelmixer=.3:.4:.3:0:.3:.4:.3:0:.3:.4:.3")
-			}
-
-			// Sepia tone (apply after grayscale-like transform)
-			if sepia {
-				videoFilters = append(videoFilters, "colorchannelmixer=.393:.769:.189:0:.349:.686:.168:0:.272:.534:.131")
-			}
-
-			// Negative/Invert colors
-			if negative {
-				videoFilters = append(videoFilters, "negate")
-			}
-
-		case "split":
-			// Split is handled separately in processSplit method
-			// This case is here to avoid "unknown operation" errors
-			// The actual split logic extracts segments at specific times
-
-		case "thumbnail":
-			// Thumbnail generation - extract frame(s) as image(s)
-			// This is handled specially since output is image not video
-			timestamp := getStringParam(op.Params, "timestamp", "00:00:01")
-			width := getIntParam(op.Params, "width", 320)
-
-			// For thumbnail, we override the entire args since it's different
-			args = []string{"-ss", timestamp, "-i", opts.InputPath}
-			args = append(args, "-vframes", "1")
-			args = append(args, "-vf", fmt.Sprintf("scale=%d:-1", width))
-			args = append(args, "-q:v", "2") // High quality JPEG
-			args = append(args, opts.OutputPath)
-			return args // Return early for thumbnail
-
-		case "addAudio":
-			// Add/replace audio track
-			// This requires a second input file (audio)
-			audioPath := getStringParam(op.Params, "audioPath", "")
-			mode := getStringParam(op.Params, "mode", "mix") // mix, replace
-			volume := getFloatParam(op.Params, "volume", 1.0)
-
-			if audioPath != "" {
-				// We need to handle this differently with filter_complex
-				// This will be processed specially
-				if mode == "replace" {
-					// Remove original audio, use new audio
-					args = []string{"-i", opts.InputPath, "-i", audioPath}
-					args = append(args, "-map", "0:v", "-map", "1:a")
-					args = append(args, "-c:v", "copy")
-					if volume != 1.0 {
-						args = append(args, "-af", fmt.Sprintf("volume=%.2f", volume))
-					}
-					args = append(args, "-shortest")
-					args = append(args, opts.OutputPath)
-					return args
-				} else {
-					// Mix both audio tracks
-					args = []string{"-i", opts.InputPath, "-i", audioPath}
-					volumeFilter := ""
-					if volume != 1.0 {
-						volumeFilter = fmt.Sprintf(",volume=%.2f", volume)
-					}
-					args = append(args, "-filter_complex", fmt.Sprintf("[0:a][1:a]amix=inputs=2:duration=first%s[aout]", volumeFilter))
-					args = append(args, "-map", "0:v", "-map", "[aout]")
-					args = append(args, "-c:v", "copy")
-					args = append(args, opts.OutputPath)
-					return args
-				}
-			}
-
-		case "addText":
-			// Add text overlay (similar to watermark but with more options)
-			text := getStringParam(op.Params, "text", "")
-			if text != "" {
-				position := getStringParam(op.Params, "position", "center")
-				fontSize := getIntParam(op.Params, "fontSize", 48)
-				fontColor := getStringParam(op.Params, "fontColor", "white")
-				bgColor := getStringParam(op.Params, "bgColor", "")
-				bgOpacity := getFloatParam(op.Params, "bgOpacity", 0.5)
-				startTime := getFloatParam(op.Params, "startTime", 0)
-				endTime := getFloatParam(op.Params, "endTime", 0) // 0 means entire video
-				animation := getStringParam(op.Params, "animation", "none")
-
-				// Map position to coordinates
-				var x, y string
-				switch position {
-				case "topleft":
-					x, y = "20", "20"
-				case "topcenter":
-					x, y = "(w-tw)/2", "20"
-				case "topright":
-					x, y = "w-tw-20", "20"
-				case "centerleft":
-					x, y = "20", "(h-th)/2"
-				case "center":
-					x, y = "(w-tw)/2", "(h-th)/2"
-				case "centerright":
-					x, y = "w-tw-20", "(h-th)/2"
-				case "bottomleft":
-					x, y = "20", "h-th-20"
-				case "bottomcenter":
-					x, y = "(w-tw)/2", "h-th-20"
-				case "bottomright":
-					x, y = "w-tw-20", "h-th-20"
-				default:
-					x, y = "(w-tw)/2", "(h-th)/2"
-				}
-
-				// Apply animation to position
-				switch animation {
-				case "scrollLeft":
-					x = fmt.Sprintf("w-%d*t", fontSize*2) // Scroll from right to left
-				case "scrollRight":
-					x = fmt.Sprintf("-%d+%d*t", fontSize*5, fontSize*2) // Scroll from left to right
-				case "scrollUp":
-					y = fmt.Sprintf("h-%d*t", fontSize) // Scroll from bottom to top
-				case "scrollDown":
-					y = fmt.Sprintf("-%d+%d*t", fontSize*2, fontSize) // Scroll from top to bottom
-				case "fadeIn":
-					// Fade handled via alpha
-				}
-
-				// Escape special characters
-				escapedText := text
-				escapedText = strings.ReplaceAll(escapedText, "\\", "\\\\")
-				escapedText = strings.ReplaceAll(escapedText, "'", "'\\''")
-				escapedText = strings.ReplaceAll(escapedText, ":", "\\:")
-
-				// Build filter
-				filter := fmt.Sprintf(
-					"drawtext=text='%s':fontfile=/usr/share/fonts/ttf-dejavu/DejaVuSans-Bold.ttf:fontsize=%d:fontcolor=%s:x=%s:y=%s",
-					escapedText, fontSize, fontColor, x, y,
-				)
-
-				// Add background box if specified
-				if bgColor != "" {
-					filter += fmt.Sprintf(":box=1:boxcolor=%s@%.2f:boxborderw=10", bgColor, bgOpacity)
-				}
-
-				// Add time constraints
-				if startTime > 0 || endTime > 0 {
-					if endTime > 0 {
-						filter += fmt.Sprintf(":enable='between(t,%.2f,%.2f)'", startTime, endTime)
-					} else {
-						filter += fmt.Sprintf(":enable='gte(t,%.2f)'", startTime)
-					}
-				}
-
-				// Add fade animation
-				if animation == "fadeIn" {
-					filter += ":alpha='if(lt(t,1),t,1)'"
-				}
-
-				videoFilters = append(videoFilters, filter)
-			}
-
-		case "removeAudio":
-			// Strip audio track from video
-			args = append(args, "-an")
-
-		case "reverse":
-			// Reverse video playback
-			videoFilters = append(videoFilters, "reverse")
-			audioFilters = append(audioFilters, "areverse")
-
-		case "loop":
-			// Loop video N times
-			loopCount := getIntParam(op.Params, "count", 2)
-			args = append(args, "-stream_loop", fmt.Sprintf("%d", loopCount-1))
-
-		case "fade":
-			// Video fade in/out transitions
-			fadeIn := getFloatParam(op.Params, "fadeIn", 0)
-			fadeOut := getFloatParam(op.Params, "fadeOut", 0)
-			duration := getFloatParam(op.Params, "duration", 0) // Total video duration for fade out calculation
-			fadeColor := getStringParam(op.Params, "color", "black")
-
-			if fadeIn > 0 {
-				// Fade in from color at start
-				videoFilters = append(videoFilters, fmt.Sprintf("fade=t=in:st=0:d=%.2f:color=%s", fadeIn, fadeColor))
-				audioFilters = append(audioFilters, fmt.Sprintf("afade=t=in:st=0:d=%.2f", fadeIn))
-			}
-			if fadeOut > 0 && duration > 0 {
-				// Fade out to color at end
-				fadeOutStart := duration - fadeOut
-				if fadeOutStart < 0 {
-					fadeOutStart = 0
-				}
-				videoFilters = append(videoFilters, fmt.Sprintf("fade=t=out:st=%.2f:d=%.2f:color=%s", fadeOutStart, fadeOut, fadeColor))
-				audioFilters = append(audioFilters, fmt.Sprintf("afade=t=out:st=%.2f:d=%.2f", fadeOutStart, fadeOut))
-			}
-
-		case "frameRate":
-			// Change video frame rate
-			fps := getIntParam(op.Params, "fps", 30)
-			interpolation := getStringParam(op.Params, "interpolation", "duplicate")
-
-			switch interpolation {
-			case "blend":
-				// Blend frames for smoother motion (motion blur effect)
-				videoFilters = append(videoFilters, fmt.Sprintf("minterpolate=fps=%d:mi_mode=blend", fps))
-			case "motion":
-				// Motion interpolation for smoother slow-mo
-				videoFilters = append(videoFilters, fmt.Sprintf("minterpolate=fps=%d:mi_mode=mci:mc_mode=aobmc:me_mode=bidir:vsbmc=1", fps))
-			default:
-				// Simple frame duplication/dropping
-				videoFilters = append(videoFilters, fmt.Sprintf("fps=%d", fps))
-			}
-
-		case "normalize":
-			// Audio normalization
-			normType := getStringParam(op.Params, "type", "loudnorm")
-			targetLevel := getFloatParam(op.Params, "targetLevel", -14) // LUFS for loudnorm
-
-			switch normType {
-			case "loudnorm":
-				// EBU R128 loudness normalization (broadcast standard)
-				audioFilters = append(audioFilters, fmt.Sprintf("loudnorm=I=%.1f:TP=-1.5:LRA=11", targetLevel))
-			case "dynaudnorm":
-				// Dynamic audio normalization (adjusts volume in real-time)
-				audioFilters = append(audioFilters, "dynaudnorm=p=0.9:s=5")
-			case "peak":
-				// Peak normalization to 0dB
-				audioFilters = append(audioFilters, "acompressor=threshold=0.5:ratio=2:attack=5:release=50,volume=2dB")
-			}
-
-		case "noiseReduction":
-			// Noise reduction for video (hqdn3d) and/or audio (afftdn)
-			strength := getStringParam(op.Params, "strength", "medium") // low, medium, high
-			applyTo := getStringParam(op.Params, "applyTo", "both")     // both, video, audio
-
-			var afftdnNr float64
-			switch strength {
-			case "low":
-				afftdnNr = 8
-			case "high":
-				afftdnNr = 20
-			default:
-				afftdnNr = 12
-			}
-			if applyTo == "both" || applyTo == "audio" {
-				audioFilters = append(audioFilters, fmt.Sprintf("afftdn=nr=%.0f:nf=-40", afftdnNr))
-			}
-
-			var hqdn3dParams string
-			switch strength {
-			case "low":
-				hqdn3dParams = "2:2:3:2"
-			case "high":
-				hqdn3dParams = "8:6:12:9"
-			default:
-				hqdn3dParams = "4:4:6:4"
-			}
-			if applyTo == "both" || applyTo == "video" {
-				videoFilters = append(videoFilters, "hqdn3d="+hqdn3dParams)
-			}
-
-		case "convertAudioFormat":
-			args = append(args, "-vn")
-			format := getStringParam(op.Params, "format", "mp3")
-			bitrate := getIntParam(op.Params, "bitrate", 192000)
-
-			switch format {
-			case "mp3":
-				args = append(args, "-acodec", "libmp3lame")
-				args = append(args, "-b:a", fmt.Sprintf("%dk", bitrate/1000))
-			case "aac":
-				args = append(args, "-acodec", "aac")
-				args = append(args, "-b:a", fmt.Sprintf("%dk", bitrate/1000))
-			case "wav":
-				args = append(args, "-acodec", "pcm_s16le")
-			case "flac":
-				args = append(args, "-acodec", "flac")
-				args = append(args, "-compression_level", "5")
-			case "ogg":
-				args = append(args, "-acodec", "libvorbis")
-				args = append(args, "-b:a", fmt.Sprintf("%dk", bitrate/1000))
-			default:
-				args = append(args, "-acodec", "libmp3lame")
-				args = append(args, "-b:a", "192k")
-			}
-		}
-	}
-
-	// Apply video filters
-	if len(videoFilters) > 0 {
-		args = append(args, "-vf", strings.Join(videoFilters, ","))
+	return encoderSettings{
+		HWAccel:    p.useHWAccel(opts),
+		Preset:     preset,
+		Threads:    threads,
+		AV1Encoder: p.knownCapabilities().AV1Encoder(p.av1Encoder),
 	}
-
-	// Apply audio filters
-	if len(audioFilters) > 0 {
-		args = append(args, "-af", strings.Join(audioFilters, ","))
-	}
-
-	// Check if we need to add default codec settings for operations that don't set them
-	// This prevents FFmpeg from using slow default settings
-	hasVideoCodec := false
-	for _, arg := range args {
-		if arg == "-c:v" || strings.HasPrefix(arg, "-codec:v") {
-			hasVideoCodec = true
-			break
-		}
-	}
-
-	// If no video codec was specified, add optimized default
-	if !hasVideoCodec && len(videoFilters) > 0 {
-		if p.useHWAccel(&opts) {
-			args = append(args, "-c:v", "h264_videotoolbox", "-c:a", "aac")
-		} else {
-			preset := "medium"
-			if p.preferFastPresets {
-				preset = "faster"
-			}
-			args = append(args, "-c:v", "libx264", "-preset", preset, "-c:a", "aac")
-		}
-	}
-
-	// Add -movflags +faststart for MP4 outputs (enables progressive download/playback)
-	if strings.HasSuffix(strings.ToLower(opts.OutputPath), ".mp4") {
-		args = append(args, "-movflags", "+faststart")
-	}
-
-	args = append(args, opts.OutputPath)
-	return args
 }
 
-func (p *Processor) parseProgress(stderr interface{ Read([]byte) (int, error) }, onProgress func(int, string)) {
-	if onProgress == nil {
-		return
-	}
-
-	scanner := bufio.NewScanner(stderr)
-	progressRegex := regexp.MustCompile(`time=(\d+):(\d+):(\d+)\.(\d+)`)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		matches := progressRegex.FindStringSubmatch(line)
-		if len(matches) > 0 {
-			// Parse time and calculate progress
-			// This is a simplified version; real implementation would need total duration
-			hours, _ := strconv.Atoi(matches[1])
-			minutes, _ := strconv.Atoi(matches[2])
-			seconds, _ := strconv.Atoi(matches[3])
-			totalSeconds := hours*3600 + minutes*60 + seconds
-			_ = totalSeconds // Use for progress calculation
-		}
-	}
+// parseProgress consumes FFmpeg -progress output and reports percent, speed and ETA.
+// The reader is always drained, even when nobody is listening for progress.
+func (p *Processor) parseProgress(stdout io.Reader, total float64, operation string, onProgress func(ProgressUpdate)) {
+	newProgressTracker(total, operation, onProgress).consume(stdout)
 }
 
 // Probe extracts metadata using ffprobe
@@ -909,34 +352,13 @@ func (p *Processor) Probe(ctx context.Context, inputPath string) (*MediaInfo, er
 		inputPath,
 	}
 
-	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+	cmd := exec.CommandContext(ctx, p.ffprobePath, args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("ffprobe failed: %w", err)
 	}
 
-	// Parse JSON output
-	// For now, return a placeholder
-	_ = output
-
-	return &MediaInfo{
-		Format: "mp4",
-	}, nil
-}
-
-// GenerateThumbnail creates a thumbnail from a video
-func (p *Processor) GenerateThumbnail(ctx context.Context, inputPath, outputPath string, timestamp float64) error {
-	args := []string{
-		"-y",
-		"-ss", fmt.Sprintf("%.2f", timestamp),
-		"-i", inputPath,
-		"-vframes", "1",
-		"-vf", "scale=320:-1",
-		outputPath,
-	}
-
-	cmd := exec.CommandContext(ctx, p.ffmpegPath, args...)
-	return cmd.Run()
+	return parseProbeOutput(output)
 }
 
 // Helper functions
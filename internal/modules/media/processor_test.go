@@ -1,6 +1,7 @@
 package media
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -203,3 +204,64 @@ func TestProcessorConfiguration(t *testing.T) {
 		assert.True(t, p.useHWAccel(opts), "Pro tier should enable hardware acceleration")
 	})
 }
+
+func TestParseProbeOutput(t *testing.T) {
+	output := []byte(`{
+		"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "12.500000", "size": "1048576", "bit_rate": "671088"},
+		"streams": [
+			{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080,
+			 "avg_frame_rate": "30000/1001", "bit_rate": "600000",
+			 "side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]},
+			{"index": 1, "codec_type": "audio", "codec_name": "aac", "channels": 2, "sample_rate": "48000",
+			 "tags": {"language": "eng"}},
+			{"index": 2, "codec_type": "video", "codec_name": "mjpeg", "width": 300, "height": 300,
+			 "disposition": {"attached_pic": 1}}
+		]
+	}`)
+
+	info, err := parseProbeOutput(output)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 12.5, info.Duration)
+	assert.Equal(t, int64(1048576), info.Size)
+	assert.Equal(t, 671088, info.BitRate)
+	assert.Equal(t, "h264", info.VideoCodec)
+	assert.Equal(t, "aac", info.AudioCodec)
+	assert.Equal(t, 1920, info.Width)
+	assert.Equal(t, 1080, info.Height)
+	assert.InDelta(t, 29.97, info.FrameRate, 0.01)
+	assert.Equal(t, 90, info.Rotation)
+	assert.Len(t, info.Streams, 3)
+	assert.Equal(t, "eng", info.Streams[1].Language)
+	assert.Equal(t, 48000, info.Streams[1].SampleRate)
+	assert.True(t, info.HasDecodableStreams())
+
+	t.Run("legacy rotate tag", func(t *testing.T) {
+		info, err := parseProbeOutput([]byte(`{"format": {}, "streams": [
+			{"index": 0, "codec_type": "video", "codec_name": "h264", "tags": {"rotate": "270"}}
+		]}`))
+		assert.NoError(t, err)
+		assert.Equal(t, 270, info.Rotation)
+	})
+
+	t.Run("no decodable streams", func(t *testing.T) {
+		info, err := parseProbeOutput([]byte(`{"format": {}, "streams": [
+			{"index": 0, "codec_type": "data", "codec_name": "bin_data"}
+		]}`))
+		assert.NoError(t, err)
+		assert.False(t, info.HasDecodableStreams())
+	})
+}
+
+func TestProcessRejectsStandaloneInChain(t *testing.T) {
+	p := NewProcessor(nil, "", zap.NewNop())
+	p.caps = testCapabilities()
+
+	_, err := p.Process(context.Background(), ProcessOptions{
+		InputPath:  "in.mp4",
+		OutputPath: "out.mp4",
+		Operations: []Operation{op("trim", map[string]interface{}{"startTime": 1.0}), op("stabilize", nil)},
+	})
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+	assert.Contains(t, err.Error(), "'stabilize' must be the only operation")
+}
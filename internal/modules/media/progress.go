@@ -0,0 +1,226 @@
+package media
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProgressUpdate reports the state of a running FFmpeg command
+type ProgressUpdate struct {
+	Percent   int
+	Operation string
+	Speed     float64 // Encoding speed relative to realtime (2.0 = twice as fast as playback)
+	ETA       int     // Estimated seconds remaining (0 = unknown)
+}
+
+// progressReportInterval bounds how often an unchanged percentage is re-reported
+const progressReportInterval = 2 * time.Second
+
+// progressTracker turns FFmpeg "-progress" key=value blocks into ProgressUpdates.
+// FFmpeg writes one block roughly every 500ms, terminated by a "progress=continue"
+// or "progress=end" line.
+type progressTracker struct {
+	total      float64 // Expected output duration in seconds (0 = unknown)
+	operation  string
+	onProgress func(ProgressUpdate)
+
+	startedAt   time.Time
+	lastReport  time.Time
+	lastPercent int
+	outTime     float64
+	speed       float64
+}
+
+func newProgressTracker(total float64, operation string, onProgress func(ProgressUpdate)) *progressTracker {
+	return &progressTracker{
+		total:       total,
+		operation:   operation,
+		onProgress:  onProgress,
+		startedAt:   time.Now(),
+		lastPercent: -1,
+	}
+}
+
+// handleLine consumes a single line of -progress output
+func (t *progressTracker) handleLine(line string) {
+	key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok {
+		return
+	}
+
+	switch key {
+	case "out_time_us", "out_time_ms":
+		// Both keys are in microseconds (out_time_ms is misnamed upstream)
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+			t.outTime = float64(us) / 1e6
+		}
+	case "out_time":
+		if secs, err := parseTimestamp(value); err == nil {
+			t.outTime = secs
+		}
+	case "speed":
+		// "1.53x", or "N/A" before the first frame is encoded
+		if s, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "x"), 64); err == nil {
+			t.speed = s
+		}
+	case "progress":
+		t.report(value == "end")
+	}
+}
+
+// report emits an update at the end of a progress block
+func (t *progressTracker) report(finished bool) {
+	if t.onProgress == nil {
+		return
+	}
+
+	update := ProgressUpdate{
+		Operation: t.operation,
+		Speed:     math.Round(t.speed*100) / 100,
+	}
+
+	switch {
+	case finished:
+		update.Percent = 100
+	case t.total > 0:
+		update.Percent = int(t.outTime / t.total * 100)
+		if update.Percent > 99 {
+			update.Percent = 99 // 100% is reserved for progress=end
+		}
+		if update.Percent < 0 {
+			update.Percent = 0
+		}
+		update.ETA = t.eta()
+	default:
+		// Unknown duration: nothing meaningful to report until the end
+		return
+	}
+
+	now := time.Now()
+	if !finished && update.Percent == t.lastPercent && now.Sub(t.lastReport) < progressReportInterval {
+		return
+	}
+	t.lastPercent = update.Percent
+	t.lastReport = now
+
+	t.onProgress(update)
+}
+
+// eta estimates remaining seconds from FFmpeg's speed, falling back to wall-clock rate
+func (t *progressTracker) eta() int {
+	remaining := t.total - t.outTime
+	if remaining <= 0 {
+		return 0
+	}
+	if t.speed > 0 {
+		return int(math.Ceil(remaining / t.speed))
+	}
+	elapsed := time.Since(t.startedAt).Seconds()
+	if t.outTime > 0 && elapsed > 0 {
+		return int(math.Ceil(remaining / (t.outTime / elapsed)))
+	}
+	return 0
+}
+
+// consume reads -progress output until EOF
+func (t *progressTracker) consume(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		t.handleLine(scanner.Text())
+	}
+}
+
+// expectedOutputDuration estimates the output timeline length in seconds for an
+// operation chain applied to an input of the given duration. Returns 0 when unknown.
+func expectedOutputDuration(ops []Operation, inputDuration float64) float64 {
+	if inputDuration <= 0 {
+		return 0
+	}
+
+	duration := inputDuration
+	for _, op := range ops {
+		switch op.Type {
+		case "trim":
+			start, end := trimBounds(op.Params)
+			if end <= 0 || end > duration {
+				end = duration
+			}
+			if start >= 0 && start < end {
+				duration = end - start
+			}
+
+		case "changeSpeed":
+			multiplier := getFloatParam(op.Params, "multiplier", 1.0)
+			if multiplier > 0 {
+				duration = duration / multiplier
+			}
+
+		case "loop":
+			count := getIntParam(op.Params, "count", 2)
+			if count > 1 {
+				duration = duration * float64(count)
+			}
+		}
+	}
+
+	return duration
+}
+
+// trimBounds returns the trim start/end in seconds (0 = not set)
+func trimBounds(params map[string]interface{}) (start, end float64) {
+	if s, ok := params["startTime"]; ok {
+		start, _ = parseTimeParam(s)
+	}
+	if e, ok := params["endTime"]; ok {
+		end, _ = parseTimeParam(e)
+	}
+	return start, end
+}
+
+// parseTimeParam accepts a number of seconds or an FFmpeg-style timestamp string
+func parseTimeParam(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case int:
+		return float64(val), nil
+	case string:
+		return parseTimestamp(val)
+	}
+	return 0, fmt.Errorf("unsupported time value: %v", v)
+}
+
+// parseTimestamp parses "HH:MM:SS.mmm", "MM:SS" or plain seconds into seconds
+func parseTimestamp(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty timestamp")
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp: %s", s)
+	}
+
+	var total float64
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp: %s", s)
+		}
+		total = total*60 + v
+	}
+
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
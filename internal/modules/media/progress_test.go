@@ -0,0 +1,122 @@
+package media
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"00:00:10.500000", 10.5},
+		{"01:02:03", 3723},
+		{"02:30", 150},
+		{"42.25", 42.25},
+		{"-00:00:01.000000", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseTimestamp(tt.input)
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.expected, got, 0.0001)
+		})
+	}
+
+	t.Run("rejects invalid input", func(t *testing.T) {
+		_, err := parseTimestamp("abc")
+		assert.Error(t, err)
+		_, err = parseTimestamp("")
+		assert.Error(t, err)
+	})
+}
+
+func TestExpectedOutputDuration(t *testing.T) {
+	t.Run("unknown input duration", func(t *testing.T) {
+		assert.Equal(t, 0.0, expectedOutputDuration(nil, 0))
+	})
+
+	t.Run("no timeline operations", func(t *testing.T) {
+		ops := []Operation{{Type: "resize", Params: map[string]interface{}{"width": 640.0}}}
+		assert.Equal(t, 60.0, expectedOutputDuration(ops, 60))
+	})
+
+	t.Run("trim then speed up", func(t *testing.T) {
+		ops := []Operation{
+			{Type: "trim", Params: map[string]interface{}{"startTime": "00:00:10", "endTime": 40.0}},
+			{Type: "changeSpeed", Params: map[string]interface{}{"multiplier": 2.0}},
+		}
+		assert.InDelta(t, 15.0, expectedOutputDuration(ops, 60), 0.0001)
+	})
+
+	t.Run("trim end beyond input is clamped", func(t *testing.T) {
+		ops := []Operation{{Type: "trim", Params: map[string]interface{}{"startTime": 50.0, "endTime": 500.0}}}
+		assert.InDelta(t, 10.0, expectedOutputDuration(ops, 60), 0.0001)
+	})
+
+	t.Run("loop multiplies", func(t *testing.T) {
+		ops := []Operation{{Type: "loop", Params: map[string]interface{}{"count": 3.0}}}
+		assert.InDelta(t, 30.0, expectedOutputDuration(ops, 10), 0.0001)
+	})
+}
+
+func TestProgressTracker(t *testing.T) {
+	t.Run("reports percent, speed and eta", func(t *testing.T) {
+		var updates []ProgressUpdate
+		tracker := newProgressTracker(100, "Processing: resize", func(u ProgressUpdate) {
+			updates = append(updates, u)
+		})
+
+		tracker.consume(strings.NewReader(strings.Join([]string{
+			"frame=100",
+			"out_time_us=25000000",
+			"speed=2.5x",
+			"progress=continue",
+			"out_time_us=50000000",
+			"speed=2.5x",
+			"progress=continue",
+			"out_time_us=100000000",
+			"speed=2.5x",
+			"progress=end",
+		}, "\n")))
+
+		assert.Len(t, updates, 3)
+		assert.Equal(t, 25, updates[0].Percent)
+		assert.Equal(t, 2.5, updates[0].Speed)
+		assert.Equal(t, 30, updates[0].ETA)
+		assert.Equal(t, "Processing: resize", updates[0].Operation)
+		assert.Equal(t, 50, updates[1].Percent)
+		assert.Equal(t, 20, updates[1].ETA)
+		assert.Equal(t, 100, updates[2].Percent)
+		assert.Equal(t, 0, updates[2].ETA)
+	})
+
+	t.Run("caps at 99 until end", func(t *testing.T) {
+		var last ProgressUpdate
+		tracker := newProgressTracker(10, "Merging", func(u ProgressUpdate) { last = u })
+
+		tracker.consume(strings.NewReader("out_time=00:00:12.000000\nspeed=N/A\nprogress=continue\n"))
+		assert.Equal(t, 99, last.Percent)
+	})
+
+	t.Run("suppresses repeated percent", func(t *testing.T) {
+		count := 0
+		tracker := newProgressTracker(100, "Processing", func(ProgressUpdate) { count++ })
+
+		tracker.consume(strings.NewReader("out_time_us=1000000\nprogress=continue\nout_time_us=1200000\nprogress=continue\n"))
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("unknown duration only reports completion", func(t *testing.T) {
+		var updates []ProgressUpdate
+		tracker := newProgressTracker(0, "Processing", func(u ProgressUpdate) { updates = append(updates, u) })
+
+		tracker.consume(strings.NewReader("out_time_us=1000000\nprogress=continue\nprogress=end\n"))
+		assert.Len(t, updates, 1)
+		assert.Equal(t, 100, updates[0].Percent)
+	})
+}
@@ -0,0 +1,432 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidOperation is returned for unknown operations or invalid parameters
+var ErrInvalidOperation = errors.New("invalid operation")
+
+// ParamType is the JSON type an operation parameter accepts
+type ParamType string
+
+const (
+	ParamInteger ParamType = "integer"
+	ParamNumber  ParamType = "number"
+	ParamString  ParamType = "string"
+	ParamBoolean ParamType = "boolean"
+	ParamTime    ParamType = "time"  // Seconds or an "HH:MM:SS.mmm" timestamp
+	ParamColor   ParamType = "color" // FFmpeg color name or RRGGBB[AA] hex value
+	ParamList    ParamType = "list"  // Array of objects described by Fields
+)
+
+// ParamSpec describes one operation parameter
+type ParamSpec struct {
+	Name        string        `json:"name"`
+	Type        ParamType     `json:"type"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Default     interface{}   `json:"default,omitempty"`
+	Min         *float64      `json:"min,omitempty"`
+	Max         *float64      `json:"max,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Fields      []ParamSpec   `json:"fields,omitempty"` // Item schema for list parameters; Min/Max bound the length
+}
+
+// OperationSpec declares an operation: its parameters, the media it applies
+// to and how it contributes to the FFmpeg plan
+type OperationSpec struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	MediaTypes  []string    `json:"mediaTypes"`
+	Params      []ParamSpec `json:"params"`
+	MinInputs   int         `json:"minInputs,omitempty"` // Operations over several inputs run on their own (e.g. merge)
+
+	// Apply folds the operation into the plan. Nil for operations that are
+	// executed separately rather than combined into a single-output plan.
+	Apply func(b *PlanBuilder, p Params) error `json:"-"`
+
+	// Run executes an operation that can't be combined with others, such as
+	// merging several inputs or packaging a stream into many files
+	Run func(ctx context.Context, proc *Processor, opts ProcessOptions, p Params) (*ProcessResult, error) `json:"-"`
+
+	// Requires checks that the FFmpeg build has what Run uses; the plans of
+	// Apply operations are checked as a whole instead
+	Requires func(p Params, caps *Capabilities) error `json:"-"`
+}
+
+// AppliesTo reports whether the operation is meant for a media type
+func (s *OperationSpec) AppliesTo(mediaType string) bool {
+	for _, t := range s.MediaTypes {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// Params are an operation's parameters after validation, with defaults filled in
+type Params map[string]interface{}
+
+// Int returns an integer parameter (0 if absent)
+func (p Params) Int(name string) int { return getIntParam(p, name, 0) }
+
+// Float returns a numeric parameter (0 if absent)
+func (p Params) Float(name string) float64 { return getFloatParam(p, name, 0) }
+
+// String returns a string parameter ("" if absent)
+func (p Params) String(name string) string { return getStringParam(p, name, "") }
+
+// Bool returns a boolean parameter (false if absent)
+func (p Params) Bool(name string) bool { return getBoolParam(p, name, false) }
+
+// List returns a list parameter's validated items (nil if absent)
+func (p Params) List(name string) []Params {
+	items, _ := p[name].([]Params)
+	return items
+}
+
+// Has reports whether a parameter was given or has a default
+func (p Params) Has(name string) bool {
+	_, ok := p[name]
+	return ok
+}
+
+// Registry holds the operations the processor knows how to run
+type Registry struct {
+	mu    sync.RWMutex
+	specs map[string]*OperationSpec
+}
+
+// NewRegistry creates an empty operation registry
+func NewRegistry() *Registry {
+	return &Registry{specs: make(map[string]*OperationSpec)}
+}
+
+// Register adds an operation. Types must be unique.
+func (r *Registry) Register(spec OperationSpec) error {
+	if spec.Type == "" {
+		return errors.New("operation type is required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.specs[spec.Type]; exists {
+		return fmt.Errorf("operation %q is already registered", spec.Type)
+	}
+	r.specs[spec.Type] = &spec
+	return nil
+}
+
+// Lookup returns the spec for an operation type
+func (r *Registry) Lookup(opType string) (*OperationSpec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	spec, ok := r.specs[opType]
+	return spec, ok
+}
+
+// List returns all registered operations sorted by type
+func (r *Registry) List() []OperationSpec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	specs := make([]OperationSpec, 0, len(r.specs))
+	for _, spec := range r.specs {
+		specs = append(specs, *spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Type < specs[j].Type })
+	return specs
+}
+
+// Validate checks an operation against its spec and returns its parameters
+// with defaults applied, or every problem found
+func (r *Registry) Validate(op Operation) (Params, []string) {
+	spec, ok := r.Lookup(op.Type)
+	if !ok {
+		return nil, []string{fmt.Sprintf("Unknown operation: %s", op.Type)}
+	}
+	return spec.validate(op.Params)
+}
+
+// resolve looks up and validates an operation, returning a single error
+func (r *Registry) resolve(op Operation) (*OperationSpec, Params, error) {
+	spec, ok := r.Lookup(op.Type)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidOperation, op.Type)
+	}
+	params, problems := spec.validate(op.Params)
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidOperation, strings.Join(problems, "; "))
+	}
+	return spec, params, nil
+}
+
+func (s *OperationSpec) validate(raw map[string]interface{}) (Params, []string) {
+	return validateFields(s.Type, s.Params, raw)
+}
+
+// validateFields checks raw values against parameter specs; prefix names the
+// operation (and list item) in problem messages
+func validateFields(prefix string, fields []ParamSpec, raw map[string]interface{}) (Params, []string) {
+	params := Params{}
+	var problems []string
+
+	for _, ps := range fields {
+		value, ok := raw[ps.Name]
+		if !ok || value == nil || (ps.Required && value == "") {
+			if ps.Required {
+				problems = append(problems, fmt.Sprintf("%s: %s is required", prefix, ps.Name))
+			} else if ps.Default != nil {
+				params[ps.Name] = ps.Default
+			}
+			continue
+		}
+
+		if ps.Type == ParamList {
+			items, itemProblems := ps.checkList(prefix, value)
+			if len(itemProblems) > 0 {
+				problems = append(problems, itemProblems...)
+				continue
+			}
+			params[ps.Name] = items
+			continue
+		}
+
+		normalized, err := ps.check(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s %v", prefix, ps.Name, err))
+			continue
+		}
+		params[ps.Name] = normalized
+	}
+
+	// A misspelled parameter would otherwise be silently replaced by its default
+	var unknown []string
+	for name := range raw {
+		if !hasField(fields, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, fmt.Sprintf("%s: unknown parameter %s", prefix, name))
+	}
+
+	return params, problems
+}
+
+func hasField(fields []ParamSpec, name string) bool {
+	for _, ps := range fields {
+		if ps.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkList validates every item of a list parameter against its Fields
+func (ps *ParamSpec) checkList(prefix string, value interface{}) ([]Params, []string) {
+	raw, ok := value.([]interface{})
+	if !ok {
+		return nil, []string{fmt.Sprintf("%s: %s must be a list", prefix, ps.Name)}
+	}
+	if ps.Min != nil && float64(len(raw)) < *ps.Min {
+		return nil, []string{fmt.Sprintf("%s: %s needs at least %g items", prefix, ps.Name, *ps.Min)}
+	}
+	if ps.Max != nil && float64(len(raw)) > *ps.Max {
+		return nil, []string{fmt.Sprintf("%s: %s takes at most %g items", prefix, ps.Name, *ps.Max)}
+	}
+
+	items := make([]Params, 0, len(raw))
+	var problems []string
+	for i, item := range raw {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: %s[%d] must be an object", prefix, ps.Name, i))
+			continue
+		}
+		params, itemProblems := validateFields(fmt.Sprintf("%s: %s[%d]", prefix, ps.Name, i), ps.Fields, fields)
+		problems = append(problems, itemProblems...)
+		items = append(items, params)
+	}
+	return items, problems
+}
+
+// check validates a value and converts it to the parameter's canonical Go type
+func (ps *ParamSpec) check(value interface{}) (interface{}, error) {
+	var normalized interface{}
+	var number float64
+	isNumber := false
+
+	switch ps.Type {
+	case ParamInteger, ParamNumber:
+		switch v := value.(type) {
+		case float64:
+			number = v
+		case int:
+			number = float64(v)
+		case string:
+			// Form-style clients send numbers as strings
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be a number")
+			}
+			number = f
+		default:
+			return nil, fmt.Errorf("must be a number")
+		}
+		if ps.Type == ParamInteger && number != float64(int64(number)) {
+			return nil, fmt.Errorf("must be a whole number")
+		}
+		normalized, isNumber = number, true
+
+	case ParamBoolean:
+		switch v := value.(type) {
+		case bool:
+			normalized = v
+		case string:
+			if v != "true" && v != "false" && v != "1" && v != "0" {
+				return nil, fmt.Errorf("must be true or false")
+			}
+			normalized = v == "true" || v == "1"
+		default:
+			return nil, fmt.Errorf("must be true or false")
+		}
+
+	case ParamString:
+		v, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		normalized = v
+
+	case ParamColor:
+		v, ok := value.(string)
+		if !ok || !colorPattern.MatchString(v) {
+			return nil, fmt.Errorf("must be a color name or RRGGBB hex value")
+		}
+		normalized = v
+
+	case ParamTime:
+		seconds, err := parseTimeParam(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be seconds or a HH:MM:SS timestamp")
+		}
+		// Keep the original form; FFmpeg accepts both
+		normalized, number, isNumber = value, seconds, true
+	}
+
+	if isNumber {
+		if ps.Min != nil && number < *ps.Min {
+			return nil, fmt.Errorf("must be at least %g", *ps.Min)
+		}
+		if ps.Max != nil && number > *ps.Max {
+			return nil, fmt.Errorf("must be at most %g", *ps.Max)
+		}
+	}
+
+	if len(ps.Enum) > 0 {
+		allowed := false
+		for _, e := range ps.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(normalized) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			options := make([]string, len(ps.Enum))
+			for i, e := range ps.Enum {
+				options[i] = fmt.Sprint(e)
+			}
+			return nil, fmt.Errorf("must be one of %s", strings.Join(options, ", "))
+		}
+	}
+
+	return normalized, nil
+}
+
+// colorPattern matches the colors FFmpeg filters accept that can't break out
+// of a filter option: "white", "#FF0000", "0xFF000080"
+var colorPattern = regexp.MustCompile(`^([A-Za-z]+|(#|0x)?[0-9A-Fa-f]{6}([0-9A-Fa-f]{2})?)$`)
+
+// registry holds the built-in operations and any registered by other packages
+var registry = builtinRegistry()
+
+// RegisterOperation adds an operation to the registry used by the processor,
+// validation and the operations endpoint
+func RegisterOperation(spec OperationSpec) error {
+	return registry.Register(spec)
+}
+
+// Operations returns every registered operation
+func Operations() []OperationSpec {
+	return registry.List()
+}
+
+// LookupOperation returns the spec for an operation type
+func LookupOperation(opType string) (*OperationSpec, bool) {
+	return registry.Lookup(opType)
+}
+
+// Param helpers keep the built-in operation table readable
+func numberRange(min, max float64) (*float64, *float64) { return &min, &max }
+
+func intParam(name string, def, min, max float64, desc string) ParamSpec {
+	lo, hi := numberRange(min, max)
+	return ParamSpec{Name: name, Type: ParamInteger, Description: desc, Default: def, Min: lo, Max: hi}
+}
+
+func numberParam(name string, def, min, max float64, desc string) ParamSpec {
+	lo, hi := numberRange(min, max)
+	return ParamSpec{Name: name, Type: ParamNumber, Description: desc, Default: def, Min: lo, Max: hi}
+}
+
+func boolParam(name string, def bool, desc string) ParamSpec {
+	return ParamSpec{Name: name, Type: ParamBoolean, Description: desc, Default: def}
+}
+
+func stringParam(name, def, desc string) ParamSpec {
+	ps := ParamSpec{Name: name, Type: ParamString, Description: desc}
+	if def != "" {
+		ps.Default = def
+	}
+	return ps
+}
+
+func colorParam(name, def, desc string) ParamSpec {
+	ps := stringParam(name, def, desc)
+	ps.Type = ParamColor
+	return ps
+}
+
+func enumParam(name, def, desc string, values ...string) ParamSpec {
+	enum := make([]interface{}, len(values))
+	for i, v := range values {
+		enum[i] = v
+	}
+	ps := ParamSpec{Name: name, Type: ParamString, Description: desc, Enum: enum}
+	if def != "" {
+		ps.Default = def
+	}
+	return ps
+}
+
+// optional drops a parameter's default, so operations can tell whether it was given
+func optional(ps ParamSpec) ParamSpec {
+	ps.Default = nil
+	return ps
+}
+
+func required(ps ParamSpec) ParamSpec {
+	ps.Required = true
+	ps.Default = nil
+	return ps
+}
@@ -0,0 +1,133 @@
+package media
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryValidate(t *testing.T) {
+	t.Run("defaults are filled in", func(t *testing.T) {
+		params, problems := registry.Validate(op("createGif", nil))
+		require.Empty(t, problems)
+		assert.Equal(t, 10, params.Int("fps"))
+		assert.Equal(t, 480, params.Int("width"))
+	})
+
+	t.Run("colors", func(t *testing.T) {
+		for _, color := range []string{"white", "#FF8800", "0xff880080", "FF8800"} {
+			_, problems := registry.Validate(op("addText", map[string]interface{}{"text": "hi", "fontColor": color}))
+			assert.Empty(t, problems, color)
+		}
+	})
+
+	t.Run("numeric strings are accepted", func(t *testing.T) {
+		params, problems := registry.Validate(op("compress", map[string]interface{}{"quality": "55"}))
+		require.Empty(t, problems)
+		assert.Equal(t, 55, params.Int("quality"))
+	})
+
+	tests := []struct {
+		name string
+		op   Operation
+		want string
+	}{
+		{"unknown operation", op("explode", nil), "Unknown operation: explode"},
+		{"out of range", op("compress", map[string]interface{}{"quality": 150.0}), "quality must be at most 100"},
+		{"not a whole number", op("loop", map[string]interface{}{"count": 2.5}), "count must be a whole number"},
+		{"wrong type", op("resize", map[string]interface{}{"maintainAspect": "maybe"}), "maintainAspect must be true or false"},
+		{"not in enum", op("rotate", map[string]interface{}{"degrees": 45.0}), "degrees must be one of 0, 90, 180, 270"},
+		{"missing required", op("crop", map[string]interface{}{"width": 100.0}), "crop: height is required"},
+		{"empty required string", op("addText", map[string]interface{}{"text": ""}), "addText: text is required"},
+		{"bad timestamp", op("trim", map[string]interface{}{"startTime": "soon"}), "startTime must be seconds"},
+		{"unknown parameter", op("resize", map[string]interface{}{"widht": 640.0}), "resize: unknown parameter widht"},
+		{"color injecting filter options", op("addText", map[string]interface{}{"text": "hi", "fontColor": "white:x=0,drawbox"}), "fontColor must be a color"},
+		{"fade color injecting a filter", op("fade", map[string]interface{}{"color": "black;[0]null"}), "color must be a color"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, problems := registry.Validate(tt.op)
+			require.NotEmpty(t, problems)
+			assert.Contains(t, problems[0], tt.want)
+		})
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Register(OperationSpec{Type: "sepia", MediaTypes: videoOnly}))
+	assert.Error(t, r.Register(OperationSpec{Type: "sepia"}))
+	assert.Error(t, r.Register(OperationSpec{}))
+
+	spec, ok := r.Lookup("sepia")
+	require.True(t, ok)
+	assert.True(t, spec.AppliesTo(MediaVideo))
+	assert.False(t, spec.AppliesTo(MediaAudio))
+}
+
+func TestValidateOperations(t *testing.T) {
+	m := &Module{}
+	ctx := context.Background()
+
+	t.Run("operations the processor runs are accepted", func(t *testing.T) {
+		for _, opType := range []string{"filters", "reverse", "loop", "fade", "frameRate", "normalize", "noiseReduction"} {
+			result := m.ValidateOperations(ctx, []Operation{op(opType, nil)}, "video", "")
+			assert.True(t, result.Valid, "%s: %v", opType, result.Errors)
+		}
+		result := m.ValidateOperations(ctx, []Operation{op("addText", map[string]interface{}{"text": "hi"})}, "video", "")
+		assert.True(t, result.Valid, result.Errors)
+	})
+
+	t.Run("unknown operations are rejected", func(t *testing.T) {
+		result := m.ValidateOperations(ctx, []Operation{op("sharpenAudio", nil)}, "", "")
+		assert.False(t, result.Valid)
+	})
+
+	t.Run("removeSilence runs on its own", func(t *testing.T) {
+		assert.True(t, m.ValidateOperations(ctx, []Operation{op("removeSilence", nil)}, "audio", "").Valid)
+
+		result := m.ValidateOperations(ctx, []Operation{op("removeSilence", nil), op("reverse", nil)}, "audio", "")
+		assert.False(t, result.Valid)
+	})
+
+	t.Run("media type mismatch is a warning", func(t *testing.T) {
+		result := m.ValidateOperations(ctx, []Operation{op("resize", map[string]interface{}{"width": 640.0})}, "audio", "")
+		assert.True(t, result.Valid)
+		assert.Equal(t, []string{"Operation 'resize' is intended for video or image"}, result.Warnings)
+	})
+
+	t.Run("merge must run on its own", func(t *testing.T) {
+		assert.True(t, m.ValidateOperations(ctx, []Operation{op("merge", nil)}, "video", "").Valid)
+
+		result := m.ValidateOperations(ctx, []Operation{op("merge", nil), op("reverse", nil)}, "video", "")
+		assert.False(t, result.Valid)
+		assert.Contains(t, result.Errors[0], "only operation")
+	})
+
+	t.Run("built-in presets are valid", func(t *testing.T) {
+		m := NewModule(nil, nil, nil, nil)
+		for _, preset := range m.GetPresets() {
+			result := m.ValidateOperations(ctx, preset.Operations, preset.Type, "")
+			assert.True(t, result.Valid, "%s: %v", preset.ID, result.Errors)
+			assert.Empty(t, result.Warnings, preset.ID)
+		}
+	})
+}
+
+func TestGetOperations(t *testing.T) {
+	m := &Module{}
+
+	all := m.GetOperations("")
+	require.NotEmpty(t, all)
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].Type, all[i].Type)
+	}
+
+	for _, spec := range m.GetOperations(MediaAudio) {
+		assert.True(t, spec.AppliesTo(MediaAudio), spec.Type)
+		assert.NotEqual(t, "resize", spec.Type)
+	}
+}
@@ -0,0 +1,269 @@
+package media
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Interval is a span of the input's timeline in seconds
+type Interval struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Duration returns the interval's length
+func (i Interval) Duration() float64 {
+	return i.End - i.Start
+}
+
+// SilenceReport is what removeSilence writes in detectOnly mode
+type SilenceReport struct {
+	Duration    float64    `json:"duration"`    // Input length in seconds
+	Threshold   float64    `json:"threshold"`   // dB
+	MinDuration float64    `json:"minDuration"` // Seconds
+	Silences    []Interval `json:"silences"`    // As detected, before padding
+	Removable   float64    `json:"removable"`   // Seconds removeSilence would cut with the given padding
+}
+
+// silenceOptions are removeSilence's validated parameters
+type silenceOptions struct {
+	Threshold   float64
+	MinDuration float64
+	Padding     float64
+}
+
+func runRemoveSilence(ctx context.Context, proc *Processor, opts ProcessOptions, p Params) (*ProcessResult, error) {
+	silence := silenceOptions{
+		Threshold:   p.Float("threshold"),
+		MinDuration: p.Float("minDuration"),
+		Padding:     p.Float("padding"),
+	}
+	detectOnly := p.Bool("detectOnly")
+	container := containerFromPath(opts.OutputPath)
+	if detectOnly != (container == "json") {
+		return nil, fmt.Errorf("%w: removeSilence writes JSON only in detectOnly mode", ErrInvalidOperation)
+	}
+
+	info, err := proc.Probe(ctx, opts.InputPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.AudioCodec == "" {
+		return nil, fmt.Errorf("%w: removeSilence needs an audio stream", ErrInvalidOperation)
+	}
+
+	// Detection metadata goes to a per-job scratch file rather than FFmpeg's log
+	scratch, err := os.MkdirTemp("", "silence-"+opts.JobID+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	detectProgress := opts.OnProgress
+	if !detectOnly {
+		detectProgress = passProgress(opts.OnProgress, 1)
+	}
+	silences, err := proc.detectSilence(ctx, opts.InputPath, filepath.Join(scratch, "silence.txt"), silence, info.Duration, detectProgress)
+	if err != nil {
+		return nil, err
+	}
+	cuts := padSilences(silences, silence.Padding, info.Duration)
+
+	if detectOnly {
+		report := SilenceReport{
+			Duration:    info.Duration,
+			Threshold:   silence.Threshold,
+			MinDuration: silence.MinDuration,
+			Silences:    silences,
+		}
+		for _, c := range cuts {
+			report.Removable += c.Duration()
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(opts.OutputPath, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write silence report: %w", err)
+		}
+		return nil, nil
+	}
+
+	keep := keptIntervals(cuts, info.Duration)
+	if len(keep) == 0 {
+		return nil, fmt.Errorf("%w: the whole input is silent at %g dB", ErrInvalidOperation, silence.Threshold)
+	}
+	withVideo := info.VideoCodec != "" && !audioContainers[container]
+
+	script := filepath.Join(scratch, "graph.txt")
+	if err := os.WriteFile(script, []byte(silenceCutGraph(keep, withVideo)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write filtergraph: %w", err)
+	}
+	spec := silenceCutSpec(opts.InputPath, opts.OutputPath, script, withVideo, proc.encoderSettings(&opts))
+
+	var total float64
+	for _, k := range keep {
+		total += k.Duration()
+	}
+	args := spec.Args()
+	proc.logger.Info("Removing silence",
+		zap.String("input", opts.InputPath),
+		zap.Int("silences", len(cuts)),
+		zap.Float64("kept_duration", total),
+		zap.Strings("args", args),
+	)
+	if err := proc.runFFmpeg(ctx, args, total, "Removing silence", passProgress(opts.OnProgress, 2)); err != nil {
+		return nil, fmt.Errorf("FFmpeg execution failed: %w", err)
+	}
+	return nil, nil
+}
+
+// detectSilence runs silencedetect over the first audio stream and returns the
+// silent intervals it found
+func (p *Processor) detectSilence(ctx context.Context, inputPath, metadataPath string, o silenceOptions, duration float64, onProgress func(ProgressUpdate)) ([]Interval, error) {
+	args := []string{
+		"-y", "-i", inputPath,
+		"-map", "0:a:0",
+		"-af", fmt.Sprintf("silencedetect=noise=%gdB:duration=%g,ametadata=mode=print:file=%s",
+			o.Threshold, o.MinDuration, escapeFilterValue(metadataPath)),
+		"-f", "null", os.DevNull,
+	}
+	p.logger.Info("Detecting silence", zap.String("input", inputPath), zap.Strings("args", args))
+	if err := p.runFFmpeg(ctx, args, duration, "Detecting silence", onProgress); err != nil {
+		return nil, fmt.Errorf("FFmpeg silence detection failed: %w", err)
+	}
+
+	f, err := os.Open(metadataPath)
+	if os.IsNotExist(err) {
+		return nil, nil // No frame carried metadata: nothing is silent
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseSilenceMetadata(f, duration)
+}
+
+// parseSilenceMetadata reads the lavfi.silence_start/lavfi.silence_end entries
+// that ametadata prints. A silence still open at the end runs to the end of the input.
+func parseSilenceMetadata(r io.Reader, duration float64) ([]Interval, error) {
+	var silences []Interval
+	open := -1.0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		t, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "lavfi.silence_start":
+			open = math.Max(t, 0)
+		case "lavfi.silence_end":
+			if open >= 0 && t > open {
+				silences = append(silences, Interval{Start: open, End: t})
+			}
+			open = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read silence metadata: %w", err)
+	}
+	if open >= 0 && duration > open {
+		silences = append(silences, Interval{Start: open, End: duration})
+	}
+	return silences, nil
+}
+
+// padSilences shrinks each silence by padding on both sides, so that much quiet
+// is kept around the remaining sound; silences too short to cut are dropped.
+// The input's own start and end need no padding.
+func padSilences(silences []Interval, padding, duration float64) []Interval {
+	var cuts []Interval
+	for _, s := range silences {
+		c := s
+		if c.Start > 0 {
+			c.Start += padding
+		}
+		if duration <= 0 || c.End < duration {
+			c.End -= padding
+		}
+		if c.Duration() > 0 {
+			cuts = append(cuts, c)
+		}
+	}
+	return cuts
+}
+
+// keptIntervals returns the parts of [0, duration] outside the cuts, which are in order
+func keptIntervals(cuts []Interval, duration float64) []Interval {
+	var keep []Interval
+	pos := 0.0
+	for _, c := range cuts {
+		if c.Start > pos {
+			keep = append(keep, Interval{Start: pos, End: c.Start})
+		}
+		pos = math.Max(pos, c.End)
+	}
+	if pos < duration {
+		keep = append(keep, Interval{Start: pos, End: duration})
+	}
+	return keep
+}
+
+// silenceCutGraph trims every kept interval out of the input and concatenates
+// them; video and audio are cut at the same timestamps so they stay in sync
+func silenceCutGraph(keep []Interval, withVideo bool) string {
+	var chains []string
+	var inputs strings.Builder
+	for i, k := range keep {
+		if withVideo {
+			chains = append(chains, fmt.Sprintf("[0:v:0]trim=start=%g:end=%g,setpts=PTS-STARTPTS[v%d]", k.Start, k.End, i))
+			fmt.Fprintf(&inputs, "[v%d]", i)
+		}
+		chains = append(chains, fmt.Sprintf("[0:a:0]atrim=start=%g:end=%g,asetpts=PTS-STARTPTS[a%d]", k.Start, k.End, i))
+		fmt.Fprintf(&inputs, "[a%d]", i)
+	}
+
+	outputs, v := "[aout]", 0
+	if withVideo {
+		outputs, v = "[vout][aout]", 1
+	}
+	chains = append(chains, fmt.Sprintf("%sconcat=n=%d:v=%d:a=1%s", inputs.String(), len(keep), v, outputs))
+	return strings.Join(chains, ";\n")
+}
+
+// silenceCutSpec plans the encode of the concatenated intervals. The graph is
+// read from a script file: a long recording can have thousands of pauses.
+func silenceCutSpec(inputPath, outputPath, script string, withVideo bool, enc encoderSettings) *OutputSpec {
+	spec := &OutputSpec{
+		Inputs:       []InputSpec{{Path: inputPath}},
+		Container:    containerFromPath(outputPath),
+		FilterScript: script,
+		Maps:         []string{"[aout]"},
+		OutputPath:   outputPath,
+		Threads:      enc.Threads,
+	}
+	if !withVideo {
+		// The audio encoder follows the container, as for any audio conversion
+		spec.DropVideo = true
+		return spec
+	}
+
+	spec.Maps = []string{"[vout]", "[aout]"}
+	useDefaultEncoders(spec, enc)
+	return spec
+}
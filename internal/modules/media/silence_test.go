@@ -0,0 +1,83 @@
+package media
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSilenceMetadata(t *testing.T) {
+	metadata := `frame:12   pts:11264   pts_time:0.255
+lavfi.silence_start=-0.01
+frame:95   pts:97280   pts_time:2.2
+lavfi.silence_end=2.2
+lavfi.silence_duration=2.21
+frame:300  pts:307200  pts_time:6.966
+lavfi.silence_start=6.966
+`
+	silences, err := parseSilenceMetadata(strings.NewReader(metadata), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Interval{{Start: 0, End: 2.2}, {Start: 6.966, End: 10}}, silences, "an open silence runs to the end")
+
+	silences, err = parseSilenceMetadata(strings.NewReader(""), 10)
+	require.NoError(t, err)
+	assert.Empty(t, silences)
+}
+
+func TestPadSilences(t *testing.T) {
+	silences := []Interval{{Start: 0, End: 2}, {Start: 4, End: 4.15}, {Start: 5, End: 7}, {Start: 9, End: 10}}
+	cuts := padSilences(silences, 0.1, 10)
+
+	// The file's own start and end aren't padded; the short pause disappears
+	assert.InDeltaSlice(t, []float64{0, 1.9, 5.1, 6.9, 9.1, 10}, flatten(cuts), 1e-9)
+}
+
+func TestKeptIntervals(t *testing.T) {
+	assert.Equal(t, []Interval{{Start: 2, End: 5}, {Start: 7, End: 9}},
+		keptIntervals([]Interval{{Start: 0, End: 2}, {Start: 5, End: 7}, {Start: 9, End: 10}}, 10))
+	assert.Equal(t, []Interval{{Start: 0, End: 10}}, keptIntervals(nil, 10))
+	assert.Empty(t, keptIntervals([]Interval{{Start: 0, End: 10}}, 10))
+}
+
+func TestSilenceCutGraph(t *testing.T) {
+	keep := []Interval{{Start: 0, End: 1.5}, {Start: 3, End: 4}}
+
+	t.Run("video is cut in sync", func(t *testing.T) {
+		assert.Equal(t, "[0:v:0]trim=start=0:end=1.5,setpts=PTS-STARTPTS[v0];\n"+
+			"[0:a:0]atrim=start=0:end=1.5,asetpts=PTS-STARTPTS[a0];\n"+
+			"[0:v:0]trim=start=3:end=4,setpts=PTS-STARTPTS[v1];\n"+
+			"[0:a:0]atrim=start=3:end=4,asetpts=PTS-STARTPTS[a1];\n"+
+			"[v0][a0][v1][a1]concat=n=2:v=1:a=1[vout][aout]", silenceCutGraph(keep, true))
+	})
+
+	t.Run("audio only", func(t *testing.T) {
+		graph := silenceCutGraph(keep, false)
+		assert.NotContains(t, graph, "[0:v:0]")
+		assert.True(t, strings.HasSuffix(graph, "[a0][a1]concat=n=2:v=0:a=1[aout]"))
+	})
+}
+
+func TestSilenceCutSpec(t *testing.T) {
+	t.Run("video", func(t *testing.T) {
+		args := strings.Join(silenceCutSpec("in.mp4", "out.mp4", "/tmp/graph.txt", true, encoderSettings{Preset: "veryfast"}).Args(), " ")
+		assert.Contains(t, args, "-filter_complex_script /tmp/graph.txt -map [vout] -map [aout]")
+		assert.Contains(t, args, "-c:v libx264 -preset veryfast")
+		assert.Contains(t, args, "-c:a aac")
+	})
+
+	t.Run("audio", func(t *testing.T) {
+		args := strings.Join(silenceCutSpec("in.mp4", "out.mp3", "/tmp/graph.txt", false, encoderSettings{}).Args(), " ")
+		assert.Contains(t, args, "-map [aout] -vn")
+		assert.NotContains(t, args, "[vout]")
+	})
+}
+
+func flatten(intervals []Interval) []float64 {
+	var out []float64
+	for _, i := range intervals {
+		out = append(out, i.Start, i.End)
+	}
+	return out
+}
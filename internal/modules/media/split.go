@@ -0,0 +1,345 @@
+package media
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// maxSplitParts bounds how many files one split may produce
+const maxSplitParts = 200
+
+// minSplitPart is the shortest part kept when split points are closer together;
+// stream-copied parts can't be shorter than a keyframe interval anyway
+const minSplitPart = 0.5
+
+// splitOptions are split's validated parameters
+type splitOptions struct {
+	Mode            string // "duration", "timestamps", "scenes" or "chapters"
+	SegmentDuration float64
+	Timestamps      []float64
+	SceneThreshold  float64
+	MinPart         float64 // Shortest part in scenes mode
+	Precise         bool
+}
+
+// splitTimestampsParam lists the explicit split points of timestamps mode
+func splitTimestampsParam() ParamSpec {
+	lo, hi := numberRange(1, maxSplitParts-1)
+	return ParamSpec{
+		Name:        "timestamps",
+		Type:        ParamList,
+		Description: "Times at which a new part starts (timestamps mode)",
+		Min:         lo,
+		Max:         hi,
+		Fields:      []ParamSpec{{Name: "at", Type: ParamTime, Required: true, Description: "Start of the part"}},
+	}
+}
+
+func runSplit(ctx context.Context, proc *Processor, opts ProcessOptions, p Params) (*ProcessResult, error) {
+	split := splitOptions{
+		Mode:            p.String("mode"),
+		SegmentDuration: p.Float("segmentDuration"),
+		SceneThreshold:  p.Float("sceneThreshold"),
+		MinPart:         p.Float("minPartDuration"),
+		Precise:         p.Bool("precise"),
+	}
+	for _, item := range p.List("timestamps") {
+		t, err := parseTimeParam(item["at"])
+		if err != nil {
+			return nil, fmt.Errorf("%w: split timestamp: %v", ErrInvalidOperation, err)
+		}
+		split.Timestamps = append(split.Timestamps, t)
+	}
+
+	info, err := proc.Probe(ctx, opts.InputPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Duration <= 0 {
+		return nil, fmt.Errorf("%w: split needs an input with a known duration", ErrInvalidOperation)
+	}
+
+	var points []float64
+	switch split.Mode {
+	case "duration":
+		points = durationSplitPoints(info.Duration, split.SegmentDuration)
+	case "timestamps":
+		points, err = timestampSplitPoints(split.Timestamps, info.Duration)
+		if err != nil {
+			return nil, err
+		}
+	case "scenes":
+		if info.VideoCodec == "" {
+			return nil, fmt.Errorf("%w: split by scenes needs a video stream", ErrInvalidOperation)
+		}
+		scenes, err := proc.detectScenes(ctx, opts.InputPath, opts.JobID, split.SceneThreshold, info.Duration, passProgress(opts.OnProgress, 1))
+		if err != nil {
+			return nil, err
+		}
+		points = spaceSplitPoints(scenes, split.MinPart, info.Duration)
+	case "chapters":
+		chapters, err := proc.probeChapters(ctx, opts.InputPath)
+		if err != nil {
+			return nil, err
+		}
+		if len(chapters) < 2 {
+			return nil, fmt.Errorf("%w: the input has no chapters to split at", ErrInvalidOperation)
+		}
+		points = chapters
+	default:
+		return nil, fmt.Errorf("%w: unknown split mode %q", ErrInvalidOperation, split.Mode)
+	}
+
+	points = normalizeSplitPoints(points, info.Duration)
+	if len(points)+1 > maxSplitParts {
+		return nil, fmt.Errorf("%w: split would produce %d parts, at most %d are allowed", ErrInvalidOperation, len(points)+1, maxSplitParts)
+	}
+
+	dir := packageDir(opts.OutputPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	args := splitArgs(opts.InputPath, dir, containerFromPath(opts.OutputPath), points, info, split.Precise, proc.encoderSettings(&opts))
+	proc.logger.Info("Splitting",
+		zap.String("input", opts.InputPath),
+		zap.String("mode", split.Mode),
+		zap.Int("parts", len(points)+1),
+		zap.Strings("args", args),
+	)
+
+	onProgress := opts.OnProgress
+	if split.Mode == "scenes" {
+		onProgress = passProgress(opts.OnProgress, 2)
+	}
+	if err := proc.runFFmpeg(ctx, args, info.Duration, "Splitting", onProgress); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("FFmpeg split failed: %w", err)
+	}
+	return collectOutputs(dir)
+}
+
+// durationSplitPoints cuts every segment seconds
+func durationSplitPoints(duration, segment float64) []float64 {
+	var points []float64
+	for t := segment; t < duration; t += segment {
+		points = append(points, t)
+	}
+	return points
+}
+
+// spaceSplitPoints drops points that would start a part shorter than minPart
+func spaceSplitPoints(points []float64, minPart, duration float64) []float64 {
+	var spaced []float64
+	last := 0.0
+	for _, t := range points {
+		if t-last >= minPart && duration-t >= minPart {
+			spaced = append(spaced, t)
+			last = t
+		}
+	}
+	return spaced
+}
+
+// normalizeSplitPoints sorts the points, drops those outside the input and
+// merges points closer than minSplitPart
+func normalizeSplitPoints(points []float64, duration float64) []float64 {
+	sorted := append([]float64(nil), points...)
+	sort.Float64s(sorted)
+	return spaceSplitPoints(sorted, minSplitPart, duration)
+}
+
+// timestampSplitPoints checks that explicit split points cut the input at
+// least once; otherwise the split would just copy it to a single part
+func timestampSplitPoints(timestamps []float64, duration float64) ([]float64, error) {
+	if len(timestamps) == 0 {
+		return nil, fmt.Errorf("%w: split by timestamps needs at least one timestamp", ErrInvalidOperation)
+	}
+	points := normalizeSplitPoints(timestamps, duration)
+	if len(points) == 0 {
+		return nil, fmt.Errorf("%w: split timestamps %s are not inside the %gs input; each must be at least %gs after its start and before its end",
+			ErrInvalidOperation, formatSplitTimes(timestamps), duration, minSplitPart)
+	}
+	return points, nil
+}
+
+// formatSplitTimes lists split points in seconds for error messages
+func formatSplitTimes(points []float64) string {
+	times := make([]string, len(points))
+	for i, t := range points {
+		times[i] = strconv.FormatFloat(t, 'g', -1, 64) + "s"
+	}
+	return strings.Join(times, ", ")
+}
+
+// splitArgs writes every part with FFmpeg's segment muxer in a single run.
+// Stream-copied parts start at the first keyframe after each split point;
+// precise parts are re-encoded with keyframes forced at the split points.
+// Streams the container can't hold as they are are re-encoded even when not precise.
+func splitArgs(inputPath, dir, container string, points []float64, info *MediaInfo, precise bool, enc encoderSettings) []string {
+	times := make([]string, len(points))
+	for i, t := range points {
+		times[i] = strconv.FormatFloat(t, 'f', 3, 64)
+	}
+
+	args := []string{"-y"}
+	if enc.Threads > 0 {
+		args = append(args, "-threads", strconv.Itoa(enc.Threads))
+	}
+	args = append(args, "-i", inputPath)
+	withVideo := info.VideoCodec != "" && !audioContainers[container]
+	if withVideo {
+		args = append(args, "-map", "0:v:0")
+	}
+	if info.AudioCodec != "" {
+		args = append(args, "-map", "0:a:0")
+	}
+
+	copyVideo := !withVideo || !precise && canStreamCopy(container, info.VideoCodec)
+	copyAudio := info.AudioCodec == "" || !precise && canStreamCopy(container, info.AudioCodec)
+	if copyVideo && copyAudio {
+		args = append(args, "-c", "copy")
+	} else {
+		defaults := &OutputSpec{Container: container}
+		useDefaultEncoders(defaults, enc)
+		switch {
+		case !withVideo:
+		case copyVideo:
+			args = append(args, "-c:v", "copy")
+		default:
+			args = append(args, "-c:v", defaults.VideoCodec)
+			args = append(args, videoEncoderArgs(defaults.VideoCodec, defaults.Rate)...)
+			args = append(args, "-force_key_frames", strings.Join(times, ","))
+		}
+		switch {
+		case info.AudioCodec == "":
+		case copyAudio:
+			args = append(args, "-c:a", "copy")
+		case withVideo:
+			args = append(args, "-c:a", defaults.AudioCodec)
+		}
+		// Audio-only parts are otherwise re-encoded by the container's default encoder
+	}
+
+	args = append(args,
+		"-f", "segment",
+		"-segment_times", strings.Join(times, ","),
+		"-reset_timestamps", "1",
+	)
+	switch container {
+	case "mp4", "mov", "m4a":
+		args = append(args, "-segment_format_options", "movflags=+faststart")
+	}
+	return append(args, filepath.Join(dir, "part_%03d."+container))
+}
+
+// collectOutputs lists the separate output files written to dir, in name order
+func collectOutputs(dir string) (*ProcessResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list output files: %w", err)
+	}
+	result := &ProcessResult{Dir: dir}
+	for _, e := range entries {
+		if !e.IsDir() {
+			result.Outputs = append(result.Outputs, Artifact{Name: e.Name(), Path: filepath.Join(dir, e.Name())})
+		}
+	}
+	if len(result.Outputs) == 0 {
+		return nil, fmt.Errorf("FFmpeg did not write any output files")
+	}
+	return result, nil
+}
+
+// detectScenes returns the times of scene changes whose score exceeds threshold
+func (p *Processor) detectScenes(ctx context.Context, inputPath, jobID string, threshold, duration float64, onProgress func(ProgressUpdate)) ([]float64, error) {
+	scratch, err := os.MkdirTemp("", "scenes-"+jobID+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+	metadataPath := filepath.Join(scratch, "scenes.txt")
+
+	// Scores are just as good on a small picture and much cheaper to compute
+	args := []string{
+		"-y", "-i", inputPath,
+		"-map", "0:v:0", "-an",
+		"-vf", fmt.Sprintf("scale=320:-2,select=gt(scene\\,%g),metadata=mode=print:file=%s", threshold, escapeFilterValue(metadataPath)),
+		"-f", "null", os.DevNull,
+	}
+	p.logger.Info("Detecting scenes", zap.String("input", inputPath), zap.Strings("args", args))
+	if err := p.runFFmpeg(ctx, args, duration, "Detecting scenes", onProgress); err != nil {
+		return nil, fmt.Errorf("FFmpeg scene detection failed: %w", err)
+	}
+
+	f, err := os.Open(metadataPath)
+	if os.IsNotExist(err) {
+		return nil, nil // No frame passed the threshold
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseSceneMetadata(f)
+}
+
+// parseSceneMetadata reads the pts_time of every frame the metadata filter printed
+func parseSceneMetadata(r io.Reader) ([]float64, error) {
+	var times []float64
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		for _, field := range strings.Fields(scanner.Text()) {
+			if v, ok := strings.CutPrefix(field, "pts_time:"); ok {
+				if t, err := strconv.ParseFloat(v, 64); err == nil && !math.IsNaN(t) {
+					times = append(times, t)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read scene metadata: %w", err)
+	}
+	return times, nil
+}
+
+// probeChapters returns the start time of every chapter of the input
+func (p *Processor) probeChapters(ctx context.Context, inputPath string) ([]float64, error) {
+	cmd := exec.CommandContext(ctx, p.ffprobePath, "-v", "quiet", "-print_format", "json", "-show_chapters", inputPath)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return parseChapters(output)
+}
+
+// parseChapters reads chapter start times from ffprobe's JSON output
+func parseChapters(output []byte) ([]float64, error) {
+	var probe struct {
+		Chapters []struct {
+			StartTime string `json:"start_time"`
+		} `json:"chapters"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse chapters: %w", err)
+	}
+	starts := make([]float64, 0, len(probe.Chapters))
+	for _, c := range probe.Chapters {
+		t, err := strconv.ParseFloat(c.StartTime, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chapter start %q", c.StartTime)
+		}
+		starts = append(starts, t)
+	}
+	return starts, nil
+}
@@ -0,0 +1,143 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationSplitPoints(t *testing.T) {
+	assert.Equal(t, []float64{60, 120}, durationSplitPoints(150, 60))
+	assert.Equal(t, []float64{60}, durationSplitPoints(120, 60), "no empty part at the end")
+	assert.Nil(t, durationSplitPoints(30, 60))
+}
+
+func TestNormalizeSplitPoints(t *testing.T) {
+	t.Run("sorts and drops points outside the input", func(t *testing.T) {
+		assert.Equal(t, []float64{10, 30}, normalizeSplitPoints([]float64{30, 0, 10, 120}, 100))
+	})
+
+	t.Run("merges points closer than the minimum part", func(t *testing.T) {
+		assert.Equal(t, []float64{10, 20}, normalizeSplitPoints([]float64{10, 10.2, 20, 99.8}, 100))
+	})
+}
+
+func TestTimestampSplitPoints(t *testing.T) {
+	points, err := timestampSplitPoints([]float64{30, 0, 10}, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{10, 30}, points)
+
+	_, err = timestampSplitPoints(nil, 100)
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+
+	_, err = timestampSplitPoints([]float64{0, 99.8, 120.5}, 100)
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+	assert.Contains(t, err.Error(), "0s, 99.8s, 120.5s")
+}
+
+func TestSpaceSplitPoints(t *testing.T) {
+	scenes := []float64{1, 4, 5, 9, 14, 19}
+	assert.Equal(t, []float64{4, 9, 14}, spaceSplitPoints(scenes, 3, 20))
+}
+
+func TestSplitArgs(t *testing.T) {
+	info := &MediaInfo{Duration: 100, VideoCodec: "h264", AudioCodec: "aac"}
+
+	t.Run("stream copy", func(t *testing.T) {
+		args := strings.Join(splitArgs("in.mp4", "/out/job", "mp4", []float64{10, 42.5}, info, false, encoderSettings{}), " ")
+
+		assert.Contains(t, args, "-i in.mp4 -map 0:v:0 -map 0:a:0 -c copy")
+		assert.Contains(t, args, "-f segment -segment_times 10.000,42.500 -reset_timestamps 1")
+		assert.Contains(t, args, "movflags=+faststart")
+		assert.True(t, strings.HasSuffix(args, "/out/job/part_%03d.mp4"))
+		assert.NotContains(t, args, "-force_key_frames")
+	})
+
+	t.Run("precise re-encodes with keyframes at the split points", func(t *testing.T) {
+		args := strings.Join(splitArgs("in.mkv", "/out/job", "mkv", []float64{10}, info, true, encoderSettings{Preset: "fast"}), " ")
+
+		assert.Contains(t, args, "-c:v libx264")
+		assert.Contains(t, args, "-preset fast")
+		assert.Contains(t, args, "-force_key_frames 10.000")
+		assert.Contains(t, args, "-c:a ")
+		assert.NotContains(t, args, "-c copy")
+		assert.NotContains(t, args, "movflags")
+	})
+
+	t.Run("audio container drops the video", func(t *testing.T) {
+		args := strings.Join(splitArgs("in.mp4", "/out/job", "mp3", []float64{10}, info, false, encoderSettings{}), " ")
+
+		assert.NotContains(t, args, "0:v:0")
+		assert.Contains(t, args, "-map 0:a:0")
+		assert.NotContains(t, args, "copy", "AAC can't be copied into MP3")
+		assert.True(t, strings.HasSuffix(args, "part_%03d.mp3"))
+	})
+
+	t.Run("audio copied into a container that holds it", func(t *testing.T) {
+		mp3 := &MediaInfo{Duration: 100, AudioCodec: "mp3"}
+		args := strings.Join(splitArgs("in.mp3", "/out/job", "mp3", []float64{10}, mp3, false, encoderSettings{}), " ")
+
+		assert.Contains(t, args, "-map 0:a:0 -c copy")
+	})
+
+	t.Run("only the stream the container can't hold is re-encoded", func(t *testing.T) {
+		pcm := &MediaInfo{Duration: 100, VideoCodec: "h264", AudioCodec: "pcm_s16le"}
+		args := strings.Join(splitArgs("in.mov", "/out/job", "mp4", []float64{10}, pcm, false, encoderSettings{}), " ")
+
+		assert.Contains(t, args, "-c:v copy -c:a aac")
+		assert.NotContains(t, args, "-force_key_frames")
+	})
+
+	t.Run("video the container can't hold is re-encoded", func(t *testing.T) {
+		args := strings.Join(splitArgs("in.mp4", "/out/job", "webm", []float64{10}, info, false, encoderSettings{}), " ")
+
+		assert.NotContains(t, args, "copy")
+		assert.Contains(t, args, "-force_key_frames 10.000")
+	})
+}
+
+func TestParseSceneMetadata(t *testing.T) {
+	metadata := `frame:0    pts:75075   pts_time:3.003
+lavfi.scene_score=0.512
+frame:1    pts:270270  pts_time:10.81
+lavfi.scene_score=0.733
+`
+	times, err := parseSceneMetadata(strings.NewReader(metadata))
+	require.NoError(t, err)
+	assert.Equal(t, []float64{3.003, 10.81}, times)
+}
+
+func TestParseChapters(t *testing.T) {
+	output := []byte(`{"chapters": [
+		{"id": 0, "start_time": "0.000000", "end_time": "312.5"},
+		{"id": 1, "start_time": "312.500000", "end_time": "640"}
+	]}`)
+	starts, err := parseChapters(output)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 312.5}, starts)
+
+	starts, err = parseChapters([]byte(`{}`))
+	require.NoError(t, err)
+	assert.Empty(t, starts)
+}
+
+func TestCollectOutputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"part_001.mp4", "part_000.mp4", "part_002.mp4"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644))
+	}
+
+	result, err := collectOutputs(dir)
+	require.NoError(t, err)
+	require.Len(t, result.Outputs, 3)
+	assert.Equal(t, "part_000.mp4", result.Outputs[0].Name)
+	assert.Equal(t, filepath.Join(dir, "part_002.mp4"), result.Outputs[2].Path)
+	assert.Empty(t, result.Artifacts)
+
+	_, err = collectOutputs(t.TempDir())
+	assert.Error(t, err)
+}
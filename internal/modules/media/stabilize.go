@@ -0,0 +1,136 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// stabilizeOptions are stabilize's validated parameters
+type stabilizeOptions struct {
+	Shakiness int     // How shaky the footage is, 1-10 (detection)
+	Smoothing int     // Frames averaged on each side to smooth the camera path
+	Zoom      float64 // Percent; 0 with AutoZoom unset means no zoom
+	AutoZoom  bool    // Zoom just enough to hide the borders moved into view
+	Crop      string  // "black" fills borders, "keep" shows the previous frame's content
+}
+
+func runStabilize(ctx context.Context, proc *Processor, opts ProcessOptions, p Params) (*ProcessResult, error) {
+	stab := stabilizeOptions{
+		Shakiness: p.Int("shakiness"),
+		Smoothing: p.Int("smoothing"),
+		Zoom:      p.Float("zoom"),
+		AutoZoom:  !p.Has("zoom"),
+		Crop:      p.String("crop"),
+	}
+	container := containerFromPath(opts.OutputPath)
+	if audioContainers[container] || imageContainers[container] {
+		return nil, fmt.Errorf("%w: stabilize writes video, not %s", ErrInvalidOperation, container)
+	}
+
+	info, err := proc.Probe(ctx, opts.InputPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.VideoCodec == "" {
+		return nil, fmt.Errorf("%w: stabilize needs a video stream", ErrInvalidOperation)
+	}
+	enc := proc.encoderSettings(&opts)
+
+	if caps := proc.Capabilities(ctx); !caps.HasFilter("vidstabdetect") || !caps.HasFilter("vidstabtransform") {
+		// Without libvidstab, deshake stabilizes in a single pass
+		args := stabilizeSpec(opts.InputPath, opts.OutputPath, info, deshakeFilter(stab), enc).Args()
+		proc.logger.Info("Stabilizing with deshake", zap.String("input", opts.InputPath), zap.Strings("args", args))
+		if err := proc.runFFmpeg(ctx, args, info.Duration, "Stabilizing", opts.OnProgress); err != nil {
+			return nil, fmt.Errorf("FFmpeg execution failed: %w", err)
+		}
+		return nil, nil
+	}
+
+	// The camera motion found by the first pass is read back by the second
+	scratch, err := os.MkdirTemp("", "stabilize-"+opts.JobID+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+	transforms := filepath.Join(scratch, "transforms.trf")
+
+	detect := []string{
+		"-y", "-i", opts.InputPath,
+		"-map", "0:v:0", "-an",
+		"-vf", fmt.Sprintf("vidstabdetect=shakiness=%d:accuracy=15:result=%s", stab.Shakiness, escapeFilterValue(transforms)),
+		"-f", "null", os.DevNull,
+	}
+	if enc.Threads > 0 {
+		detect = append([]string{"-threads", fmt.Sprint(enc.Threads)}, detect...)
+	}
+	proc.logger.Info("Detecting camera motion", zap.String("input", opts.InputPath), zap.Strings("args", detect))
+	if err := proc.runFFmpeg(ctx, detect, info.Duration, "Detecting camera motion", passProgress(opts.OnProgress, 1)); err != nil {
+		return nil, fmt.Errorf("FFmpeg motion detection failed: %w", err)
+	}
+
+	args := stabilizeSpec(opts.InputPath, opts.OutputPath, info, vidstabTransformFilter(stab, transforms), enc).Args()
+	proc.logger.Info("Stabilizing", zap.String("input", opts.InputPath), zap.Strings("args", args))
+	if err := proc.runFFmpeg(ctx, args, info.Duration, "Stabilizing", passProgress(opts.OnProgress, 2)); err != nil {
+		return nil, fmt.Errorf("FFmpeg execution failed: %w", err)
+	}
+	return nil, nil
+}
+
+// vidstabTransformFilter applies the detected transforms, then sharpens
+// slightly as libvidstab recommends, since the transform interpolates pixels
+func vidstabTransformFilter(o stabilizeOptions, transforms string) string {
+	zoom := "optzoom=1"
+	if !o.AutoZoom {
+		zoom = fmt.Sprintf("optzoom=0:zoom=%g", o.Zoom)
+	}
+	return fmt.Sprintf("vidstabtransform=input=%s:smoothing=%d:crop=%s:%s,unsharp=5:5:0.8:3:3:0.4",
+		escapeFilterValue(transforms), o.Smoothing, o.Crop, zoom)
+}
+
+// deshakeFilter is the single-pass fallback. It has no smoothing window, so
+// only the border handling and zoom carry over.
+func deshakeFilter(o stabilizeOptions) string {
+	edge := "blank"
+	if o.Crop == "keep" {
+		edge = "original"
+	}
+	filter := "deshake=edge=" + edge
+	if o.Zoom > 0 {
+		// deshake can't zoom itself: scale up and cut the frame back to size
+		scale := 1 + o.Zoom/100
+		filter += fmt.Sprintf(",scale=trunc(iw*%g/2)*2:trunc(ih*%g/2)*2,crop=iw/%g:ih/%g", scale, scale, scale, scale)
+	}
+	return filter
+}
+
+// stabilizeSpec plans the encode of the stabilized video; audio is carried
+// over, re-encoded by the container's default encoder
+func stabilizeSpec(inputPath, outputPath string, info *MediaInfo, filter string, enc encoderSettings) *OutputSpec {
+	spec := &OutputSpec{
+		Inputs:       []InputSpec{{Path: inputPath}},
+		Container:    containerFromPath(outputPath),
+		VideoFilters: []string{filter},
+		Maps:         []string{"0:v:0"},
+		OutputPath:   outputPath,
+		Threads:      enc.Threads,
+	}
+	useDefaultEncoders(spec, enc)
+	if info.AudioCodec != "" {
+		spec.Maps = append(spec.Maps, "0:a:0")
+	} else {
+		spec.DropAudio = true
+	}
+	return spec
+}
+
+// requireStabilizeFilters checks for libvidstab, or the deshake fallback
+func requireStabilizeFilters(_ Params, caps *Capabilities) error {
+	if caps.HasFilter("vidstabdetect") && caps.HasFilter("vidstabtransform") || caps.HasFilter("deshake") {
+		return nil
+	}
+	return fmt.Errorf("%w: stabilize needs the vidstabdetect or deshake filter", ErrUnsupported)
+}
@@ -0,0 +1,36 @@
+package media
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVidstabTransformFilter(t *testing.T) {
+	auto := stabilizeOptions{Smoothing: 10, AutoZoom: true, Crop: "black"}
+	assert.Equal(t, `vidstabtransform=input=/tmp/stabilize-job-1/transforms.trf:smoothing=10:crop=black:optzoom=1,unsharp=5:5:0.8:3:3:0.4`,
+		vidstabTransformFilter(auto, "/tmp/stabilize-job-1/transforms.trf"))
+
+	fixed := stabilizeOptions{Smoothing: 30, Zoom: 5, Crop: "keep"}
+	assert.Contains(t, vidstabTransformFilter(fixed, "t.trf"), "smoothing=30:crop=keep:optzoom=0:zoom=5,")
+}
+
+func TestDeshakeFilter(t *testing.T) {
+	assert.Equal(t, "deshake=edge=blank", deshakeFilter(stabilizeOptions{Crop: "black", AutoZoom: true}))
+	assert.Equal(t, "deshake=edge=original,scale=trunc(iw*1.1/2)*2:trunc(ih*1.1/2)*2,crop=iw/1.1:ih/1.1",
+		deshakeFilter(stabilizeOptions{Crop: "keep", Zoom: 10}))
+}
+
+func TestStabilizeSpec(t *testing.T) {
+	info := &MediaInfo{VideoCodec: "h264", AudioCodec: "aac"}
+
+	args := strings.Join(stabilizeSpec("in.mp4", "out.mp4", info, "deshake=edge=blank", encoderSettings{Preset: "fast", Threads: 2}).Args(), " ")
+	assert.Contains(t, args, "-threads 2 -i in.mp4 -map 0:v:0 -map 0:a:0 -vf deshake=edge=blank -c:v libx264 -preset fast -c:a aac")
+
+	silent := &MediaInfo{VideoCodec: "h264"}
+	args = strings.Join(stabilizeSpec("in.mp4", "out.webm", silent, "deshake", encoderSettings{}).Args(), " ")
+	assert.Contains(t, args, "-c:v libvpx-vp9")
+	assert.Contains(t, args, "-an")
+	assert.NotContains(t, args, "0:a:0")
+}
@@ -0,0 +1,217 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// subtitleTrack is a soft subtitle stream muxed into the output by addSubtitles
+type subtitleTrack struct {
+	Input    int    // Index of the subtitle file among the FFmpeg inputs
+	Language string // ISO 639 language tag
+}
+
+// subtitleCodecs is the subtitle codec each container stores natively
+var subtitleCodecs = map[string]string{
+	"mp4":  "mov_text",
+	"mov":  "mov_text",
+	"mkv":  "copy", // Matroska holds SRT, WebVTT and ASS as they are, styling included
+	"webm": "webvtt",
+}
+
+// assAlignment maps positions onto ASS numpad alignments (bottom centre is 2)
+var assAlignment = map[string]int{"bottom": 2, "middle": 5, "top": 8}
+
+// fontNamePattern matches the font family names burned-in subtitles accept
+var fontNamePattern = regexp.MustCompile(`^[A-Za-z0-9 _-]+$`)
+
+// assColorNames are the color names accepted for burned-in subtitles
+var assColorNames = map[string]string{
+	"white":   "FFFFFF",
+	"black":   "000000",
+	"yellow":  "FFFF00",
+	"red":     "FF0000",
+	"green":   "00FF00",
+	"blue":    "0000FF",
+	"cyan":    "00FFFF",
+	"magenta": "FF00FF",
+	"gray":    "808080",
+}
+
+func applyAddSubtitles(b *PlanBuilder, p Params) error {
+	if err := b.Claim("subtitles"); err != nil {
+		return err
+	}
+
+	if p.String("mode") == "burn" {
+		style, err := subtitleStyle(p)
+		if err != nil {
+			return err
+		}
+		filter := "subtitles=filename=" + escapeFilterValue(p.String("subtitlePath"))
+		if style != "" {
+			filter += ":force_style=" + escapeFilterValue(style)
+		}
+		b.AddVideoFilter(filter)
+		return nil
+	}
+
+	language := p.String("language")
+	if !isLanguageTag(language) {
+		return fmt.Errorf("%w: addSubtitles: language must be a 2 or 3 letter code, got %q", ErrInvalidOperation, language)
+	}
+	b.Spec.Inputs = append(b.Spec.Inputs, InputSpec{Path: p.String("subtitlePath")})
+	b.subtitles = &subtitleTrack{Input: len(b.Spec.Inputs) - 1, Language: language}
+	return nil
+}
+
+// subtitleStyle renders the burn-in options that were given as an ASS
+// force_style; options left out keep the subtitle file's own styling
+func subtitleStyle(p Params) (string, error) {
+	var style []string
+	if p.Has("fontName") {
+		// force_style is a comma-separated list of fields: a ',' or '=' in the
+		// name would set fields of its own
+		name := p.String("fontName")
+		if !fontNamePattern.MatchString(name) {
+			return "", fmt.Errorf("%w: addSubtitles: fontName may only contain letters, digits, spaces, '_' and '-'", ErrInvalidOperation)
+		}
+		style = append(style, "FontName="+name)
+	}
+	if p.Has("fontSize") {
+		style = append(style, "FontSize="+strconv.Itoa(p.Int("fontSize")))
+	}
+	for _, c := range []struct{ param, field string }{
+		{"fontColor", "PrimaryColour"},
+		{"outlineColor", "OutlineColour"},
+	} {
+		if !p.Has(c.param) {
+			continue
+		}
+		color, err := assColor(p.String(c.param))
+		if err != nil {
+			return "", fmt.Errorf("%w: addSubtitles: %s %v", ErrInvalidOperation, c.param, err)
+		}
+		style = append(style, c.field+"="+color)
+	}
+	if p.Has("outline") {
+		style = append(style, "BorderStyle=1", "Outline="+strconv.Itoa(p.Int("outline")))
+	}
+	if p.Has("position") {
+		style = append(style, "Alignment="+strconv.Itoa(assAlignment[p.String("position")]))
+	}
+	if p.Has("margin") {
+		style = append(style, "MarginV="+strconv.Itoa(p.Int("margin")))
+	}
+	return strings.Join(style, ","), nil
+}
+
+// assColor converts a color name or RRGGBB hex value into ASS's &HAABBGGRR form
+func assColor(color string) (string, error) {
+	hex := strings.ToLower(color)
+	if named, ok := assColorNames[hex]; ok {
+		hex = named
+	}
+	hex = strings.TrimPrefix(strings.TrimPrefix(hex, "#"), "0x")
+	if _, err := strconv.ParseUint(hex, 16, 32); err != nil || len(hex) != 6 {
+		return "", fmt.Errorf("must be a color name or RRGGBB hex value, got %q", color)
+	}
+	return strings.ToUpper("&H00" + hex[4:6] + hex[2:4] + hex[0:2]), nil
+}
+
+func isLanguageTag(tag string) bool {
+	if len(tag) < 2 || len(tag) > 3 {
+		return false
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// muxSubtitles maps the soft subtitle track into the output with the
+// container's native subtitle codec
+func (b *PlanBuilder) muxSubtitles() error {
+	s := b.Spec
+	codec, ok := subtitleCodecs[s.Container]
+	if s.Container != "" && !ok {
+		return conflictf("%s files (from %s) can't hold a subtitle track; burn the subtitles in instead", s.Container, b.owners["container"])
+	}
+
+	if len(s.Maps) == 0 {
+		// An explicit map replaces FFmpeg's default stream selection
+		s.Maps = append(s.Maps, "0:v?", "0:a?")
+	}
+	s.Maps = append(s.Maps, fmt.Sprintf("%d:s:0", b.subtitles.Input))
+	if codec != "" {
+		s.OutputOptions = append(s.OutputOptions, "-c:s", codec)
+	}
+	s.OutputOptions = append(s.OutputOptions, "-metadata:s:s:0", "language="+b.subtitles.Language)
+	return nil
+}
+
+// escapeFilterValue escapes a filter option value inside a filtergraph: once
+// for the option parser and once more for the graph parser
+func escapeFilterValue(v string) string {
+	return escapeChars(escapeChars(v, `\':`), `\'[],;`)
+}
+
+func escapeChars(s, special string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// subtitleFileEncoders are the FFmpeg encoders, and muxers of the same name,
+// that write each standalone subtitle format
+var subtitleFileEncoders = map[string]string{
+	"srt": "srt",
+	"vtt": "webvtt",
+	"ass": "ass",
+	"ssa": "ass",
+}
+
+func runExtractSubtitles(ctx context.Context, proc *Processor, opts ProcessOptions, p Params) (*ProcessResult, error) {
+	args, err := extractSubtitlesArgs(opts.InputPath, opts.OutputPath, p.Int("stream"))
+	if err != nil {
+		return nil, err
+	}
+
+	total := opts.InputDuration
+	if total <= 0 {
+		total = proc.probeDuration(ctx, opts.InputPath)
+	}
+	proc.logger.Info("Extracting subtitles", zap.String("input", opts.InputPath), zap.Strings("args", args))
+	if err := proc.runFFmpeg(ctx, args, total, "Extracting subtitles", opts.OnProgress); err != nil {
+		return nil, fmt.Errorf("FFmpeg subtitle extraction failed: %w", err)
+	}
+	return nil, nil
+}
+
+// extractSubtitlesArgs copies the stream-th subtitle stream into a file of the
+// output's format. Bitmap subtitles (PGS, DVD) can't be encoded as text and fail.
+func extractSubtitlesArgs(inputPath, outputPath string, stream int) ([]string, error) {
+	container := containerFromPath(outputPath)
+	encoder, ok := subtitleFileEncoders[container]
+	if !ok {
+		return nil, fmt.Errorf("%w: extractSubtitles writes srt, vtt or ass files, not %s", ErrInvalidOperation, container)
+	}
+	return []string{
+		"-y", "-i", inputPath,
+		"-map", fmt.Sprintf("0:s:%d", stream),
+		"-c:s", encoder, "-f", encoder,
+		outputPath,
+	}, nil
+}
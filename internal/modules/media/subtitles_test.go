@@ -0,0 +1,104 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddSubtitles(t *testing.T) {
+	t.Run("burn only forces the given style", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4", op("addSubtitles", map[string]interface{}{
+			"subtitlePath": "/uploads/sub's:1.srt",
+			"fontSize":     32.0,
+			"fontColor":    "yellow",
+			"position":     "top",
+		}))
+		assert.Equal(t,
+			`subtitles=filename=/uploads/sub\\\'s\\:1.srt:force_style=FontSize=32\,PrimaryColour=&H0000FFFF\,Alignment=8`,
+			flagValue(args, "-vf"))
+		assert.Equal(t, "libx264", flagValue(args, "-c:v"))
+	})
+
+	t.Run("burn without style options keeps the file's styling", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4", op("addSubtitles", map[string]interface{}{"subtitlePath": "styled.ass"}))
+		assert.Equal(t, "subtitles=filename=styled.ass", flagValue(args, "-vf"))
+	})
+
+	t.Run("soft into mp4 uses mov_text and copies streams", func(t *testing.T) {
+		args := resolveArgs(t, "out.mp4", op("addSubtitles", map[string]interface{}{
+			"subtitlePath": "sub.vtt",
+			"mode":         "soft",
+			"language":     "fra",
+		}))
+		assert.Equal(t, []string{"-i", "in.mp4", "-i", "sub.vtt"}, args[3:7])
+		assert.Equal(t, []string{"-map", "0:v?", "-map", "0:a?", "-map", "1:s:0"}, args[7:13])
+		assert.Equal(t, "mov_text", flagValue(args, "-c:s"))
+		assert.Equal(t, "language=fra", flagValue(args, "-metadata:s:s:0"))
+		assert.Equal(t, "copy", flagValue(args, "-c:v"))
+		assert.Equal(t, "copy", flagValue(args, "-c:a"))
+	})
+
+	t.Run("soft into webm converts to WebVTT", func(t *testing.T) {
+		args := resolveArgs(t, "out.webm",
+			op("compress", nil),
+			op("addSubtitles", map[string]interface{}{"subtitlePath": "sub.srt", "mode": "soft"}),
+		)
+		assert.Equal(t, "webvtt", flagValue(args, "-c:s"))
+		assert.Equal(t, "libvpx-vp9", flagValue(args, "-c:v"))
+		assert.Equal(t, "language=und", flagValue(args, "-metadata:s:s:0"))
+	})
+
+	t.Run("soft alongside replaced audio", func(t *testing.T) {
+		args := resolveArgs(t, "out.mkv",
+			op("addSubtitles", map[string]interface{}{"subtitlePath": "sub.ass", "mode": "soft"}),
+			op("addAudio", map[string]interface{}{"audioPath": "dub.mp3", "mode": "replace"}),
+		)
+		assert.Equal(t, []string{"-map", "0:v", "-map", "2:a", "-map", "1:s:0"}, args[9:15])
+		assert.Equal(t, "copy", flagValue(args, "-c:s"))
+		assert.Empty(t, flagValue(args, "-c:a"))
+	})
+
+	t.Run("soft into a container without subtitle tracks", func(t *testing.T) {
+		_, err := resolveOutputSpec("in.mp4", "out.avi", []Operation{
+			op("addSubtitles", map[string]interface{}{"subtitlePath": "sub.srt", "mode": "soft"}),
+		}, testEncoder)
+		require.ErrorIs(t, err, ErrConflictingOperations)
+		assert.Contains(t, err.Error(), "burn the subtitles in")
+	})
+
+	t.Run("bad color, font and language are invalid", func(t *testing.T) {
+		for _, params := range []map[string]interface{}{
+			{"subtitlePath": "sub.srt", "fontColor": "teal-ish"},
+			{"subtitlePath": "sub.srt", "fontName": "Arial,PrimaryColour=&H0000FF&"},
+			{"subtitlePath": "sub.srt", "mode": "soft", "language": "French"},
+		} {
+			_, err := resolveOutputSpec("in.mp4", "out.mp4", []Operation{op("addSubtitles", params)}, testEncoder)
+			assert.ErrorIs(t, err, ErrInvalidOperation)
+		}
+	})
+}
+
+func TestASSColor(t *testing.T) {
+	for in, want := range map[string]string{
+		"white":    "&H00FFFFFF",
+		"#ff8000":  "&H000080FF",
+		"0x123456": "&H00563412",
+	} {
+		got, err := assColor(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := assColor("12345")
+	assert.Error(t, err)
+}
+
+func TestExtractSubtitlesArgs(t *testing.T) {
+	args, err := extractSubtitlesArgs("in.mkv", "out.vtt", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-y", "-i", "in.mkv", "-map", "0:s:2", "-c:s", "webvtt", "-f", "webvtt", "out.vtt"}, args)
+
+	_, err = extractSubtitlesArgs("in.mkv", "out.mp4", 0)
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+}
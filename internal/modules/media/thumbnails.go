@@ -0,0 +1,199 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ThumbnailSize is a poster frame variant
+type ThumbnailSize struct {
+	Name  string
+	Width int // Maximum width; smaller videos are never upscaled
+}
+
+// ThumbnailSizes are the poster variants generated for every video
+var ThumbnailSizes = []ThumbnailSize{
+	{Name: "small", Width: 320},
+	{Name: "medium", Width: 640},
+	{Name: "large", Width: 1280},
+}
+
+// Names of the generated preview files. The storyboard references the sprite
+// by a relative URL, so both must be served from the same directory.
+const (
+	SpriteName     = "sprite.jpg"
+	StoryboardName = "storyboard.vtt"
+)
+
+// Sprite sheet layout: tiles are letterboxed to a fixed size so the
+// storyboard's coordinates don't depend on the video's aspect ratio
+const (
+	spriteTileWidth    = 160
+	spriteTileHeight   = 90
+	spriteColumns      = 10
+	spriteMaxTiles     = 100
+	spriteMinInterval  = 2.0 // Seconds between tiles on short videos
+	posterBatchFrames  = 100 // Frames the thumbnail filter compares to pick a poster
+	posterMaxSkip      = 30.0
+	posterSkipFraction = 0.1
+)
+
+// DefaultThumbnailSize is served when no size is asked for
+const DefaultThumbnailSize = "medium"
+
+// PosterName returns the file name of a poster variant
+func PosterName(size string) string {
+	return size + ".jpg"
+}
+
+// spriteLayout spreads up to spriteMaxTiles tiles evenly over a video
+type spriteLayout struct {
+	Interval float64 // Seconds covered by each tile
+	Tiles    int
+	Columns  int
+	Rows     int
+}
+
+func newSpriteLayout(duration float64) spriteLayout {
+	interval := math.Max(spriteMinInterval, math.Ceil(duration/spriteMaxTiles))
+	tiles := int(math.Ceil(duration / interval))
+	if tiles < 1 {
+		tiles = 1
+	}
+	columns := spriteColumns
+	if tiles < columns {
+		columns = tiles
+	}
+	return spriteLayout{
+		Interval: interval,
+		Tiles:    tiles,
+		Columns:  columns,
+		Rows:     (tiles + columns - 1) / columns,
+	}
+}
+
+// posterOffset skips past intros and fades from black before the thumbnail
+// filter looks for a representative frame
+func posterOffset(duration float64) float64 {
+	return math.Min(duration*posterSkipFraction, posterMaxSkip)
+}
+
+// posterArgs picks a poster frame with FFmpeg's thumbnail filter and writes it
+// once per size into dir
+func posterArgs(inputPath, dir string, offset float64) []string {
+	labels := make([]string, len(ThumbnailSizes))
+	for i := range ThumbnailSizes {
+		labels[i] = fmt.Sprintf("[p%d]", i)
+	}
+	graph := []string{fmt.Sprintf("[0:v:0]thumbnail=%d,split=%d%s", posterBatchFrames, len(ThumbnailSizes), strings.Join(labels, ""))}
+	for i, size := range ThumbnailSizes {
+		graph = append(graph, fmt.Sprintf("%sscale=w='min(%d,iw)':h=-2[%s]", labels[i], size.Width, size.Name))
+	}
+
+	args := []string{"-y", "-v", "error"}
+	if offset > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", offset))
+	}
+	args = append(args, "-i", inputPath, "-filter_complex", strings.Join(graph, ";"))
+	for _, size := range ThumbnailSizes {
+		args = append(args,
+			"-map", "["+size.Name+"]",
+			"-frames:v", "1", "-q:v", "2",
+			filepath.Join(dir, PosterName(size.Name)),
+		)
+	}
+	return args
+}
+
+// spriteArgs samples one frame per interval and tiles them into a single image
+func spriteArgs(inputPath, outputPath string, layout spriteLayout) []string {
+	filters := []string{
+		fmt.Sprintf("fps=1/%g", layout.Interval),
+		fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", spriteTileWidth, spriteTileHeight),
+		fmt.Sprintf("pad=%d:%d:(ow-iw)/2:(oh-ih)/2", spriteTileWidth, spriteTileHeight),
+		fmt.Sprintf("tile=%dx%d", layout.Columns, layout.Rows),
+	}
+	return []string{
+		"-y", "-v", "error",
+		"-i", inputPath,
+		"-map", "0:v:0",
+		"-vf", strings.Join(filters, ","),
+		"-frames:v", "1", "-q:v", "4",
+		outputPath,
+	}
+}
+
+// storyboardVTT writes a WebVTT file whose cues point at the sprite's tiles
+// with media fragments (sprite.jpg#xywh=x,y,w,h), as scrubbing previews expect
+func storyboardVTT(spriteURL string, duration float64, layout spriteLayout) []byte {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for i := 0; i < layout.Tiles; i++ {
+		start := float64(i) * layout.Interval
+		end := math.Min(start+layout.Interval, duration)
+		if end <= start {
+			end = start + layout.Interval
+		}
+		x := (i % layout.Columns) * spriteTileWidth
+		y := (i / layout.Columns) * spriteTileHeight
+		fmt.Fprintf(&b, "%s --> %s\n%s#xywh=%d,%d,%d,%d\n\n",
+			vttTimestamp(start), vttTimestamp(end), spriteURL, x, y, spriteTileWidth, spriteTileHeight)
+	}
+	return []byte(b.String())
+}
+
+// vttTimestamp formats seconds as hh:mm:ss.mmm
+func vttTimestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// GenerateThumbnails writes poster frames in every ThumbnailSizes variant, a
+// sprite sheet and its WebVTT storyboard into dir. The sprite and storyboard
+// are skipped when the video's duration is unknown.
+func (p *Processor) GenerateThumbnails(ctx context.Context, inputPath, dir string) (*ProcessResult, error) {
+	info, err := p.Probe(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.VideoCodec == "" {
+		return nil, fmt.Errorf("%w: no video stream to take thumbnails from", ErrInvalidOperation)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create thumbnail directory: %w", err)
+	}
+
+	args := posterArgs(inputPath, dir, posterOffset(info.Duration))
+	p.logger.Info("Generating poster frames", zap.String("input", inputPath), zap.Strings("args", args))
+	if err := p.runFFmpeg(ctx, args, 0, "Generating thumbnails", nil); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("FFmpeg poster generation failed: %w", err)
+	}
+
+	if info.Duration > 0 {
+		layout := newSpriteLayout(info.Duration)
+		args := spriteArgs(inputPath, filepath.Join(dir, SpriteName), layout)
+		p.logger.Info("Generating preview sprite",
+			zap.String("input", inputPath),
+			zap.Int("tiles", layout.Tiles),
+			zap.Strings("args", args),
+		)
+		if err := p.runFFmpeg(ctx, args, info.Duration, "Generating preview sprite", nil); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("FFmpeg sprite generation failed: %w", err)
+		}
+		vtt := storyboardVTT(SpriteName, info.Duration, layout)
+		if err := os.WriteFile(filepath.Join(dir, StoryboardName), vtt, 0644); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to write storyboard: %w", err)
+		}
+	}
+
+	return collectArtifacts(dir, PosterName(DefaultThumbnailSize))
+}
@@ -0,0 +1,63 @@
+package media
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpriteLayout(t *testing.T) {
+	t.Run("short video uses the minimum interval", func(t *testing.T) {
+		assert.Equal(t, spriteLayout{Interval: 2, Tiles: 5, Columns: 5, Rows: 1}, newSpriteLayout(9.5))
+	})
+
+	t.Run("long video is capped at the maximum tiles", func(t *testing.T) {
+		layout := newSpriteLayout(3600)
+		assert.Equal(t, 36.0, layout.Interval)
+		assert.Equal(t, 100, layout.Tiles)
+		assert.Equal(t, 10, layout.Columns)
+		assert.Equal(t, 10, layout.Rows)
+	})
+
+	t.Run("partial last row", func(t *testing.T) {
+		assert.Equal(t, spriteLayout{Interval: 2, Tiles: 23, Columns: 10, Rows: 3}, newSpriteLayout(45))
+	})
+}
+
+func TestStoryboardVTT(t *testing.T) {
+	vtt := string(storyboardVTT("sprite.jpg", 25, newSpriteLayout(25)))
+
+	assert.True(t, strings.HasPrefix(vtt, "WEBVTT\n\n00:00:00.000 --> 00:00:02.000\nsprite.jpg#xywh=0,0,160,90\n\n"))
+	assert.Contains(t, vtt, "00:00:18.000 --> 00:00:20.000\nsprite.jpg#xywh=1440,0,160,90\n")
+	assert.Contains(t, vtt, "00:00:20.000 --> 00:00:22.000\nsprite.jpg#xywh=0,90,160,90\n")
+	// The last cue ends with the video
+	assert.True(t, strings.HasSuffix(vtt, "00:00:24.000 --> 00:00:25.000\nsprite.jpg#xywh=320,90,160,90\n\n"))
+	assert.Equal(t, 13, strings.Count(vtt, "-->"))
+}
+
+func TestVTTTimestamp(t *testing.T) {
+	assert.Equal(t, "00:00:00.000", vttTimestamp(0))
+	assert.Equal(t, "00:01:02.500", vttTimestamp(62.5))
+	assert.Equal(t, "01:00:36.000", vttTimestamp(3636))
+}
+
+func TestPosterArgs(t *testing.T) {
+	args := strings.Join(posterArgs("in.mp4", "/out/thumbs", posterOffset(120)), " ")
+
+	assert.Contains(t, args, "-ss 12.000 -i in.mp4")
+	assert.Contains(t, args, "[0:v:0]thumbnail=100,split=3[p0][p1][p2];[p0]scale=w='min(320,iw)':h=-2[small];")
+	assert.Contains(t, args, "[p2]scale=w='min(1280,iw)':h=-2[large]")
+	assert.Contains(t, args, "-map [medium] -frames:v 1 -q:v 2 /out/thumbs/medium.jpg")
+
+	assert.Equal(t, 30.0, posterOffset(7200), "intro skip is capped")
+	assert.NotContains(t, strings.Join(posterArgs("in.mp4", "/out", 0), " "), "-ss")
+}
+
+func TestSpriteArgs(t *testing.T) {
+	args := strings.Join(spriteArgs("in.mp4", "/out/sprite.jpg", newSpriteLayout(45)), " ")
+
+	assert.Contains(t, args, "-map 0:v:0")
+	assert.Contains(t, args, "fps=1/2,scale=160:90:force_original_aspect_ratio=decrease,pad=160:90:(ow-iw)/2:(oh-ih)/2,tile=10x3")
+	assert.Contains(t, args, "-frames:v 1 -q:v 4 /out/sprite.jpg")
+}
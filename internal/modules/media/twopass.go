@@ -0,0 +1,159 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ErrTargetUnreachable is returned when a size or bitrate target can't be met
+var ErrTargetUnreachable = errors.New("target size unreachable")
+
+const (
+	// muxOverhead is the share of a target size reserved for container overhead
+	muxOverhead = 0.03
+	// targetSizeTolerance is how far the output may overshoot a target size
+	targetSizeTolerance = 0.02
+	// minVideoBitrate is the lowest video bitrate worth encoding at (bits/s)
+	minVideoBitrate = 64000
+	// maxTargetRetries bounds how often an oversized output is re-encoded
+	maxTargetRetries = 1
+)
+
+// targetVideoBitrate works out the video bitrate that fits a size or bitrate
+// target, given the output duration and the audio bitrate sharing the budget
+func targetVideoBitrate(rate RateControl, duration float64, audioBitrate int) (int, error) {
+	total := float64(rate.TargetBitrate)
+	if rate.TargetSize > 0 {
+		if duration <= 0 {
+			return 0, fmt.Errorf("%w: output duration is unknown", ErrTargetUnreachable)
+		}
+		total = float64(rate.TargetSize) * 8 * (1 - muxOverhead) / duration
+	}
+
+	video := int(total) - audioBitrate
+	if video < minVideoBitrate {
+		if rate.TargetSize > 0 {
+			return 0, fmt.Errorf("%w: %d bytes is too small for %.0f seconds of video", ErrTargetUnreachable, rate.TargetSize, duration)
+		}
+		return 0, fmt.Errorf("%w: %d bits/s leaves too little for video after %d bits/s of audio", ErrTargetUnreachable, rate.TargetBitrate, audioBitrate)
+	}
+	return video, nil
+}
+
+// parseBitrate reads an FFmpeg bitrate such as "128k", "2M" or "96000" in bits/s
+func parseBitrate(s string) int {
+	s = strings.TrimSpace(s)
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "k"), strings.HasSuffix(s, "K"):
+		multiplier, s = 1000, s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		multiplier, s = 1000000, s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int(v * multiplier)
+}
+
+// encodeToTarget runs a bitrate-targeted encode: two passes where the encoder
+// supports it, followed by a size check that re-encodes once if the output is too big
+func (p *Processor) encodeToTarget(ctx context.Context, spec *OutputSpec, duration float64, opts ProcessOptions) error {
+	audioBitrate := 0
+	if !spec.DropAudio {
+		audioBitrate = parseBitrate(spec.AudioBitrate)
+	}
+	videoBitrate, err := targetVideoBitrate(spec.Rate, duration, audioBitrate)
+	if err != nil {
+		return err
+	}
+
+	// Pass logs live in a per-job scratch directory so concurrent jobs don't share them
+	scratch, err := os.MkdirTemp("", "passlog-"+opts.JobID+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create pass log directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	twoPass := supportsTwoPass(spec.VideoCodec)
+	spec.Rate.PassLogFile = filepath.Join(scratch, "ffmpeg2pass")
+	label := describeOperations(opts.Operations)
+
+	for attempt := 0; ; attempt++ {
+		spec.Rate.VideoBitrate = videoBitrate
+
+		if twoPass && attempt == 0 {
+			// The first pass's statistics stay valid for re-encodes at a lower bitrate
+			spec.Rate.Pass = 1
+			if err := p.runFFmpeg(ctx, spec.Args(), duration, label+" (pass 1/2)", passProgress(opts.OnProgress, 1)); err != nil {
+				return err
+			}
+		}
+		spec.Rate.Pass = 0
+		if twoPass {
+			spec.Rate.Pass = 2
+		}
+
+		p.logger.Info("Executing targeted encode",
+			zap.String("output", spec.OutputPath),
+			zap.Int("video_bitrate", videoBitrate),
+			zap.Int("audio_bitrate", audioBitrate),
+			zap.Int64("target_size", spec.Rate.TargetSize),
+			zap.Int("attempt", attempt+1),
+		)
+		if err := p.runFFmpeg(ctx, spec.Args(), duration, label, passProgress(opts.OnProgress, spec.Rate.Pass)); err != nil {
+			return err
+		}
+
+		if spec.Rate.TargetSize == 0 {
+			return nil
+		}
+		info, err := os.Stat(spec.OutputPath)
+		if err != nil {
+			return fmt.Errorf("failed to check output size: %w", err)
+		}
+		size := info.Size()
+		limit := int64(float64(spec.Rate.TargetSize) * (1 + targetSizeTolerance))
+		if size <= limit {
+			return nil
+		}
+		if attempt >= maxTargetRetries {
+			return fmt.Errorf("%w: output is %d bytes, target was %d", ErrTargetUnreachable, size, spec.Rate.TargetSize)
+		}
+
+		// Scale the video share down by the overshoot, with the same safety margin as the first try
+		videoBitrate = int(math.Floor(float64(videoBitrate) * float64(spec.Rate.TargetSize) / float64(size) * (1 - muxOverhead)))
+		if videoBitrate < minVideoBitrate {
+			return fmt.Errorf("%w: output is %d bytes, target was %d", ErrTargetUnreachable, size, spec.Rate.TargetSize)
+		}
+		p.logger.Warn("Output exceeds target size, re-encoding",
+			zap.Int64("size", size),
+			zap.Int64("target_size", spec.Rate.TargetSize),
+		)
+	}
+}
+
+// passProgress maps a pass's progress onto the whole two-pass encode, so the
+// percentage keeps rising: pass 1 covers 0-50%, pass 2 covers 50-100%
+func passProgress(onProgress func(ProgressUpdate), pass int) func(ProgressUpdate) {
+	if onProgress == nil || pass == 0 {
+		return onProgress
+	}
+	return func(u ProgressUpdate) {
+		u.Percent = (pass-1)*50 + u.Percent/2
+		if pass == 1 {
+			// Assume the second pass takes about as long as the first
+			u.ETA *= 2
+		}
+		onProgress(u)
+	}
+}
@@ -0,0 +1,54 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetVideoBitrate(t *testing.T) {
+	t.Run("size is spread over the duration minus audio", func(t *testing.T) {
+		// 25 MB over 200s = 1,000,000 bits/s, less 3% overhead and 128k audio
+		bitrate, err := targetVideoBitrate(RateControl{TargetSize: 25000000}, 200, 128000)
+		require.NoError(t, err)
+		assert.Equal(t, 842000, bitrate)
+	})
+
+	t.Run("bitrate target leaves room for audio", func(t *testing.T) {
+		bitrate, err := targetVideoBitrate(RateControl{TargetBitrate: 1000000}, 0, 128000)
+		require.NoError(t, err)
+		assert.Equal(t, 872000, bitrate)
+	})
+
+	t.Run("unknown duration", func(t *testing.T) {
+		_, err := targetVideoBitrate(RateControl{TargetSize: 25000000}, 0, 128000)
+		assert.ErrorIs(t, err, ErrTargetUnreachable)
+	})
+
+	t.Run("target too small", func(t *testing.T) {
+		_, err := targetVideoBitrate(RateControl{TargetSize: 1000000}, 3600, 128000)
+		assert.ErrorIs(t, err, ErrTargetUnreachable)
+	})
+}
+
+func TestParseBitrate(t *testing.T) {
+	assert.Equal(t, 128000, parseBitrate("128k"))
+	assert.Equal(t, 2000000, parseBitrate("2M"))
+	assert.Equal(t, 96000, parseBitrate("96000"))
+	assert.Equal(t, 0, parseBitrate(""))
+}
+
+func TestPassProgress(t *testing.T) {
+	var got []ProgressUpdate
+	record := func(u ProgressUpdate) { got = append(got, u) }
+
+	passProgress(record, 1)(ProgressUpdate{Percent: 100, ETA: 0})
+	passProgress(record, 1)(ProgressUpdate{Percent: 50, ETA: 10})
+	passProgress(record, 2)(ProgressUpdate{Percent: 50, ETA: 10})
+	passProgress(record, 0)(ProgressUpdate{Percent: 40})
+
+	assert.Equal(t, []int{50, 25, 75, 40}, []int{got[0].Percent, got[1].Percent, got[2].Percent, got[3].Percent})
+	assert.Equal(t, 20, got[1].ETA)
+	assert.Equal(t, 10, got[2].ETA)
+}
@@ -0,0 +1,286 @@
+package media
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// Waveform peak files, written next to each other so either format can be served
+const (
+	WaveformJSONName = "peaks.json"
+	WaveformDatName  = "peaks.dat"
+)
+
+// Waveform resolution limits. Each min/max pair covers SamplesPerPixel samples,
+// so the lower bound caps the size of the peaks for long recordings.
+const (
+	DefaultSamplesPerPixel = 256
+	MinSamplesPerPixel     = 64
+	MaxSamplesPerPixel     = 65536
+
+	waveformSampleRate  = 44100 // Used when the audio stream's own rate isn't known
+	waveformMaxChannels = 8
+)
+
+// WaveformOptions select the resolution and layout of the generated peaks
+type WaveformOptions struct {
+	SamplesPerPixel int    `json:"samplesPerPixel"`
+	Channels        string `json:"channels"` // "mono" mixes all channels down, "split" keeps each one
+	Bits            int    `json:"bits"`     // 8 or 16
+}
+
+// Validate checks the options against the supported ranges
+func (o WaveformOptions) Validate() error {
+	if o.SamplesPerPixel < MinSamplesPerPixel || o.SamplesPerPixel > MaxSamplesPerPixel {
+		return fmt.Errorf("samplesPerPixel must be between %d and %d", MinSamplesPerPixel, MaxSamplesPerPixel)
+	}
+	if o.Channels != "mono" && o.Channels != "split" {
+		return errors.New("channels must be mono or split")
+	}
+	if o.Bits != 8 && o.Bits != 16 {
+		return errors.New("bits must be 8 or 16")
+	}
+	return nil
+}
+
+// Variant names the options, so each resolution is generated and stored once
+func (o WaveformOptions) Variant() string {
+	return fmt.Sprintf("%d-%s-%d", o.SamplesPerPixel, o.Channels, o.Bits)
+}
+
+// Waveform holds min/max peak pairs in the audiowaveform data format. Data is
+// interleaved per pixel: min and max of channel 0, then of channel 1, and so on.
+type Waveform struct {
+	Version         int     `json:"version"`
+	Channels        int     `json:"channels"`
+	SampleRate      int     `json:"sample_rate"`
+	SamplesPerPixel int     `json:"samples_per_pixel"`
+	Bits            int     `json:"bits"`
+	Length          int     `json:"length"` // Number of pixels
+	Data            []int16 `json:"data"`
+}
+
+// GenerateWaveform decodes the first audio stream of inputPath and writes its
+// peaks into dir, both as audiowaveform JSON and as its binary .dat format
+func (p *Processor) GenerateWaveform(ctx context.Context, inputPath, dir string, opts WaveformOptions) (*ProcessResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOperation, err)
+	}
+	info, err := p.Probe(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.AudioCodec == "" {
+		return nil, fmt.Errorf("%w: no audio stream to draw a waveform from", ErrInvalidOperation)
+	}
+
+	sampleRate, channels := waveformSampleRate, 1
+	for _, s := range info.Streams {
+		if s.Type == "audio" {
+			if s.SampleRate > 0 {
+				sampleRate = s.SampleRate
+			}
+			if opts.Channels == "split" && s.Channels > 0 {
+				channels = min(s.Channels, waveformMaxChannels)
+			}
+			break
+		}
+	}
+
+	args := waveformArgs(inputPath, sampleRate, channels)
+	p.logger.Info("Computing waveform",
+		zap.String("input", inputPath),
+		zap.String("variant", opts.Variant()),
+		zap.Strings("args", args),
+	)
+	wf, err := p.decodePeaks(ctx, args, newPeakBuilder(sampleRate, channels, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create waveform directory: %w", err)
+	}
+	encodeJSON := func(w io.Writer) error { return json.NewEncoder(w).Encode(wf) }
+	if err := writeWaveformFile(filepath.Join(dir, WaveformJSONName), encodeJSON); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := writeWaveformFile(filepath.Join(dir, WaveformDatName), wf.WriteDat); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return collectArtifacts(dir, WaveformJSONName)
+}
+
+// waveformArgs decodes the first audio stream to raw 16-bit PCM on stdout
+func waveformArgs(inputPath string, sampleRate, channels int) []string {
+	return []string{
+		"-v", "error", "-nostdin",
+		"-i", inputPath,
+		"-map", "0:a:0",
+		"-ac", strconv.Itoa(channels),
+		"-ar", strconv.Itoa(sampleRate),
+		"-f", "s16le", "-acodec", "pcm_s16le",
+		"pipe:1",
+	}
+}
+
+// decodePeaks runs FFmpeg and feeds its PCM output through the peak builder
+// as it arrives, so even long recordings are never held in memory
+func (p *Processor) decodePeaks(ctx context.Context, args []string, b *peakBuilder) (*Waveform, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.ffmpegPath, args...)
+	killOnCancel(cmd)
+	cmd.WaitDelay = ffmpegWaitDelay
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start FFmpeg: %w", err)
+	}
+
+	if err := b.consume(bufio.NewReaderSize(stdout, 64*1024)); err != nil {
+		// Nothing drains stdout any more, so FFmpeg would block on a full pipe
+		// and Wait would never return: stop it first
+		cancel()
+		cmd.Wait()
+		return nil, fmt.Errorf("failed to read decoded audio: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("FFmpeg audio decoding failed: %w", err)
+	}
+	return b.Waveform(), nil
+}
+
+// peakBuilder accumulates interleaved 16-bit samples into min/max pairs
+type peakBuilder struct {
+	wf       Waveform
+	min, max []int16
+	count    int // Frames in the current pixel
+}
+
+func newPeakBuilder(sampleRate, channels int, opts WaveformOptions) *peakBuilder {
+	b := &peakBuilder{
+		wf: Waveform{
+			Version:         2,
+			Channels:        channels,
+			SampleRate:      sampleRate,
+			SamplesPerPixel: opts.SamplesPerPixel,
+			Bits:            opts.Bits,
+		},
+		min: make([]int16, channels),
+		max: make([]int16, channels),
+	}
+	b.reset()
+	return b
+}
+
+// consume reads little-endian interleaved PCM until EOF
+func (b *peakBuilder) consume(r io.Reader) error {
+	frame := make([]byte, 2*b.wf.Channels)
+	for {
+		if _, err := io.ReadFull(r, frame); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil // A trailing partial frame is dropped
+			}
+			return err
+		}
+		for c := 0; c < b.wf.Channels; c++ {
+			s := int16(binary.LittleEndian.Uint16(frame[2*c:]))
+			b.min[c] = min(b.min[c], s)
+			b.max[c] = max(b.max[c], s)
+		}
+		b.count++
+		if b.count == b.wf.SamplesPerPixel {
+			b.flush()
+		}
+	}
+}
+
+// Waveform returns the peaks, including a final partial pixel
+func (b *peakBuilder) Waveform() *Waveform {
+	if b.count > 0 {
+		b.flush()
+	}
+	if b.wf.Data == nil {
+		b.wf.Data = []int16{}
+	}
+	return &b.wf
+}
+
+func (b *peakBuilder) flush() {
+	for c := range b.min {
+		lo, hi := b.min[c], b.max[c]
+		if b.wf.Bits == 8 {
+			// Same scaling as audiowaveform: keep the high byte
+			lo, hi = lo>>8, hi>>8
+		}
+		b.wf.Data = append(b.wf.Data, lo, hi)
+	}
+	b.wf.Length++
+	b.reset()
+}
+
+func (b *peakBuilder) reset() {
+	for c := range b.min {
+		b.min[c], b.max[c] = 32767, -32768
+	}
+	b.count = 0
+}
+
+// WriteDat writes the waveform in audiowaveform's binary format (version 2):
+// a little-endian header followed by int8 or int16 peaks
+func (w *Waveform) WriteDat(out io.Writer) error {
+	var flags uint32
+	if w.Bits == 8 {
+		flags = 1
+	}
+	header := []any{int32(2), flags, int32(w.SampleRate), int32(w.SamplesPerPixel), uint32(w.Length), int32(w.Channels)}
+	for _, v := range header {
+		if err := binary.Write(out, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	if w.Bits == 8 {
+		data := make([]int8, len(w.Data))
+		for i, v := range w.Data {
+			data[i] = int8(v)
+		}
+		return binary.Write(out, binary.LittleEndian, data)
+	}
+	return binary.Write(out, binary.LittleEndian, w.Data)
+}
+
+// writeWaveformFile creates path and fills it through a buffered writer
+func writeWaveformFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,84 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pcm encodes samples as little-endian 16-bit PCM
+func pcm(samples ...int16) *bytes.Reader {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, samples)
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestPeakBuilder(t *testing.T) {
+	t.Run("mono with a partial last pixel", func(t *testing.T) {
+		b := newPeakBuilder(8000, 1, WaveformOptions{SamplesPerPixel: 3, Channels: "mono", Bits: 16})
+		require.NoError(t, b.consume(pcm(10, -20, 5, 300, 200, -100, 7)))
+
+		wf := b.Waveform()
+		assert.Equal(t, 3, wf.Length)
+		assert.Equal(t, []int16{-20, 10, -100, 300, 7, 7}, wf.Data)
+		assert.Equal(t, 8000, wf.SampleRate)
+	})
+
+	t.Run("split channels are interleaved per pixel", func(t *testing.T) {
+		b := newPeakBuilder(8000, 2, WaveformOptions{SamplesPerPixel: 2, Channels: "split", Bits: 16})
+		require.NoError(t, b.consume(pcm(1, -1, 4, -4, 9, 0, -9, 3)))
+
+		assert.Equal(t, []int16{1, 4, -4, -1, -9, 9, 0, 3}, b.Waveform().Data)
+	})
+
+	t.Run("8 bit keeps the high byte", func(t *testing.T) {
+		b := newPeakBuilder(8000, 1, WaveformOptions{SamplesPerPixel: 4, Channels: "mono", Bits: 8})
+		require.NoError(t, b.consume(pcm(-32768, 32767, 256, -257)))
+
+		assert.Equal(t, []int16{-128, 127}, b.Waveform().Data)
+	})
+
+	t.Run("silence of no length", func(t *testing.T) {
+		b := newPeakBuilder(8000, 1, WaveformOptions{SamplesPerPixel: 4, Channels: "mono", Bits: 8})
+		require.NoError(t, b.consume(pcm()))
+
+		wf := b.Waveform()
+		assert.Equal(t, 0, wf.Length)
+		assert.NotNil(t, wf.Data, "encodes as an empty array")
+	})
+}
+
+func TestWaveformWriteDat(t *testing.T) {
+	wf := &Waveform{Version: 2, Channels: 1, SampleRate: 44100, SamplesPerPixel: 256, Bits: 8, Length: 2, Data: []int16{-3, 5, -128, 127}}
+	var buf bytes.Buffer
+	require.NoError(t, wf.WriteDat(&buf))
+
+	header := make([]int32, 6)
+	require.NoError(t, binary.Read(&buf, binary.LittleEndian, header))
+	assert.Equal(t, []int32{2, 1, 44100, 256, 2, 1}, header)
+	assert.Equal(t, []byte{0xfd, 0x05, 0x80, 0x7f}, buf.Bytes())
+
+	wf.Bits = 16
+	buf.Reset()
+	require.NoError(t, wf.WriteDat(&buf))
+	assert.Equal(t, 24+8, buf.Len())
+}
+
+func TestWaveformOptions(t *testing.T) {
+	opts := WaveformOptions{SamplesPerPixel: 512, Channels: "split", Bits: 16}
+	assert.NoError(t, opts.Validate())
+	assert.Equal(t, "512-split-16", opts.Variant())
+
+	assert.Error(t, WaveformOptions{SamplesPerPixel: 8, Channels: "mono", Bits: 8}.Validate())
+	assert.Error(t, WaveformOptions{SamplesPerPixel: 256, Channels: "stereo", Bits: 8}.Validate())
+	assert.Error(t, WaveformOptions{SamplesPerPixel: 256, Channels: "mono", Bits: 12}.Validate())
+}
+
+func TestWaveformArgs(t *testing.T) {
+	args := strings.Join(waveformArgs("in.mp4", 48000, 2), " ")
+	assert.Contains(t, args, "-i in.mp4 -map 0:a:0 -ac 2 -ar 48000 -f s16le -acodec pcm_s16le pipe:1")
+}
@@ -242,3 +242,16 @@ func ConversionMinutesFromDuration(seconds float64) int {
 	}
 	return int(math.Ceil(seconds / 60))
 }
+
+// imageMegapixelsPerMinute is how many megapixels of still images cost one conversion minute
+const imageMegapixelsPerMinute = 24
+
+// ConversionMinutesForImage computes conversion minutes for a still image, which
+// has no duration: one minute per started 24 megapixels, so typical photos cost 1
+func ConversionMinutesForImage(width, height int) int {
+	megapixels := float64(width) * float64(height) / 1e6
+	if megapixels <= imageMegapixelsPerMinute {
+		return 1
+	}
+	return int(math.Ceil(megapixels / imageMegapixelsPerMinute))
+}
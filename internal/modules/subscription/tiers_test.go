@@ -184,3 +184,11 @@ func TestConversionMinutesLimits(t *testing.T) {
 		assert.True(t, standard < pro, "standard tier should have fewer conversion minutes than pro")
 	})
 }
+
+func TestConversionMinutesForImage(t *testing.T) {
+	assert.Equal(t, 1, ConversionMinutesForImage(0, 0))
+	assert.Equal(t, 1, ConversionMinutesForImage(4032, 3024))  // 12 MP phone photo
+	assert.Equal(t, 1, ConversionMinutesForImage(6000, 4000))  // Exactly 24 MP
+	assert.Equal(t, 2, ConversionMinutesForImage(8256, 5504))  // 45 MP
+	assert.Equal(t, 5, ConversionMinutesForImage(16000, 7000)) // 112 MP panorama
+}
@@ -0,0 +1,113 @@
+package subtitles
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// assEventFormat is the Dialogue field order written to ASS files
+const assEventFormat = "Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text"
+
+// defaultASSHeader is used when converting other formats to ASS
+const defaultASSHeader = `[Script Info]
+ScriptType: v4.00+
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+PlayResX: 1920
+PlayResY: 1080
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,64,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,3,0,2,60,60,50,1
+`
+
+func parseASS(text string) (*Document, error) {
+	doc := &Document{Format: FormatASS}
+	header, events, ok := strings.Cut(text, "[Events]")
+	if !ok {
+		return nil, fmt.Errorf("%w: missing [Events] section", ErrInvalidSubtitle)
+	}
+	doc.Header = strings.TrimRight(header, "\n") + "\n"
+
+	var fields []string
+	for _, line := range strings.Split(events, "\n") {
+		if strings.HasPrefix(line, "[") {
+			break // Next section (e.g. [Fonts])
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Format":
+			fields = strings.Split(value, ",")
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+		case "Dialogue":
+			if fields == nil {
+				return nil, fmt.Errorf("%w: Dialogue before Format line", ErrInvalidSubtitle)
+			}
+			cue, err := parseDialogue(fields, value)
+			if err != nil {
+				return nil, fmt.Errorf("cue %d: %w", len(doc.Cues)+1, err)
+			}
+			doc.Cues = append(doc.Cues, cue)
+		}
+	}
+	return doc, nil
+}
+
+// parseDialogue reads one Dialogue line; the last field (Text) may contain commas
+func parseDialogue(fields []string, value string) (Cue, error) {
+	values := strings.SplitN(strings.TrimLeft(value, " "), ",", len(fields))
+	if len(values) != len(fields) {
+		return Cue{}, fmt.Errorf("%w: Dialogue has %d fields, Format names %d", ErrInvalidSubtitle, len(values), len(fields))
+	}
+
+	var cue Cue
+	for i, name := range fields {
+		var err error
+		switch name {
+		case "Start":
+			cue.Start, err = parseTimestamp(values[i])
+		case "End":
+			cue.End, err = parseTimestamp(values[i])
+		case "Style":
+			cue.Style = strings.TrimSpace(values[i])
+		case "Text":
+			cue.Text = strings.ReplaceAll(values[i], `\N`, "\n")
+		}
+		if err != nil {
+			return Cue{}, err
+		}
+	}
+	return cue, nil
+}
+
+func writeASS(doc *Document) []byte {
+	var b strings.Builder
+	if doc.Format == FormatASS && doc.Header != "" {
+		b.WriteString(doc.Header)
+	} else {
+		b.WriteString(defaultASSHeader)
+	}
+	b.WriteString("\n[Events]\nFormat: " + assEventFormat + "\n")
+
+	for _, cue := range doc.Cues {
+		style := "Default"
+		if doc.Format == FormatASS && cue.Style != "" {
+			style = cue.Style
+		}
+		text := convertMarkup(cue.Text, doc.Format, FormatASS)
+		fmt.Fprintf(&b, "Dialogue: 0,%s,%s,%s,,0,0,0,,%s\n", assTimestamp(cue.Start), assTimestamp(cue.End), style,
+			strings.ReplaceAll(text, "\n", `\N`))
+	}
+	return []byte(b.String())
+}
+
+func assTimestamp(d time.Duration) string {
+	h, m, s, rem := clock(d)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", h, m, s, rem/(10*time.Millisecond))
+}
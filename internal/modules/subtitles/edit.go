@@ -0,0 +1,162 @@
+package subtitles
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalidEdit is returned for edits that don't fit the document
+var ErrInvalidEdit = errors.New("invalid subtitle edit")
+
+// Edit is one change to a document's cues. Cue numbers are 1-based, as in SRT files.
+type Edit struct {
+	Type string `json:"type"` // shift, scale, framerate, merge, split
+
+	Offset  float64 `json:"offset,omitempty"`  // shift: seconds, may be negative
+	Factor  float64 `json:"factor,omitempty"`  // scale: multiplier for every timestamp
+	FromFPS float64 `json:"fromFps,omitempty"` // framerate: rate the subtitles were timed for
+	ToFPS   float64 `json:"toFps,omitempty"`   // framerate: rate of the video they'll play with
+	From    int     `json:"from,omitempty"`    // merge: first cue
+	To      int     `json:"to,omitempty"`      // merge: last cue
+	Cue     int     `json:"cue,omitempty"`     // split: cue to split
+	At      float64 `json:"at,omitempty"`      // split: seconds; 0 splits in proportion to the text
+}
+
+// Apply runs edits against the document in order
+func Apply(doc *Document, edits []Edit) error {
+	for i, e := range edits {
+		var err error
+		switch e.Type {
+		case "shift":
+			doc.Shift(seconds(e.Offset))
+		case "scale":
+			err = doc.Scale(e.Factor)
+		case "framerate":
+			err = doc.ConvertFrameRate(e.FromFPS, e.ToFPS)
+		case "merge":
+			err = doc.Merge(e.From-1, e.To-1)
+		case "split":
+			err = doc.Split(e.Cue-1, seconds(e.At))
+		default:
+			err = fmt.Errorf("%w: unknown edit type %q", ErrInvalidEdit, e.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("edit %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Shift moves every cue by offset. Cues pushed entirely before zero are
+// dropped; cues straddling zero are cut to start at zero.
+func (d *Document) Shift(offset time.Duration) {
+	kept := d.Cues[:0]
+	for _, c := range d.Cues {
+		c.Start += offset
+		c.End += offset
+		if c.End <= 0 {
+			continue
+		}
+		if c.Start < 0 {
+			c.Start = 0
+		}
+		kept = append(kept, c)
+	}
+	d.Cues = kept
+}
+
+// Scale multiplies every timestamp by factor, e.g. to follow a sped-up video
+func (d *Document) Scale(factor float64) error {
+	if factor <= 0 {
+		return fmt.Errorf("%w: scale factor must be positive", ErrInvalidEdit)
+	}
+	for i := range d.Cues {
+		d.Cues[i].Start = time.Duration(float64(d.Cues[i].Start) * factor)
+		d.Cues[i].End = time.Duration(float64(d.Cues[i].End) * factor)
+	}
+	return nil
+}
+
+// ConvertFrameRate retimes subtitles made for a video at one frame rate to the
+// same video played at another (e.g. 23.976 to 25 for a PAL speed-up)
+func (d *Document) ConvertFrameRate(from, to float64) error {
+	if from <= 0 || to <= 0 {
+		return fmt.Errorf("%w: frame rates must be positive", ErrInvalidEdit)
+	}
+	return d.Scale(from / to)
+}
+
+// Merge joins cues first..last (0-based, inclusive) into one cue spanning them all
+func (d *Document) Merge(first, last int) error {
+	if first < 0 || last >= len(d.Cues) || first >= last {
+		return fmt.Errorf("%w: merge needs a range of at least two cues within 1-%d", ErrInvalidEdit, len(d.Cues))
+	}
+
+	merged := d.Cues[first]
+	for _, c := range d.Cues[first+1 : last+1] {
+		merged.Text += "\n" + c.Text
+		if c.End > merged.End {
+			merged.End = c.End
+		}
+	}
+	d.Cues = append(append(d.Cues[:first:first], merged), d.Cues[last+1:]...)
+	return nil
+}
+
+// Split divides cue i (0-based) in two: multi-line cues between their lines,
+// single lines at the word nearest the middle. The second cue starts at at,
+// or, when at is zero, at a time proportional to the text before the split.
+func (d *Document) Split(i int, at time.Duration) error {
+	if i < 0 || i >= len(d.Cues) {
+		return fmt.Errorf("%w: cue %d does not exist", ErrInvalidEdit, i+1)
+	}
+	c := d.Cues[i]
+
+	first, second, ok := splitText(c.Text)
+	if !ok {
+		return fmt.Errorf("%w: cue %d has a single word", ErrInvalidEdit, i+1)
+	}
+	if at == 0 {
+		total := utf8.RuneCountInString(first) + utf8.RuneCountInString(second)
+		at = c.Start + (c.End-c.Start)*time.Duration(utf8.RuneCountInString(first))/time.Duration(total)
+	}
+	if at <= c.Start || at >= c.End {
+		return fmt.Errorf("%w: split point must fall inside cue %d", ErrInvalidEdit, i+1)
+	}
+
+	head, tail := c, c
+	head.End, head.Text = at, first
+	tail.Start, tail.Text = at, second
+	tail.ID = ""
+	d.Cues = append(d.Cues[:i], append([]Cue{head, tail}, d.Cues[i+1:]...)...)
+	return nil
+}
+
+func splitText(text string) (string, string, bool) {
+	lines := strings.Split(text, "\n")
+	if len(lines) > 1 {
+		half := (len(lines) + 1) / 2
+		return strings.Join(lines[:half], "\n"), strings.Join(lines[half:], "\n"), true
+	}
+
+	words := strings.Fields(text)
+	if len(words) < 2 {
+		return "", "", false
+	}
+	// Break before the word that crosses the middle of the line
+	middle, length := utf8.RuneCountInString(text)/2, 0
+	for n, w := range words[:len(words)-1] {
+		length += utf8.RuneCountInString(w) + 1
+		if length >= middle {
+			return strings.Join(words[:n+1], " "), strings.Join(words[n+1:], " "), true
+		}
+	}
+	return strings.Join(words[:len(words)-1], " "), words[len(words)-1], true
+}
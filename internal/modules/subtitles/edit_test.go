@@ -0,0 +1,102 @@
+package subtitles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ms(n int) time.Duration {
+	return time.Duration(n) * time.Millisecond
+}
+
+func testDocument() *Document {
+	return &Document{
+		Format: FormatSRT,
+		Cues: []Cue{
+			{Start: ms(1000), End: ms(2000), Text: "One"},
+			{Start: ms(3000), End: ms(5000), Text: "Two words"},
+			{Start: ms(6000), End: ms(8000), Text: "Three\nlines\nhere"},
+		},
+	}
+}
+
+func TestShift(t *testing.T) {
+	doc := testDocument()
+	doc.Shift(ms(500))
+	assert.Equal(t, ms(1500), doc.Cues[0].Start)
+	assert.Equal(t, ms(8500), doc.Cues[2].End)
+
+	// Cues moved before zero are dropped or cut
+	doc = testDocument()
+	doc.Shift(-ms(3500))
+	require.Len(t, doc.Cues, 2)
+	assert.Equal(t, time.Duration(0), doc.Cues[0].Start)
+	assert.Equal(t, ms(1500), doc.Cues[0].End)
+	assert.Equal(t, "Two words", doc.Cues[0].Text)
+}
+
+func TestScaleAndFrameRate(t *testing.T) {
+	doc := testDocument()
+	require.NoError(t, doc.Scale(0.5))
+	assert.Equal(t, ms(500), doc.Cues[0].Start)
+	assert.Equal(t, ms(4000), doc.Cues[2].End)
+
+	doc = testDocument()
+	require.NoError(t, doc.ConvertFrameRate(25, 23.976))
+	assert.Equal(t, time.Duration(float64(ms(8000))*25/23.976), doc.Cues[2].End)
+
+	assert.ErrorIs(t, testDocument().Scale(0), ErrInvalidEdit)
+	assert.ErrorIs(t, testDocument().ConvertFrameRate(25, 0), ErrInvalidEdit)
+}
+
+func TestMerge(t *testing.T) {
+	doc := testDocument()
+	require.NoError(t, doc.Merge(0, 1))
+	require.Len(t, doc.Cues, 2)
+	assert.Equal(t, Cue{Start: ms(1000), End: ms(5000), Text: "One\nTwo words"}, doc.Cues[0])
+	assert.Equal(t, "Three\nlines\nhere", doc.Cues[1].Text)
+
+	assert.ErrorIs(t, testDocument().Merge(1, 1), ErrInvalidEdit)
+	assert.ErrorIs(t, testDocument().Merge(1, 3), ErrInvalidEdit)
+}
+
+func TestSplit(t *testing.T) {
+	// A single line splits between words, timed by text length
+	doc := testDocument()
+	require.NoError(t, doc.Split(1, 0))
+	require.Len(t, doc.Cues, 4)
+	assert.Equal(t, Cue{Start: ms(3000), End: ms(3750), Text: "Two"}, doc.Cues[1])
+	assert.Equal(t, Cue{Start: ms(3750), End: ms(5000), Text: "words"}, doc.Cues[2])
+
+	// Multiple lines split between lines, at the given time
+	doc = testDocument()
+	require.NoError(t, doc.Split(2, ms(7000)))
+	require.Len(t, doc.Cues, 4)
+	assert.Equal(t, "Three\nlines", doc.Cues[2].Text)
+	assert.Equal(t, ms(7000), doc.Cues[2].End)
+	assert.Equal(t, "here", doc.Cues[3].Text)
+	assert.Equal(t, ms(7000), doc.Cues[3].Start)
+
+	assert.ErrorIs(t, testDocument().Split(0, 0), ErrInvalidEdit)
+	assert.ErrorIs(t, testDocument().Split(1, ms(9000)), ErrInvalidEdit)
+	assert.ErrorIs(t, testDocument().Split(5, 0), ErrInvalidEdit)
+}
+
+func TestApply(t *testing.T) {
+	doc := testDocument()
+	err := Apply(doc, []Edit{
+		{Type: "merge", From: 1, To: 2},
+		{Type: "shift", Offset: 1.5},
+	})
+	require.NoError(t, err)
+	require.Len(t, doc.Cues, 2)
+	assert.Equal(t, ms(2500), doc.Cues[0].Start)
+	assert.Equal(t, ms(6500), doc.Cues[0].End)
+
+	err = Apply(testDocument(), []Edit{{Type: "shift", Offset: 1}, {Type: "reverse"}})
+	assert.ErrorIs(t, err, ErrInvalidEdit)
+	assert.Contains(t, err.Error(), "edit 2")
+}
@@ -0,0 +1,78 @@
+package subtitles
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlTag matches SRT/WebVTT tags such as <i>, </b>, <font color="#ff0000"> or <c.yellow>
+	htmlTag = regexp.MustCompile(`</?([a-zA-Z]+)[^>]*>`)
+	// fontColor extracts the color of an SRT <font> tag
+	fontColor = regexp.MustCompile(`color\s*=\s*["']?#?([0-9a-fA-F]{6})`)
+	// assOverride matches an ASS override block such as {\i1\pos(10,20)}
+	assOverride = regexp.MustCompile(`\{[^}]*\}`)
+	// assTag matches one tag inside an override block
+	assTag = regexp.MustCompile(`\\(i|b|u)([01])`)
+)
+
+// convertMarkup translates inline styling between formats. Italic, bold and
+// underline survive every conversion, SRT font colors become ASS colors, and
+// everything else the target can't express is dropped.
+func convertMarkup(text string, from, to Format) string {
+	if from == to {
+		return text
+	}
+	if from == FormatASS {
+		return assToHTML(text)
+	}
+	if to == FormatASS {
+		return htmlToASS(text)
+	}
+	// SRT and WebVTT share <b>, <i> and <u>
+	return htmlTag.ReplaceAllStringFunc(text, func(tag string) string {
+		switch strings.ToLower(htmlTag.FindStringSubmatch(tag)[1]) {
+		case "b", "i", "u":
+			return strings.ToLower(tag)
+		}
+		return ""
+	})
+}
+
+func assToHTML(text string) string {
+	text = assOverride.ReplaceAllStringFunc(text, func(block string) string {
+		var out strings.Builder
+		for _, m := range assTag.FindAllStringSubmatch(block, -1) {
+			if m[2] == "1" {
+				out.WriteString("<" + m[1] + ">")
+			} else {
+				out.WriteString("</" + m[1] + ">")
+			}
+		}
+		return out.String()
+	})
+	text = strings.ReplaceAll(text, `\h`, " ")
+	return strings.ReplaceAll(text, `\n`, " ")
+}
+
+func htmlToASS(text string) string {
+	return htmlTag.ReplaceAllStringFunc(text, func(tag string) string {
+		closing := strings.HasPrefix(tag, "</")
+		switch name := strings.ToLower(htmlTag.FindStringSubmatch(tag)[1]); name {
+		case "b", "i", "u":
+			if closing {
+				return `{\` + name + `0}`
+			}
+			return `{\` + name + `1}`
+		case "font":
+			if closing {
+				return `{\c}`
+			}
+			if m := fontColor.FindStringSubmatch(tag); m != nil {
+				rgb := strings.ToUpper(m[1])
+				return `{\c&H` + rgb[4:6] + rgb[2:4] + rgb[0:2] + `&}`
+			}
+		}
+		return ""
+	})
+}
@@ -0,0 +1,252 @@
+package subtitles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nextconvert/backend/internal/modules/jobs"
+	"github.com/nextconvert/backend/internal/shared/database"
+	"github.com/nextconvert/backend/internal/shared/storage"
+	"go.uber.org/zap"
+)
+
+// MaxFileSize bounds the subtitle files the service reads into memory
+const MaxFileSize = 10 << 20
+
+var (
+	// ErrNotFound is returned for unknown files
+	ErrNotFound = errors.New("file not found")
+	// ErrTooLarge is returned for files over MaxFileSize
+	ErrTooLarge = errors.New("subtitle file too large")
+	// ErrNoSubtitleStream is returned when a video has no (text) subtitle stream to extract
+	ErrNoSubtitleStream = errors.New("no subtitle stream")
+)
+
+// bitmapCodecs are subtitle codecs that are pictures, not text
+var bitmapCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true,
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+	"xsub":              true,
+}
+
+// StoredFile is a subtitle file the service wrote to ZoneOutput
+type StoredFile struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size"`
+	Format   Format `json:"format"`
+	CueCount int    `json:"cueCount"`
+}
+
+// ConvertParams describes a conversion of an uploaded subtitle file
+type ConvertParams struct {
+	UserID string
+	FileID string
+	Format Format // Target format; empty keeps the source format
+	Edits  []Edit
+}
+
+// ExtractParams describes extracting a subtitle stream from an uploaded video
+type ExtractParams struct {
+	UserID string
+	FileID string
+	Stream int    // Index among the file's subtitle streams
+	Format Format // Empty means SRT
+}
+
+// Service converts, retimes and extracts subtitle files
+type Service struct {
+	db      *database.Postgres
+	storage *storage.Service
+	jobs    *jobs.Module // Queues extractions for the workers
+	logger  *zap.Logger
+}
+
+// NewService creates a new subtitle service
+func NewService(db *database.Postgres, storage *storage.Service, jobsModule *jobs.Module, logger *zap.Logger) *Service {
+	return &Service{
+		db:      db,
+		storage: storage,
+		jobs:    jobsModule,
+		logger:  logger,
+	}
+}
+
+// storedFile is the files row of an input
+type storedFile struct {
+	Name        string
+	StoragePath string
+	Size        int64
+	Metadata    []byte
+}
+
+func (s *Service) getFile(ctx context.Context, fileID string) (*storedFile, error) {
+	var f storedFile
+	err := s.db.Pool.QueryRow(ctx,
+		"SELECT original_name, storage_path, size_bytes, metadata FROM files WHERE id = $1", fileID,
+	).Scan(&f.Name, &f.StoragePath, &f.Size, &f.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return &f, nil
+}
+
+// Load reads and parses an uploaded subtitle file
+func (s *Service) Load(ctx context.Context, fileID string) (*Document, error) {
+	doc, _, err := s.load(ctx, fileID)
+	return doc, err
+}
+
+func (s *Service) load(ctx context.Context, fileID string) (*Document, *storedFile, error) {
+	file, err := s.getFile(ctx, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	format, err := FormatFromFileName(file.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file.Size > MaxFileSize {
+		return nil, nil, ErrTooLarge
+	}
+
+	reader, err := s.storage.Retrieve(ctx, file.StoragePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read subtitle file: %w", err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, MaxFileSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read subtitle file: %w", err)
+	}
+	if len(data) > MaxFileSize {
+		return nil, nil, ErrTooLarge
+	}
+
+	doc, err := Parse(data, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, file, nil
+}
+
+// Convert applies edits to an uploaded subtitle file and stores the result,
+// in the requested format, as a new output file
+func (s *Service) Convert(ctx context.Context, params ConvertParams) (*StoredFile, error) {
+	doc, file, err := s.load(ctx, params.FileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := Apply(doc, params.Edits); err != nil {
+		return nil, err
+	}
+
+	format := params.Format
+	if format == "" {
+		format = doc.Format
+	}
+	data, err := Write(doc, format)
+	if err != nil {
+		return nil, err
+	}
+	return s.store(ctx, params.UserID, outputName(file.Name, "", format), format, data, len(doc.Cues))
+}
+
+// Extract queues a job that copies one subtitle stream out of an uploaded
+// video into a standalone subtitle file. FFmpeg runs on a worker, like every
+// other job; missing and bitmap streams (Blu-ray PGS, DVD) are rejected first.
+func (s *Service) Extract(ctx context.Context, params ExtractParams) (*jobs.Job, error) {
+	file, err := s.getFile(ctx, params.FileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSubtitleStream(file.Metadata, params.Stream); err != nil {
+		return nil, err
+	}
+	format := params.Format
+	if format == "" {
+		format = FormatSRT
+	}
+
+	return s.jobs.CreateJob(ctx, jobs.CreateJobParams{
+		UserID:      params.UserID,
+		InputFileID: params.FileID,
+		Operations: []jobs.Operation{{
+			Type:   "extractSubtitles",
+			Params: map[string]interface{}{"stream": params.Stream},
+		}},
+		OutputFormat:   string(format),
+		OutputFileName: outputName(file.Name, "."+strconv.Itoa(params.Stream), format),
+	})
+}
+
+// checkSubtitleStream uses the upload's probed metadata, when there is any, to
+// reject missing and bitmap subtitle streams before running FFmpeg
+func checkSubtitleStream(metadata []byte, stream int) error {
+	var info struct {
+		Streams []struct {
+			Type  string `json:"type"`
+			Codec string `json:"codec"`
+		} `json:"streams"`
+	}
+	if len(metadata) == 0 || json.Unmarshal(metadata, &info) != nil || len(info.Streams) == 0 {
+		return nil
+	}
+
+	n := 0
+	for _, st := range info.Streams {
+		if st.Type != "subtitle" {
+			continue
+		}
+		if n == stream {
+			if bitmapCodecs[st.Codec] {
+				return fmt.Errorf("%w: stream %d is %s, a bitmap format", ErrNoSubtitleStream, stream, st.Codec)
+			}
+			return nil
+		}
+		n++
+	}
+	return fmt.Errorf("%w: the file has %d subtitle streams", ErrNoSubtitleStream, n)
+}
+
+// outputName derives an output file name from the input's, e.g. movie.mkv -> movie.2.vtt
+func outputName(original, suffix string, format Format) string {
+	return strings.TrimSuffix(original, filepath.Ext(original)) + suffix + "." + string(format)
+}
+
+// store writes a subtitle file to ZoneOutput and records it for the user
+func (s *Service) store(ctx context.Context, userID, name string, format Format, data []byte, cueCount int) (*StoredFile, error) {
+	info, err := s.storage.Store(ctx, storage.ZoneOutput, name, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Pool.Exec(ctx, `
+		INSERT INTO files (id, user_id, original_name, storage_path, mime_type, size_bytes, zone, media_type, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 'subtitle', $8, NOW())
+	`, info.ID, userID, name, info.Path, format.MimeType(), info.Size, string(storage.ZoneOutput), info.ExpiresAt)
+	if err != nil {
+		if delErr := s.storage.Delete(ctx, info.Path); delErr != nil {
+			s.logger.Warn("Failed to delete unrecorded subtitle file", zap.String("path", info.Path), zap.Error(delErr))
+		}
+		return nil, fmt.Errorf("failed to record subtitle file: %w", err)
+	}
+
+	return &StoredFile{
+		ID:       info.ID,
+		Name:     name,
+		MimeType: format.MimeType(),
+		Size:     info.Size,
+		Format:   format,
+		CueCount: cueCount,
+	}, nil
+}
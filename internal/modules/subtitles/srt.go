@@ -0,0 +1,49 @@
+package subtitles
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+func parseSRT(text string) (*Document, error) {
+	doc := &Document{Format: FormatSRT}
+	for _, block := range blocks(text) {
+		// The cue number is optional in practice; the timing line is not
+		timing := 0
+		for timing < len(block) && !strings.Contains(block[timing], "-->") {
+			timing++
+		}
+		if timing == len(block) {
+			continue
+		}
+
+		start, end, _, err := parseTiming(block[timing])
+		if err != nil {
+			return nil, fmt.Errorf("cue %d: %w", len(doc.Cues)+1, err)
+		}
+		doc.Cues = append(doc.Cues, Cue{
+			Start: start,
+			End:   end,
+			Text:  strings.Join(block[timing+1:], "\n"),
+		})
+	}
+	if len(doc.Cues) == 0 {
+		return nil, fmt.Errorf("%w: no cues found", ErrInvalidSubtitle)
+	}
+	return doc, nil
+}
+
+func writeSRT(doc *Document) []byte {
+	var b strings.Builder
+	for i, cue := range doc.Cues {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, srtTimestamp(cue.Start), srtTimestamp(cue.End),
+			convertMarkup(cue.Text, doc.Format, FormatSRT))
+	}
+	return []byte(b.String())
+}
+
+func srtTimestamp(d time.Duration) string {
+	h, m, s, rem := clock(d)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", h, m, s, rem/time.Millisecond)
+}
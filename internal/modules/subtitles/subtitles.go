@@ -0,0 +1,191 @@
+// Package subtitles reads, edits and writes SRT, WebVTT and ASS/SSA subtitle
+// files in pure Go. Only extracting subtitle streams from videos needs FFmpeg.
+package subtitles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a subtitle file format
+type Format string
+
+// Supported formats. SSA files are read as ASS and written back as ASS.
+const (
+	FormatSRT Format = "srt"
+	FormatVTT Format = "vtt"
+	FormatASS Format = "ass"
+)
+
+var (
+	// ErrUnsupportedFormat is returned for formats other than SRT, WebVTT and ASS/SSA
+	ErrUnsupportedFormat = errors.New("unsupported subtitle format")
+	// ErrInvalidSubtitle is returned for files that can't be parsed
+	ErrInvalidSubtitle = errors.New("invalid subtitle file")
+)
+
+// Cue is one timed subtitle
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string // Lines separated by "\n", in the document format's markup
+
+	// Format-specific attributes, kept when the output format is the same
+	ID       string // WebVTT cue identifier
+	Settings string // WebVTT cue settings (position, alignment)
+	Style    string // ASS style name
+}
+
+// Document is a parsed subtitle file
+type Document struct {
+	Format Format
+	Header string // ASS script info and styles, everything before [Events]
+	Cues   []Cue
+}
+
+// ParseFormat maps a format name or file extension (e.g. "webvtt", ".ssa") to a Format
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "srt", "subrip":
+		return FormatSRT, nil
+	case "vtt", "webvtt":
+		return FormatVTT, nil
+	case "ass", "ssa":
+		return FormatASS, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, name)
+}
+
+// FormatFromFileName returns the format of a subtitle file name
+func FormatFromFileName(name string) (Format, error) {
+	return ParseFormat(filepath.Ext(name))
+}
+
+// MimeType returns the MIME type files of the format are stored with
+func (f Format) MimeType() string {
+	switch f {
+	case FormatSRT:
+		return "application/x-subrip"
+	case FormatVTT:
+		return "text/vtt"
+	}
+	return "text/x-ssa"
+}
+
+// Parse reads a subtitle file in the given format
+func Parse(data []byte, format Format) (*Document, error) {
+	text := string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))) // UTF-8 BOM
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	switch format {
+	case FormatSRT:
+		return parseSRT(text)
+	case FormatVTT:
+		return parseVTT(text)
+	case FormatASS:
+		return parseASS(text)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
+
+// Write renders the document in the given format, converting text markup
+// and dropping attributes the target format has no place for
+func Write(doc *Document, format Format) ([]byte, error) {
+	switch format {
+	case FormatSRT:
+		return writeSRT(doc), nil
+	case FormatVTT:
+		return writeVTT(doc), nil
+	case FormatASS:
+		return writeASS(doc), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
+
+// parseTimestamp reads "hh:mm:ss,mmm", "hh:mm:ss.mmm", "mm:ss.mmm" and ASS's "h:mm:ss.cc"
+func parseTimestamp(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	clock, frac := s, ""
+	if i := strings.LastIndexAny(s, ",."); i >= 0 {
+		clock, frac = s[:i], s[i+1:]
+	}
+
+	parts := strings.Split(clock, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("%w: bad timestamp %q", ErrInvalidSubtitle, s)
+	}
+	var d time.Duration
+	units := []time.Duration{time.Second, time.Minute, time.Hour}
+	for i := range parts {
+		n, err := strconv.Atoi(parts[len(parts)-1-i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%w: bad timestamp %q", ErrInvalidSubtitle, s)
+		}
+		d += time.Duration(n) * units[i]
+	}
+
+	if frac != "" {
+		f, err := strconv.ParseFloat("0."+frac, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: bad timestamp %q", ErrInvalidSubtitle, s)
+		}
+		d += time.Duration(f*1000+0.5) * time.Millisecond
+	}
+	return d, nil
+}
+
+// parseTiming reads a "start --> end" line, returning anything after the end
+// timestamp (WebVTT cue settings)
+func parseTiming(line string) (start, end time.Duration, rest string, err error) {
+	left, right, ok := strings.Cut(line, "-->")
+	if !ok {
+		return 0, 0, "", fmt.Errorf("%w: bad timing line %q", ErrInvalidSubtitle, line)
+	}
+	right = strings.TrimSpace(right)
+	endField, rest, _ := strings.Cut(right, " ")
+
+	if start, err = parseTimestamp(left); err != nil {
+		return 0, 0, "", err
+	}
+	if end, err = parseTimestamp(endField); err != nil {
+		return 0, 0, "", err
+	}
+	return start, end, strings.TrimSpace(rest), nil
+}
+
+// clock splits a non-negative duration into its clock fields
+func clock(d time.Duration) (h, m, s int, rem time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h = int(d / time.Hour)
+	m = int(d % time.Hour / time.Minute)
+	s = int(d % time.Minute / time.Second)
+	return h, m, s, d % time.Second
+}
+
+// blocks splits text into blank-line separated blocks of lines
+func blocks(text string) [][]string {
+	var out [][]string
+	var current []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				out = append(out, current)
+				current = nil
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		out = append(out, current)
+	}
+	return out
+}
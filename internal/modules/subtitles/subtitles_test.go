@@ -0,0 +1,229 @@
+package subtitles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleSRT = "\xef\xbb\xbf1\r\n00:00:01,500 --> 00:00:03,000\r\n<i>Hello</i> there\r\n\r\n2\r\n00:01:02,250 --> 00:01:04,000\r\n<font color=\"#FF8000\">Two</font>\r\nlines\r\n"
+
+const sampleVTT = `WEBVTT - sample
+
+NOTE this is ignored
+
+intro
+00:01.500 --> 00:03.000 align:start position:10%
+<b>Hello</b> <c.yellow>there</c>
+
+00:01:02.250 --> 00:01:04.000
+Two
+lines
+`
+
+const sampleASS = `[Script Info]
+ScriptType: v4.00+
+PlayResX: 1280
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize
+Style: Sign,Arial,40
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Comment: 0,0:00:00.00,0:00:01.00,Sign,,0,0,0,,ignored
+Dialogue: 0,0:00:01.50,0:00:03.00,Sign,,0,0,0,,{\i1}Hello{\i0}, there
+Dialogue: 0,0:01:02.25,0:01:04.00,Default,,0,0,0,,{\pos(10,20)}Two\Nlines
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format Format
+		texts  []string
+	}{
+		{"srt", sampleSRT, FormatSRT, []string{"<i>Hello</i> there", "<font color=\"#FF8000\">Two</font>\nlines"}},
+		{"vtt", sampleVTT, FormatVTT, []string{"<b>Hello</b> <c.yellow>there</c>", "Two\nlines"}},
+		{"ass", sampleASS, FormatASS, []string{`{\i1}Hello{\i0}, there`, `{\pos(10,20)}Two` + "\nlines"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.data), tt.format)
+			require.NoError(t, err)
+			require.Len(t, doc.Cues, 2)
+			assert.Equal(t, 1500*time.Millisecond, doc.Cues[0].Start)
+			assert.Equal(t, 3*time.Second, doc.Cues[0].End)
+			assert.Equal(t, 62250*time.Millisecond, doc.Cues[1].Start)
+			assert.Equal(t, 64*time.Second, doc.Cues[1].End)
+			for i, text := range tt.texts {
+				assert.Equal(t, text, doc.Cues[i].Text)
+			}
+		})
+	}
+}
+
+func TestParseFormatSpecificAttributes(t *testing.T) {
+	vtt, err := Parse([]byte(sampleVTT), FormatVTT)
+	require.NoError(t, err)
+	assert.Equal(t, "intro", vtt.Cues[0].ID)
+	assert.Equal(t, "align:start position:10%", vtt.Cues[0].Settings)
+
+	ass, err := Parse([]byte(sampleASS), FormatASS)
+	require.NoError(t, err)
+	assert.Equal(t, "Sign", ass.Cues[0].Style)
+	assert.Contains(t, ass.Header, "Style: Sign,Arial,40")
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format Format
+	}{
+		{"srt without cues", "hello\n", FormatSRT},
+		{"srt bad timestamp", "1\n00:00:xx,000 --> 00:00:02,000\nHi\n", FormatSRT},
+		{"vtt without header", "00:01.000 --> 00:02.000\nHi\n", FormatVTT},
+		{"ass without events", "[Script Info]\n", FormatASS},
+		{"ass dialogue before format", "[Events]\nDialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,Hi\n", FormatASS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data), tt.format)
+			assert.ErrorIs(t, err, ErrInvalidSubtitle)
+		})
+	}
+
+	_, err := Parse([]byte(sampleSRT), Format("sub"))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestWrite(t *testing.T) {
+	srt, err := Parse([]byte(sampleSRT), FormatSRT)
+	require.NoError(t, err)
+	vtt, err := Parse([]byte(sampleVTT), FormatVTT)
+	require.NoError(t, err)
+	ass, err := Parse([]byte(sampleASS), FormatASS)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		doc    *Document
+		format Format
+		want   string
+	}{
+		{
+			name:   "srt to vtt",
+			doc:    srt,
+			format: FormatVTT,
+			want:   "WEBVTT\n\n00:00:01.500 --> 00:00:03.000\n<i>Hello</i> there\n\n00:01:02.250 --> 00:01:04.000\nTwo\nlines\n\n",
+		},
+		{
+			name:   "vtt to vtt keeps ids and settings",
+			doc:    vtt,
+			format: FormatVTT,
+			want:   "WEBVTT\n\nintro\n00:00:01.500 --> 00:00:03.000 align:start position:10%\n<b>Hello</b> <c.yellow>there</c>\n\n00:01:02.250 --> 00:01:04.000\nTwo\nlines\n\n",
+		},
+		{
+			name:   "vtt to srt",
+			doc:    vtt,
+			format: FormatSRT,
+			want:   "1\n00:00:01,500 --> 00:00:03,000\n<b>Hello</b> there\n\n2\n00:01:02,250 --> 00:01:04,000\nTwo\nlines\n\n",
+		},
+		{
+			name:   "ass to srt",
+			doc:    ass,
+			format: FormatSRT,
+			want:   "1\n00:00:01,500 --> 00:00:03,000\n<i>Hello</i>, there\n\n2\n00:01:02,250 --> 00:01:04,000\nTwo\nlines\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Write(tt.doc, tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+		})
+	}
+}
+
+func TestWriteASS(t *testing.T) {
+	srt, err := Parse([]byte(sampleSRT), FormatSRT)
+	require.NoError(t, err)
+	data, err := Write(srt, FormatASS)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), defaultASSHeader)
+	assert.Contains(t, string(data), `Dialogue: 0,0:00:01.50,0:00:03.00,Default,,0,0,0,,{\i1}Hello{\i0} there`)
+	assert.Contains(t, string(data), `Dialogue: 0,0:01:02.25,0:01:04.00,Default,,0,0,0,,{\c&H0080FF&}Two{\c}\Nlines`)
+
+	// ASS to ASS keeps the script header and styles
+	ass, err := Parse([]byte(sampleASS), FormatASS)
+	require.NoError(t, err)
+	data, err = Write(ass, FormatASS)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Style: Sign,Arial,40")
+	assert.NotContains(t, string(data), "Comment:")
+	assert.Contains(t, string(data), `Dialogue: 0,0:00:01.50,0:00:03.00,Sign,,0,0,0,,{\i1}Hello{\i0}, there`)
+
+	// And parses back to the same cues
+	again, err := Parse(data, FormatASS)
+	require.NoError(t, err)
+	assert.Equal(t, ass.Cues, again.Cues)
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"00:00:01,000", time.Second},
+		{"01:02:03.456", time.Hour + 2*time.Minute + 3456*time.Millisecond},
+		{"02:03.4", 2*time.Minute + 3400*time.Millisecond},
+		{"0:00:05.07", 5070 * time.Millisecond},
+		{" 00:00:07 ", 7 * time.Second},
+	}
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, bad := range []string{"", "12", "aa:bb", "00:00:01,x"} {
+		_, err := parseTimestamp(bad)
+		assert.ErrorIs(t, err, ErrInvalidSubtitle, bad)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"srt": FormatSRT, "WebVTT": FormatVTT, ".vtt": FormatVTT, "ssa": FormatASS} {
+		got, err := ParseFormat(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	format, err := FormatFromFileName("Episode 1.en.ASS")
+	require.NoError(t, err)
+	assert.Equal(t, FormatASS, format)
+
+	_, err = FormatFromFileName("movie.mkv")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestCheckSubtitleStream(t *testing.T) {
+	metadata := []byte(`{"streams":[
+		{"type":"video","codec":"h264"},
+		{"type":"subtitle","codec":"subrip"},
+		{"type":"audio","codec":"aac"},
+		{"type":"subtitle","codec":"hdmv_pgs_subtitle"}
+	]}`)
+
+	assert.NoError(t, checkSubtitleStream(metadata, 0))
+	assert.ErrorIs(t, checkSubtitleStream(metadata, 1), ErrNoSubtitleStream)
+	assert.ErrorIs(t, checkSubtitleStream(metadata, 2), ErrNoSubtitleStream)
+	// Without probe data FFmpeg decides
+	assert.NoError(t, checkSubtitleStream(nil, 3))
+}
@@ -0,0 +1,67 @@
+package subtitles
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+func parseVTT(text string) (*Document, error) {
+	if !strings.HasPrefix(text, "WEBVTT") {
+		return nil, fmt.Errorf("%w: missing WEBVTT header", ErrInvalidSubtitle)
+	}
+
+	doc := &Document{Format: FormatVTT}
+	for i, block := range blocks(text) {
+		if i == 0 {
+			continue // Header block
+		}
+		switch first := block[0]; {
+		case strings.HasPrefix(first, "NOTE"), strings.HasPrefix(first, "STYLE"), strings.HasPrefix(first, "REGION"):
+			continue
+		}
+
+		var id string
+		timing := 0
+		if !strings.Contains(block[0], "-->") {
+			if len(block) < 2 || !strings.Contains(block[1], "-->") {
+				return nil, fmt.Errorf("%w: cue %d has no timing line", ErrInvalidSubtitle, len(doc.Cues)+1)
+			}
+			id, timing = block[0], 1
+		}
+
+		start, end, settings, err := parseTiming(block[timing])
+		if err != nil {
+			return nil, fmt.Errorf("cue %d: %w", len(doc.Cues)+1, err)
+		}
+		doc.Cues = append(doc.Cues, Cue{
+			Start:    start,
+			End:      end,
+			Text:     strings.Join(block[timing+1:], "\n"),
+			ID:       id,
+			Settings: settings,
+		})
+	}
+	return doc, nil
+}
+
+func writeVTT(doc *Document) []byte {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, cue := range doc.Cues {
+		if doc.Format == FormatVTT && cue.ID != "" {
+			b.WriteString(cue.ID + "\n")
+		}
+		fmt.Fprintf(&b, "%s --> %s", vttTimestamp(cue.Start), vttTimestamp(cue.End))
+		if doc.Format == FormatVTT && cue.Settings != "" {
+			b.WriteString(" " + cue.Settings)
+		}
+		fmt.Fprintf(&b, "\n%s\n\n", convertMarkup(cue.Text, doc.Format, FormatVTT))
+	}
+	return []byte(b.String())
+}
+
+func vttTimestamp(d time.Duration) string {
+	h, m, s, rem := clock(d)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, rem/time.Millisecond)
+}
@@ -0,0 +1,625 @@
+package uploads
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/nextconvert/backend/internal/shared/database"
+	"github.com/nextconvert/backend/internal/shared/storage"
+	"go.uber.org/zap"
+)
+
+// DefaultChunkSize is the chunk size handed to clients. S3 server-side assembly
+// requires every part except the last to be at least 5 MiB.
+const DefaultChunkSize int64 = 5 * 1024 * 1024
+
+// SessionTTL is how long an upload session stays resumable after its last activity
+const SessionTTL = 24 * time.Hour
+
+// Session statuses
+const (
+	StatusUploading  = "uploading"
+	StatusAssembling = "assembling"
+	StatusCompleted  = "completed"
+)
+
+var (
+	// ErrNotFound is returned for unknown sessions and sessions owned by another user
+	ErrNotFound = errors.New("upload session not found")
+	// ErrInvalidChunk is returned for out-of-range or wrongly sized chunks
+	ErrInvalidChunk = errors.New("invalid chunk")
+	// ErrChecksumMismatch is returned when a chunk or the assembled file fails hash verification
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrIncomplete is returned when completing an upload with missing chunks
+	ErrIncomplete = errors.New("upload incomplete")
+	// ErrSizeMismatch is returned when the assembled file is not the declared size
+	ErrSizeMismatch = errors.New("assembled size mismatch")
+	// ErrInvalidChecksum is returned for checksums that are not hex-encoded SHA-256
+	ErrInvalidChecksum = errors.New("checksum must be a hex-encoded SHA-256")
+	// ErrConflict is returned when the session is already being assembled or is completed
+	ErrConflict = errors.New("upload session is not accepting changes")
+)
+
+// Chunk describes a received chunk
+type Chunk struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"` // Hex SHA-256
+}
+
+// Session is a resumable chunked upload
+type Session struct {
+	ID             string           `json:"uploadId"`
+	UserID         string           `json:"-"`
+	FileName       string           `json:"fileName"`
+	MimeType       string           `json:"mimeType,omitempty"`
+	Size           int64            `json:"size"`
+	ChunkSize      int64            `json:"chunkSize"`
+	TotalChunks    int              `json:"totalChunks"`
+	ReceivedChunks map[string]Chunk `json:"-"`
+	Checksum       string           `json:"checksum,omitempty"`
+	Status         string           `json:"status"`
+	FileID         *string          `json:"fileId,omitempty"`
+	ExpiresAt      time.Time        `json:"expiresAt"`
+	CreatedAt      time.Time        `json:"createdAt"`
+}
+
+// CreateParams contains parameters for starting an upload session
+type CreateParams struct {
+	UserID   string
+	FileName string
+	MimeType string
+	Size     int64
+	Checksum string // Optional hex SHA-256 of the whole file
+}
+
+// CompletedUpload describes the file produced by a finished upload
+type CompletedUpload struct {
+	FileID      string
+	FileName    string
+	StoragePath string
+	MimeType    string
+	MediaType   *string
+	Size        int64
+	Checksum    string
+	Verified    bool // True when a client-supplied whole-file checksum matched
+	CreatedAt   time.Time
+}
+
+// Service manages resumable chunked uploads
+type Service struct {
+	db      *database.Postgres
+	storage *storage.Service
+	logger  *zap.Logger
+}
+
+// NewService creates a new upload service
+func NewService(db *database.Postgres, storage *storage.Service, logger *zap.Logger) *Service {
+	return &Service{
+		db:      db,
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// CreateSession starts a new upload session
+func (s *Service) CreateSession(ctx context.Context, params CreateParams) (*Session, error) {
+	if params.Size <= 0 {
+		return nil, fmt.Errorf("size must be positive")
+	}
+	checksum, err := normalizeChecksum(params.Checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		ID:             uuid.New().String(),
+		UserID:         params.UserID,
+		FileName:       params.FileName,
+		MimeType:       params.MimeType,
+		Size:           params.Size,
+		ChunkSize:      DefaultChunkSize,
+		TotalChunks:    totalChunks(params.Size, DefaultChunkSize),
+		ReceivedChunks: map[string]Chunk{},
+		Checksum:       checksum,
+		Status:         StatusUploading,
+		ExpiresAt:      time.Now().Add(SessionTTL),
+	}
+
+	err = s.db.Pool.QueryRow(ctx, `
+		INSERT INTO upload_sessions (id, user_id, file_name, mime_type, size_bytes, chunk_size, total_chunks, checksum, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at
+	`, session.ID, session.UserID, session.FileName, session.MimeType, session.Size, session.ChunkSize,
+		session.TotalChunks, nullIfEmpty(session.Checksum), session.Status, session.ExpiresAt,
+	).Scan(&session.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload session: %w", err)
+	}
+
+	return session, nil
+}
+
+// GetSession returns a session owned by userID
+func (s *Service) GetSession(ctx context.Context, uploadID, userID string) (*Session, error) {
+	if _, err := uuid.Parse(uploadID); err != nil {
+		return nil, ErrNotFound
+	}
+
+	var session Session
+	var sessionUserID, mimeType, checksum *string
+	var received []byte
+
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT id, user_id, file_name, mime_type, size_bytes, chunk_size, total_chunks, received_chunks,
+		       checksum, status, file_id, expires_at, created_at
+		FROM upload_sessions
+		WHERE id = $1
+	`, uploadID).Scan(
+		&session.ID,
+		&sessionUserID,
+		&session.FileName,
+		&mimeType,
+		&session.Size,
+		&session.ChunkSize,
+		&session.TotalChunks,
+		&received,
+		&checksum,
+		&session.Status,
+		&session.FileID,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+
+	if sessionUserID == nil || *sessionUserID != userID {
+		return nil, ErrNotFound
+	}
+	session.UserID = *sessionUserID
+	if mimeType != nil {
+		session.MimeType = *mimeType
+	}
+	if checksum != nil {
+		session.Checksum = *checksum
+	}
+
+	session.ReceivedChunks = map[string]Chunk{}
+	if len(received) > 0 {
+		if err := json.Unmarshal(received, &session.ReceivedChunks); err != nil {
+			return nil, fmt.Errorf("failed to decode received chunks: %w", err)
+		}
+	}
+
+	return &session, nil
+}
+
+// PutChunk stores one chunk. Re-sending an index replaces the previous chunk,
+// which is how clients retry a chunk that failed verification. checksum is optional.
+func (s *Service) PutChunk(ctx context.Context, uploadID, userID string, index int, checksum string, reader io.ReadSeeker) (*Session, error) {
+	session, err := s.GetSession(ctx, uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != StatusUploading {
+		return nil, ErrConflict
+	}
+	if index < 0 || index >= session.TotalChunks {
+		return nil, fmt.Errorf("%w: index %d out of range [0, %d)", ErrInvalidChunk, index, session.TotalChunks)
+	}
+
+	expected, err := normalizeChecksum(checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hash and measure before storing so a corrupt chunk never replaces a good one
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	if want := session.expectedChunkSize(index); size != want {
+		return nil, fmt.Errorf("%w: chunk %d is %d bytes, expected %d", ErrInvalidChunk, index, size, want)
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if expected != "" && expected != actual {
+		return nil, fmt.Errorf("%w: chunk %d", ErrChecksumMismatch, index)
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind chunk: %w", err)
+	}
+
+	info, err := s.storage.StoreAs(ctx, storage.ZoneWorking, chunkName(session.ID, index), reader)
+	if err != nil {
+		return nil, err
+	}
+
+	chunk := Chunk{Path: info.Path, Size: size, Checksum: actual}
+	chunkJSON, _ := json.Marshal(chunk)
+
+	// Merge into the JSONB map atomically so concurrent chunk uploads don't clobber each other
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE upload_sessions
+		SET received_chunks = received_chunks || jsonb_build_object($2::text, $3::jsonb),
+		    expires_at = $4
+		WHERE id = $1 AND status = $5
+	`, session.ID, strconv.Itoa(index), chunkJSON, time.Now().Add(SessionTTL), StatusUploading)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record chunk: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrConflict
+	}
+
+	session.ReceivedChunks[strconv.Itoa(index)] = chunk
+	return session, nil
+}
+
+// Complete assembles all chunks into a file in the upload zone, verifies its size and
+// checksum, and registers it in the files table. Completing an already completed
+// session returns the existing file.
+func (s *Service) Complete(ctx context.Context, uploadID, userID, checksum string) (*CompletedUpload, error) {
+	session, err := s.GetSession(ctx, uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status == StatusCompleted && session.FileID != nil {
+		return s.completedUpload(ctx, *session.FileID)
+	}
+	if session.Status != StatusUploading {
+		return nil, ErrConflict
+	}
+
+	expected, err := normalizeChecksum(checksum)
+	if err != nil {
+		return nil, err
+	}
+	if expected == "" {
+		expected = session.Checksum
+	}
+
+	if missing := session.MissingChunks(); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %d of %d chunks missing", ErrIncomplete, len(missing), session.TotalChunks)
+	}
+
+	// Claim the session so concurrent completes can't assemble twice
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE upload_sessions SET status = $2 WHERE id = $1 AND status = $3
+	`, session.ID, StatusAssembling, StatusUploading)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock upload session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrConflict
+	}
+
+	result, err := s.assemble(ctx, session, expected)
+	if err != nil {
+		// Release the session so the client can re-send chunks and retry
+		if _, resetErr := s.db.Pool.Exec(ctx, `
+			UPDATE upload_sessions SET status = $2 WHERE id = $1
+		`, session.ID, StatusUploading); resetErr != nil {
+			s.logger.Error("Failed to release upload session", zap.String("upload_id", session.ID), zap.Error(resetErr))
+		}
+		return nil, err
+	}
+
+	// Chunks are no longer needed once the file is registered
+	s.deleteChunks(ctx, session)
+
+	return result, nil
+}
+
+// assemble composes, verifies and registers the uploaded file
+func (s *Service) assemble(ctx context.Context, session *Session, expectedChecksum string) (*CompletedUpload, error) {
+	parts := make([]string, session.TotalChunks)
+	for i := range parts {
+		parts[i] = session.ReceivedChunks[strconv.Itoa(i)].Path
+	}
+
+	info, err := s.storage.Compose(ctx, storage.ZoneUpload, session.FileName, parts)
+	if err != nil {
+		return nil, err
+	}
+
+	discard := func() {
+		if err := s.storage.Delete(ctx, info.Path); err != nil {
+			s.logger.Warn("Failed to delete rejected upload", zap.String("path", info.Path), zap.Error(err))
+		}
+	}
+
+	if info.Size != session.Size {
+		discard()
+		return nil, fmt.Errorf("%w: got %d bytes, expected %d", ErrSizeMismatch, info.Size, session.Size)
+	}
+
+	actual, err := s.storage.Checksum(ctx, info.Path)
+	if err != nil {
+		discard()
+		return nil, err
+	}
+	if expectedChecksum != "" && actual != expectedChecksum {
+		discard()
+		return nil, fmt.Errorf("%w: assembled file", ErrChecksumMismatch)
+	}
+
+	mimeType := session.MimeType
+	if sub := SubtitleMimeType(session.FileName); sub != "" {
+		// Clients report subtitle files as plain text, if at all
+		mimeType = sub
+	} else if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = s.sniffMimeType(ctx, parts[0])
+	}
+	mediaType := MediaTypeFromMime(mimeType)
+
+	result := &CompletedUpload{
+		FileID:      info.ID,
+		FileName:    session.FileName,
+		StoragePath: info.Path,
+		MimeType:    mimeType,
+		MediaType:   mediaType,
+		Size:        info.Size,
+		Checksum:    actual,
+		Verified:    expectedChecksum != "",
+	}
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		discard()
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO files (id, user_id, original_name, storage_path, mime_type, size_bytes, zone, media_type, checksum, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+		RETURNING created_at
+	`, info.ID, session.UserID, session.FileName, info.Path, mimeType, info.Size, string(storage.ZoneUpload),
+		mediaType, actual, info.ExpiresAt,
+	).Scan(&result.CreatedAt)
+	if err != nil {
+		discard()
+		return nil, fmt.Errorf("failed to insert file: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE upload_sessions SET status = $2, file_id = $3, received_chunks = '{}' WHERE id = $1
+	`, session.ID, StatusCompleted, info.ID)
+	if err != nil {
+		discard()
+		return nil, fmt.Errorf("failed to complete upload session: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		discard()
+		return nil, fmt.Errorf("failed to commit upload: %w", err)
+	}
+
+	s.logger.Info("Chunked upload assembled",
+		zap.String("upload_id", session.ID),
+		zap.String("file_id", info.ID),
+		zap.Int64("size", info.Size),
+		zap.Int("chunks", session.TotalChunks),
+		zap.Bool("checksum_verified", result.Verified),
+	)
+
+	return result, nil
+}
+
+// completedUpload loads the file registered by an already completed session
+func (s *Service) completedUpload(ctx context.Context, fileID string) (*CompletedUpload, error) {
+	var result CompletedUpload
+	var mimeType, checksum *string
+
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT id, original_name, storage_path, mime_type, media_type, size_bytes, checksum, created_at
+		FROM files WHERE id = $1
+	`, fileID).Scan(
+		&result.FileID,
+		&result.FileName,
+		&result.StoragePath,
+		&mimeType,
+		&result.MediaType,
+		&result.Size,
+		&checksum,
+		&result.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uploaded file: %w", err)
+	}
+	if mimeType != nil {
+		result.MimeType = *mimeType
+	}
+	if checksum != nil {
+		result.Checksum = *checksum
+	}
+	return &result, nil
+}
+
+// CleanupExpired removes sessions that were abandoned or finished more than SessionTTL ago,
+// along with any chunks they still hold. Returns the number of sessions removed.
+func (s *Service) CleanupExpired(ctx context.Context) (int, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, received_chunks FROM upload_sessions WHERE expires_at < NOW()
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expired upload sessions: %w", err)
+	}
+
+	type expired struct {
+		id     string
+		chunks map[string]Chunk
+	}
+	var sessions []expired
+	for rows.Next() {
+		var id string
+		var received []byte
+		if err := rows.Scan(&id, &received); err != nil {
+			continue
+		}
+		e := expired{id: id, chunks: map[string]Chunk{}}
+		json.Unmarshal(received, &e.chunks)
+		sessions = append(sessions, e)
+	}
+	rows.Close()
+
+	var removed int
+	for _, e := range sessions {
+		s.deleteChunks(ctx, &Session{ID: e.id, ReceivedChunks: e.chunks})
+
+		if _, err := s.db.Pool.Exec(ctx, "DELETE FROM upload_sessions WHERE id = $1", e.id); err != nil {
+			s.logger.Warn("Failed to delete upload session", zap.String("upload_id", e.id), zap.Error(err))
+			continue
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// deleteChunks removes a session's chunk files from storage
+func (s *Service) deleteChunks(ctx context.Context, session *Session) {
+	for _, chunk := range session.ReceivedChunks {
+		if err := s.storage.Delete(ctx, chunk.Path); err != nil {
+			s.logger.Warn("Failed to delete upload chunk",
+				zap.String("upload_id", session.ID),
+				zap.String("path", chunk.Path),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// sniffMimeType detects the MIME type from the first bytes of a stored file
+func (s *Service) sniffMimeType(ctx context.Context, path string) string {
+	reader, err := s.storage.Retrieve(ctx, path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer reader.Close()
+
+	buffer := make([]byte, 512)
+	n, _ := io.ReadFull(reader, buffer)
+	return http.DetectContentType(buffer[:n])
+}
+
+// ReceivedIndexes returns the indexes of received chunks in ascending order
+func (sess *Session) ReceivedIndexes() []int {
+	indexes := make([]int, 0, len(sess.ReceivedChunks))
+	for i := 0; i < sess.TotalChunks; i++ {
+		if _, ok := sess.ReceivedChunks[strconv.Itoa(i)]; ok {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// MissingChunks returns the indexes of chunks not yet received in ascending order
+func (sess *Session) MissingChunks() []int {
+	missing := []int{}
+	for i := 0; i < sess.TotalChunks; i++ {
+		if _, ok := sess.ReceivedChunks[strconv.Itoa(i)]; !ok {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// expectedChunkSize returns the exact size chunk index must have
+func (sess *Session) expectedChunkSize(index int) int64 {
+	if index == sess.TotalChunks-1 {
+		return sess.Size - int64(index)*sess.ChunkSize
+	}
+	return sess.ChunkSize
+}
+
+// MediaTypeFromMime maps a MIME type to the files.media_type enum (nil if not media)
+func MediaTypeFromMime(mimeType string) *string {
+	var mt string
+	switch {
+	case strings.HasPrefix(mimeType, "video/"):
+		mt = "video"
+	case strings.HasPrefix(mimeType, "audio/"):
+		mt = "audio"
+	case strings.HasPrefix(mimeType, "image/"):
+		mt = "image"
+	case isSubtitleMime(mimeType):
+		mt = "subtitle"
+	default:
+		return nil
+	}
+	return &mt
+}
+
+// subtitleMimeTypes maps subtitle file extensions to their MIME types
+var subtitleMimeTypes = map[string]string{
+	".srt": "application/x-subrip",
+	".vtt": "text/vtt",
+	".ass": "text/x-ssa",
+	".ssa": "text/x-ssa",
+}
+
+// SubtitleMimeType returns the MIME type of a subtitle file name ("" if it isn't one)
+func SubtitleMimeType(fileName string) string {
+	return subtitleMimeTypes[strings.ToLower(filepath.Ext(fileName))]
+}
+
+func isSubtitleMime(mimeType string) bool {
+	for _, mt := range subtitleMimeTypes {
+		if mt == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+func totalChunks(size, chunkSize int64) int {
+	return int((size + chunkSize - 1) / chunkSize)
+}
+
+func chunkName(uploadID string, index int) string {
+	return uploadID + "_chunk_" + strconv.Itoa(index)
+}
+
+// normalizeChecksum validates an optional hex SHA-256 and lower-cases it
+func normalizeChecksum(checksum string) (string, error) {
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	checksum = strings.TrimPrefix(checksum, "sha256:")
+	if checksum == "" {
+		return "", nil
+	}
+	if len(checksum) != sha256.Size*2 {
+		return "", ErrInvalidChecksum
+	}
+	if _, err := hex.DecodeString(checksum); err != nil {
+		return "", ErrInvalidChecksum
+	}
+	return checksum, nil
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
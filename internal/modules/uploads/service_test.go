@@ -0,0 +1,80 @@
+package uploads
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTotalChunks(t *testing.T) {
+	assert.Equal(t, 1, totalChunks(1, DefaultChunkSize))
+	assert.Equal(t, 1, totalChunks(DefaultChunkSize, DefaultChunkSize))
+	assert.Equal(t, 2, totalChunks(DefaultChunkSize+1, DefaultChunkSize))
+	assert.Equal(t, 3, totalChunks(3*DefaultChunkSize, DefaultChunkSize))
+}
+
+func TestSessionChunks(t *testing.T) {
+	session := &Session{
+		Size:        2*DefaultChunkSize + 100,
+		ChunkSize:   DefaultChunkSize,
+		TotalChunks: 3,
+		ReceivedChunks: map[string]Chunk{
+			"2": {Size: 100},
+			"0": {Size: DefaultChunkSize},
+		},
+	}
+
+	t.Run("received and missing indexes are ordered", func(t *testing.T) {
+		assert.Equal(t, []int{0, 2}, session.ReceivedIndexes())
+		assert.Equal(t, []int{1}, session.MissingChunks())
+	})
+
+	t.Run("last chunk holds the remainder", func(t *testing.T) {
+		assert.Equal(t, DefaultChunkSize, session.expectedChunkSize(0))
+		assert.Equal(t, DefaultChunkSize, session.expectedChunkSize(1))
+		assert.Equal(t, int64(100), session.expectedChunkSize(2))
+	})
+
+	t.Run("no chunks missing when complete", func(t *testing.T) {
+		complete := &Session{TotalChunks: 1, ReceivedChunks: map[string]Chunk{"0": {}}}
+		assert.Empty(t, complete.MissingChunks())
+	})
+}
+
+func TestNormalizeChecksum(t *testing.T) {
+	valid := strings.Repeat("ab", 32)
+
+	got, err := normalizeChecksum("")
+	assert.NoError(t, err)
+	assert.Equal(t, "", got)
+
+	got, err = normalizeChecksum(strings.ToUpper(valid))
+	assert.NoError(t, err)
+	assert.Equal(t, valid, got)
+
+	got, err = normalizeChecksum("sha256:" + valid)
+	assert.NoError(t, err)
+	assert.Equal(t, valid, got)
+
+	_, err = normalizeChecksum("abc")
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+
+	_, err = normalizeChecksum(strings.Repeat("zz", 32))
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+}
+
+func TestMediaTypeFromMime(t *testing.T) {
+	assert.Equal(t, "video", *MediaTypeFromMime("video/mp4"))
+	assert.Equal(t, "audio", *MediaTypeFromMime("audio/mpeg"))
+	assert.Equal(t, "image", *MediaTypeFromMime("image/png"))
+	assert.Nil(t, MediaTypeFromMime("application/pdf"))
+	assert.Equal(t, "subtitle", *MediaTypeFromMime("text/vtt"))
+	assert.Nil(t, MediaTypeFromMime("text/plain"))
+}
+
+func TestSubtitleMimeType(t *testing.T) {
+	assert.Equal(t, "application/x-subrip", SubtitleMimeType("episode.SRT"))
+	assert.Equal(t, "text/x-ssa", SubtitleMimeType("styled.ass"))
+	assert.Empty(t, SubtitleMimeType("video.mp4"))
+}
@@ -0,0 +1,109 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/nextconvert/backend/internal/shared/database"
+)
+
+// AnonIDPrefix is prepended to anonymous (cookie-tracked) user IDs
+const AnonIDPrefix = "anon:"
+
+// ErrNotFound is returned both for missing resources and for resources owned by
+// someone else, so that callers can't probe for other users' IDs
+var ErrNotFound = errors.New("resource not found")
+
+// Resource identifies a kind of user-owned record
+type Resource string
+
+const (
+	ResourceFile   Resource = "file"
+	ResourceJob    Resource = "job"
+	ResourcePreset Resource = "preset"
+)
+
+// resourceTables maps each resource to the table holding its user_id
+var resourceTables = map[Resource]string{
+	ResourceFile:   "files",
+	ResourceJob:    "jobs",
+	ResourcePreset: "presets",
+}
+
+// OwnerStore looks up who owns a resource
+type OwnerStore interface {
+	// Owner returns the owning user ID, nil for rows without an owner,
+	// or ErrNotFound if the resource doesn't exist
+	Owner(ctx context.Context, resource Resource, id string) (*string, error)
+}
+
+// Authorizer checks resource ownership for the current user
+type Authorizer struct {
+	owners OwnerStore
+}
+
+// NewAuthorizer creates an authorizer backed by the database
+func NewAuthorizer(db *database.Postgres) *Authorizer {
+	return &Authorizer{owners: &dbOwnerStore{db: db}}
+}
+
+// Authorize returns nil if userID may access the resource, ErrNotFound if it
+// doesn't exist or belongs to someone else, or a lookup error
+func (a *Authorizer) Authorize(ctx context.Context, resource Resource, id, userID string) error {
+	owner, err := a.owners.Owner(ctx, resource, id)
+	if err != nil {
+		return err
+	}
+	if !CanAccess(resource, owner, userID) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CanAccess applies the ownership rule to an already-loaded owner.
+// Files and jobs created before ownership was recorded (NULL user_id) are
+// only visible to anonymous users, matching ListFiles/ListJobs. Presets
+// without an owner are system presets and can't be modified by anyone.
+func CanAccess(resource Resource, owner *string, userID string) bool {
+	if userID == "" {
+		return false
+	}
+	if owner == nil {
+		return resource != ResourcePreset && IsAnonymous(userID)
+	}
+	return *owner == userID
+}
+
+// IsAnonymous reports whether a user ID belongs to an anonymous user
+func IsAnonymous(userID string) bool {
+	return strings.HasPrefix(userID, AnonIDPrefix)
+}
+
+// dbOwnerStore reads owners from the resource tables
+type dbOwnerStore struct {
+	db *database.Postgres
+}
+
+func (s *dbOwnerStore) Owner(ctx context.Context, resource Resource, id string) (*string, error) {
+	table, ok := resourceTables[resource]
+	if !ok {
+		return nil, errors.New("unknown resource: " + string(resource))
+	}
+	// IDs are UUIDs; anything else can't exist (and would be a query error)
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	var owner *string
+	err := s.db.Pool.QueryRow(ctx, "SELECT user_id FROM "+table+" WHERE id = $1", id).Scan(&owner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
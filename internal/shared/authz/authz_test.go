@@ -0,0 +1,94 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryOwners is an in-memory OwnerStore keyed by resource and ID
+type memoryOwners map[Resource]map[string]*string
+
+func (m memoryOwners) Owner(_ context.Context, resource Resource, id string) (*string, error) {
+	owner, ok := m[resource][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return owner, nil
+}
+
+type failingOwners struct{}
+
+func (failingOwners) Owner(context.Context, Resource, string) (*string, error) {
+	return nil, errors.New("connection refused")
+}
+
+func owner(id string) *string { return &id }
+
+func TestAuthorize(t *testing.T) {
+	a := &Authorizer{owners: memoryOwners{
+		ResourceFile: {
+			"alice-file":  owner("user_alice"),
+			"anon-file":   owner("anon:device-1"),
+			"legacy-file": nil,
+		},
+		ResourceJob: {
+			"alice-job":  owner("user_alice"),
+			"legacy-job": nil,
+		},
+		ResourcePreset: {
+			"alice-preset":  owner("user_alice"),
+			"system-preset": nil,
+		},
+	}}
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		resource Resource
+		id       string
+		userID   string
+		wantErr  error
+	}{
+		{"owner can access own file", ResourceFile, "alice-file", "user_alice", nil},
+		{"owner can access own job", ResourceJob, "alice-job", "user_alice", nil},
+		{"owner can access own preset", ResourcePreset, "alice-preset", "user_alice", nil},
+		{"other user gets not found", ResourceFile, "alice-file", "user_bob", ErrNotFound},
+		{"other user gets not found for jobs", ResourceJob, "alice-job", "user_bob", ErrNotFound},
+		{"anonymous user can access own file", ResourceFile, "anon-file", "anon:device-1", nil},
+		{"other anonymous device gets not found", ResourceFile, "anon-file", "anon:device-2", ErrNotFound},
+		{"anonymous user can't access user's file", ResourceFile, "alice-file", "anon:device-1", ErrNotFound},
+		{"user can't access anonymous file", ResourceFile, "anon-file", "user_alice", ErrNotFound},
+		{"anonymous user can access legacy file", ResourceFile, "legacy-file", "anon:device-1", nil},
+		{"anonymous user can access legacy job", ResourceJob, "legacy-job", "anon:device-1", nil},
+		{"authenticated user can't access legacy file", ResourceFile, "legacy-file", "user_alice", ErrNotFound},
+		{"nobody owns system presets", ResourcePreset, "system-preset", "anon:device-1", ErrNotFound},
+		{"missing user gets not found", ResourceFile, "alice-file", "", ErrNotFound},
+		{"missing resource", ResourceFile, "no-such-file", "user_alice", ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.Authorize(ctx, tt.resource, tt.id, tt.userID)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("lookup errors are not reported as not found", func(t *testing.T) {
+		err := (&Authorizer{owners: failingOwners{}}).Authorize(ctx, ResourceFile, "alice-file", "user_alice")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestIsAnonymous(t *testing.T) {
+	assert.True(t, IsAnonymous("anon:abc"))
+	assert.False(t, IsAnonymous("user_abc"))
+	assert.False(t, IsAnonymous(""))
+}
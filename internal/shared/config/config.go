@@ -26,9 +26,10 @@ type Config struct {
 	// FFmpeg
 	FFmpegPath          string
 	FFprobePath         string
-	FFmpegMaxThreads    int  // Max CPU threads for FFmpeg (0 = unlimited)
-	FFmpegHardwareAccel bool // Use hardware acceleration (VideoToolbox on macOS)
-	FFmpegFastPresets   bool // Use faster encoding presets (less CPU, slightly larger files)
+	FFmpegMaxThreads    int    // Max CPU threads for FFmpeg (0 = unlimited)
+	FFmpegHardwareAccel bool   // Use hardware acceleration (VideoToolbox on macOS)
+	FFmpegFastPresets   bool   // Use faster encoding presets (less CPU, slightly larger files)
+	FFmpegAV1Encoder    string // AV1 encoder: libsvtav1 or libaom-av1
 
 	// Worker
 	WorkerConcurrency int
@@ -81,6 +82,7 @@ func Load() (*Config, error) {
 		FFmpegMaxThreads:    getEnvInt("FFMPEG_MAX_THREADS", 0),         // Default: 0 = auto (use available cores)
 		FFmpegHardwareAccel: getEnvBool("FFMPEG_HARDWARE_ACCEL", false), // Default: false (cloud servers typically don't have GPU)
 		FFmpegFastPresets:   getEnvBool("FFMPEG_FAST_PRESETS", true),    // Default: use fast presets for quicker processing
+		FFmpegAV1Encoder:    getEnv("FFMPEG_AV1_ENCODER", "libsvtav1"),  // Default: SVT-AV1, far faster than libaom
 		WorkerConcurrency:   getEnvInt("WORKER_CONCURRENCY", 2),
 		ClerkSecretKey:      getEnv("CLERK_SECRET_KEY", ""),
 		AllowedOrigins:      getEnvSlice("ALLOWED_ORIGINS", "http://localhost:5173"),
@@ -4,12 +4,25 @@ import (
 	"context"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Pool is the part of *pgxpool.Pool the modules use, so tests can substitute a mock
+type Pool interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+	Close()
+}
+
 // Postgres wraps a PostgreSQL connection pool
 type Postgres struct {
-	Pool *pgxpool.Pool
+	Pool Pool
 }
 
 // NewPostgres creates a new PostgreSQL connection pool
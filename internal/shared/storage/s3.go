@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -121,6 +123,70 @@ func (b *S3Backend) Store(ctx context.Context, zone Zone, filename string, reade
 	return key, nil
 }
 
+// Compose concatenates objects server-side using a multipart upload with
+// UploadPartCopy. Every part except the last must be at least 5 MiB.
+func (b *S3Backend) Compose(ctx context.Context, zone Zone, filename string, parts []string) (string, error) {
+	key := b.objectKey(zone, filename)
+
+	upload, err := b.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("s3 create multipart upload failed: %w", err)
+	}
+
+	abort := func() {
+		b.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(b.bucket),
+			Key:      aws.String(key),
+			UploadId: upload.UploadId,
+		})
+	}
+
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for i, part := range parts {
+		partNumber := int32(i + 1)
+		resp, err := b.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:     aws.String(b.bucket),
+			Key:        aws.String(key),
+			UploadId:   upload.UploadId,
+			PartNumber: aws.Int32(partNumber),
+			CopySource: aws.String(url.PathEscape(b.bucket) + "/" + escapeKey(part)),
+		})
+		if err != nil {
+			abort()
+			return "", fmt.Errorf("s3 upload part copy failed (part %d): %w", partNumber, err)
+		}
+		completed = append(completed, types.CompletedPart{
+			ETag:       resp.CopyPartResult.ETag,
+			PartNumber: aws.Int32(partNumber),
+		})
+	}
+
+	_, err = b.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(b.bucket),
+		Key:             aws.String(key),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		abort()
+		return "", fmt.Errorf("s3 complete multipart upload failed: %w", err)
+	}
+
+	return key, nil
+}
+
+// escapeKey URL-encodes each segment of an object key for use in CopySource
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
 func (b *S3Backend) Retrieve(ctx context.Context, storagePath string) (io.ReadCloser, error) {
 	resp, err := b.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(b.bucket),
@@ -193,6 +259,23 @@ func (b *S3Backend) GetPresignedUploadURL(ctx context.Context, zone Zone, filena
 	return resp.URL, key, nil
 }
 
+// GetPresignedDownloadURL generates a presigned GET URL for an object
+func (b *S3Backend) GetPresignedDownloadURL(ctx context.Context, storagePath string, expiry time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(b.client)
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(storagePath),
+	}
+
+	resp, err := presignClient.PresignGetObject(ctx, input, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+
+	return resp.URL, nil
+}
+
 func (b *S3Backend) List(ctx context.Context, prefix string) ([]string, error) {
 	var keys []string
 
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -13,6 +15,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// probeURLExpiry bounds how long a presigned URL handed to ffprobe stays valid
+const probeURLExpiry = 5 * time.Minute
+
 // Zone represents a storage zone
 type Zone string
 
@@ -51,6 +56,12 @@ type Backend interface {
 	List(ctx context.Context, prefix string) ([]string, error)
 }
 
+// Composer is implemented by backends that can concatenate stored objects
+// into a new object without streaming them through the application.
+type Composer interface {
+	Compose(ctx context.Context, zone Zone, filename string, parts []string) (string, error)
+}
+
 // NewService creates a new storage service
 func NewService(cfg config.StorageConfig) (*Service, error) {
 	var backend Backend
@@ -106,6 +117,105 @@ func (s *Service) Store(ctx context.Context, zone Zone, originalName string, rea
 	}, nil
 }
 
+// StoreAs saves a file to the specified zone under an exact filename, replacing
+// any existing file with that name. Used for upload chunks, which must be addressable
+// by upload ID and index.
+func (s *Service) StoreAs(ctx context.Context, zone Zone, filename string, reader io.Reader) (*FileInfo, error) {
+	path, err := s.backend.Store(ctx, zone, filename, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store file: %w", err)
+	}
+
+	size, err := s.backend.GetSize(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file size: %w", err)
+	}
+
+	return &FileInfo{
+		ID:        filename,
+		Name:      filename,
+		Path:      path,
+		Zone:      zone,
+		Size:      size,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+	}, nil
+}
+
+// Compose concatenates the given stored files, in order, into a new file in zone.
+// The source files are left in place.
+func (s *Service) Compose(ctx context.Context, zone Zone, originalName string, parts []string) (*FileInfo, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no parts to compose")
+	}
+
+	fileID := uuid.New().String()
+	filename := fileID + filepath.Ext(originalName)
+
+	var path string
+	var err error
+	if composer, ok := s.backend.(Composer); ok {
+		path, err = composer.Compose(ctx, zone, filename, parts)
+	} else {
+		path, err = s.backend.Store(ctx, zone, filename, s.concatReader(ctx, parts))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compose file: %w", err)
+	}
+
+	size, err := s.backend.GetSize(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file size: %w", err)
+	}
+
+	return &FileInfo{
+		ID:        fileID,
+		Name:      originalName,
+		Path:      path,
+		Zone:      zone,
+		Size:      size,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+	}, nil
+}
+
+// concatReader streams the given files back to back through a pipe
+func (s *Service) concatReader(ctx context.Context, parts []string) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		for _, part := range parts {
+			reader, err := s.backend.Retrieve(ctx, part)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			_, err = io.Copy(pw, reader)
+			reader.Close()
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+	return pr
+}
+
+// Checksum returns the hex-encoded SHA-256 of a stored file
+func (s *Service) Checksum(ctx context.Context, path string) (string, error) {
+	reader, err := s.backend.Retrieve(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 // Retrieve gets a file from storage
 func (s *Service) Retrieve(ctx context.Context, path string) (io.ReadCloser, error) {
 	return s.backend.Retrieve(ctx, path)
@@ -215,6 +325,18 @@ func (s *Service) PrepareInputForProcessing(ctx context.Context, storagePath str
 	return tmpFile.Name(), func() { os.Remove(tmpFile.Name()) }, nil
 }
 
+// PrepareInputForProbing returns an input ffprobe can read storagePath from.
+// Remote files are read in place through a short-lived presigned URL, since
+// ffprobe only needs the container header and not the whole object.
+// For local backend, returns path as-is.
+func (s *Service) PrepareInputForProbing(ctx context.Context, storagePath string) (string, error) {
+	s3backend, ok := s.backend.(*S3Backend)
+	if !ok {
+		return storagePath, nil
+	}
+	return s3backend.GetPresignedDownloadURL(ctx, storagePath, probeURLExpiry)
+}
+
 // FinalizeOutputFromLocal uploads local file to remote at storagePath.
 // For local backend, no-op (file already at storagePath).
 func (s *Service) FinalizeOutputFromLocal(ctx context.Context, storagePath, localPath string) error {
@@ -274,6 +396,41 @@ func (b *LocalBackend) Store(ctx context.Context, zone Zone, filename string, re
 	return path, nil
 }
 
+// Compose concatenates local files into a new file
+func (b *LocalBackend) Compose(ctx context.Context, zone Zone, filename string, parts []string) (string, error) {
+	path := filepath.Join(b.basePath, string(zone), filename)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	for _, part := range parts {
+		if err := ctx.Err(); err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		if err := appendFile(file, part); err != nil {
+			os.Remove(path)
+			return "", err
+		}
+	}
+
+	return path, nil
+}
+
+func appendFile(dst *os.File, srcPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
 func (b *LocalBackend) Retrieve(ctx context.Context, path string) (io.ReadCloser, error) {
 	return os.Open(path)
 }
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/nextconvert/backend/internal/shared/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareInputForProbing(t *testing.T) {
+	t.Run("local", func(t *testing.T) {
+		s, err := NewService(config.StorageConfig{Backend: "local", BasePath: t.TempDir()})
+		require.NoError(t, err)
+
+		path := s.GetPath(ZoneUpload, "clip.mp4")
+		input, err := s.PrepareInputForProbing(context.Background(), path)
+		require.NoError(t, err)
+		assert.Equal(t, path, input)
+	})
+
+	t.Run("s3", func(t *testing.T) {
+		s, err := NewService(config.StorageConfig{
+			Backend:     "s3",
+			S3Endpoint:  "http://minio.internal:9000",
+			S3Bucket:    "media",
+			S3AccessKey: "access",
+			S3SecretKey: "secret",
+		})
+		require.NoError(t, err)
+
+		input, err := s.PrepareInputForProbing(context.Background(), "upload/clip.mp4")
+		require.NoError(t, err)
+
+		// The object is read in place rather than downloaded first
+		u, err := url.Parse(input)
+		require.NoError(t, err)
+		assert.Equal(t, "minio.internal:9000", u.Host)
+		assert.Equal(t, "/media/upload/clip.mp4", u.Path)
+		assert.Equal(t, "300", u.Query().Get("X-Amz-Expires"))
+		assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+	})
+}
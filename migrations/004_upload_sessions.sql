@@ -0,0 +1,28 @@
+-- Migration: Add resumable chunked upload sessions
+-- Tracks chunk uploads so clients can resume after a disconnect
+
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id TEXT,
+    file_name TEXT NOT NULL,
+    mime_type VARCHAR(255),
+    size_bytes BIGINT NOT NULL,
+    chunk_size BIGINT NOT NULL,
+    total_chunks INT NOT NULL,
+    received_chunks JSONB NOT NULL DEFAULT '{}', -- chunk index -> {path, size, checksum}
+    checksum VARCHAR(64), -- Optional SHA-256 of the whole file, verified on completion
+    status VARCHAR(20) NOT NULL DEFAULT 'uploading', -- uploading/assembling/completed
+    file_id UUID REFERENCES files(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_user_id ON upload_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
+
+DROP TRIGGER IF EXISTS update_upload_sessions_updated_at ON upload_sessions;
+CREATE TRIGGER update_upload_sessions_updated_at
+    BEFORE UPDATE ON upload_sessions
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,12 @@
+-- Migration: Add artifacts for multi-file outputs
+-- A packaged output (e.g. HLS) is one logical file made of many stored files
+
+CREATE TABLE IF NOT EXISTS file_artifacts (
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    name TEXT NOT NULL, -- Path relative to the package root, as referenced from manifests
+    storage_path TEXT NOT NULL,
+    mime_type VARCHAR(255),
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (file_id, name)
+);
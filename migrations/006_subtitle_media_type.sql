@@ -0,0 +1,4 @@
+-- Migration: Add subtitle media type
+-- Uploaded SRT, WebVTT and ASS files are referenced by addSubtitles
+
+ALTER TYPE media_type ADD VALUE IF NOT EXISTS 'subtitle';
@@ -0,0 +1,4 @@
+-- Migration: Add multi-output jobs
+-- Operations such as split produce several output files; output_file_id keeps the first
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS output_file_ids JSONB;
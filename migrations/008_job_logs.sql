@@ -0,0 +1,13 @@
+-- Migration: Add job logs
+-- Pipeline stages, FFmpeg command lines and the tail of FFmpeg's stderr, written by workers
+
+CREATE TABLE IF NOT EXISTS job_logs (
+    id BIGSERIAL PRIMARY KEY, -- Also the pagination cursor
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    stage VARCHAR(20) NOT NULL, -- job/download/encode/upload
+    level VARCHAR(20) NOT NULL, -- info/error/command/stderr
+    message TEXT NOT NULL, -- Secrets and paths are redacted before storing
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_logs_job_id ON job_logs(job_id, id);
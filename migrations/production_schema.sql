@@ -50,10 +50,12 @@ END $$;
 
 -- Media type
 DO $$ BEGIN
-    CREATE TYPE media_type AS ENUM ('video', 'audio', 'image');
+    CREATE TYPE media_type AS ENUM ('video', 'audio', 'image', 'subtitle');
 EXCEPTION
     WHEN duplicate_object THEN null;
 END $$;
+-- Databases created before subtitle support
+ALTER TYPE media_type ADD VALUE IF NOT EXISTS 'subtitle';
 
 -- ============================================================================
 -- TABLES
@@ -135,6 +137,7 @@ CREATE TABLE IF NOT EXISTS jobs (
     priority INT DEFAULT 5,
     input_file_id UUID REFERENCES files(id),
     output_file_id UUID REFERENCES files(id),
+    output_file_ids JSONB, -- Every output file of a multi-output job (e.g. split), in order
     output_format VARCHAR(20),
     output_file_name TEXT,
     operations JSONB DEFAULT '[]',
@@ -163,6 +166,54 @@ CREATE TABLE IF NOT EXISTS presets (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- ----------------------------------------------------------------------------
+-- Upload Sessions Table (Resumable chunked uploads)
+-- Tracks received chunks until they are assembled into a file
+-- ----------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id TEXT,
+    file_name TEXT NOT NULL,
+    mime_type VARCHAR(255),
+    size_bytes BIGINT NOT NULL,
+    chunk_size BIGINT NOT NULL,
+    total_chunks INT NOT NULL,
+    received_chunks JSONB NOT NULL DEFAULT '{}', -- chunk index -> {path, size, checksum}
+    checksum VARCHAR(64), -- Optional SHA-256 of the whole file, verified on completion
+    status VARCHAR(20) NOT NULL DEFAULT 'uploading', -- uploading/assembling/completed
+    file_id UUID REFERENCES files(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- ----------------------------------------------------------------------------
+-- File Artifacts Table (Multi-file outputs)
+-- Every stored file of a packaged output such as an HLS package
+-- ----------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS file_artifacts (
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    name TEXT NOT NULL, -- Path relative to the package root, as referenced from manifests
+    storage_path TEXT NOT NULL,
+    mime_type VARCHAR(255),
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (file_id, name)
+);
+
+-- ----------------------------------------------------------------------------
+-- Job Logs Table (Per-job logs)
+-- Pipeline stages, FFmpeg command lines and the tail of FFmpeg's stderr
+-- ----------------------------------------------------------------------------
+CREATE TABLE IF NOT EXISTS job_logs (
+    id BIGSERIAL PRIMARY KEY, -- Also the pagination cursor
+    job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    stage VARCHAR(20) NOT NULL, -- job/download/encode/upload
+    level VARCHAR(20) NOT NULL, -- info/error/command/stderr
+    message TEXT NOT NULL, -- Secrets and paths are redacted before storing
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
 -- ============================================================================
 -- INDEXES
 -- ============================================================================
@@ -194,6 +245,13 @@ CREATE INDEX IF NOT EXISTS idx_presets_user_id ON presets(user_id);
 CREATE INDEX IF NOT EXISTS idx_presets_media_type ON presets(media_type);
 CREATE INDEX IF NOT EXISTS idx_presets_is_system ON presets(is_system);
 
+-- Upload sessions indexes
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_user_id ON upload_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
+
+-- Job logs indexes
+CREATE INDEX IF NOT EXISTS idx_job_logs_job_id ON job_logs(job_id, id);
+
 -- ============================================================================
 -- FUNCTIONS & TRIGGERS
 -- ============================================================================
@@ -228,6 +286,13 @@ CREATE TRIGGER update_subscriptions_updated_at
     FOR EACH ROW
     EXECUTE FUNCTION update_updated_at_column();
 
+-- Trigger for upload_sessions table
+DROP TRIGGER IF EXISTS update_upload_sessions_updated_at ON upload_sessions;
+CREATE TRIGGER update_upload_sessions_updated_at
+    BEFORE UPDATE ON upload_sessions
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
 -- ============================================================================
 -- DEFAULT DATA
 -- ============================================================================